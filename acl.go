@@ -0,0 +1,89 @@
+package syslog
+
+import (
+	"net"
+	"net/netip"
+)
+
+// sourceACL decides which source addresses may send messages. A nil sourceACL allows
+// everything.
+type sourceACL struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// SetSourceACL restricts the networks from which messages are accepted on all listeners.
+// A packet or frame from an address within any of the deny networks is discarded, as is
+// one from an address outside all the allow networks unless allow is empty. This is
+// checked before anything is parsed, so unwanted traffic is discarded cheaply; discarded
+// packets are counted (see [Server.Denied]). Sources without an IP address, such as Unix
+// sockets, are always accepted. This must be set before calling [Server.Listen].
+func (s *Server) SetSourceACL(allow, deny []net.IPNet) {
+	if len(allow) == 0 && len(deny) == 0 {
+		s.acl = nil
+		return
+	}
+	s.acl = &sourceACL{allow: toPrefixes(allow), deny: toPrefixes(deny)}
+}
+
+// Denied returns the number of packets discarded because of their source address. See
+// [Server.SetSourceACL].
+func (s *Server) Denied() uint64 {
+	return s.denied.Load()
+}
+
+// toPrefixes converts networks to prefixes. An IPv4 network may hold its address in 16 bytes,
+// as from [net.ParseIP], with a 4-byte mask.
+func toPrefixes(nets []net.IPNet) []netip.Prefix {
+	ps := make([]netip.Prefix, 0, len(nets))
+	for _, n := range nets {
+		ip := n.IP
+		if len(n.Mask) == net.IPv4len {
+			ip = ip.To4()
+		}
+		a, ok := netip.AddrFromSlice(ip)
+		if !ok {
+			continue
+		}
+		ones, _ := n.Mask.Size()
+		if a.Is4In6() && ones >= 96 {
+			a = a.Unmap()
+			ones -= 96
+		}
+		ps = append(ps, netip.PrefixFrom(a, ones).Masked())
+	}
+	return ps
+}
+
+// allows reports whether messages from addr are acceptable.
+func (acl *sourceACL) allows(addr net.Addr) bool {
+	if acl == nil {
+		return true
+	}
+
+	var ip netip.Addr
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		ip = a.AddrPort().Addr()
+	case *net.TCPAddr:
+		ip = a.AddrPort().Addr()
+	default:
+		return true
+	}
+	ip = ip.Unmap()
+
+	for _, p := range acl.deny {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	if len(acl.allow) == 0 {
+		return true
+	}
+	for _, p := range acl.allow {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
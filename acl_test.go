@@ -0,0 +1,57 @@
+package syslog
+
+import (
+	"net"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func cidrs(t *testing.T, ss ...string) []net.IPNet {
+	var nets []net.IPNet
+	for _, s := range ss {
+		_, n, err := net.ParseCIDR(s)
+		expect.Error(err).ToBeNil(t)
+		nets = append(nets, *n)
+	}
+	return nets
+}
+
+func TestServer_SetSourceACL(t *testing.T) {
+	s := NewServer()
+	defer s.Shutdown()
+	s.SetSourceACL(cidrs(t, "10.0.0.0/8", "2001:db8::/32"), cidrs(t, "10.1.0.0/16"))
+
+	pkt := []byte("<13>1 - host app - - - hello")
+	receive := func(addr net.Addr) bool {
+		return s.receive(pkt, addr, AcceptEverything) != nil
+	}
+
+	expect.Bool(receive(&net.UDPAddr{IP: net.ParseIP("10.2.3.4")})).ToBeTrue(t)
+	expect.Bool(receive(&net.TCPAddr{IP: net.ParseIP("2001:db8::1")})).ToBeTrue(t)
+	expect.Bool(receive(&net.UnixAddr{Name: "/dev/log"})).ToBeTrue(t)
+	expect.Bool(receive(&net.UDPAddr{IP: net.ParseIP("10.1.3.4")})).ToBeFalse(t)
+	expect.Bool(receive(&net.UDPAddr{IP: net.ParseIP("::ffff:10.1.3.4")})).ToBeFalse(t)
+	expect.Bool(receive(&net.UDPAddr{IP: net.ParseIP("192.168.1.1")})).ToBeFalse(t)
+	expect.Number(s.Denied()).ToBe(t, 3)
+
+	s.SetSourceACL(nil, nil)
+	expect.Bool(receive(&net.UDPAddr{IP: net.ParseIP("192.168.1.1")})).ToBeTrue(t)
+}
+
+func TestServer_SetSourceACL_parseIP(t *testing.T) {
+	s := NewServer()
+	defer s.Shutdown()
+	allow := []net.IPNet{{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(8, 32)}}
+	deny := []net.IPNet{{IP: net.ParseIP("10.1.0.0").To4(), Mask: net.CIDRMask(16, 32)}}
+	s.SetSourceACL(allow, deny)
+
+	pkt := []byte("<13>1 - host app - - - hello")
+	receive := func(addr net.Addr) bool {
+		return s.receive(pkt, addr, AcceptEverything) != nil
+	}
+
+	expect.Bool(receive(&net.UDPAddr{IP: net.ParseIP("10.2.3.4")})).ToBeTrue(t)
+	expect.Bool(receive(&net.UDPAddr{IP: net.ParseIP("10.1.2.3")})).ToBeFalse(t)
+	expect.Bool(receive(&net.UDPAddr{IP: net.ParseIP("192.168.1.1")})).ToBeFalse(t)
+}
@@ -0,0 +1,207 @@
+package syslog
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// acmeTLSALPNProtocol is negotiated by ACME servers that validate a domain using the
+// TLS-ALPN-01 challenge (RFC 8737).
+const acmeTLSALPNProtocol = "acme-tls/1"
+
+// CertificateManager obtains certificates automatically and renews them before they expire,
+// typically from Let's Encrypt or another ACME certificate authority. The Go standard library
+// does not implement ACME, so this is provided by a third-party package, for example:
+//
+//	m := &autocert.Manager{ // golang.org/x/crypto/acme/autocert
+//		Prompt:     autocert.AcceptTOS,
+//		HostPolicy: autocert.HostWhitelist("logs.example.com"),
+//		Cache:      autocert.DirCache("/var/lib/syslog/acme"),
+//	}
+//
+// The certificate authority must be able to verify that the collector owns its hostname:
+// either the listener is on port 443, which allows the TLS-ALPN-01 challenge, or the
+// manager's HTTP handler is served on port 80 for the HTTP-01 challenge, e.g.
+//
+//	go http.ListenAndServe(":80", m.HTTPHandler(nil))
+type CertificateManager interface {
+	GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error)
+}
+
+// ListenACME starts a goroutine that receives syslog messages over TLS, like
+// [Server.ListenTLS], using certificates obtained and renewed automatically by m. This
+// suits deployments where devices validate the collector by its public hostname. Only
+// the messages matching accept are processed.
+func (s *Server) ListenACME(addr string, m CertificateManager, accept Filter) error {
+	if m == nil {
+		return errors.New("ListenACME requires a certificate manager")
+	}
+
+	return s.ListenTLS(addr, &tls.Config{
+		GetCertificate: m.GetCertificate,
+		NextProtos:     []string{acmeTLSALPNProtocol},
+		MinVersion:     tls.VersionTLS12,
+	}, accept)
+}
+
+// ObtainFunc obtains a new certificate for the domains, returning the PEM-encoded
+// certificate chain and private key. For internal names that a public certificate
+// authority cannot reach, it would typically use the ACME DNS-01 challenge, for example
+// with the github.com/go-acme/lego client and a provider for the site's DNS servers.
+type ObtainFunc func(ctx context.Context, domains []string) (certPEM, keyPEM []byte, err error)
+
+// defaultRenewBefore is used when [RenewingCertificate.RenewBefore] is not specified.
+const defaultRenewBefore = 30 * 24 * time.Hour
+
+// renewRetryInterval is how long to wait after a renewal fails before trying again.
+const renewRetryInterval = time.Hour
+
+// RenewingCertificate is a [CertificateManager] that keeps a certificate in a cache
+// directory and renews it using an [ObtainFunc] before it expires. This allows small
+// sites to run encrypted syslog without a PKI:
+//
+//	m := &RenewingCertificate{
+//		Domains: []string{"syslog.internal.example.com"},
+//		Obtain:  obtainUsingDNS01, // e.g. using lego
+//		Cache:   "/var/lib/syslog/acme",
+//	}
+//	err := s.ListenACME(":6514", m, AcceptEverything)
+//
+// The first connection waits whilst a certificate is obtained, unless one is cached; it
+// gives up if the client goes away. Renewal happens in the background and, if it fails,
+// is retried an hour later whilst the old certificate continues to be used. A
+// RenewingCertificate is safe for concurrent use.
+type RenewingCertificate struct {
+	Domains     []string      // the first is the name of the files in the cache
+	Obtain      ObtainFunc    // required
+	Cache       string        // directory in which the certificate and key are kept
+	RenewBefore time.Duration // how long before expiry to renew; the default is 30 days
+
+	first       sync.Mutex // held whilst the first certificate is obtained
+	mu          sync.Mutex // guards the fields below
+	cert        *tls.Certificate
+	renewing    bool
+	nextAttempt time.Time // when renewal may be tried again after a failure
+	clock       func() time.Time
+}
+
+// GetCertificate gets the current certificate, obtaining or renewing it as needed.
+func (rc *RenewingCertificate) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := rc.current(); cert != nil {
+		return cert, nil
+	}
+
+	// only one connection obtains the first certificate; the others wait for it
+	rc.first.Lock()
+	defer rc.first.Unlock()
+	if cert := rc.current(); cert != nil {
+		return cert, nil
+	}
+
+	ctx := context.Background()
+	if hello != nil && hello.Context() != nil {
+		ctx = hello.Context()
+	}
+
+	cert, err := rc.load()
+	if err != nil {
+		if cert, err = rc.obtain(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	rc.mu.Lock()
+	rc.cert = cert
+	rc.mu.Unlock()
+	return rc.current(), nil
+}
+
+// current returns the certificate, if there is one, starting its renewal when it is due.
+func (rc *RenewingCertificate) current() *tls.Certificate {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.cert != nil && !rc.renewing && rc.due(rc.cert) && !rc.now().Before(rc.nextAttempt) {
+		rc.renewing = true
+		go rc.renew()
+	}
+	return rc.cert
+}
+
+func (rc *RenewingCertificate) renew() {
+	cert, err := rc.obtain(context.Background())
+	checkErr(err, "renew certificate for", rc.Domains[0])
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if err == nil {
+		rc.cert = cert
+	} else {
+		rc.nextAttempt = rc.now().Add(renewRetryInterval)
+	}
+	rc.renewing = false
+}
+
+// due is true when the certificate will expire within the renewal period.
+func (rc *RenewingCertificate) due(cert *tls.Certificate) bool {
+	return rc.now().Add(cmp.Or(rc.RenewBefore, defaultRenewBefore)).After(cert.Leaf.NotAfter)
+}
+
+func (rc *RenewingCertificate) now() time.Time {
+	if rc.clock != nil {
+		return rc.clock()
+	}
+	return time.Now()
+}
+
+// obtain gets a new certificate and saves it in the cache.
+func (rc *RenewingCertificate) obtain(ctx context.Context) (*tls.Certificate, error) {
+	if len(rc.Domains) == 0 || rc.Obtain == nil {
+		return nil, errors.New("RenewingCertificate requires domains and an obtain function")
+	}
+
+	certPEM, keyPEM, err := rc.Obtain(ctx, rc.Domains)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	if rc.Cache != "" {
+		certFile, keyFile := rc.files()
+		err = errors.Join(
+			os.MkdirAll(rc.Cache, 0700),
+			os.WriteFile(keyFile, keyPEM, 0600),
+			os.WriteFile(certFile, certPEM, 0644),
+		)
+		checkErr(err, "cache certificate in", rc.Cache)
+	}
+	return &cert, nil
+}
+
+// load gets the certificate from the cache.
+func (rc *RenewingCertificate) load() (*tls.Certificate, error) {
+	if rc.Cache == "" || len(rc.Domains) == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	cert, err := tls.LoadX509KeyPair(rc.files())
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func (rc *RenewingCertificate) files() (certFile, keyFile string) {
+	base := filepath.Join(rc.Cache, rc.Domains[0])
+	return base + ".crt", base + ".key"
+}
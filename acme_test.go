@@ -0,0 +1,148 @@
+package syslog
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+// fakeCertificateManager is a stand-in for a real ACME client.
+type fakeCertificateManager struct {
+	cert       tls.Certificate
+	serverName string
+}
+
+func (m *fakeCertificateManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.serverName = hello.ServerName
+	return &m.cert, nil
+}
+
+func TestListenACME(t *testing.T) {
+	cert, pool := testCertificate(t, "localhost")
+
+	received := make(chan *Message, 1)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	expect.Error(s.ListenACME("127.0.0.1:0", nil, AcceptEverything)).ToContain(t, "requires a certificate manager")
+
+	m := &fakeCertificateManager{cert: cert}
+	expect.Error(s.ListenACME("127.0.0.1:0", m, AcceptEverything)).ToBeNil(t)
+
+	c, err := tls.Dial("tcp", s.listeners[0].Addr().String(), &tls.Config{RootCAs: pool, ServerName: "localhost"})
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+
+	msg := "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - hello"
+	_, err = fmt.Fprintf(c, "%d %s", len(msg), msg)
+	expect.Error(err).ToBeNil(t)
+	expect.String((<-received).Content).ToBe(t, "hello")
+	expect.String(m.serverName).ToBe(t, "localhost")
+}
+
+func TestRenewingCertificate(t *testing.T) {
+	var obtained atomic.Int32
+	obtain := func(_ context.Context, domains []string) ([]byte, []byte, error) {
+		obtained.Add(1)
+		cert, _ := testCertificate(t, domains[0])
+		certPEM, keyPEM := encodeKeyPair(t, cert)
+		return certPEM, keyPEM, nil
+	}
+
+	cache := t.TempDir()
+	rc := &RenewingCertificate{Domains: []string{"localhost"}, Obtain: obtain, Cache: cache, RenewBefore: time.Minute}
+	cert1, err := rc.GetCertificate(nil)
+	expect.Error(err).ToBeNil(t)
+	expect.String(cert1.Leaf.Subject.CommonName).ToBe(t, "localhost")
+	again, _ := rc.GetCertificate(nil)
+	expect.Bool(again == cert1).ToBeTrue(t)
+	expect.Number(obtained.Load()).ToBe(t, 1)
+
+	// a restarted server uses the cached certificate
+	rc = &RenewingCertificate{Domains: []string{"localhost"}, Obtain: obtain, Cache: cache, RenewBefore: time.Minute}
+	cert2, err := rc.GetCertificate(nil)
+	expect.Error(err).ToBeNil(t)
+	expect.Bool(cert2.Leaf.Equal(cert1.Leaf)).ToBeTrue(t)
+	expect.Number(obtained.Load()).ToBe(t, 1)
+
+	// near expiry, the old certificate is used whilst a new one is obtained
+	rc.mu.Lock()
+	rc.clock = func() time.Time { return cert1.Leaf.NotAfter.Add(-time.Second) }
+	rc.mu.Unlock()
+	current, _ := rc.GetCertificate(nil)
+	expect.Bool(current == cert2).ToBeTrue(t)
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		rc.mu.Lock()
+		current = rc.cert
+		rc.mu.Unlock()
+		if current != cert2 {
+			break
+		}
+	}
+	expect.Bool(current != cert2).ToBeTrue(t)
+	expect.Number(obtained.Load()).ToBe(t, 2)
+
+	rc = &RenewingCertificate{Obtain: obtain}
+	expect.Error(rc.GetCertificate(nil)).ToContain(t, "requires domains")
+}
+
+func TestRenewingCertificate_retry(t *testing.T) {
+	var obtained atomic.Int32
+	fail := errors.New("CA unavailable")
+	obtain := func(ctx context.Context, domains []string) ([]byte, []byte, error) {
+		if obtained.Add(1) > 1 {
+			return nil, nil, fail
+		}
+		cert, _ := testCertificate(t, domains[0])
+		certPEM, keyPEM := encodeKeyPair(t, cert)
+		return certPEM, keyPEM, ctx.Err()
+	}
+
+	rc := &RenewingCertificate{Domains: []string{"localhost"}, Obtain: obtain, RenewBefore: time.Minute}
+	cert, err := rc.GetCertificate(&tls.ClientHelloInfo{})
+	expect.Error(err).ToBeNil(t)
+
+	now := cert.Leaf.NotAfter.Add(-time.Second)
+	rc.mu.Lock()
+	rc.clock = func() time.Time { return now }
+	rc.mu.Unlock()
+
+	waitForRenewal := func() {
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			rc.mu.Lock()
+			renewing := rc.renewing
+			rc.mu.Unlock()
+			if !renewing {
+				return
+			}
+		}
+	}
+
+	// a failed renewal is not retried on every connection
+	rc.GetCertificate(nil)
+	waitForRenewal()
+	expect.Number(obtained.Load()).ToBe(t, 2)
+	current, _ := rc.GetCertificate(nil)
+	waitForRenewal()
+	expect.Bool(current == cert).ToBeTrue(t)
+	expect.Number(obtained.Load()).ToBe(t, 2)
+
+	rc.mu.Lock()
+	rc.clock = func() time.Time { return now.Add(renewRetryInterval) }
+	rc.mu.Unlock()
+	rc.GetCertificate(nil)
+	waitForRenewal()
+	expect.Number(obtained.Load()).ToBe(t, 3)
+}
@@ -0,0 +1,89 @@
+package syslog
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd.
+const listenFDsStart = 3
+
+// ListenActivated starts goroutines that receive syslog messages on the sockets passed by
+// systemd socket activation (see sd_listen_fds(3)). This allows the collector to receive
+// on port 514 without running as root. Datagram sockets (UDP or Unix) and stream sockets
+// (TCP or Unix, using RFC 6587 framing) are both supported.
+//
+// The names of the sockets (from the FileDescriptorName setting, or "unknown") are
+// returned. If the process was not socket-activated, no names and no error are returned.
+// The LISTEN_* environment variables are unset so that they are not inherited by child
+// processes. Only the messages matching accept are processed.
+func (s *Server) ListenActivated(accept Filter) ([]string, error) {
+	if s.shutDown.Load() {
+		panic("Server is already shut down")
+	}
+
+	files, names, err := activationFiles()
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	var errs []error
+	for _, f := range files {
+		errs = append(errs, s.listenFile(f, accept))
+	}
+	return names, errors.Join(errs...)
+}
+
+// listenFile receives messages on a socket file, which may be a datagram socket or a
+// listening stream socket.
+func (s *Server) listenFile(f *os.File, accept Filter) error {
+	defer f.Close() // the net package uses a duplicate
+
+	if l, err := net.FileListener(f); err == nil {
+		s.ListenListener(l, accept)
+		return nil
+	}
+
+	c, err := net.FilePacketConn(f)
+	if err != nil {
+		return err
+	}
+	s.ListenPacketConn(c, accept)
+	return nil
+}
+
+// activationFiles gets the files passed by systemd socket activation.
+func activationFiles() ([]*os.File, []string, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil, nil // not for this process
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil, errors.New("LISTEN_FDS: invalid value")
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	if len(names) != n {
+		names = make([]string, n)
+		for i := range names {
+			names[i] = "unknown"
+		}
+	}
+
+	files := make([]*os.File, n)
+	for i := range files {
+		files[i] = os.NewFile(uintptr(listenFDsStart+i), names[i])
+	}
+	return files, names, nil
+}
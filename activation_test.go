@@ -0,0 +1,62 @@
+package syslog
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestListenActivated_notActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+
+	s := NewServer(WithQueueLength(1))
+	defer s.Shutdown()
+
+	names, err := s.ListenActivated(AcceptEverything)
+	expect.Slice(names, err).ToBe(t)
+	_, exists := os.LookupEnv("LISTEN_FDS")
+	expect.Bool(exists).ToBeFalse(t)
+}
+
+func TestListenFile(t *testing.T) {
+	received := make(chan *Message, 2)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	expect.Error(err).ToBeNil(t)
+	udpFile, err := udp.File()
+	expect.Error(err).ToBeNil(t)
+	expect.Error(s.listenFile(udpFile, AcceptEverything)).ToBeNil(t)
+
+	tcp, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	expect.Error(err).ToBeNil(t)
+	tcpFile, err := tcp.File()
+	expect.Error(err).ToBeNil(t)
+	expect.Error(s.listenFile(tcpFile, AcceptEverything)).ToBeNil(t)
+
+	c1, err := net.Dial("udp", udp.LocalAddr().String())
+	expect.Error(err).ToBeNil(t)
+	udp.Close() // the server has its own duplicate
+	c1.Write([]byte("<13>1 - host app - - - by udp"))
+	c1.Close()
+	m := <-received
+	expect.String(m.Content).ToBe(t, "by udp")
+
+	c2, err := net.Dial("tcp", tcp.Addr().String())
+	expect.Error(err).ToBeNil(t)
+	tcp.Close()
+	c2.Write([]byte("<13>1 - host app - - - by tcp\n"))
+	c2.Close()
+	m = <-received
+	expect.String(m.Content).ToBe(t, "by tcp")
+}
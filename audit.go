@@ -0,0 +1,61 @@
+package syslog
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	verdictAccepted    = "accepted"
+	verdictRejected    = "rejected"
+	verdictInvalid     = "invalid"
+	verdictDropped     = "dropped"
+	verdictTimedOut    = "timed-out"
+	verdictDenied      = "denied"
+	verdictRateLimited = "rate-limited"
+)
+
+type auditRecord struct {
+	Time     time.Time `json:"time"`
+	Sequence uint64    `json:"seq,omitempty"`
+	Source   string    `json:"source,omitempty"`
+	Size     int       `json:"bytes"`
+	Verdict  string    `json:"verdict"`
+	Handlers []string  `json:"handlers,omitempty"`
+}
+
+// auditor writes audit records as JSON lines. A nil auditor does nothing.
+type auditor struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newAuditor(w io.Writer) *auditor {
+	if w == nil {
+		return nil
+	}
+	return &auditor{enc: json.NewEncoder(w)}
+}
+
+func (a *auditor) record(m *Message, verdict string, handlers []string) {
+	if a == nil {
+		return
+	}
+
+	r := auditRecord{
+		Time:     m.Time,
+		Sequence: m.Sequence,
+		Size:     m.Size,
+		Verdict:  verdict,
+		Handlers: handlers,
+	}
+	if m.Source != nil {
+		r.Source = m.Source.String()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	checkErr(a.enc.Encode(r), "audit")
+}
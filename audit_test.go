@@ -0,0 +1,31 @@
+package syslog
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestAuditor(t *testing.T) {
+	buf := &bytes.Buffer{}
+	a := newAuditor(buf)
+
+	m := &Message{
+		Time:     time.Date(2023, 10, 26, 15, 31, 1, 0, time.UTC),
+		Source:   &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 514},
+		Sequence: 7,
+		Size:     123,
+	}
+	a.record(m, verdictAccepted, []string{"*syslog.FileHandler"})
+	a.record(&Message{Time: m.Time, Size: 5}, verdictInvalid, nil)
+
+	expect.String(buf.String()).ToBe(t,
+		`{"time":"2023-10-26T15:31:01Z","seq":7,"source":"10.0.0.1:514","bytes":123,"verdict":"accepted","handlers":["*syslog.FileHandler"]}`+"\n"+
+			`{"time":"2023-10-26T15:31:01Z","bytes":5,"verdict":"invalid"}`+"\n")
+
+	var none *auditor
+	none.record(m, verdictRejected, nil) // no panic
+}
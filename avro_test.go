@@ -0,0 +1,101 @@
+package syslog
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestAvroEncoder(t *testing.T) {
+	m := &Message{
+		Time:        time.UnixMicro(1700000000000001),
+		Source:      &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 514},
+		Facility:    Daemon,
+		Severity:    Err,
+		Version:     1,
+		Hostname:    "host",
+		Application: "app",
+		Content:     "hi",
+		Sequence:    7,
+		Size:        2,
+	}
+	m.Annotate("tenant", "acme")
+
+	e := NewAvroEncoder()
+	e.SetSchemaID(42)
+	bs, err := e.AppendFormat(nil, m)
+	expect.Error(err).ToBeNil(t)
+	expect.Slice(bs[:5]).ToBe(t, 0, 0, 0, 0, 42)
+
+	r := avroReader(bs[5:])
+	expect.Number(r.long()).ToBe(t, 1700000000000001)
+	expect.Number(r.long()).ToBe(t, 1) // union branch
+	expect.String(r.string()).ToBe(t, "10.0.0.1:514")
+	expect.Number(r.long()).ToBe(t, int64(Daemon))
+	expect.Number(r.long()).ToBe(t, int64(Err))
+	expect.Number(r.long()).ToBe(t, 1)
+	expect.Number(r.long()).ToBe(t, 0) // zero timestamp
+	for _, want := range []string{"host", "app", "", "", "", "hi"} {
+		expect.String(r.string()).ToBe(t, want)
+	}
+	expect.Number(r.long()).ToBe(t, 7)
+	expect.Number(r.long()).ToBe(t, 2)
+	expect.Number(r.long()).ToBe(t, 1)
+	expect.String(r.string()).ToBe(t, "tenant")
+	expect.String(r.string()).ToBe(t, "acme")
+	expect.Number(r.long()).ToBe(t, 0)
+	expect.Number(len(r)).ToBe(t, 0)
+
+	var schema map[string]any
+	expect.Error(json.Unmarshal([]byte(AvroSchema), &schema)).ToBeNil(t)
+	expect.Number(len(schema["fields"].([]any))).ToBe(t, 15)
+}
+
+type avroReader []byte
+
+func (r *avroReader) long() int64 {
+	v, n := binary.Varint(*r)
+	*r = (*r)[n:]
+	return v
+}
+
+func (r *avroReader) string() string {
+	n := r.long()
+	s := string((*r)[:n])
+	*r = (*r)[n:]
+	return s
+}
+
+func TestRegisterAvroSchema(t *testing.T) {
+	var path, contentType string
+	var body map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		bs, _ := io.ReadAll(r.Body)
+		json.Unmarshal(bs, &body)
+		if r.URL.Path == "/subjects/bad/versions" {
+			http.Error(w, `{"error_code":42201}`, http.StatusUnprocessableEntity)
+			return
+		}
+		w.Write([]byte(`{"id":17}`))
+	}))
+	defer srv.Close()
+
+	id, err := RegisterAvroSchema(context.Background(), srv.URL+"/", "syslog-value")
+	expect.Error(err).ToBeNil(t)
+	expect.Number(id).ToBe(t, 17)
+	expect.String(path).ToBe(t, "/subjects/syslog-value/versions")
+	expect.String(contentType).ToBe(t, "application/vnd.schemaregistry.v1+json")
+	expect.String(body["schema"]).ToBe(t, AvroSchema)
+
+	_, err = RegisterAvroSchema(context.Background(), srv.URL, "bad")
+	expect.Error(err).ToContain(t, "422")
+}
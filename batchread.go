@@ -0,0 +1,43 @@
+package syslog
+
+import "net"
+
+// packetReader reads one or more datagrams at a time from a socket.
+type packetReader interface {
+	// read waits for datagrams, returning how many were read.
+	read() (int, error)
+	// packet gets the ith datagram of the last read and its sender.
+	packet(i int) ([]byte, net.Addr)
+}
+
+// newPacketReader gets a reader for c, which reads batches of datagrams if the server is
+// configured to and the platform supports it.
+func (s *Server) newPacketReader(c net.PacketConn) packetReader {
+	if s.readBatch > 1 {
+		if r := newBatchReader(c, s.readBatch, s.readBufferSize); r != nil {
+			return r
+		}
+	}
+	return &singleReader{c: c, buf: make([]byte, s.readBufferSize)}
+}
+
+// singleReader reads one datagram per system call.
+type singleReader struct {
+	c    net.PacketConn
+	buf  []byte
+	n    int
+	addr net.Addr
+}
+
+func (r *singleReader) read() (int, error) {
+	var err error
+	r.n, r.addr, err = r.c.ReadFrom(r.buf)
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (r *singleReader) packet(int) ([]byte, net.Addr) {
+	return r.buf[:r.n], r.addr
+}
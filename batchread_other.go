@@ -0,0 +1,9 @@
+//go:build !linux
+
+package syslog
+
+import "net"
+
+func newBatchReader(net.PacketConn, int, int) packetReader {
+	return nil
+}
@@ -0,0 +1,49 @@
+package syslog
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestWithBatchedReads(t *testing.T) {
+	for network, addr := range map[string]string{"udp4": "127.0.0.1:0", "udp6": "[::1]:0"} {
+		t.Run(network, func(t *testing.T) {
+			pc, err := net.ListenPacket(network, addr)
+			if err != nil {
+				t.Skip(err)
+			}
+
+			received := make(chan *Message, 100)
+			s := NewServer(WithBatchedReads(16), WithQueueLength(100))
+			s.AddHandler(handlerFunc(func(m *Message) *Message {
+				if m != nil {
+					received <- m
+				}
+				return m
+			}))
+			defer s.Shutdown()
+			_, single := s.newPacketReader(pc).(*singleReader)
+			expect.Bool(single).ToBe(t, runtime.GOOS != "linux")
+			s.ListenPacketConn(pc, AcceptEverything)
+
+			c, err := net.Dial(network, pc.LocalAddr().String())
+			expect.Error(err).ToBeNil(t)
+			defer c.Close()
+
+			for i := range 50 {
+				_, err = fmt.Fprintf(c, "<13>1 - host app - - - message %d", i)
+				expect.Error(err).ToBeNil(t)
+			}
+
+			for i := range 50 {
+				m := <-received
+				expect.String(m.Content).ToBe(t, fmt.Sprintf("message %d", i))
+				expect.String(m.Source.String()).ToBe(t, c.LocalAddr().String())
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package bench
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rickb777/syslog"
+)
+
+// counter is a handler that signals when a number of messages have been handled.
+type counter struct {
+	n      atomic.Int64
+	target int64
+	done   chan struct{}
+}
+
+func newCounter(target int) *counter {
+	return &counter{target: int64(target), done: make(chan struct{})}
+}
+
+func (c *counter) Handle(m *syslog.Message) *syslog.Message {
+	if m != nil && c.n.Add(1) == c.target {
+		close(c.done)
+	}
+	return m
+}
+
+func newFileServer(b *testing.B, target int) (*syslog.Server, *counter) {
+	b.Helper()
+	fh := syslog.NewFileHandler(filepath.Join(b.TempDir(), "%hostname%.log"), syslog.RFCFormat)
+	fh.SetPropagateAll(true)
+
+	c := newCounter(target)
+	s := syslog.NewServer(syslog.WithQueueLength(100))
+	s.AddHandler(fh)
+	s.AddHandler(c)
+	return s, c
+}
+
+func BenchmarkTCPToFile(b *testing.B) {
+	for _, corpus := range []struct {
+		name     string
+		messages [][]byte
+	}{
+		{name: "RFC3164", messages: RFC3164},
+		{name: "RFC5424", messages: RFC5424},
+		{name: "Malformed", messages: Malformed},
+	} {
+		b.Run(corpus.name, func(b *testing.B) {
+			s, c := newFileServer(b, b.N)
+			defer s.Shutdown()
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				b.Fatal(err)
+			}
+			s.ListenListener(l, syslog.AcceptEverything)
+
+			conn, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer conn.Close()
+
+			frames := make([][]byte, len(corpus.messages))
+			for i, m := range corpus.messages {
+				frames[i] = fmt.Appendf(nil, "%d %s", len(m), m)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err = conn.Write(frames[i%len(frames)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+			<-c.done
+		})
+	}
+}
+
+func BenchmarkUDPToFile(b *testing.B) {
+	s, c := newFileServer(b, b.N)
+	defer s.Shutdown()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	s.ListenPacketConn(pc, syslog.AcceptEverything)
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = conn.Write(All[i%len(All)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	// UDP may drop packets under load, so don't wait forever
+	select {
+	case <-c.done:
+	case <-time.After(5 * time.Second):
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(int64(b.N)-c.n.Load())/float64(b.N), "dropped/op")
+}
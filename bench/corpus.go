@@ -0,0 +1,49 @@
+// Package bench provides realistic message corpora and end-to-end benchmarks for the
+// syslog package, so that performance can be compared across commits, e.g.
+//
+//	go test -run=NONE -bench=. -benchmem -count=10 ./bench > new.txt
+//	benchstat old.txt new.txt
+//
+// See also the "Bench" mage target.
+package bench
+
+// RFC3164 contains typical BSD-style messages.
+var RFC3164 = [][]byte{
+	[]byte(`<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8`),
+	[]byte(`<13>Feb  5 17:32:18 10.0.0.99 Use the BFG!`),
+	[]byte(`<0>1990 Oct 22 10:52:01 TZ-6 scapegoat.dmz.example.org sched[0]: That's All Folks!`),
+	[]byte(`<86>Nov  3 06:25:01 web01 CRON[21234]: pam_unix(cron:session): session opened for user root by (uid=0)`),
+	[]byte(`<30>Nov  3 06:25:07 web01 systemd[1]: Started Session 4213 of user www-data.`),
+	[]byte(`<38>Nov  3 06:25:09 web01 sshd[4456]: Accepted publickey for deploy from 192.0.2.44 port 52144 ssh2: ED25519 SHA256:abc`),
+	[]byte(`<190>Nov  3 06:25:11 lb02 haproxy[991]: 198.51.100.7:40210 [03/Nov/2023:06:25:11.123] fe_https~ be_app/app3 0/0/1/12/13 200 5123 - - ---- 412/410/3/1/0 0/0 "GET /api/v1/items?page=2 HTTP/1.1"`),
+}
+
+// RFC5424 contains modern messages, with and without structured data.
+var RFC5424 = [][]byte{
+	[]byte("<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - \xEF\xBB\xBF'su root' failed for lonvick on /dev/pts/8"),
+	[]byte(`<165>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 myproc 8710 - - %% It's time to make the donuts.`),
+	[]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"] An application event log entry...`),
+	[]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"]`),
+	[]byte(`<14>1 2023-11-03T06:25:11.482913Z app-7f9c4b.example.com checkout 2231 ORDER [meta@32473 tenant="acme" region="eu-west-1" trace="4bf92f3577b34da6a3ce929d0e0e4736"] order 991823 accepted, 3 items, total=129.95 EUR`),
+}
+
+// Malformed contains messages that violate the RFCs in ways seen in the wild.
+var Malformed = [][]byte{
+	[]byte(`<165>Aug 24 05:34:00 CST 1987 mymachine myproc[10]: %% It's time to make the do-nuts.  %%  Ingredients: Mix=OK, Jelly=OK # Devices: Mixer=OK, Jelly_Injector=OK, Frier=OK # Transport: Conveyer1=OK, Conveyer2=OK # %%`),
+	[]byte(`no priority or header at all, just some text`),
+	[]byte(`<13>`),
+	[]byte(`<999>1 not-a-timestamp host app - - - content`),
+	[]byte(`<134>1 2023-11-03T06:25:11Z host app - - [unterminated@1 a="b" message`),
+	[]byte("<13>Nov  3 06:25:11 host app: trailing junk\x00\r\n"),
+}
+
+// All contains every message in the corpora.
+var All = concat(RFC3164, RFC5424, Malformed)
+
+func concat(lists ...[][]byte) [][]byte {
+	var all [][]byte
+	for _, l := range lists {
+		all = append(all, l...)
+	}
+	return all
+}
@@ -0,0 +1,9 @@
+package bench
+
+import "testing"
+
+func TestCorpora(t *testing.T) {
+	if len(All) != len(RFC3164)+len(RFC5424)+len(Malformed) {
+		t.Errorf("All has %d messages", len(All))
+	}
+}
@@ -0,0 +1,121 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"iter"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// maxStoredLine limits the length of the lines read by [ReadMessages].
+const maxStoredLine = 1 << 20
+
+// DefaultCaptureBuffer is the number of messages a capture holds whilst its consumer is busy.
+const DefaultCaptureBuffer = 1024
+
+// captureCount is used to give each capture a unique handler name.
+var captureCount atomic.Uint64
+
+// Capture adds a temporary handler that passes copies of the messages matching accept to
+// the returned sequence, for analysis tools that consume live traffic using range-over-func:
+//
+//	for m := range s.Capture(ctx, filter) {
+//		...
+//	}
+//
+// The capture starts when the iteration starts and ends when ctx is done, the loop stops
+// or the server is shut down; the handler is then removed. The server never waits for the
+// consumer: if more than [DefaultCaptureBuffer] messages are waiting, new ones are dropped.
+// A sequence can be iterated more than once, each time starting a new capture.
+func (s *Server) Capture(ctx context.Context, accept Filter) iter.Seq[*Message] {
+	return func(yield func(*Message) bool) {
+		c := &captureHandler{
+			accept: accept,
+			ch:     make(chan *Message, DefaultCaptureBuffer),
+			done:   make(chan struct{}),
+		}
+		name := "capture-" + strconv.FormatUint(captureCount.Add(1), 10)
+		if checkErr(s.AddNamedHandler(name, c), "capture") {
+			return
+		}
+		defer func() {
+			// fails if the server has been shut down, which has already closed the handler
+			s.RemoveHandler(name)
+		}()
+
+		for {
+			select {
+			case m := <-c.ch:
+				if !yield(m) {
+					return
+				}
+			case <-c.done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// captureHandler feeds a capture. It is safe for concurrent use.
+type captureHandler struct {
+	accept Filter
+	ch     chan *Message
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (c *captureHandler) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
+
+func (c *captureHandler) Handle(m *Message) *Message {
+	if m == nil {
+		c.Close()
+	} else if c.accept(m) {
+		select {
+		case c.ch <- m.Clone():
+		default: // the consumer is too slow
+		}
+	}
+	return m
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// ReadMessages returns a sequence of the messages stored in r, one per line, such as a file
+// written by [FileHandler] in [RFCFormat] or by [JSONHandler]. Lines starting with '{' are
+// decoded with [ParseJSON]; others are parsed as syslog messages. A line that cannot be
+// parsed yields a nil message and the error, and iteration continues with the next line;
+// an error reading r ends the sequence.
+func ReadMessages(r io.Reader) iter.Seq2[*Message, error] {
+	return func(yield func(*Message, error) bool) {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(nil, maxStoredLine)
+		for sc.Scan() {
+			line := sc.Bytes()
+			if len(line) == 0 {
+				continue
+			}
+
+			var m *Message
+			var err error
+			if line[0] == '{' {
+				m, _, err = ParseJSON(line)
+			} else {
+				m, err = parseMessage(line)
+			}
+			if !yield(m, err) {
+				return
+			}
+		}
+		if err := sc.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
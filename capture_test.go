@@ -0,0 +1,86 @@
+package syslog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestCapture(t *testing.T) {
+	s := NewServer()
+	defer s.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	captured := make(chan []string)
+	go func() {
+		var contents []string
+		for m := range s.Capture(ctx, func(m *Message) bool { return m.Severity <= Err }) {
+			contents = append(contents, m.Content)
+			if len(contents) == 2 {
+				break
+			}
+		}
+		captured <- contents
+	}()
+
+	for len(s.handlerChain()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	s.push(&Message{Severity: Info, Content: "a"})
+	s.push(&Message{Severity: Err, Content: "b"})
+	s.push(&Message{Severity: Crit, Content: "c"})
+
+	expect.Slice(<-captured).ToBe(t, "b", "c")
+	for len(s.handlerChain()) > 0 { // removed when the loop stopped
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCapture_shutdown(t *testing.T) {
+	s := NewServer()
+	done := make(chan struct{})
+	go func() {
+		for range s.Capture(context.Background(), AcceptEverything) {
+		}
+		close(done)
+	}()
+
+	for len(s.handlerChain()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	s.Shutdown()
+	<-done
+}
+
+func TestReadMessages(t *testing.T) {
+	stored := "<14>1 2024-03-01T12:00:00Z host app - - - first\n\n" +
+		`{"schema":2,"facility":"user","severity":"err","priority":11,"version":1,"content":"second"}` + "\n" +
+		"{bad\n" +
+		"<13>Mar  1 12:00:01 host app: third\n"
+
+	var contents []string
+	var errs []error
+	for m, err := range ReadMessages(strings.NewReader(stored)) {
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			contents = append(contents, m.Content)
+		}
+	}
+	expect.Slice(contents).ToBe(t, "first", "second", ": third")
+	expect.Number(len(errs)).ToBe(t, 1)
+
+	for _, err := range ReadMessages(iotestErrReader{}) {
+		expect.Error(err).ToContain(t, "broken")
+	}
+}
+
+type iotestErrReader struct{}
+
+func (iotestErrReader) Read([]byte) (int, error) { return 0, errors.New("broken") }
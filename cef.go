@@ -0,0 +1,161 @@
+package syslog
+
+import (
+	"errors"
+	"strings"
+)
+
+// DefaultCEFSDID is the SD-ID used by [CEFHandler] when its SDID is blank.
+const DefaultCEFSDID = "cef@32473"
+
+// cefHeader names the header fields of CEF, as structured data parameters.
+var cefHeader = []string{"cefVersion", "deviceVendor", "deviceProduct", "deviceVersion", "signatureId", "name", "severity"}
+
+// CEFHandler is a [Handler] that decodes ArcSight Common Event Format (CEF) messages, as sent
+// by many security products, into structured data for filtering and output. The content of
+// such a message has the form
+//
+//	CEF:Version|Device Vendor|Device Product|Device Version|Signature ID|Name|Severity|Extension
+//
+// where the extension is a list of key=value pairs separated by spaces. A structured data
+// element is added with the header fields as the parameters cefVersion, deviceVendor,
+// deviceProduct, deviceVersion, signatureId, name and severity, followed by the extension
+// pairs, e.g. [cef@32473 cefVersion="0" deviceVendor="Security" ... src="10.0.0.1"]. The
+// escapes of CEF are removed; extension keys that cannot be SD parameter names are dropped.
+//
+// RFC 3164 senders often put CEF where the tag should be, so that "CEF" is parsed as the
+// application (or the hostname); the application then becomes the device product. The
+// content is unchanged, apart from restoring the "CEF:" prefix. Other messages are
+// unchanged.
+type CEFHandler struct {
+	// SDID is the SD-ID of the element added to the structured data, which should have the
+	// form name@enterprise-number. If blank, [DefaultCEFSDID] is used.
+	SDID string
+}
+
+// Close does nothing; it implements [io.Closer].
+func (h CEFHandler) Close() error {
+	return nil
+}
+
+func (h CEFHandler) Handle(m *Message) *Message {
+	if m == nil || !m.IsParsed() {
+		return m
+	}
+
+	content, misparsed := findPayload(m, "CEF")
+	if content == "" {
+		return m
+	}
+	params, err := parseCEF(content)
+	if err != nil {
+		return m
+	}
+
+	addPayload(m, ifBlank(h.SDID, DefaultCEFSDID), params, content, misparsed)
+	return m
+}
+
+// findPayload finds content starting with a prefix such as "CEF", which may have been split
+// at the colon after the prefix by the RFC 3164 parser. It returns blank for other messages.
+func findPayload(m *Message, prefix string) (content string, misparsed bool) {
+	if strings.HasPrefix(m.Content, ":") && strings.Contains(m.Content, "|") &&
+		(m.Application == prefix || m.Hostname == prefix && m.Application == "") {
+		return prefix + m.Content, true
+	}
+
+	content = strings.TrimLeft(m.Content, ": ")
+	if strings.HasPrefix(content, prefix+":") {
+		return content, false
+	}
+	return "", false
+}
+
+// addPayload adds the decoded params of a payload as a structured data element. If the
+// prefix was misparsed, the content is restored and the application becomes the product,
+// which is the third header field.
+func addPayload(m *Message, sdID string, params []SDParam, content string, misparsed bool) {
+	if misparsed {
+		if m.Application == "" {
+			m.Hostname = ""
+		}
+		m.Application = params[2].Value
+		m.Content = content
+	}
+
+	var pairs []string
+	for _, p := range params {
+		if validSDName(p.Name) {
+			pairs = append(pairs, p.Name, p.Value)
+		}
+	}
+	m.addSDElement(sdID, pairs...)
+}
+
+var errNotCEF = errors.New("not CEF")
+
+// parseCEF splits CEF into the header fields and the extension pairs, without escapes.
+func parseCEF(s string) ([]SDParam, error) {
+	s, found := strings.CutPrefix(s, "CEF:")
+	if !found {
+		return nil, errNotCEF
+	}
+
+	params := make([]SDParam, 0, len(cefHeader)+8)
+	for _, name := range cefHeader {
+		end := indexUnescaped(s, '|')
+		if end < 0 {
+			return nil, errNotCEF
+		}
+		params = append(params, SDParam{name, cefHeaderEscapes.Replace(s[:end])})
+		s = s[end+1:]
+	}
+
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+
+		// the value ends at the space before the next key
+		end := nextCEFKey(s)
+		if end < 0 {
+			end = len(s)
+		}
+		params = append(params, SDParam{key, cefExtensionEscapes.Replace(strings.TrimRight(s[:end], " "))})
+		s = s[end:]
+	}
+	return params, nil
+}
+
+// nextCEFKey finds the start of the next "key=" in an extension, or -1.
+func nextCEFKey(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '=':
+			// back up over the key to the space before it
+			j := i
+			for j > 0 && isCEFKeyChar(s[j-1]) {
+				j--
+			}
+			if j > 0 && j < i && s[j-1] == ' ' {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+func isCEFKeyChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '_' || c == '.' || c == '-' || c == '[' || c == ']'
+}
+
+var (
+	cefHeaderEscapes    = strings.NewReplacer(`\|`, `|`, `\\`, `\`)
+	cefExtensionEscapes = strings.NewReplacer(`\=`, `=`, `\\`, `\`, `\n`, "\n", `\r`, "\r")
+)
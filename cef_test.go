@@ -0,0 +1,44 @@
+package syslog
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestCEFHandler(t *testing.T) {
+	const header = `cefVersion="0" deviceVendor="Security" deviceProduct="threatmanager" deviceVersion="1.0" signatureId="100" `
+	cases := []struct {
+		in, host, app, content, data string
+	}{
+		{`<134>Feb  1 12:00:00 fw1 CEF:0|Security|threatmanager|1.0|100|worm stopped|10|src=10.0.0.1 msg=Detected a\=b \\ worm\nhere act=blocked`,
+			"fw1", "threatmanager",
+			`CEF:0|Security|threatmanager|1.0|100|worm stopped|10|src=10.0.0.1 msg=Detected a\=b \\ worm\nhere act=blocked`,
+			`[cef@32473 ` + header + `name="worm stopped" severity="10" src="10.0.0.1" msg="Detected a=b \\ worm` + "\n" + `here" act="blocked"]`},
+		{`<134>1 2024-01-01T00:00:00Z fw1 ids - - - CEF:0|Security|threatmanager|1.0|100|a \| b|High|`,
+			"fw1", "ids", `CEF:0|Security|threatmanager|1.0|100|a \| b|High|`,
+			`[cef@32473 ` + header + `name="a | b" severity="High"]`},
+		{`<134>CEF:0|Security|threatmanager|1.0|100|n|1|cs1Label=x cs1=a b c`,
+			"", "threatmanager", `CEF:0|Security|threatmanager|1.0|100|n|1|cs1Label=x cs1=a b c`,
+			`[cef@32473 ` + header + `name="n" severity="1" cs1Label="x" cs1="a b c"]`},
+		{`<134>1 2024-01-01T00:00:00Z fw1 ids - - - CEF:0|truncated`, "fw1", "ids", "CEF:0|truncated", "-"},
+		{`<134>1 2024-01-01T00:00:00Z fw1 ids - - - plain text`, "fw1", "ids", "plain text", "-"},
+	}
+
+	for _, c := range cases {
+		m, err := parseMessage([]byte(c.in))
+		expect.Error(err).ToBeNil(t)
+		m = CEFHandler{}.Handle(m)
+		expect.String(m.Hostname).Info(c.in).ToBe(t, c.host)
+		expect.String(m.Application).Info(c.in).ToBe(t, c.app)
+		expect.String(m.Content).Info(c.in).ToBe(t, c.content)
+		expect.String(m.Data).Info(c.in).ToBe(t, c.data)
+	}
+
+	m, _ := parseMessage([]byte(`<134>1 - h a - - - CEF:0|V|P|1|2|N|3|src=1`))
+	elems, err := CEFHandler{SDID: "cef@99"}.Handle(m).StructuredData()
+	expect.Error(err).ToBeNil(t)
+	v, _ := elems[0].Param("src")
+	expect.String(elems[0].ID).ToBe(t, "cef@99")
+	expect.String(v).ToBe(t, "1")
+}
@@ -0,0 +1,110 @@
+package syslog
+
+import (
+	"fmt"
+	"slices"
+)
+
+// namedHandler is an entry in the handler chain. Handlers added with [Server.AddHandler]
+// have no name.
+type namedHandler struct {
+	Handler
+	name string
+}
+
+// label identifies the handler in audit records and diagnostics.
+func (h *namedHandler) label() string {
+	if h.name != "" {
+		return h.name
+	}
+	return fmt.Sprintf("%T", h.Handler)
+}
+
+// handlerChain gets the current handlers. The slice is never modified once it has been
+// stored, so it can be used without locking.
+func (s *Server) handlerChain() []*namedHandler {
+	if c := s.chain.Load(); c != nil {
+		return *c
+	}
+	return nil
+}
+
+// AddNamedHandler adds h to the end of the handler chain under a name that can later be
+// used to remove or replace it. The name must not be empty or already in use. This is
+// safe to call while the server is running; h receives messages from the next batch on.
+func (s *Server) AddNamedHandler(name string, h Handler) error {
+	if name == "" {
+		return fmt.Errorf("handler %T needs a name", h)
+	}
+
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+
+	chain := s.handlerChain()
+	if indexOfHandler(chain, name) >= 0 {
+		return fmt.Errorf("%s: handler name is already in use", name)
+	}
+	s.storeChain(append(slices.Clip(chain), &namedHandler{Handler: h, name: name}))
+	return nil
+}
+
+// RemoveHandler removes the named handler from the chain. It waits for any messages that
+// are being handled to finish, then shuts the handler down using its Close method if it is
+// an [io.Closer], or otherwise by calling Handle with nil; any error from Close is returned.
+// This is safe to call while the server is running.
+func (s *Server) RemoveHandler(name string) error {
+	old, err := s.swapHandler(name, nil)
+	if err != nil {
+		return err
+	}
+	return closeHandler(old)
+}
+
+// ReplaceHandler puts h in place of the named handler, keeping its position in the chain.
+// Like [Server.RemoveHandler], it waits for any messages that are being handled to finish
+// and then shuts down the old handler. This is safe to call while the server is running,
+// so an output destination can be changed without restarting.
+func (s *Server) ReplaceHandler(name string, h Handler) error {
+	old, err := s.swapHandler(name, h)
+	if err != nil {
+		return err
+	}
+	return closeHandler(old)
+}
+
+// swapHandler replaces (or removes, if h is nil) the named handler, then waits until it is
+// no longer in use.
+func (s *Server) swapHandler(name string, h Handler) (Handler, error) {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+
+	chain := s.handlerChain()
+	i := indexOfHandler(chain, name)
+	if i < 0 {
+		return nil, fmt.Errorf("%s: no such handler", name)
+	}
+
+	old := chain[i].Handler
+	if h == nil {
+		s.storeChain(slices.Delete(slices.Clone(chain), i, i+1))
+	} else {
+		chain = slices.Clone(chain)
+		chain[i] = &namedHandler{Handler: h, name: name}
+		s.storeChain(chain)
+	}
+
+	// batches that started with the old chain finish before this lock is acquired
+	s.inFlight.Lock()
+	s.inFlight.Unlock()
+	return old, nil
+}
+
+func (s *Server) storeChain(chain []*namedHandler) {
+	s.chain.Store(&chain)
+}
+
+func indexOfHandler(chain []*namedHandler, name string) int {
+	return slices.IndexFunc(chain, func(h *namedHandler) bool {
+		return h.name == name
+	})
+}
@@ -0,0 +1,327 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ColdStore is a cheaper storage location for old archives, such as another mount or an
+// object store, to which a [Tiering] job moves them. Names are slash-separated paths. An
+// implementation for S3 or similar can be written with the provider's SDK.
+type ColdStore interface {
+	// Put stores an archive under a name, replacing any with that name.
+	Put(ctx context.Context, name string, r io.Reader) error
+	// Open fetches an archive.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// DirColdStore is a [ColdStore] in a directory, e.g. on another mount.
+type DirColdStore string
+
+func (d DirColdStore) Put(_ context.Context, name string, r io.Reader) error {
+	dest, err := d.path("put", name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly after the rename
+
+	if _, err = io.Copy(f, r); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), dest)
+}
+
+// Open opens an archive. Names that are not local to the directory, such as "../x", are
+// rejected, so a name may safely come from a query.
+func (d DirColdStore) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	p, err := d.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// path converts a name to a path within the directory; see [filepath.IsLocal].
+func (d DirColdStore) path(op, name string) (string, error) {
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(string(d), local), nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// ColdIndexFile is the name of the index that a [Tiering] job keeps in the primary directory.
+const ColdIndexFile = "cold-index.jsonl"
+
+// DefaultArchivePattern matches the archives moved by a [Tiering] job, i.e. the files
+// compressed by the log rotation of [FileHandler].
+const DefaultArchivePattern = "*.gz"
+
+// ColdArchive is an entry in the index of a [Tiering] job.
+type ColdArchive struct {
+	Name     string    `json:"name"`      // the path of the archive within the primary directory
+	ColdName string    `json:"cold_name"` // the name in the cold store
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	MovedAt  time.Time `json:"moved_at"`
+}
+
+// Tiering is a background job that moves archives, such as rotated log files, that are older
+// than a threshold from a primary directory to a [ColdStore]. Each archive is recorded in an
+// index ([ColdIndexFile] in the primary directory) before it is removed, so that it can still
+// be found and fetched; see [Tiering.Open].
+//
+// Rotation renames archives as newer ones arrive (file.log.1.gz becomes file.log.2.gz), so
+// the cold name is prefixed by the modification time, e.g.
+// web01/20240301T120000Z_app.log.3.gz, and the same name can be moved more than once.
+type Tiering struct {
+	dir     string
+	store   ColdStore
+	age     time.Duration
+	pattern string
+	clock   func() time.Time
+
+	running  sync.Mutex // held by Run, so that only one runs at a time
+	mu       sync.Mutex // guards the pattern and index
+	archives []ColdArchive
+	stop     chan struct{}
+	stopped  sync.WaitGroup
+}
+
+// stagingSuffix is added to the name of an archive whilst it is being moved, so that
+// rotation cannot replace it. An archive left staged, e.g. by a crash, is moved by the next run.
+const stagingSuffix = ".tiering"
+
+// NewTiering creates a job that moves archives in dir (and its subdirectories) to store
+// once they are older than age, loading the index if there is one. Archives are the files
+// matching [DefaultArchivePattern]; see [Tiering.SetPattern].
+func NewTiering(dir string, store ColdStore, age time.Duration) (*Tiering, error) {
+	t := &Tiering{dir: dir, store: store, age: age, pattern: DefaultArchivePattern, clock: time.Now}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// SetPattern changes the pattern (see [path.Match]) that the base names of archives match.
+func (t *Tiering) SetPattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pattern = pattern
+	return nil
+}
+
+// Start runs the job at the given interval, starting now, until [Tiering.Stop] is called.
+// Errors are logged.
+func (t *Tiering) Start(interval time.Duration) {
+	t.stop = make(chan struct{})
+	t.stopped.Add(1)
+	go func() {
+		defer t.stopped.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			checkErr(t.Run(context.Background()), "tiering")
+			select {
+			case <-ticker.C:
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the job started by [Tiering.Start], waiting for any move in progress.
+func (t *Tiering) Stop() {
+	close(t.stop)
+	t.stopped.Wait()
+}
+
+// Run moves the archives that are old enough, once.
+func (t *Tiering) Run(ctx context.Context) error {
+	t.running.Lock()
+	defer t.running.Unlock()
+
+	t.mu.Lock()
+	pattern := t.pattern
+	t.mu.Unlock()
+
+	cutoff := t.clock().Add(-t.age)
+	var errs []error
+	err := filepath.WalkDir(t.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if d.IsDir() || d.Name() == ColdIndexFile {
+			return ctx.Err()
+		}
+		staged := strings.HasSuffix(d.Name(), stagingSuffix)
+		if matched, _ := path.Match(pattern, strings.TrimSuffix(d.Name(), stagingSuffix)); !matched {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err == nil && (staged || fi.ModTime().Before(cutoff)) {
+			err = t.move(ctx, strings.TrimSuffix(p, stagingSuffix))
+		}
+		switch {
+		case err == nil, errors.Is(err, fs.ErrNotExist):
+			// rotation may have renamed it
+		default:
+			errs = append(errs, err)
+		}
+		return ctx.Err()
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+// move copies an archive to the cold store, records it and removes it. The archive is
+// first renamed to a staging name, so that what is copied is exactly what is removed, even
+// if rotation renames another archive to its name in the meantime.
+func (t *Tiering) move(ctx context.Context, p string) error {
+	staged := p + stagingSuffix
+	if _, err := os.Lstat(staged); err != nil {
+		// nothing else creates staged files and runs are serialised, so this cannot race
+		if err = os.Rename(p, staged); err != nil {
+			return err
+		}
+	}
+
+	fi, err := os.Lstat(staged)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(t.dir, p)
+	if err != nil {
+		return err
+	}
+	name := filepath.ToSlash(rel)
+	a := ColdArchive{
+		Name:     name,
+		ColdName: path.Join(path.Dir(name), fi.ModTime().UTC().Format("20060102T150405Z")+"_"+path.Base(name)),
+		Size:     fi.Size(),
+		ModTime:  fi.ModTime(),
+	}
+
+	f, err := os.Open(staged)
+	if err != nil {
+		return err
+	}
+	err = t.store.Put(ctx, a.ColdName, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	a.MovedAt = t.clock()
+	if err = t.record(a); err != nil {
+		return err
+	}
+	return os.Remove(staged)
+}
+
+// record appends an archive to the index.
+func (t *Tiering) record(a ColdArchive) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	line, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(t.dir, ColdIndexFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		t.archives = append(t.archives, a)
+	}
+	return err
+}
+
+// load reads the index, if it exists.
+func (t *Tiering) load() error {
+	f, err := os.Open(filepath.Join(t.dir, ColdIndexFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var a ColdArchive
+		if err = json.Unmarshal(sc.Bytes(), &a); err != nil {
+			return err
+		}
+		t.archives = append(t.archives, a)
+	}
+	return sc.Err()
+}
+
+// Archives lists the archives that have been moved to the cold store, oldest first.
+func (t *Tiering) Archives() []ColdArchive {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.archives)
+}
+
+// Locate finds the archives that were moved from the given path within the primary
+// directory, e.g. "web01/app.log.3.gz", most recently moved first.
+func (t *Tiering) Locate(name string) []ColdArchive {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var found []ColdArchive
+	for _, a := range slices.Backward(t.archives) {
+		if a.Name == name {
+			found = append(found, a)
+		}
+	}
+	return found
+}
+
+// Open fetches an archive from the cold store, given its cold name.
+func (t *Tiering) Open(ctx context.Context, coldName string) (io.ReadCloser, error) {
+	return t.store.Open(ctx, coldName)
+}
@@ -0,0 +1,124 @@
+package syslog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestTiering(t *testing.T) {
+	dir, cold := t.TempDir(), t.TempDir()
+	old := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for name, mtime := range map[string]time.Time{
+		"web01/app.log.2.gz": old,
+		"web01/app.log.1.gz": time.Now(),
+		"web01/app.log":      old,
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		expect.Error(os.MkdirAll(filepath.Dir(p), 0750)).ToBeNil(t)
+		expect.Error(os.WriteFile(p, []byte(name), 0640)).ToBeNil(t)
+		expect.Error(os.Chtimes(p, mtime, mtime)).ToBeNil(t)
+	}
+
+	tg, err := NewTiering(dir, DirColdStore(cold), 24*time.Hour)
+	expect.Error(err).ToBeNil(t)
+	expect.Error(tg.Run(context.Background())).ToBeNil(t)
+
+	_, err = os.Stat(filepath.Join(dir, "web01", "app.log.2.gz"))
+	expect.Bool(os.IsNotExist(err)).ToBeTrue(t)
+	_, err = os.Stat(filepath.Join(dir, "web01", "app.log.1.gz"))
+	expect.Error(err).ToBeNil(t)
+	_, err = os.Stat(filepath.Join(dir, "web01", "app.log"))
+	expect.Error(err).ToBeNil(t)
+
+	// a new job finds the archive through the index
+	tg, err = NewTiering(dir, DirColdStore(cold), 24*time.Hour)
+	expect.Error(err).ToBeNil(t)
+	found := tg.Locate("web01/app.log.2.gz")
+	expect.Slice(found).ToHaveLength(t, 1)
+	expect.String(found[0].ColdName).ToBe(t, "web01/20240301T120000Z_app.log.2.gz")
+	expect.Number(found[0].Size).ToBe(t, 18)
+
+	rc, err := tg.Open(context.Background(), found[0].ColdName)
+	expect.Error(err).ToBeNil(t)
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	expect.String(string(content), err).ToBe(t, "web01/app.log.2.gz")
+	expect.Slice(tg.Archives()).ToHaveLength(t, 1)
+}
+
+// rotatingStore simulates log rotation renaming a newer archive to the name of the one
+// being uploaded.
+type rotatingStore struct {
+	DirColdStore
+	path string
+}
+
+func (s rotatingStore) Put(ctx context.Context, name string, r io.Reader) error {
+	if err := os.WriteFile(s.path, []byte("newer"), 0640); err != nil {
+		return err
+	}
+	return s.DirColdStore.Put(ctx, name, r)
+}
+
+func TestTiering_rotated(t *testing.T) {
+	dir, cold := t.TempDir(), t.TempDir()
+	p := filepath.Join(dir, "app.log.1.gz")
+	old := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	expect.Error(os.WriteFile(p, []byte("older"), 0640)).ToBeNil(t)
+	expect.Error(os.Chtimes(p, old, old)).ToBeNil(t)
+
+	tg, err := NewTiering(dir, rotatingStore{DirColdStore(cold), p}, 24*time.Hour)
+	expect.Error(err).ToBeNil(t)
+	expect.Error(tg.Run(context.Background())).ToBeNil(t)
+
+	// the newer archive is kept; the older one is moved
+	content, err := os.ReadFile(p)
+	expect.String(string(content), err).ToBe(t, "newer")
+	expect.Slice(tg.Archives()).ToHaveLength(t, 1)
+	content, err = os.ReadFile(filepath.Join(cold, "20240301T120000Z_app.log.1.gz"))
+	expect.String(string(content), err).ToBe(t, "older")
+}
+
+func TestTiering_staged(t *testing.T) {
+	dir, cold := t.TempDir(), t.TempDir()
+	p := filepath.Join(dir, "app.log.1.gz")
+	old := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	expect.Error(os.WriteFile(p+stagingSuffix, []byte("older"), 0640)).ToBeNil(t)
+	expect.Error(os.Chtimes(p+stagingSuffix, old, old)).ToBeNil(t)
+	expect.Error(os.WriteFile(p, []byte("newer"), 0640)).ToBeNil(t)
+
+	tg, err := NewTiering(dir, DirColdStore(cold), 24*time.Hour)
+	expect.Error(err).ToBeNil(t)
+	expect.Error(tg.Run(context.Background())).ToBeNil(t)
+
+	// an archive left staged by an earlier run is moved, and is not overwritten
+	_, err = os.Stat(p + stagingSuffix)
+	expect.Bool(os.IsNotExist(err)).ToBeTrue(t)
+	content, err := os.ReadFile(p)
+	expect.String(string(content), err).ToBe(t, "newer")
+	found := tg.Locate("app.log.1.gz")
+	expect.Slice(found).ToHaveLength(t, 1)
+	expect.String(found[0].ColdName).ToBe(t, "20240301T120000Z_app.log.1.gz")
+}
+
+func TestDirColdStore_notLocal(t *testing.T) {
+	parent := t.TempDir()
+	expect.Error(os.WriteFile(filepath.Join(parent, "secret"), []byte("x"), 0640)).ToBeNil(t)
+	store := DirColdStore(filepath.Join(parent, "cold"))
+
+	for _, name := range []string{"../secret", "/etc/passwd", "a/../../secret", ""} {
+		_, err := store.Open(context.Background(), name)
+		expect.Bool(errors.Is(err, fs.ErrInvalid)).ToBeTrue(t)
+		err = store.Put(context.Background(), name, strings.NewReader("y"))
+		expect.Bool(errors.Is(err, fs.ErrInvalid)).ToBeTrue(t)
+	}
+}
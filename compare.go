@@ -0,0 +1,68 @@
+package syslog
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Canonical returns a copy of the message with the receive metadata (Time, Source, Sequence,
+// Size, TLSPeer and Raw) cleared, NILVALUE ("-") fields made blank and the timestamp in UTC,
+// so that messages that differ only in how or when they were received compare as equal.
+// An unparsed message is parsed first (see [WithLazyParsing]); if that fails, it is returned
+// as it is, apart from the receive metadata.
+func (m *Message) Canonical() *Message {
+	c := m.Clone()
+	c.Parse()
+	c.Time = time.Time{}
+	c.Source = nil
+	c.Sequence = 0
+	c.Size = 0
+	c.TLSPeer = nil
+	c.Raw = nil
+	c.Timestamp = c.Timestamp.UTC()
+	for _, f := range []*string{&c.Hostname, &c.Application, &c.ProcID, &c.MsgID, &c.Data} {
+		if *f == "-" {
+			*f = ""
+		}
+	}
+	if len(c.Annotations) == 0 {
+		c.Annotations = nil
+	}
+	return c
+}
+
+// DiffMessages compares the canonical forms (see [Message.Canonical]) of two messages and
+// describes each field that differs, e.g. `Hostname: "a" != "b"`, in field order. The result
+// is empty if the messages are equivalent. It is intended for tests of parsers and
+// formatters.
+func DiffMessages(want, got *Message) []string {
+	w, g := want.Canonical(), got.Canonical()
+
+	var diffs []string
+	diff := func(field string, w, g any) {
+		if w != g {
+			diffs = append(diffs, fmt.Sprintf("%s: %#v != %#v", field, w, g))
+		}
+	}
+	diff("Facility", w.Facility.String(), g.Facility.String())
+	diff("Severity", w.Severity.String(), g.Severity.String())
+	diff("Version", w.Version, g.Version)
+	if !w.Timestamp.Equal(g.Timestamp) {
+		diff("Timestamp", w.Timestamp.Format(time.RFC3339Nano), g.Timestamp.Format(time.RFC3339Nano))
+	}
+	diff("Hostname", w.Hostname, g.Hostname)
+	diff("Application", w.Application, g.Application)
+	diff("ProcID", w.ProcID, g.ProcID)
+	diff("MsgID", w.MsgID, g.MsgID)
+	diff("Data", w.Data, g.Data)
+	diff("Content", w.Content, g.Content)
+
+	keys := slices.AppendSeq(slices.Collect(maps.Keys(w.Annotations)), maps.Keys(g.Annotations))
+	slices.Sort(keys)
+	for _, k := range slices.Compact(keys) {
+		diff("Annotations["+k+"]", w.Annotations[k], g.Annotations[k])
+	}
+	return diffs
+}
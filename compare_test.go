@@ -0,0 +1,36 @@
+package syslog
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestCanonical(t *testing.T) {
+	m := &Message{
+		Time:      time.Now(),
+		Source:    &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1)},
+		Size:      40,
+		Timestamp: time.Date(2024, 3, 1, 13, 0, 0, 0, time.FixedZone("CET", 3600)),
+		Hostname:  "host",
+		ProcID:    "-",
+		Data:      "-",
+		Content:   "content",
+	}
+	c := m.Canonical()
+	expect.Any(c).ToBe(t, &Message{
+		Timestamp: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:  "host",
+		Content:   "content",
+	})
+	expect.String(m.ProcID).ToBe(t, "-") // unchanged
+
+	other := m.Clone()
+	other.Annotate("k", "v")
+	other.Severity = Err
+	expect.Slice(DiffMessages(m, other)).ToBe(t,
+		`Severity: "emerg" != "err"`,
+		`Annotations[k]: "" != "v"`)
+}
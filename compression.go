@@ -0,0 +1,104 @@
+package syslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math"
+)
+
+// CompressionPolicy chooses the gzip level (see [compress/gzip]) for data to be compressed,
+// given a sample of it; [gzip.NoCompression] stores the data without compressing it, which
+// saves the CPU time that would be wasted on data that cannot be compressed. See
+// [FileHandler.SetCompression] and [ParquetHandler.SetCompression].
+type CompressionPolicy func(sample []byte) int
+
+// FixedCompression is a [CompressionPolicy] that always uses the same level.
+func FixedCompression(level int) CompressionPolicy {
+	return func([]byte) int { return level }
+}
+
+// AdaptiveCompression is a [CompressionPolicy] that judges the data by the entropy of the
+// bytes in the sample. Data that is already compressed or encrypted (near 8 bits per byte),
+// or that starts with the signature of a compressed format, is stored; base64 and similar
+// encodings of binary data (about 6 bits per byte) are compressed at [gzip.BestSpeed], for
+// which they gain almost as much as at higher levels; text is compressed at level 5, which
+// is both quite good and quite fast.
+func AdaptiveCompression(sample []byte) int {
+	switch h := entropy(sample); {
+	case len(sample) == 0:
+		return defaultCompressionLevel
+	case h >= 7.5 || isCompressed(sample):
+		return gzip.NoCompression
+	case h >= 5.5:
+		return gzip.BestSpeed
+	default:
+		return defaultCompressionLevel
+	}
+}
+
+// defaultCompressionLevel is used when there is no policy.
+const defaultCompressionLevel = 5
+
+// entropy estimates the Shannon entropy of data, in bits per byte.
+func entropy(data []byte) float64 {
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	h := 0.0
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(len(data))
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// compressedSignatures are the starts of gzip, zstd, xz, bzip2 and zip data.
+var compressedSignatures = [][]byte{
+	{0x1f, 0x8b}, {0x28, 0xb5, 0x2f, 0xfd}, {0xfd, '7', 'z', 'X', 'Z'}, []byte("BZh"), []byte("PK\x03\x04"),
+}
+
+func isCompressed(data []byte) bool {
+	for _, sig := range compressedSignatures {
+		if bytes.HasPrefix(data, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressionSample is the amount of a file that is sampled, in compressionSamples pieces
+// spread through it.
+const (
+	compressionSample  = 64 * 1024
+	compressionSamples = 4
+)
+
+// sampleData reads a sample of data of the given size, in pieces spread through it.
+func sampleData(r io.ReaderAt, size int64) []byte {
+	if size <= compressionSample {
+		sample := make([]byte, size)
+		n, _ := r.ReadAt(sample, 0)
+		return sample[:n]
+	}
+
+	piece := int64(compressionSample / compressionSamples)
+	sample := make([]byte, 0, compressionSample)
+	for i := range int64(compressionSamples) {
+		buf := make([]byte, piece)
+		n, _ := r.ReadAt(buf, i*(size-piece)/(compressionSamples-1))
+		sample = append(sample, buf[:n]...)
+	}
+	return sample
+}
+
+// compressionLevel applies a policy, which may be nil, to a sample of data.
+func compressionLevel(policy CompressionPolicy, r io.ReaderAt, size int64) int {
+	if policy == nil {
+		return defaultCompressionLevel
+	}
+	return policy(sampleData(r, size))
+}
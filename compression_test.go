@@ -0,0 +1,69 @@
+package syslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func randomBytes(n int) []byte {
+	r := rand.New(rand.NewPCG(1, 2))
+	bs := make([]byte, n)
+	for i := range bs {
+		bs[i] = byte(r.Uint32())
+	}
+	return bs
+}
+
+func TestAdaptiveCompression(t *testing.T) {
+	text := []byte(strings.Repeat("<34>1 2024-03-01T12:00:00Z host app 123 - - user logged in\n", 100))
+	random := randomBytes(16 * 1024)
+	b64 := []byte(base64.StdEncoding.EncodeToString(random))
+
+	expect.Number(AdaptiveCompression(text)).ToBe(t, 5)
+	expect.Number(AdaptiveCompression(b64)).ToBe(t, gzip.BestSpeed)
+	expect.Number(AdaptiveCompression(random)).ToBe(t, gzip.NoCompression)
+	expect.Number(AdaptiveCompression([]byte("\x1f\x8b\x08 short"))).ToBe(t, gzip.NoCompression)
+	expect.Number(AdaptiveCompression(nil)).ToBe(t, 5)
+	expect.Number(FixedCompression(9)(random)).ToBe(t, 9)
+}
+
+func TestSampleData(t *testing.T) {
+	data := bytes.Repeat([]byte("abcdefgh"), 100_000)
+	sample := sampleData(bytes.NewReader(data), int64(len(data)))
+	expect.Number(len(sample)).ToBe(t, compressionSample)
+
+	sample = sampleData(bytes.NewReader(data[:100]), 100)
+	expect.Slice(sample).ToBe(t, data[:100]...)
+}
+
+func TestFileHandler_SetCompression(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "binary.log")
+	random := randomBytes(100_000)
+	expect.Error(os.WriteFile(filename+tmp, random, 0644)).ToBeNil(t)
+
+	h := NewFileHandler(filename, RFCFormat)
+	h.SetRotate(1)
+	h.SetCompression(AdaptiveCompression)
+	h.logRotate(filename)
+
+	f, err := os.Open(filename + ".1.gz")
+	expect.Error(err).ToBeNil(t)
+	defer f.Close()
+	fi, _ := f.Stat()
+	expect.Bool(fi.Size() > int64(len(random))).Info(fi.Size()).ToBeTrue(t) // stored, not compressed
+
+	gz, err := gzip.NewReader(f)
+	expect.Error(err).ToBeNil(t)
+	bs, err := io.ReadAll(gz)
+	expect.Error(err).ToBeNil(t)
+	expect.Bool(bytes.Equal(bs, random)).ToBeTrue(t)
+}
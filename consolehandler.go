@@ -0,0 +1,101 @@
+package syslog
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// ConsoleHandler is a [Handler] that writes messages to the system console, /dev/console,
+// matching the traditional "/dev/console" target of syslog.conf. This gives visibility of
+// critical messages on servers with serial consoles. By default, only [Crit], [Alert] and
+// [Emerg] messages are written; see [ConsoleHandler.SetFilter].
+//
+// Control characters from the message, such as escape sequences, are written as visible
+// escapes (e.g. \x1b) so that senders cannot control the console.
+//
+// The console is written without blocking: if it is busy, messages are dropped and
+// counted (see [ConsoleHandler.Dropped]). All messages are passed on to subsequent handlers.
+// It is safe for concurrent use.
+type ConsoleHandler struct {
+	acceptFunc Filter
+	format     string
+	path       string
+	mu         sync.Mutex // guards f and buf
+	f          *os.File
+	buf        []byte
+	dropped    atomic.Uint64
+}
+
+// NewConsoleHandler creates a handler that writes messages to /dev/console in the
+// specified format, e.g. [RFCFormat].
+func NewConsoleHandler(format string) *ConsoleHandler {
+	return &ConsoleHandler{
+		acceptFunc: Severities{Emerg, Alert, Crit}.Filter(),
+		format:     format,
+		path:       "/dev/console",
+	}
+}
+
+// SetFilter changes the function used to decide which messages are written.
+func (h *ConsoleHandler) SetFilter(acceptFunc Filter) {
+	h.acceptFunc = acceptFunc
+}
+
+// Dropped returns the number of messages dropped because the console was busy or
+// could not be opened.
+func (h *ConsoleHandler) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+func (h *ConsoleHandler) Handle(m *Message) *Message {
+	if m == nil {
+		h.close()
+	} else if h.acceptFunc(m) {
+		h.write(m)
+	}
+	return m
+}
+
+func (h *ConsoleHandler) write(m *Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.f == nil {
+		f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|oNonBlock|oNoCTTY, 0)
+		if err != nil {
+			h.dropped.Add(1)
+			return
+		}
+		h.f = f
+	}
+
+	h.buf = append(appendPrintable(h.buf[:0], m.Format(h.format)), '\n')
+	if _, err := writeNonBlocking(h.f, h.buf); err != nil {
+		h.dropped.Add(1)
+		if !wouldBlock(err) {
+			checkErr(h.closeFile(), "close", h.path) // re-open on the next message
+		}
+	}
+}
+
+// Close closes the console; it is re-opened if another message is handled. It is safe to
+// call more than once.
+func (h *ConsoleHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closeFile()
+}
+
+func (h *ConsoleHandler) closeFile() error {
+	if h.f == nil {
+		return nil
+	}
+	err := h.f.Close()
+	h.f = nil
+	return err
+}
+
+func (h *ConsoleHandler) close() {
+	checkErr(h.Close(), "close", h.path)
+}
@@ -0,0 +1,26 @@
+package syslog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestConsoleHandler(t *testing.T) {
+	h := NewConsoleHandler("%S %C")
+	h.path = filepath.Join(t.TempDir(), "console")
+
+	h.Handle(&Message{Severity: Crit, Content: "before"})
+	expect.Number(h.Dropped()).ToBe(t, 1) // console does not exist yet
+
+	expect.Error(os.WriteFile(h.path, nil, 0600)).ToBeNil(t)
+	h.Handle(&Message{Severity: Info, Content: "ignored"})
+	h.Handle(&Message{Severity: Emerg, Content: "shown\x1b[2J"})
+	h.Handle(nil)
+
+	bs, err := os.ReadFile(h.path)
+	expect.String(string(bs), err).ToBe(t, "emerg shown\\x1b[2J\n")
+	expect.Number(h.Dropped()).ToBe(t, 1)
+}
@@ -0,0 +1,105 @@
+package syslog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Encoder renders messages for a [FileHandler] in place of its format string; see
+// [FileHandler.SetEncoder]. A [*Template] is an Encoder, as is a [*CSVEncoder]. If an Encoder
+// also has a method Header() []byte, the header is written at the start of each new file.
+// The binary encoders, [*AvroEncoder] and [*ProtobufEncoder], render one message at a time
+// for message-oriented outputs such as Kafka or gRPC streams.
+type Encoder interface {
+	AppendFormat(bs []byte, m *Message) ([]byte, error)
+}
+
+// DefaultCSVColumns are the columns used by [NewCSVEncoder] and [NewTSVEncoder] if none are
+// given.
+var DefaultCSVColumns = []string{"Timestamp", "Hostname", "Application", "ProcID", "Facility", "Severity", "MsgID", "Content"}
+
+// CSVEncoder is an [Encoder] that renders each message as a row of comma-separated values
+// (RFC 4180) or tab-separated values, for loading into spreadsheets and data warehouses. The
+// columns are named like the fields available to a [Template], i.e. Priority, Facility,
+// Severity, Version, Timestamp, Time, Hostname, Application, ProcID, MsgID, Data, Content,
+// Source, Sequence and Size; "Annotations.key" gives the annotation with that key. The header
+// row lists the column names. A CSVEncoder is safe for concurrent use.
+//
+// CSV fields are quoted when they contain a comma, quote, CR or LF. TSV fields cannot be
+// quoted, so backslash, tab, CR and LF are escaped as \\, \t, \r and \n, the convention
+// used by most databases.
+type CSVEncoder struct {
+	columns []string
+	tsv     bool
+}
+
+// NewCSVEncoder creates an encoder for comma-separated values with the given columns, or
+// [DefaultCSVColumns]. An error is returned for unknown column names.
+func NewCSVEncoder(columns ...string) (*CSVEncoder, error) {
+	return newCSVEncoder(columns, false)
+}
+
+// NewTSVEncoder creates an encoder for tab-separated values with the given columns, or
+// [DefaultCSVColumns]. An error is returned for unknown column names.
+func NewTSVEncoder(columns ...string) (*CSVEncoder, error) {
+	return newCSVEncoder(columns, true)
+}
+
+func newCSVEncoder(columns []string, tsv bool) (*CSVEncoder, error) {
+	if len(columns) == 0 {
+		columns = DefaultCSVColumns
+	}
+
+	fields := templateData(&Message{})
+	for _, c := range columns {
+		_, isField := fields[c]
+		if c == "Annotations" || !isField && !strings.HasPrefix(c, "Annotations.") {
+			return nil, fmt.Errorf("%s: unknown column", c)
+		}
+	}
+	return &CSVEncoder{columns: columns, tsv: tsv}, nil
+}
+
+// Header returns the header row, without a line ending.
+func (e *CSVEncoder) Header() []byte {
+	return e.appendRow(nil, e.columns)
+}
+
+// AppendFormat appends the row for a message, without a line ending, to bs.
+func (e *CSVEncoder) AppendFormat(bs []byte, m *Message) ([]byte, error) {
+	fields := templateData(m)
+	row := make([]string, len(e.columns))
+	for i, c := range e.columns {
+		if key, found := strings.CutPrefix(c, "Annotations."); found {
+			row[i] = m.Annotations[key]
+		} else {
+			row[i] = fmt.Sprint(fields[c])
+		}
+	}
+	return e.appendRow(bs, row), nil
+}
+
+func (e *CSVEncoder) appendRow(bs []byte, row []string) []byte {
+	for i, v := range row {
+		switch {
+		case i > 0 && e.tsv:
+			bs = append(bs, '\t')
+		case i > 0:
+			bs = append(bs, ',')
+		}
+
+		switch {
+		case e.tsv:
+			bs = append(bs, tsvEscaper.Replace(v)...)
+		case strings.ContainsAny(v, ",\"\r\n"):
+			bs = append(bs, '"')
+			bs = append(bs, strings.ReplaceAll(v, `"`, `""`)...)
+			bs = append(bs, '"')
+		default:
+			bs = append(bs, v...)
+		}
+	}
+	return bs
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\r", `\r`, "\n", `\n`)
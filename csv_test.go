@@ -0,0 +1,61 @@
+package syslog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestCSVEncoder(t *testing.T) {
+	m := &Message{
+		Facility:    Daemon,
+		Severity:    Err,
+		Timestamp:   time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:    "host",
+		Application: "app",
+		Content:     "say \"hi\", then\nleave\t\\",
+	}
+	m.Annotate("tenant", "acme")
+
+	csv, err := NewCSVEncoder("Timestamp", "Severity", "Priority", "Annotations.tenant", "Content")
+	expect.Error(err).ToBeNil(t)
+	expect.String(string(csv.Header())).ToBe(t, "Timestamp,Severity,Priority,Annotations.tenant,Content")
+	row, err := csv.AppendFormat(nil, m)
+	expect.Error(err).ToBeNil(t)
+	expect.String(string(row)).ToBe(t, "2024-03-01T12:00:00Z,err,27,acme,\"say \"\"hi\"\", then\nleave\t\\\"")
+
+	tsv, err := NewTSVEncoder()
+	expect.Error(err).ToBeNil(t)
+	expect.String(string(tsv.Header())).ToBe(t, "Timestamp\tHostname\tApplication\tProcID\tFacility\tSeverity\tMsgID\tContent")
+	row, err = tsv.AppendFormat(nil, m)
+	expect.Error(err).ToBeNil(t)
+	expect.String(string(row)).ToBe(t, "2024-03-01T12:00:00Z\thost\tapp\t\tdaemon\terr\t\tsay \"hi\", then\\nleave\\t\\\\")
+
+	_, err = NewCSVEncoder("Nonsense")
+	expect.Error(err).ToContain(t, "unknown column")
+	_, err = NewCSVEncoder("Annotations")
+	expect.Error(err).ToContain(t, "unknown column")
+}
+
+func TestFileHandler_encoder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.csv")
+	csv, err := NewCSVEncoder("Hostname", "Content")
+	expect.Error(err).ToBeNil(t)
+
+	h := NewFileHandler(path, RFCFormat)
+	h.SetEncoder(csv)
+	h.Handle(&Message{Hostname: "a", Content: "one"})
+	h.Handle(&Message{Hostname: "b", Content: "two"})
+	expect.Error(h.Close()).ToBeNil(t)
+
+	// the header is not repeated when the file is appended
+	h.Handle(&Message{Hostname: "c", Content: "three"})
+	expect.Error(h.Close()).ToBeNil(t)
+
+	bs, err := os.ReadFile(path)
+	expect.Error(err).ToBeNil(t)
+	expect.String(string(bs)).ToBe(t, "Hostname,Content\na,one\nb,two\nc,three\n")
+}
@@ -0,0 +1,243 @@
+package syslog
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DeviceAnnotationPrefix begins the annotation keys added by [DeviceRegistry]; each column
+// of the registry gives an annotation such as "device-site".
+const DeviceAnnotationPrefix = "device-"
+
+// DeviceColumn is the column of a device registry that identifies each device, by its
+// hostname or IP address.
+const DeviceColumn = "device"
+
+// registryTimeout limits each fetch of a registry over HTTP.
+const registryTimeout = 30 * time.Second
+
+// DeviceProfile is the metadata about one device in a [DeviceRegistry], such as the
+// expected facility, its dialect, its site and its owner, in the order of the columns.
+// Blank values are omitted.
+type DeviceProfile []SDParam
+
+// Get finds an attribute by name.
+func (p DeviceProfile) Get(name string) (string, bool) {
+	return SDElement{Params: p}.Param(name)
+}
+
+// DeviceRegistry is a [Handler] that enriches each message with inventory data about the
+// device that sent it, so that the data can drive routing (e.g. with [RouteHandler]) and
+// enrichment downstream. The registry is a CSV file, or a CSV document fetched from an
+// HTTP(S) URL, whose header names the columns, for example
+//
+//	device,facility,dialect,site,owner
+//	router1,local7,cisco,lon1,netops
+//	10.1.2.3,local4,,lon1,security
+//
+// The device column holds a hostname (matched without regard to case) or an IP address;
+// a message is matched by its hostname, or failing that by its source address. The other
+// columns name the attributes, which must be valid SD parameter names. Each non-blank
+// attribute of the device is added as an annotation with [DeviceAnnotationPrefix], such as
+// "device-site", and, if SDID is not blank, as a structured data element such as
+// [SDID facility="local7" dialect="cisco" site="lon1" owner="netops"].
+//
+// The registry is read again by [DeviceRegistry.Reload], or periodically if
+// [DeviceRegistry.Watch] is used; if it is not valid, the previous profiles are kept.
+// A DeviceRegistry is safe for concurrent use.
+type DeviceRegistry struct {
+	// SDID is the SD-ID of the element added to the structured data, which should have the
+	// form name@enterprise-number. If blank, the metadata are only annotations. It must be
+	// set before the registry is used.
+	SDID string
+
+	source   string
+	profiles atomic.Pointer[map[string]DeviceProfile]
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// LoadDeviceRegistry loads a registry of devices from a CSV file, or from an http:// or
+// https:// URL.
+func LoadDeviceRegistry(ctx context.Context, source string) (*DeviceRegistry, error) {
+	r := &DeviceRegistry{source: source}
+	if err := r.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the registry again. If it is not valid, the previous profiles are kept and
+// the error is returned.
+func (r *DeviceRegistry) Reload(ctx context.Context) error {
+	rc, err := r.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	profiles, err := readDeviceProfiles(rc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", r.source, err)
+	}
+	r.profiles.Store(&profiles)
+	return nil
+}
+
+func (r *DeviceRegistry) open(ctx context.Context) (io.ReadCloser, error) {
+	if !strings.HasPrefix(r.source, "http://") && !strings.HasPrefix(r.source, "https://") {
+		return os.Open(r.source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/csv")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", r.source, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// readDeviceProfiles reads CSV, keyed by lower-case hostname or canonical IP address.
+func readDeviceProfiles(rd io.Reader) (map[string]DeviceProfile, error) {
+	cr := csv.NewReader(rd)
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	key := -1
+	for i, col := range header {
+		header[i] = strings.TrimSpace(col)
+		switch {
+		case header[i] == DeviceColumn:
+			key = i
+		case !validSDName(header[i]):
+			return nil, fmt.Errorf("%q: invalid column name", col)
+		}
+	}
+	if key < 0 {
+		return nil, fmt.Errorf("no %q column", DeviceColumn)
+	}
+
+	profiles := make(map[string]DeviceProfile)
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return profiles, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var p DeviceProfile
+		for i, v := range row {
+			if v = strings.TrimSpace(v); i != key && v != "" {
+				p = append(p, SDParam{header[i], v})
+			}
+		}
+		if device := deviceKey(row[key]); device != "" {
+			profiles[device] = p
+		}
+	}
+}
+
+func deviceKey(device string) string {
+	device = strings.TrimSpace(device)
+	if ip, err := netip.ParseAddr(device); err == nil {
+		return ip.Unmap().String()
+	}
+	return strings.ToLower(device)
+}
+
+// Lookup finds the profile of a device, given its hostname or IP address.
+func (r *DeviceRegistry) Lookup(device string) (DeviceProfile, bool) {
+	p, ok := (*r.profiles.Load())[deviceKey(device)]
+	return p, ok
+}
+
+// Len gets the number of devices in the registry.
+func (r *DeviceRegistry) Len() int {
+	return len(*r.profiles.Load())
+}
+
+func (r *DeviceRegistry) Handle(m *Message) *Message {
+	if m == nil || !m.IsParsed() {
+		return m
+	}
+
+	p, ok := r.Lookup(m.Hostname)
+	if !ok {
+		ip, known := senderIP(m.Source)
+		if !known {
+			return m
+		}
+		if p, ok = r.Lookup(ip.String()); !ok {
+			return m
+		}
+	}
+
+	var params []string
+	for _, a := range p {
+		m.Annotate(DeviceAnnotationPrefix+a.Name, a.Value)
+		params = append(params, a.Name, a.Value)
+	}
+	if r.SDID != "" && len(params) > 0 {
+		m.addSDElement(r.SDID, params...)
+	}
+	return m
+}
+
+// Watch starts a goroutine that reloads the registry at regular intervals, so that
+// changes to the inventory take effect. Errors are logged and the previous profiles are
+// kept. Watch should be called at most once; the goroutine runs until [DeviceRegistry.Close]
+// is called.
+func (r *DeviceRegistry) Watch(interval time.Duration) {
+	r.stop = make(chan struct{})
+	go r.watch(interval, r.stop)
+}
+
+func (r *DeviceRegistry) watch(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), min(interval, registryTimeout))
+			checkErr(r.Reload(ctx), "reload", r.source)
+			cancel()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Close stops watching the registry; it implements [io.Closer].
+func (r *DeviceRegistry) Close() error {
+	r.once.Do(func() {
+		if r.stop != nil {
+			close(r.stop)
+		}
+	})
+	return nil
+}
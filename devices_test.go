@@ -0,0 +1,78 @@
+package syslog
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+const testRegistry = `device,facility,dialect,site,owner
+# core routers
+Router1,local7,cisco,lon1,netops
+10.1.2.3,local4,,lon1,security
+`
+
+func TestDeviceRegistry_file(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "devices.csv")
+	expect.Error(os.WriteFile(file, []byte(testRegistry), 0640)).ToBeNil(t)
+
+	r, err := LoadDeviceRegistry(context.Background(), file)
+	expect.Error(err).ToBeNil(t)
+	r.SDID = "device@32473"
+	expect.Number(r.Len()).ToBe(t, 2)
+
+	m, err := parseMessage([]byte("<189>Mar  1 12:00:00 router1 sshd: hello"))
+	expect.Error(err).ToBeNil(t)
+	m = r.Handle(m)
+	expect.String(m.Annotations["device-site"]).ToBe(t, "lon1")
+	expect.String(m.Annotations["device-dialect"]).ToBe(t, "cisco")
+	expect.String(m.Data).ToBe(t, `[device@32473 facility="local7" dialect="cisco" site="lon1" owner="netops"]`)
+
+	// matched by the source address
+	m, err = parseMessage([]byte("<165>Mar  1 12:00:00 fw2 kernel: drop"))
+	expect.Error(err).ToBeNil(t)
+	m.Source = &net.UDPAddr{IP: net.ParseIP("10.1.2.3"), Port: 514}
+	m = r.Handle(m)
+	expect.String(m.Annotations["device-owner"]).ToBe(t, "security")
+	_, found := m.Annotations["device-dialect"]
+	expect.Bool(found).ToBeFalse(t)
+
+	m, err = parseMessage([]byte("<165>Mar  1 12:00:00 other kernel: drop"))
+	expect.Error(err).ToBeNil(t)
+	m = r.Handle(m)
+	expect.Map(m.Annotations).ToHaveLength(t, 0)
+
+	// an invalid registry is rejected and the previous profiles are kept
+	expect.Error(os.WriteFile(file, []byte("host,site\nrouter1,lon1\n"), 0640)).ToBeNil(t)
+	expect.Error(r.Reload(context.Background())).ToContain(t, `no "device" column`)
+	p, found := r.Lookup("ROUTER1")
+	expect.Bool(found).ToBeTrue(t)
+	site, _ := p.Get("site")
+	expect.String(site).ToBe(t, "lon1")
+}
+
+func TestDeviceRegistry_http(t *testing.T) {
+	body := testRegistry
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	r, err := LoadDeviceRegistry(context.Background(), srv.URL)
+	expect.Error(err).ToBeNil(t)
+	expect.Number(r.Len()).ToBe(t, 2)
+
+	body = "device,site\nrouter1,par2\n"
+	expect.Error(r.Reload(context.Background())).ToBeNil(t)
+	expect.Number(r.Len()).ToBe(t, 1)
+	p, _ := r.Lookup("router1")
+	expect.Slice(p).ToBe(t, SDParam{"site", "par2"})
+	expect.Error(r.Close()).ToBeNil(t)
+}
@@ -0,0 +1,72 @@
+package syslog
+
+import (
+	"os"
+)
+
+// DevLog is the path of the socket to which local programs send syslog messages, e.g. using
+// syslog(3) from the C library.
+const DevLog = "/dev/log"
+
+// ListenDevLog makes the server a drop-in replacement for the local syslog daemon. It
+// receives datagrams on dgramPath, usually [DevLog]. If streamPath is not blank, it also
+// receives on a stream-mode socket there, for the few clients that do not use datagrams (a
+// path cannot be shared by both kinds of socket). Unless [WithSocketPermissions] is used, the
+// sockets can be written by all users, as usual for /dev/log.
+//
+// Local senders follow the conventions of the traditional syslogd, which are applied to each
+// message before accept sees it:
+//
+//   - the hostname is omitted, so the tag (e.g. "app[123]") is the first word and the
+//     hostname is set to that of the local host
+//   - messages claiming the kern facility are changed to user, because only the kernel
+//     may use it
+func (s *Server) ListenDevLog(dgramPath, streamPath string, accept Filter) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	local := localFilter(hostname, accept)
+	if err = s.ListenFilter(dgramPath, local); err != nil {
+		return err
+	}
+	if err = s.allowAllUsers(dgramPath); err != nil {
+		return err
+	}
+
+	if streamPath == "" {
+		return nil
+	}
+	if err = s.ListenUnix(streamPath, local); err != nil {
+		return err
+	}
+	return s.allowAllUsers(streamPath)
+}
+
+// allowAllUsers makes a socket writable by all users, unless the permissions have been set
+// explicitly.
+func (s *Server) allowAllUsers(path string) error {
+	if s.socketPermissions != nil {
+		return nil
+	}
+	return os.Chmod(path, 0666)
+}
+
+// localFilter normalises messages from local senders before passing them to accept.
+func localFilter(hostname string, accept Filter) Filter {
+	return func(m *Message) bool {
+		if m.Version == 0 && m.Application == "" && m.Hostname != "" {
+			// the first word was the tag, not the hostname
+			m.Application, m.ProcID = splitTag(m.Hostname)
+			m.Hostname = ""
+		}
+		if m.Hostname == "" || m.Hostname == "-" {
+			m.Hostname = hostname
+		}
+		if m.Facility == Kern {
+			m.Facility = User
+		}
+		return accept(m)
+	}
+}
@@ -0,0 +1,64 @@
+//go:build unix
+
+package syslog
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestServer_ListenDevLog(t *testing.T) {
+	dir := t.TempDir()
+	dgram, stream := filepath.Join(dir, "log"), filepath.Join(dir, "log.stream")
+	hostname, _ := os.Hostname()
+
+	received := make(chan *Message, 1)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+	expect.Error(s.ListenDevLog(dgram, stream, AcceptEverything)).ToBeNil(t)
+
+	fi, err := os.Stat(dgram)
+	expect.Error(err).ToBeNil(t)
+	expect.Number(fi.Mode().Perm()).ToBe(t, 0666)
+
+	c, err := net.Dial("unixgram", dgram)
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+
+	// as sent by syslog(3)
+	_, err = c.Write([]byte("<11>Oct 11 22:14:15 app[123]: hello"))
+	expect.Error(err).ToBeNil(t)
+	m := <-received
+	expect.String(m.Hostname).ToBe(t, hostname)
+	expect.String(m.Application).ToBe(t, "app")
+	expect.String(m.ProcID).ToBe(t, "123")
+	expect.String(m.Content).ToBe(t, ": hello")
+
+	// local programs cannot pretend to be the kernel
+	_, err = c.Write([]byte("<3>1 - - su - - - forged"))
+	expect.Error(err).ToBeNil(t)
+	m = <-received
+	expect.Any(m.Facility).ToBe(t, User)
+	expect.Any(m.Severity).ToBe(t, Err)
+	expect.String(m.Hostname).ToBe(t, hostname)
+
+	sc, err := net.Dial("unix", stream)
+	expect.Error(err).ToBeNil(t)
+	defer sc.Close()
+	_, err = fmt.Fprint(sc, "<13>Oct 11 22:14:15 myhost app: hi\n")
+	expect.Error(err).ToBeNil(t)
+	m = <-received
+	expect.String(m.Hostname).ToBe(t, "myhost")
+	expect.String(m.Application).ToBe(t, "app")
+}
@@ -0,0 +1,104 @@
+package syslog
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rickb777/iso8601/v3"
+)
+
+// dialect holds hints about how a sender formats its messages, learned from the messages
+// it has sent. The hints let the parser try the likely format first; they are corrected
+// whenever they turn out to be wrong. A nil *dialect gives no hints and learns nothing.
+type dialect uint8
+
+const (
+	dialectKnownTimestamp dialect = 1 << iota // the RFC5424 timestamp format has been learned
+	dialectRFC3339                            // RFC5424 timestamps are strictly RFC3339
+	dialectEnglish                            // RFC3164 timestamps have English month names
+)
+
+func (h *dialect) has(d dialect) bool {
+	return h != nil && *h&d != 0
+}
+
+func (h *dialect) set(d dialect) {
+	if h != nil {
+		*h |= d
+	}
+}
+
+func (h *dialect) clear(d dialect) {
+	if h != nil {
+		*h &^= d
+	}
+}
+
+// parseRFC5424Timestamp parses with the standard library's fast RFC3339 parser when the
+// sender is known to use it, otherwise with the more lenient ISO-8601 parser.
+func (h *dialect) parseRFC5424Timestamp(v string) (time.Time, error) {
+	if h.has(dialectRFC3339) || (h != nil && !h.has(dialectKnownTimestamp)) {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err == nil {
+			h.set(dialectKnownTimestamp | dialectRFC3339)
+			return t, nil
+		}
+		h.clear(dialectRFC3339)
+	}
+
+	t, err := iso8601.ParseString(v)
+	if err == nil {
+		h.set(dialectKnownTimestamp)
+	}
+	return t.Time, err
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// parserCache holds the dialect of each sender, keyed by IP address. It stops learning about
+// new senders when it is full.
+type parserCache struct {
+	hints sync.Map // netip.Addr -> dialect
+	size  atomic.Int64
+	max   int64
+}
+
+func newParserCache(size int) *parserCache {
+	return &parserCache{max: int64(size)}
+}
+
+// get returns the hints for a sender, or nil if the sender cannot be cached.
+func (c *parserCache) get(addr net.Addr) (netip.Addr, *dialect) {
+	ip, ok := senderIP(addr)
+	if !ok {
+		return ip, nil
+	}
+
+	var d dialect
+	if v, exists := c.hints.Load(ip); exists {
+		d = v.(dialect)
+	}
+	return ip, &d
+}
+
+func (c *parserCache) put(ip netip.Addr, d dialect) {
+	if _, loaded := c.hints.Swap(ip, d); !loaded {
+		if c.size.Add(1) > c.max {
+			c.hints.Delete(ip)
+			c.size.Add(-1)
+		}
+	}
+}
+
+func senderIP(addr net.Addr) (netip.Addr, bool) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return netip.AddrFromSlice(a.IP)
+	case *net.TCPAddr:
+		return netip.AddrFromSlice(a.IP)
+	}
+	return netip.Addr{}, false
+}
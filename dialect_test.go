@@ -0,0 +1,77 @@
+package syslog
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+	"github.com/rickb777/syslog/bench"
+)
+
+func TestParseMessageHinted(t *testing.T) {
+	t0 := time.Date(2023, 10, 26, 15, 31, 1, 0, time.UTC)
+
+	// hints never change the result
+	for _, pkt := range bench.All {
+		var h dialect
+		for i := 0; i < 3; i++ {
+			exp, err1 := parseMessageAt(pkt, t0)
+			act, err2 := parseMessageHinted(pkt, t0, &h)
+			expect.Bool(err1 == nil).Info(string(pkt)).ToBe(t, err2 == nil)
+			if err1 == nil {
+				expect.String(act.String()).Info(string(pkt)).ToBe(t, exp.String())
+			}
+		}
+	}
+
+	var h dialect
+	parseMessageHinted([]byte("<34>1 2023-10-26T15:30:00.123Z host app - - - x"), t0, &h)
+	expect.Bool(h.has(dialectKnownTimestamp | dialectRFC3339)).ToBeTrue(t)
+
+	// a wrong hint is corrected
+	m, err := parseMessageHinted([]byte("<34>1 20231026T153000Z host app - - - x"), t0, &h)
+	expect.Error(err).ToBeNil(t)
+	expect.Number(m.Timestamp.Hour()).ToBe(t, 15)
+	expect.Bool(h.has(dialectRFC3339)).ToBeFalse(t)
+
+	parseMessageHinted([]byte("<34>Oct 26 15:30:00 host app: x"), t0, &h)
+	expect.Bool(h.has(dialectEnglish)).ToBeTrue(t)
+}
+
+func TestParserCache(t *testing.T) {
+	c := newParserCache(1)
+	a1 := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 514}
+	a2 := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 514}
+
+	ip, h := c.get(a1)
+	expect.Bool(h != nil && *h == 0).ToBeTrue(t)
+	c.put(ip, dialectEnglish)
+
+	_, h = c.get(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234})
+	expect.Bool(h.has(dialectEnglish)).ToBeTrue(t)
+
+	// full
+	ip, _ = c.get(a2)
+	c.put(ip, dialectEnglish)
+	_, h = c.get(a2)
+	expect.Bool(h.has(dialectEnglish)).ToBeFalse(t)
+
+	_, h = c.get(&net.UnixAddr{Name: "/dev/log"})
+	expect.Bool(h == nil).ToBeTrue(t)
+}
+
+func BenchmarkParseMessage(b *testing.B) {
+	t0 := time.Now()
+	b.Run("plain", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			parseMessageAt(bench.RFC5424[i%len(bench.RFC5424)], t0)
+		}
+	})
+	b.Run("hinted", func(b *testing.B) {
+		var h dialect
+		for i := 0; i < b.N; i++ {
+			parseMessageHinted(bench.RFC5424[i%len(bench.RFC5424)], t0, &h)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package syslog
+
+import (
+	"encoding/json"
+	"expvar"
+	"maps"
+	"net/http"
+	"sync"
+)
+
+// maxDiscardValues limits the number of distinct key values counted by a [DiscardHandler];
+// the messages with any further values are counted under [DiscardOther].
+const maxDiscardValues = 1000
+
+// DiscardOther is the value under which a [DiscardHandler] counts messages once it has
+// counted 1000 distinct values of its key.
+const DiscardOther = "(other)"
+
+// DiscardStats describes the messages consumed by a [DiscardHandler].
+type DiscardStats struct {
+	Key      string            `json:"key"`
+	Total    uint64            `json:"total"`
+	Messages map[string]uint64 `json:"messages"` // the number of messages for each key value
+}
+
+// DiscardHandler is a [Handler] that consumes the messages matching its filter, so they
+// reach no subsequent handlers, whilst counting them by the value of a key such as the
+// application. This drops noisy sources without losing sight of how much is dropped. The
+// counts can be read using [DiscardHandler.Stats], published with [expvar] (see
+// [DiscardHandler.Publish]), or served as JSON because DiscardHandler is also an
+// [http.Handler]. Other messages are passed on. A DiscardHandler is safe for concurrent
+// use.
+type DiscardHandler struct {
+	acceptFunc Filter
+	key        TalkerKey
+
+	mu     sync.Mutex
+	total  uint64
+	counts map[string]uint64
+}
+
+// NewDiscardHandler creates a handler that discards the messages matching accept, counting
+// them by the value of key, e.g. [ApplicationKey].
+func NewDiscardHandler(accept Filter, key TalkerKey) *DiscardHandler {
+	return &DiscardHandler{
+		acceptFunc: accept,
+		key:        key,
+		counts:     make(map[string]uint64),
+	}
+}
+
+// Close does nothing; it implements [io.Closer].
+func (h *DiscardHandler) Close() error {
+	return nil
+}
+
+func (h *DiscardHandler) Handle(m *Message) *Message {
+	if m == nil || !h.acceptFunc(m) {
+		return m
+	}
+
+	v := h.key.Value(m)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.total++
+	if _, exists := h.counts[v]; !exists && len(h.counts) >= maxDiscardValues {
+		v = DiscardOther
+	}
+	h.counts[v]++
+	return nil
+}
+
+// Stats returns the number of messages discarded so far.
+func (h *DiscardHandler) Stats() DiscardStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return DiscardStats{Key: h.key.Name, Total: h.total, Messages: maps.Clone(h.counts)}
+}
+
+// Publish makes the statistics available as an [expvar] variable with the given name,
+// which must be unique.
+func (h *DiscardHandler) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return h.Stats() }))
+}
+
+// ServeHTTP writes the statistics as JSON.
+func (h *DiscardHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	checkErr(json.NewEncoder(w).Encode(h.Stats()), "write", "discard")
+}
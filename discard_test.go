@@ -0,0 +1,38 @@
+package syslog
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestDiscardHandler(t *testing.T) {
+	noisy := func(m *Message) bool { return m.Application != "keep" }
+	h := NewDiscardHandler(noisy, ApplicationKey)
+
+	for range 3 {
+		expect.Any(h.Handle(&Message{Application: "chatty"})).ToBeNil(t)
+	}
+	expect.Any(h.Handle(&Message{Hostname: "h"})).ToBeNil(t)
+	m := &Message{Application: "keep"}
+	expect.Any(h.Handle(m)).ToBe(t, m)
+
+	stats := h.Stats()
+	expect.String(stats.Key).ToBe(t, "application")
+	expect.Number(stats.Total).ToBe(t, 4)
+	expect.Map(stats.Messages).ToBe(t, map[string]uint64{"chatty": 3, "": 1})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/discarded", nil))
+	expect.String(w.Body.String()).ToBe(t, `{"key":"application","total":4,"messages":{"":1,"chatty":3}}`+"\n")
+
+	for i := range maxDiscardValues {
+		h.Handle(&Message{Application: fmt.Sprint("app", i)})
+	}
+	stats = h.Stats()
+	expect.Number(stats.Total).ToBe(t, 4+maxDiscardValues)
+	expect.Number(len(stats.Messages)).ToBe(t, maxDiscardValues+1)
+	expect.Number(stats.Messages[DiscardOther]).ToBe(t, 2)
+}
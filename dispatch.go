@@ -0,0 +1,188 @@
+package syslog
+
+import "time"
+
+// dispatcher passes batches of messages along the handler chain. Each handler sees the
+// whole batch before the next handler does; [BatchHandler]s receive it in one call.
+type dispatcher struct {
+	worker    int    // index of the worker goroutine that owns this dispatcher
+	goroutine uint64 // ID of that goroutine (see [goroutineID])
+	pending   []*Message
+	tracking  bool            // whether origin is maintained, for auditing or deadlines
+	timing    bool            // whether spent is maintained, for deadlines
+	origin    []int           // index in the batch of each pending message
+	reached   []int           // number of handlers that saw each message in the batch
+	spent     []time.Duration // time taken by the handlers on each message in the batch
+	expired   []bool          // whether each message in the batch exceeded the deadline
+}
+
+func (d *dispatcher) dispatch(s *Server, batch []*Message) {
+	s.inFlight.RLock()
+	defer s.inFlight.RUnlock()
+	chain := s.handlerChain()
+
+	d.pending = append(d.pending[:0], batch...)
+	d.timing = s.deadline > 0
+	d.tracking = s.audit != nil || d.timing
+	if d.tracking {
+		d.origin = d.origin[:0]
+		d.reached = d.reached[:0]
+		d.spent = d.spent[:0]
+		d.expired = d.expired[:0]
+		for i := range batch {
+			d.origin = append(d.origin, i)
+			d.reached = append(d.reached, 0)
+			d.spent = append(d.spent, 0)
+			d.expired = append(d.expired, false)
+		}
+	}
+
+	for i, h := range chain {
+		if d.timing && i > 0 {
+			d.expire(s)
+		}
+		if len(d.pending) == 0 {
+			break
+		}
+
+		if s.audit != nil {
+			for _, j := range d.origin {
+				d.reached[j] = i + 1
+			}
+		}
+
+		lh, lazy := h.Handler.(LazyHandler)
+		if !lazy {
+			d.parsePending(s)
+		}
+
+		s.watchdog.begin(d, h)
+		if bh, ok := h.Handler.(BatchHandler); ok {
+			d.handleBatch(bh)
+		} else if lazy {
+			d.handleEach(lh.HandleLazy)
+		} else {
+			d.handleEach(h.Handle)
+		}
+		s.watchdog.end(d.worker)
+	}
+
+	if s.audit != nil {
+		names := make([]string, len(chain))
+		for i, h := range chain {
+			names[i] = h.label()
+		}
+		for i, m := range batch {
+			verdict := verdictAccepted
+			if d.expired[i] {
+				verdict = verdictTimedOut
+			}
+			s.audit.record(m, verdict, names[:d.reached[i]])
+		}
+	}
+
+	clear(d.pending)
+}
+
+// expire removes the pending messages on which the handlers have spent longer than the
+// deadline, passing them to the dead-letter handler if there is one.
+func (d *dispatcher) expire(s *Server) {
+	kept := 0
+	for k, m := range d.pending {
+		j := d.origin[k]
+		if d.spent[j] <= s.deadline {
+			d.pending[kept] = m
+			d.origin[kept] = j
+			kept++
+			continue
+		}
+
+		d.expired[j] = true
+		s.timedOut.Add(1)
+		if s.deadLetter != nil {
+			s.deadLetter.Handle(m)
+		}
+	}
+	clear(d.pending[kept:])
+	d.pending = d.pending[:kept]
+	d.origin = d.origin[:kept]
+}
+
+func (d *dispatcher) handleEach(handle func(*Message) *Message) {
+	kept := 0
+	for k, m := range d.pending {
+		var started time.Time
+		if d.timing {
+			started = time.Now()
+		}
+
+		m = handle(m)
+
+		if d.timing {
+			d.spent[d.origin[k]] += time.Since(started)
+		}
+		if m != nil {
+			d.pending[kept] = m
+			if d.tracking {
+				d.origin[kept] = d.origin[k]
+			}
+			kept++
+		}
+	}
+	clear(d.pending[kept:])
+	d.pending = d.pending[:kept]
+	if d.tracking {
+		d.origin = d.origin[:kept]
+	}
+}
+
+// parsePending parses any messages that were received lazily, discarding those that
+// are invalid.
+func (d *dispatcher) parsePending(s *Server) {
+	d.handleEach(func(m *Message) *Message {
+		if err := m.Parse(); err != nil {
+			s.logger.Println(err.Error())
+			return nil
+		}
+		return m
+	})
+}
+
+// handleBatch passes all the pending messages to h. When timing, the time taken is shared
+// equally between the messages.
+func (d *dispatcher) handleBatch(h BatchHandler) {
+	in := d.pending
+	var before []*Message
+	if d.tracking {
+		before = append(before, in...)
+	}
+
+	var started time.Time
+	if d.timing {
+		started = time.Now()
+	}
+
+	out := h.HandleBatch(in)
+
+	if d.timing && len(before) > 0 {
+		share := time.Since(started) / time.Duration(len(before))
+		for _, j := range d.origin {
+			d.spent[j] += share
+		}
+	}
+
+	if d.tracking {
+		// follow the surviving messages by identity
+		origin := make([]int, 0, len(out))
+		for _, m := range out {
+			for k, b := range before {
+				if b == m {
+					origin = append(origin, d.origin[k])
+					break
+				}
+			}
+		}
+		d.origin = origin
+	}
+	d.pending = append(in[:0], out...) // keep our own backing array
+}
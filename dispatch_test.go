@@ -0,0 +1,98 @@
+package syslog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+// evenBatches keeps only the messages with even sequence numbers.
+type evenBatches struct {
+	sizes []int
+}
+
+func (h *evenBatches) Handle(m *Message) *Message { return m }
+
+func (h *evenBatches) HandleBatch(ms []*Message) []*Message {
+	h.sizes = append(h.sizes, len(ms))
+	kept := ms[:0]
+	for _, m := range ms {
+		if m.Sequence%2 == 0 {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+func TestDispatcher(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := &Server{audit: newAuditor(buf)}
+
+	var order []string
+	bh := &evenBatches{}
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		order = append(order, "a"+m.Content)
+		return m
+	}))
+	s.AddHandler(bh)
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		order = append(order, "b"+m.Content)
+		return nil
+	}))
+
+	batch := []*Message{
+		{Sequence: 1, Content: "1"},
+		{Sequence: 2, Content: "2"},
+		{Sequence: 3, Content: "3"},
+		{Sequence: 4, Content: "4"},
+	}
+	(&dispatcher{}).dispatch(s, batch)
+
+	expect.String(strings.Join(order, " ")).ToBe(t, "a1 a2 a3 a4 b2 b4")
+	expect.Number(len(bh.sizes)).ToBe(t, 1)
+	expect.Number(bh.sizes[0]).ToBe(t, 4)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expect.Number(len(lines)).ToBe(t, 4)
+	expect.String(lines[0]).ToContain(t, `"seq":1,"bytes":0,"verdict":"accepted","handlers":["syslog.handlerFunc","*syslog.evenBatches"]}`)
+	expect.String(lines[1]).ToContain(t, `"seq":2,"bytes":0,"verdict":"accepted","handlers":["syslog.handlerFunc","*syslog.evenBatches","syslog.handlerFunc"]}`)
+}
+
+func TestDispatcher_deadline(t *testing.T) {
+	buf := &bytes.Buffer{}
+	var dead, seen []string
+	s := &Server{
+		audit:    newAuditor(buf),
+		deadline: 5 * time.Millisecond,
+		deadLetter: handlerFunc(func(m *Message) *Message {
+			dead = append(dead, m.Content)
+			return nil
+		}),
+	}
+
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m.Content == "slow" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return m
+	}))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		seen = append(seen, m.Content)
+		return m
+	}))
+
+	batch := []*Message{{Content: "fast"}, {Content: "slow"}, {Content: "quick"}}
+	(&dispatcher{}).dispatch(s, batch)
+
+	expect.Slice(seen).ToBe(t, "fast", "quick")
+	expect.Slice(dead).ToBe(t, "slow")
+	expect.Number(s.TimedOut()).ToBe(t, 1)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expect.Number(len(lines)).ToBe(t, 3)
+	expect.String(lines[1]).ToContain(t, `"verdict":"timed-out","handlers":["syslog.handlerFunc"]}`)
+	expect.String(lines[2]).ToContain(t, `"verdict":"accepted","handlers":["syslog.handlerFunc","syslog.handlerFunc"]}`)
+}
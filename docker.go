@@ -0,0 +1,108 @@
+package syslog
+
+import (
+	"strings"
+)
+
+// Annotation keys for the container metadata found in Docker tags; see [DockerHandler].
+const (
+	ContainerIDAnnotation    = "container-id"
+	ContainerNameAnnotation  = "container-name"
+	ContainerImageAnnotation = "container-image"
+)
+
+// DockerHandler is a [Handler] that recognises the tags written by Docker's syslog logging
+// driver and splits out the container metadata. The driver puts the tag, by default the
+// short container ID, in the application field (and in the MsgID of RFC 5424 messages); it
+// is often configured to include the container and image names, e.g. with
+// --log-opt tag="{{.ImageName}}/{{.Name}}/{{.ID}}". Tags of the forms ID, NAME/ID,
+// IMAGE/NAME/ID and docker/NAME (with or without the ID) are recognised, where ID has 12 or
+// 64 hex digits and IMAGE may itself contain slashes.
+//
+// The container ID, name and image are recorded as annotations ([ContainerIDAnnotation],
+// [ContainerNameAnnotation] and [ContainerImageAnnotation]) and, if SDID is not blank, in
+// a structured data element such as [SDID id="..." name="..." image="..."]. The application
+// becomes the container name (or the ID if the name is unknown), and a MsgID copied from the
+// tag is removed. Other messages are unchanged.
+type DockerHandler struct {
+	// SDID is the SD-ID of the element added to the structured data, which should have the
+	// form name@enterprise-number. If blank, the metadata are only annotations.
+	SDID string
+}
+
+// Close does nothing; it implements [io.Closer].
+func (h DockerHandler) Close() error {
+	return nil
+}
+
+func (h DockerHandler) Handle(m *Message) *Message {
+	if m == nil || !m.IsParsed() {
+		return m
+	}
+
+	id, name, image, ok := parseDockerTag(m.Application)
+	if !ok {
+		return m
+	}
+
+	if m.MsgID == m.Application {
+		m.MsgID = ""
+	}
+	m.Application = ifBlank(name, id)
+
+	var params []string
+	for _, kv := range [][3]string{
+		{ContainerIDAnnotation, "id", id},
+		{ContainerNameAnnotation, "name", name},
+		{ContainerImageAnnotation, "image", image},
+	} {
+		if kv[2] != "" {
+			m.Annotate(kv[0], kv[2])
+			params = append(params, kv[1], kv[2])
+		}
+	}
+	if h.SDID != "" {
+		m.addSDElement(h.SDID, params...)
+	}
+	return m
+}
+
+// parseDockerTag splits a tag into its parts; ok is false if it is not a Docker tag.
+func parseDockerTag(tag string) (id, name, image string, ok bool) {
+	parts := strings.Split(tag, "/")
+	if last := parts[len(parts)-1]; isContainerID(last) {
+		id = last
+		parts = parts[:len(parts)-1]
+	}
+
+	docker := len(parts) > 0 && parts[0] == "docker"
+	if docker {
+		parts = parts[1:]
+	}
+	if id == "" && (!docker || len(parts) != 1) {
+		return "", "", "", false
+	}
+
+	switch len(parts) {
+	case 0:
+	case 1:
+		name = parts[0]
+	default:
+		name = parts[len(parts)-1]
+		image = strings.Join(parts[:len(parts)-1], "/")
+	}
+	return id, name, image, name != "" || id != ""
+}
+
+// isContainerID is true for a short (12) or full (64) hex container ID.
+func isContainerID(s string) bool {
+	if len(s) != 12 && len(s) != 64 {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
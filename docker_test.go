@@ -0,0 +1,35 @@
+package syslog
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestDockerHandler(t *testing.T) {
+	const id = "7a3f2b1c9d8e"
+	cases := []struct {
+		in, app, msgID, data string
+		annotations          map[string]string
+	}{
+		{"<30>1 2024-03-01T12:00:00Z host " + id + " 1234 " + id + " - started", id, "", `[docker@32473 id="` + id + `"]`,
+			map[string]string{ContainerIDAnnotation: id}},
+		{"<30>Mar  1 12:00:00 host library/nginx/web/" + id + "[1234]: GET /", "web", "",
+			`[docker@32473 id="` + id + `" name="web" image="library/nginx"]`,
+			map[string]string{ContainerIDAnnotation: id, ContainerNameAnnotation: "web", ContainerImageAnnotation: "library/nginx"}},
+		{"<30>1 2024-03-01T12:00:00Z host docker/db - - [x@1 a=\"b\"] ready", "db", "-", `[x@1 a="b"][docker@32473 name="db"]`,
+			map[string]string{ContainerNameAnnotation: "db"}},
+		{"<30>1 2024-03-01T12:00:00Z host sshd 99 - - accepted", "sshd", "-", "-", nil},
+		{"<30>1 2024-03-01T12:00:00Z host web/app - - - not docker", "web/app", "-", "-", nil},
+	}
+
+	for _, c := range cases {
+		m, err := parseMessage([]byte(c.in))
+		expect.Error(err).ToBeNil(t)
+		m = DockerHandler{SDID: "docker@32473"}.Handle(m)
+		expect.String(m.Application).Info(c.in).ToBe(t, c.app)
+		expect.String(m.MsgID).Info(c.in).ToBe(t, c.msgID)
+		expect.String(m.Data).Info(c.in).ToBe(t, c.data)
+		expect.Map(m.Annotations).Info(c.in).ToBe(t, c.annotations)
+	}
+}
@@ -0,0 +1,33 @@
+package syslog
+
+import (
+	"net"
+)
+
+// DTLSListenFunc creates a DTLS listener on a UDP address. Each accepted connection is
+// one DTLS association. The Go standard library does not implement DTLS, so this is
+// provided by a third-party package, for example:
+//
+//	func(network, addr string) (net.Listener, error) {
+//		a, err := net.ResolveUDPAddr(network, addr)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return dtls.Listen(network, a, dtlsConfig) // github.com/pion/dtls
+//	}
+type DTLSListenFunc func(network, addr string) (net.Listener, error)
+
+// ListenDTLS starts a goroutine that receives syslog messages over DTLS on a specified
+// host:port address, as defined in RFC 6012. This allows devices that cannot use TCP to
+// encrypt their messages in transit. The DTLS layer is supplied by listen. Messages use
+// octet-counting framing as required by RFC 6012. Only the messages matching accept are
+// processed.
+func (s *Server) ListenDTLS(addr string, listen DTLSListenFunc, accept Filter) error {
+	l, err := listen("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	s.ListenListener(l, accept)
+	return nil
+}
@@ -0,0 +1,41 @@
+package syslog
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestListenDTLS(t *testing.T) {
+	received := make(chan *Message, 1)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	// a stand-in for a real DTLS implementation
+	var network string
+	listen := func(n, addr string) (net.Listener, error) {
+		network = n
+		return net.Listen("tcp", addr)
+	}
+
+	expect.Error(s.ListenDTLS("127.0.0.1:0", listen, AcceptEverything)).ToBeNil(t)
+	expect.String(network).ToBe(t, "udp")
+
+	c, err := net.Dial("tcp", s.listeners[0].Addr().String())
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+
+	msg := "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - hello"
+	fmt.Fprintf(c, "%d %s", len(msg), msg)
+
+	m := <-received
+	expect.String(m.Content).ToBe(t, "hello")
+}
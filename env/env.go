@@ -0,0 +1,217 @@
+// Package env gets settings from environment variables, typically to provide the defaults
+// for command-line flags:
+//
+//	var vars env.Vars
+//	port := flag.Int("port", vars.Int("PORT", 514), "UDP port to listen on.")
+//	timeout := flag.Duration("timeout", vars.Duration("TIMEOUT", 10*time.Second), "Shutdown timeout.")
+//	flag.Parse()
+//	if err := vars.Err(); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// Each getter returns the default when the variable is not set. A variable that is set
+// but blank is the empty string for [GetString], and invalid for the other types.
+package env
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error reports an invalid environment variable.
+type Error struct {
+	Key string
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Key + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrRequired is the error for a required variable that is not set; see [Vars.Require].
+var ErrRequired = errors.New("required but not set")
+
+func get[T any](key string, def T, parse func(string) (T, error)) (T, error) {
+	v, exists := os.LookupEnv(key)
+	if !exists {
+		return def, nil
+	}
+	t, err := parse(v)
+	if err != nil {
+		return def, &Error{Key: key, Err: err}
+	}
+	return t, nil
+}
+
+// GetString gets a string.
+func GetString(key, def string) string {
+	v, exists := os.LookupEnv(key)
+	if !exists {
+		return def
+	}
+	return v
+}
+
+// GetBool gets a boolean such as "true", "false", "1" or "0"; see [strconv.ParseBool].
+func GetBool(key string, def bool) (bool, error) {
+	return get(key, def, strconv.ParseBool)
+}
+
+// GetInt gets an integer.
+func GetInt(key string, def int) (int, error) {
+	return get(key, def, strconv.Atoi)
+}
+
+// GetFloat gets a floating-point number.
+func GetFloat(key string, def float64) (float64, error) {
+	return get(key, def, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+}
+
+// GetDuration gets a duration such as "90s" or "1h30m"; see [time.ParseDuration].
+func GetDuration(key string, def time.Duration) (time.Duration, error) {
+	return get(key, def, time.ParseDuration)
+}
+
+// GetStrings gets a comma-separated list, ignoring spaces around the items and blank items.
+func GetStrings(key string, def []string) []string {
+	v, exists := os.LookupEnv(key)
+	if !exists {
+		return def
+	}
+	var list []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// GetURL gets an absolute URL, which must have a scheme and a host. The default, if used,
+// is not checked.
+func GetURL(key string, def *url.URL) (*url.URL, error) {
+	return get(key, def, func(s string) (*url.URL, error) {
+		u, err := url.Parse(s)
+		if err == nil && (u.Scheme == "" || u.Host == "") {
+			err = fmt.Errorf("%q: not an absolute URL", s)
+		}
+		return u, err
+	})
+}
+
+// GetPath gets a file path, which is cleaned; a leading "~/" is replaced by the home
+// directory.
+func GetPath(key, def string) (string, error) {
+	return get(key, def, func(s string) (string, error) {
+		if s == "" {
+			return "", errors.New("blank path")
+		}
+		if rest, found := strings.CutPrefix(s, "~/"); found {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			s = filepath.Join(home, rest)
+		}
+		return filepath.Clean(s), nil
+	})
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Vars gets environment variables like the Get functions, but collects the errors so that
+// they can all be reported together by [Vars.Err]. The zero value is ready to use.
+type Vars struct {
+	errs []error
+}
+
+func (vs *Vars) add(err error) {
+	if err != nil {
+		vs.errs = append(vs.errs, err)
+	}
+}
+
+// Err returns the errors so far, or nil if there were none.
+func (vs *Vars) Err() error {
+	return errors.Join(vs.errs...)
+}
+
+// Require records [ErrRequired] for each of the variables that is not set.
+func (vs *Vars) Require(keys ...string) {
+	for _, key := range keys {
+		if _, exists := os.LookupEnv(key); !exists {
+			vs.add(&Error{Key: key, Err: ErrRequired})
+		}
+	}
+}
+
+// Check records an error for a variable unless valid is true, e.g.
+//
+//	vars.Check("PORT", 0 < port && port < 65536, "port %d is out of range", port)
+func (vs *Vars) Check(key string, valid bool, format string, args ...any) {
+	if !valid {
+		vs.add(&Error{Key: key, Err: fmt.Errorf(format, args...)})
+	}
+}
+
+// String gets a string; see [GetString].
+func (vs *Vars) String(key, def string) string {
+	return GetString(key, def)
+}
+
+// Bool gets a boolean; see [GetBool].
+func (vs *Vars) Bool(key string, def bool) bool {
+	v, err := GetBool(key, def)
+	vs.add(err)
+	return v
+}
+
+// Int gets an integer; see [GetInt].
+func (vs *Vars) Int(key string, def int) int {
+	v, err := GetInt(key, def)
+	vs.add(err)
+	return v
+}
+
+// Float gets a floating-point number; see [GetFloat].
+func (vs *Vars) Float(key string, def float64) float64 {
+	v, err := GetFloat(key, def)
+	vs.add(err)
+	return v
+}
+
+// Duration gets a duration; see [GetDuration].
+func (vs *Vars) Duration(key string, def time.Duration) time.Duration {
+	v, err := GetDuration(key, def)
+	vs.add(err)
+	return v
+}
+
+// Strings gets a comma-separated list; see [GetStrings].
+func (vs *Vars) Strings(key string, def []string) []string {
+	return GetStrings(key, def)
+}
+
+// URL gets an absolute URL; see [GetURL].
+func (vs *Vars) URL(key string, def *url.URL) *url.URL {
+	v, err := GetURL(key, def)
+	vs.add(err)
+	return v
+}
+
+// Path gets a file path; see [GetPath].
+func (vs *Vars) Path(key, def string) string {
+	v, err := GetPath(key, def)
+	vs.add(err)
+	return v
+}
@@ -0,0 +1,72 @@
+package env
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestGetters(t *testing.T) {
+	t.Setenv("T_STRING", "")
+	t.Setenv("T_DURATION", "1m30s")
+	t.Setenv("T_FLOAT", "0.25")
+	t.Setenv("T_STRINGS", " a, b ,,c ")
+	t.Setenv("T_URL", "https://registry.example.com:8081/")
+	t.Setenv("T_PATH", "~/logs/../syslog/")
+
+	expect.String(GetString("T_STRING", "def")).ToBe(t, "")
+	expect.String(GetString("T_UNSET", "def")).ToBe(t, "def")
+
+	d, err := GetDuration("T_DURATION", time.Second)
+	expect.Error(err).ToBeNil(t)
+	expect.Number(d).ToBe(t, 90*time.Second)
+
+	f, err := GetFloat("T_FLOAT", 1)
+	expect.Error(err).ToBeNil(t)
+	expect.Number(f).ToBe(t, 0.25)
+
+	expect.Slice(GetStrings("T_STRINGS", nil)).ToBe(t, "a", "b", "c")
+	expect.Slice(GetStrings("T_UNSET", []string{"x"})).ToBe(t, "x")
+
+	u, err := GetURL("T_URL", nil)
+	expect.Error(err).ToBeNil(t)
+	expect.String(u.Host).ToBe(t, "registry.example.com:8081")
+
+	home, _ := os.UserHomeDir()
+	p, err := GetPath("T_PATH", "")
+	expect.Error(err).ToBeNil(t)
+	expect.String(p).ToBe(t, filepath.Join(home, "syslog"))
+}
+
+func TestVars(t *testing.T) {
+	t.Setenv("T_PORT", "99999")
+	t.Setenv("T_BOOL", "maybe")
+	t.Setenv("T_URL", "registry:8081")
+	t.Setenv("T_INT", "3")
+
+	var vars Vars
+	port := vars.Int("T_PORT", 514)
+	vars.Check("T_PORT", port < 65536, "port %d is out of range", port)
+	expect.Bool(vars.Bool("T_BOOL", true)).ToBeTrue(t) // the default
+	vars.URL("T_URL", &url.URL{})
+	expect.Number(vars.Int("T_INT", 0)).ToBe(t, 3)
+	vars.Require("T_INT", "T_REQUIRED")
+
+	err := vars.Err()
+	expect.Error(err).ToContain(t, "T_PORT: port 99999 is out of range\n"+
+		`T_BOOL: strconv.ParseBool: parsing "maybe": invalid syntax`+"\n"+
+		`T_URL: "registry:8081": not an absolute URL`+"\n"+
+		"T_REQUIRED: required but not set")
+	expect.Bool(errors.Is(err, ErrRequired)).ToBeTrue(t)
+
+	var e *Error
+	expect.Bool(errors.As(err, &e)).ToBeTrue(t)
+	expect.String(e.Key).ToBe(t, "T_PORT")
+
+	expect.Error((&Vars{}).Err()).ToBeNil(t)
+}
@@ -0,0 +1,112 @@
+package syslog
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Annotation keys for messages converted from Windows events; see [Server.ListenEventLog].
+const (
+	EventChannelAnnotation  = "event-channel"
+	EventRecordIDAnnotation = "event-record-id"
+)
+
+// winEvent is the XML rendering of a Windows event.
+type winEvent struct {
+	System struct {
+		Provider struct {
+			Name string `xml:"Name,attr"`
+		} `xml:"Provider"`
+		EventID     uint32 `xml:"EventID"`
+		Level       int    `xml:"Level"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+		EventRecordID uint64 `xml:"EventRecordID"`
+		Execution     struct {
+			ProcessID uint32 `xml:"ProcessID,attr"`
+		} `xml:"Execution"`
+		Channel  string `xml:"Channel"`
+		Computer string `xml:"Computer"`
+	} `xml:"System"`
+	EventData struct {
+		Data []struct {
+			Name  string `xml:"Name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"Data"`
+	} `xml:"EventData"`
+	RenderingInfo struct {
+		Message string `xml:"Message"`
+	} `xml:"RenderingInfo"`
+}
+
+// parseEvent parses the XML rendering of a Windows event.
+func parseEvent(bs []byte) (*winEvent, error) {
+	e := &winEvent{}
+	if err := xml.Unmarshal(bs, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// message converts an event received at time t into a message. The content is the
+// formatted message text, if known, or else the event data.
+func (e *winEvent) message(text string, t time.Time) *Message {
+	m := &Message{
+		Time:        t,
+		Facility:    Daemon,
+		Severity:    eventSeverity(e.System.Level),
+		Version:     1,
+		Timestamp:   t,
+		Hostname:    e.System.Computer,
+		Application: strings.ReplaceAll(e.System.Provider.Name, " ", "-"),
+		MsgID:       strconv.FormatUint(uint64(e.System.EventID), 10),
+		Content:     strings.TrimSpace(text),
+	}
+	if e.System.Channel == "Security" {
+		m.Facility = Authpriv
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, e.System.TimeCreated.SystemTime); err == nil {
+		m.Timestamp = ts
+	}
+	if e.System.Execution.ProcessID != 0 {
+		m.ProcID = strconv.FormatUint(uint64(e.System.Execution.ProcessID), 10)
+	}
+
+	if m.Content == "" {
+		m.Content = strings.TrimSpace(e.RenderingInfo.Message)
+	}
+	if m.Content == "" {
+		var data []string
+		for _, d := range e.EventData.Data {
+			if d.Name != "" {
+				data = append(data, d.Name+"="+d.Value)
+			} else {
+				data = append(data, d.Value)
+			}
+		}
+		m.Content = strings.Join(data, " ")
+	}
+
+	m.Annotate(EventChannelAnnotation, e.System.Channel)
+	m.Annotate(EventRecordIDAnnotation, strconv.FormatUint(e.System.EventRecordID, 10))
+	return m
+}
+
+// eventSeverity maps the level of a Windows event to a severity.
+func eventSeverity(level int) Severity {
+	switch level {
+	case 1:
+		return Crit
+	case 2:
+		return Err
+	case 3:
+		return Warning
+	case 5:
+		return Debug
+	default: // 0 (log always) and 4 (information)
+		return Info
+	}
+}
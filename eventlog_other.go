@@ -0,0 +1,10 @@
+//go:build !windows
+
+package syslog
+
+import "errors"
+
+// ListenEventLog is only supported on Windows.
+func (s *Server) ListenEventLog(channels []string, accept Filter) error {
+	return errors.New("ListenEventLog is only supported on Windows")
+}
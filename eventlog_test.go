@@ -0,0 +1,52 @@
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+const testEventXML = `<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'>
+<System>
+<Provider Name='Service Control Manager' Guid='{555908d1-a6d7-4695-8e1e-26931d2012f4}'/>
+<EventID Qualifiers='49152'>7000</EventID>
+<Level>2</Level>
+<TimeCreated SystemTime='2024-03-01T10:15:30.1234567Z'/>
+<EventRecordID>4242</EventRecordID>
+<Execution ProcessID='812' ThreadID='4'/>
+<Channel>System</Channel>
+<Computer>web01.example.com</Computer>
+</System>
+<EventData><Data Name='param1'>Spooler</Data><Data Name='param2'>%%1053</Data></EventData>
+</Event>`
+
+func TestWinEvent_message(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 15, 31, 0, time.UTC)
+	e, err := parseEvent([]byte(testEventXML))
+	expect.Error(err).ToBeNil(t)
+
+	m := e.message("", now)
+	expect.Any(m.Facility).ToBe(t, Daemon)
+	expect.Any(m.Severity).ToBe(t, Err)
+	expect.String(m.Timestamp.Format(time.RFC3339Nano)).ToBe(t, "2024-03-01T10:15:30.1234567Z")
+	expect.String(m.Hostname).ToBe(t, "web01.example.com")
+	expect.String(m.Application).ToBe(t, "Service-Control-Manager")
+	expect.String(m.ProcID).ToBe(t, "812")
+	expect.String(m.MsgID).ToBe(t, "7000")
+	expect.String(m.Content).ToBe(t, "param1=Spooler param2=%%1053")
+	expect.String(m.Annotations[EventChannelAnnotation]).ToBe(t, "System")
+	expect.String(m.Annotations[EventRecordIDAnnotation]).ToBe(t, "4242")
+
+	m = e.message("The Spooler service failed to start.\r\n", now)
+	expect.String(m.Content).ToBe(t, "The Spooler service failed to start.")
+
+	e.System.Channel = "Security"
+	e.System.Level = 0
+	m = e.message("", now)
+	expect.Any(m.Facility).ToBe(t, Authpriv)
+	expect.Any(m.Severity).ToBe(t, Info)
+
+	_, err = parseEvent([]byte("<Event>"))
+	expect.Bool(err != nil).ToBeTrue(t)
+}
@@ -0,0 +1,216 @@
+package syslog
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	wevtapi                      = syscall.NewLazyDLL("wevtapi.dll")
+	procEvtSubscribe             = wevtapi.NewProc("EvtSubscribe")
+	procEvtNext                  = wevtapi.NewProc("EvtNext")
+	procEvtRender                = wevtapi.NewProc("EvtRender")
+	procEvtClose                 = wevtapi.NewProc("EvtClose")
+	procEvtOpenPublisherMetadata = wevtapi.NewProc("EvtOpenPublisherMetadata")
+	procEvtFormatMessage         = wevtapi.NewProc("EvtFormatMessage")
+
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procCreateEventW = kernel32.NewProc("CreateEventW")
+)
+
+const (
+	evtSubscribeToFutureEvents = 1
+	evtRenderEventXML          = 1
+	evtFormatMessageEvent      = 1
+
+	errorInsufficientBuffer syscall.Errno = 122
+	errorNoMoreItems        syscall.Errno = 259
+
+	eventBatch      = 16
+	eventPollMillis = 1000 // how often the done channel is checked
+)
+
+// ListenEventLog starts a goroutine that subscribes to Windows Event Log channels, such as
+// "Application", "System" or "Security", and passes each new event to the handlers as a
+// message. The level of each event becomes the severity, its provider the application, its
+// event ID the MsgID and its formatted text the content. Events have the [Daemon] facility,
+// except those from the Security channel, which have [Authpriv]. The channel and event
+// record ID are recorded as annotations ([EventChannelAnnotation] and
+// [EventRecordIDAnnotation]).
+//
+// Only events written after the subscription are received. Like MARK messages (see
+// [Server.StartMark]), the messages bypass the source ACL and rate limits; only the
+// messages matching accept are processed.
+func (s *Server) ListenEventLog(channels []string, accept Filter) error {
+	if s.shutDown.Load() {
+		panic("Server is already shut down")
+	}
+
+	// auto-reset and initially signalled; it is shared by all the subscriptions
+	signal, _, err := procCreateEventW.Call(0, 0, 1, 0)
+	if signal == 0 {
+		return err
+	}
+
+	var subs []uintptr
+	for _, ch := range channels {
+		sub, err := evtSubscribe(signal, ch)
+		if err != nil {
+			closeEventHandles(subs)
+			syscall.CloseHandle(syscall.Handle(signal))
+			return fmt.Errorf("%s: %w", ch, err)
+		}
+		subs = append(subs, sub)
+	}
+
+	s.receivers.Add(1)
+	go s.readEventLog(syscall.Handle(signal), subs, accept)
+	return nil
+}
+
+func evtSubscribe(signal uintptr, channel string) (uintptr, error) {
+	path, err := syscall.UTF16PtrFromString(channel)
+	if err != nil {
+		return 0, err
+	}
+
+	sub, _, err := procEvtSubscribe.Call(0, signal, uintptr(unsafe.Pointer(path)), 0, 0, 0, 0,
+		evtSubscribeToFutureEvents)
+	if sub == 0 {
+		return 0, err
+	}
+	return sub, nil
+}
+
+func (s *Server) readEventLog(signal syscall.Handle, subs []uintptr, accept Filter) {
+	defer s.receivers.Done()
+	defer syscall.CloseHandle(signal)
+	defer closeEventHandles(subs)
+
+	publishers := make(map[string]uintptr) // metadata used to format the message text
+	defer func() {
+		for _, pm := range publishers {
+			procEvtClose.Call(pm)
+		}
+	}()
+
+	for {
+		for _, sub := range subs {
+			s.drainEvents(sub, publishers, accept)
+		}
+
+		syscall.WaitForSingleObject(signal, eventPollMillis)
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+	}
+}
+
+// drainEvents passes all the pending events of a subscription to the handlers.
+func (s *Server) drainEvents(sub uintptr, publishers map[string]uintptr, accept Filter) {
+	var events [eventBatch]uintptr
+	for {
+		var n uint32
+		ok, _, err := procEvtNext.Call(sub, eventBatch, uintptr(unsafe.Pointer(&events[0])), 0, 0,
+			uintptr(unsafe.Pointer(&n)))
+		if ok == 0 {
+			if err != errorNoMoreItems {
+				s.logger.Println("Event log error:", err)
+			}
+			return
+		}
+
+		var ms []*Message
+		for _, event := range events[:n] {
+			m, err := s.eventMessage(event, publishers)
+			procEvtClose.Call(event)
+			if err != nil {
+				s.logger.Println("Event log error:", err)
+			} else if accept(m) {
+				ms = append(ms, m)
+			}
+		}
+		if len(ms) > 0 {
+			s.pushStream(ms) // events wait in the log if the handlers are slow
+		}
+	}
+}
+
+// eventMessage renders an event as XML and converts it to a message.
+func (s *Server) eventMessage(event uintptr, publishers map[string]uintptr) (*Message, error) {
+	bs, err := evtRender(event)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := parseEvent(bs)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := e.System.Provider.Name
+	pm, exists := publishers[provider]
+	if !exists {
+		pm = openPublisherMetadata(provider)
+		publishers[provider] = pm
+	}
+	return e.message(formatEventMessage(pm, event), s.clock()), nil
+}
+
+// evtRender renders an event as UTF-8 XML.
+func evtRender(event uintptr) ([]byte, error) {
+	buf := make([]uint16, 4096)
+	for {
+		var used, count uint32
+		ok, _, err := procEvtRender.Call(0, event, evtRenderEventXML, uintptr(len(buf)*2),
+			uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&used)), uintptr(unsafe.Pointer(&count)))
+		if ok != 0 {
+			return []byte(syscall.UTF16ToString(buf)), nil
+		}
+		if err != errorInsufficientBuffer {
+			return nil, err
+		}
+		buf = make([]uint16, used/2+1) // used is in bytes
+	}
+}
+
+// openPublisherMetadata opens the metadata of a provider, or returns 0 if it is not available,
+// in which case the message text is taken from the event data.
+func openPublisherMetadata(provider string) uintptr {
+	name, err := syscall.UTF16PtrFromString(provider)
+	if err != nil {
+		return 0
+	}
+	pm, _, _ := procEvtOpenPublisherMetadata.Call(0, uintptr(unsafe.Pointer(name)), 0, 0, 0)
+	return pm
+}
+
+// formatEventMessage gets the message text of an event, or blank if it cannot be formatted.
+func formatEventMessage(pm, event uintptr) string {
+	if pm == 0 {
+		return ""
+	}
+
+	buf := make([]uint16, 1024)
+	for {
+		var used uint32
+		ok, _, err := procEvtFormatMessage.Call(pm, event, 0, 0, 0, evtFormatMessageEvent,
+			uintptr(len(buf)), uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&used)))
+		if ok != 0 {
+			return syscall.UTF16ToString(buf)
+		}
+		if err != errorInsufficientBuffer {
+			return ""
+		}
+		buf = make([]uint16, used) // used is in characters
+	}
+}
+
+func closeEventHandles(hs []uintptr) {
+	for _, h := range hs {
+		procEvtClose.Call(h)
+	}
+}
@@ -0,0 +1,207 @@
+package syslog
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// IdempotencyKey identifies a message for deduplication: it is the identifier assigned by
+// [Server.SetMessageIDs] if there is one, or otherwise the SHA-256 hash (in hex) of its
+// RFC 5424 rendering. Outputs to idempotent stores can use it as the document ID or object
+// name, so that a message delivered more than once, e.g. after a retry, is stored only once.
+//
+// The identifier annotation is forwarded by [ReplicationHandler], so a replicated message
+// has the same key on the peer. Other forwarders drop annotations, but if the identifier is
+// also placed in the structured data or MsgID, the hash is the same wherever the message is
+// received.
+func IdempotencyKey(m *Message) string {
+	if id := m.Annotations[IDAnnotation]; id != "" {
+		return id
+	}
+	sum := sha256.Sum256(m.appendRFC5424(nil))
+	return hex.EncodeToString(sum[:])
+}
+
+// ExactlyOnceHandler wraps a [Handler] so that it sees each message only once, judged by
+// [IdempotencyKey], which gives effectively exactly-once delivery when messages are resent,
+// e.g. by [ReplicationHandler] or RELP clients after a reconnection. The keys of the most
+// recent messages are remembered and, if a journal file is used, also survive restarts.
+// The wrapped handler is called without holding a lock, so that a slow output does not hold
+// up other workers; a duplicate that arrives whilst the first copy is being handled is
+// skipped. Keys are written to the journal after the wrapped handler has handled the message,
+// so a crash in between may lead to one redelivery, which idempotent stores tolerate.
+//
+// All messages are passed on to subsequent handlers, whether duplicates or not (unless the
+// wrapped handler consumes them). An ExactlyOnceHandler is safe for concurrent use.
+type ExactlyOnceHandler struct {
+	h      Handler
+	window int
+
+	mu      sync.Mutex
+	seen    map[string]struct{}
+	order   []string // ring buffer of the keys in seen
+	next    int
+	journal *os.File
+	path    string
+	lines   int
+
+	duplicates atomic.Uint64
+}
+
+// NewExactlyOnceHandler wraps h, remembering the keys of the most recent window messages.
+// If journal is not blank, the keys are appended to that file and loaded from it again
+// when the handler is next created.
+func NewExactlyOnceHandler(h Handler, window int, journal string) (*ExactlyOnceHandler, error) {
+	eo := &ExactlyOnceHandler{
+		h:      h,
+		window: max(window, 1),
+		seen:   make(map[string]struct{}),
+		path:   journal,
+	}
+	if journal == "" {
+		return eo, nil
+	}
+
+	if err := eo.load(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(journal, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	eo.journal = f
+	return eo, nil
+}
+
+// Duplicates returns the number of messages that the wrapped handler did not see because
+// they had already been handled.
+func (eo *ExactlyOnceHandler) Duplicates() uint64 {
+	return eo.duplicates.Load()
+}
+
+// Close closes the journal and the wrapped handler.
+func (eo *ExactlyOnceHandler) Close() error {
+	eo.mu.Lock()
+	defer eo.mu.Unlock()
+	if eo.journal != nil {
+		checkErr(eo.journal.Close(), "close", eo.path)
+		eo.journal = nil
+	}
+	return closeHandler(eo.h)
+}
+
+func (eo *ExactlyOnceHandler) Handle(m *Message) *Message {
+	if m == nil {
+		checkErr(eo.Close())
+		return nil
+	}
+
+	key := IdempotencyKey(m)
+	eo.mu.Lock()
+	if _, duplicate := eo.seen[key]; duplicate {
+		eo.mu.Unlock()
+		eo.duplicates.Add(1)
+		return m
+	}
+	eo.add(key) // reserved, so that concurrent duplicates are skipped
+	eo.mu.Unlock()
+
+	r := eo.h.Handle(m)
+
+	eo.mu.Lock()
+	eo.record(key)
+	eo.mu.Unlock()
+	return r
+}
+
+// add remembers a key, forgetting the oldest if the window is full.
+func (eo *ExactlyOnceHandler) add(key string) {
+	if _, exists := eo.seen[key]; exists {
+		return
+	}
+	if len(eo.order) < eo.window {
+		eo.order = append(eo.order, key)
+	} else {
+		delete(eo.seen, eo.order[eo.next])
+		eo.order[eo.next] = key
+		eo.next = (eo.next + 1) % eo.window
+	}
+	eo.seen[key] = struct{}{}
+}
+
+// record appends a key to the journal, which is compacted when it holds twice as many keys
+// as the window.
+func (eo *ExactlyOnceHandler) record(key string) {
+	if eo.journal == nil {
+		return
+	}
+
+	if _, err := eo.journal.WriteString(key + "\n"); checkErr(err, "write", eo.path) {
+		return
+	}
+	eo.lines++
+	if eo.lines >= 2*eo.window {
+		checkErr(eo.compact(), "compact", eo.path)
+	}
+}
+
+// compact replaces the journal with one containing only the remembered keys.
+func (eo *ExactlyOnceHandler) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(eo.path), filepath.Base(eo.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly after the rename
+
+	w := bufio.NewWriter(tmp)
+	for i := range eo.order { // oldest first
+		w.WriteString(eo.order[(eo.next+i)%len(eo.order)])
+		w.WriteByte('\n')
+	}
+	if err = w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), eo.path); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(eo.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	eo.journal.Close()
+	eo.journal = f
+	eo.lines = len(eo.order)
+	return nil
+}
+
+// load reads the keys from the journal, if it exists.
+func (eo *ExactlyOnceHandler) load() error {
+	f, err := os.Open(eo.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if key := sc.Text(); key != "" {
+			eo.add(key)
+			eo.lines++
+		}
+	}
+	return sc.Err()
+}
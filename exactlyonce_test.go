@@ -0,0 +1,99 @@
+package syslog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	m := &Message{Facility: User, Severity: Info, Version: 1, Hostname: "myhost", Content: "hello"}
+	same := *m
+	other := &Message{Facility: User, Severity: Info, Version: 1, Hostname: "myhost", Content: "hello again"}
+	expect.Number(len(IdempotencyKey(m))).ToBe(t, 64)
+	expect.String(IdempotencyKey(&same)).ToBe(t, IdempotencyKey(m))
+	expect.Bool(IdempotencyKey(other) != IdempotencyKey(m)).ToBeTrue(t)
+
+	m.Annotate(IDAnnotation, "01HQ3K9Z5R6W8Y2C4V7B9N1M3P")
+	expect.String(IdempotencyKey(m)).ToBe(t, "01HQ3K9Z5R6W8Y2C4V7B9N1M3P")
+}
+
+func TestExactlyOnceHandler(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "delivered")
+	var delivered []string
+	sink := handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			delivered = append(delivered, m.Content)
+		}
+		return m
+	})
+	msg := func(content string) *Message {
+		return &Message{Facility: User, Severity: Info, Version: 1, Hostname: "myhost", Content: content}
+	}
+
+	eo, err := NewExactlyOnceHandler(sink, 3, journal)
+	expect.Error(err).ToBeNil(t)
+	for _, c := range []string{"a", "b", "a", "c"} {
+		expect.String(eo.Handle(msg(c)).Content).ToBe(t, c) // duplicates are passed on too
+	}
+	expect.Slice(delivered).ToBe(t, "a", "b", "c")
+	expect.Number(eo.Duplicates()).ToBe(t, 1)
+	expect.Error(eo.Close()).ToBeNil(t)
+
+	// the keys survive a restart
+	eo, err = NewExactlyOnceHandler(sink, 3, journal)
+	expect.Error(err).ToBeNil(t)
+	defer eo.Close()
+	eo.Handle(msg("b"))
+	eo.Handle(msg("d"))
+	expect.Slice(delivered).ToBe(t, "a", "b", "c", "d")
+
+	// the journal is compacted to the window; "a" has been forgotten
+	eo.Handle(msg("e"))
+	eo.Handle(msg("a"))
+	expect.Slice(delivered).ToBe(t, "a", "b", "c", "d", "e", "a")
+	bs, err := os.ReadFile(journal)
+	expect.Error(err).ToBeNil(t)
+	expect.Number(strings.Count(string(bs), "\n")).ToBe(t, 3)
+}
+
+func TestExactlyOnceHandler_concurrent(t *testing.T) {
+	release := make(chan struct{})
+	handled := make(chan string, 3)
+	eo, err := NewExactlyOnceHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			if m.Content == "slow" {
+				<-release
+			}
+			handled <- m.Content
+		}
+		return m
+	}), 10, "")
+	expect.Error(err).ToBeNil(t)
+
+	slow := &Message{Hostname: "myhost", Content: "slow"}
+	go eo.Handle(slow)
+	for !eo.seenKey(slow) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// other messages are not held up by the slow one, and its duplicate is skipped
+	eo.Handle(&Message{Hostname: "myhost", Content: "fast"})
+	expect.String(<-handled).ToBe(t, "fast")
+	eo.Handle(slow)
+	expect.Number(eo.Duplicates()).ToBe(t, 1)
+
+	close(release)
+	expect.String(<-handled).ToBe(t, "slow")
+}
+
+func (eo *ExactlyOnceHandler) seenKey(m *Message) bool {
+	eo.mu.Lock()
+	defer eo.mu.Unlock()
+	_, seen := eo.seen[IdempotencyKey(m)]
+	return seen
+}
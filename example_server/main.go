@@ -1,35 +1,112 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/rickb777/syslog"
-	"github.com/rickb777/syslog/internal/env"
+	"github.com/rickb777/syslog/env"
 )
 
 var (
 	port     int
+	tcpPort  int
+	proxy    bool
+	proxies  string
+	tlsPort  int
+	relpPort int
+	reuse    int
+	mcGroup  string
+	mcIface  string
+	devLog   bool
+	certFile string
+	keyFile  string
 	file     string
+	preset   string
 	format   string
+	formats  string
 	priority string
 	retain   int
+	lockFile string
+	peer     string
+	standby  string
+	hbSecret string
+	audit    string
+	mark     int
+	console  bool
+	journald bool
+	runUser  string
+	runGroup string
+	sandbox  bool
 	debug    bool
 )
 
+const (
+	heartbeatInterval = time.Second
+	shutdownTimeout   = 10 * time.Second
+)
+
 func flags() {
-	portDefault, e1 := env.GetInt("PORT", 514)
-	retainDefault, e2 := env.GetInt("RETAIN", -1)
-	fileDefault := env.GetString("FILE", "")
-	formatDefault := env.GetString("FORMAT", syslog.RFCFormat)
-	priorityDefault := env.GetString("PRIORITY", "")
+	var vars env.Vars
+	portDefault := vars.Int("PORT", 514)
+	tcpPortDefault := vars.Int("TCP_PORT", 0)
+	tlsPortDefault := vars.Int("TLS_PORT", 0)
+	relpPortDefault := vars.Int("RELP_PORT", 0)
+	reuseDefault := vars.Int("REUSEPORT", 0)
+	proxyDefault := vars.Bool("PROXY", false)
+	proxiesDefault := vars.String("TRUSTED_PROXIES", "")
+	mcGroupDefault := vars.String("MULTICAST", "")
+	mcIfaceDefault := vars.String("MULTICAST_IFACE", "")
+	devLogDefault := vars.Bool("DEVLOG", false)
+	certDefault := vars.String("CERT", "")
+	keyDefault := vars.String("KEY", "")
+	retainDefault := vars.Int("RETAIN", -1)
+	markDefault := vars.Int("MARK", 0)
+	consoleDefault := vars.Bool("CONSOLE", false)
+	journaldDefault := vars.Bool("JOURNALD", false)
+	fileDefault := vars.String("FILE", "")
+	presetDefault := vars.String("PRESET", "")
+	formatDefault := vars.String("FORMAT", syslog.RFCFormat)
+	formatsDefault := vars.String("FORMATS", "")
+	priorityDefault := vars.String("PRIORITY", "")
+	lockDefault := vars.String("LOCK", "")
+	peerDefault := vars.String("PEER", "")
+	standbyDefault := vars.String("STANDBY", "")
+	hbSecretDefault := vars.String("HEARTBEAT_SECRET", "")
+	auditDefault := vars.String("AUDIT", "")
+	runUserDefault := vars.String("RUN_USER", "")
+	runGroupDefault := vars.String("RUN_GROUP", "")
+	sandboxDefault := vars.Bool("SANDBOX", false)
 
 	flag.IntVar(&port, "port", portDefault, "UDP port to listen on.")
+	flag.IntVar(&tcpPort, "tcp", tcpPortDefault, "TCP port to listen on (RFC 6587 framing). Zero disables TCP.")
+	flag.BoolVar(&proxy, "proxy", proxyDefault, "TCP connections start with a PROXY protocol header from a load balancer.")
+	flag.StringVar(&proxies, "trusted-proxies", proxiesDefault,
+		"Comma-separated networks of the load balancers whose PROXY headers are believed, e.g. 10.0.0.0/8.\n"+
+			"Required with -proxy.")
+	flag.IntVar(&tlsPort, "tls", tlsPortDefault, "TLS port to listen on (RFC 5425, usually 6514). Zero disables TLS.")
+	flag.IntVar(&relpPort, "relp", relpPortDefault, "RELP port to listen on. Zero disables RELP.")
+	flag.IntVar(&reuse, "reuseport", reuseDefault, "Number of UDP sockets to open with SO_REUSEPORT. Zero uses a single socket.")
+	flag.StringVar(&mcGroup, "multicast", mcGroupDefault, "UDP multicast group host:port to join, e.g. 239.192.0.1:514.")
+	flag.StringVar(&mcIface, "multicast-iface", mcIfaceDefault, "Network interface on which to join the multicast group.")
+	flag.BoolVar(&devLog, "devlog", devLogDefault, "Also receive local messages on /dev/log, replacing the local syslog daemon.")
+	flag.StringVar(&certFile, "cert", certDefault, "PEM certificate file for the TLS listener.")
+	flag.StringVar(&keyFile, "key", keyDefault, "PEM private key file for the TLS listener.")
 	flag.StringVar(&file, "file", fileDefault, "File to write messages to. (default stdout)")
-	flag.StringVar(&format, "format", formatDefault, "Format to use for messages.")
+	flag.StringVar(&preset, "preset", presetDefault,
+		"Directory in which to write files like a traditional syslogd, i.e. auth.log, syslog,\n"+
+			"kern.log and mail.log; emergency messages are broadcast to all users. This overrides -file.")
+	flag.StringVar(&format, "format", formatDefault,
+		"Format to use for messages, or the name of a format, e.g. rfc, rfc3164 or one defined by -formats.")
+	flag.StringVar(&formats, "formats", formatsDefault, "File of named formats, one per line as name=format.")
 	flag.StringVar(&priority, "priority", priorityDefault,
 		"Ignore messages that are not this priority, expressed as 'facility.severity'.\n"+
 			"Facility and severity are both lists, where * is a wildcard.\n"+
@@ -44,27 +121,68 @@ func flags() {
 	flag.IntVar(&retain, "retain", retainDefault,
 		"Truncate logfiles and rotate this number of files when opening.\n"+
 			"Negative values disable rotation.")
+	flag.StringVar(&lockFile, "lock", lockDefault,
+		"Lock file shared by multiple instances for active/standby operation.\n"+
+			"Standby instances wait until the lock is released by the active instance.\n"+
+			"Log files are also locked so that instances can share them safely.")
+	flag.StringVar(&peer, "peer", peerDefault,
+		"UDP host:port of a standby instance to which heartbeats are sent.")
+	flag.StringVar(&standby, "standby", standbyDefault,
+		"UDP host:port on which to receive heartbeats from the active instance.\n"+
+			"This instance waits in standby until the heartbeats stop.")
+	flag.StringVar(&hbSecret, "heartbeat-secret", hbSecretDefault,
+		"Secret shared by the active and standby instances to authenticate heartbeats.\n"+
+			"Prefer setting HEARTBEAT_SECRET, so that the secret is not visible in the process list.")
+	flag.StringVar(&audit, "audit", auditDefault,
+		"File to which an audit trail of all received packets is appended, as JSON lines.")
+	flag.IntVar(&mark, "mark", markDefault,
+		"Interval in minutes between synthetic '-- MARK --' messages. Zero disables them.")
+	flag.BoolVar(&console, "console", consoleDefault, "Write critical messages to /dev/console.")
+	flag.BoolVar(&journald, "journald", journaldDefault, "Also write messages to the local systemd journal.")
+	flag.StringVar(&runUser, "user", runUserDefault,
+		"User to run as after the ports have been opened, so that the collector does not run as root.")
+	flag.StringVar(&runGroup, "group", runGroupDefault, "Group to run as with -user. (default the user's group)")
+	flag.BoolVar(&sandbox, "sandbox", sandboxDefault,
+		"Restrict system calls and file access once started (Linux only; the file restriction requires a build with CGO_ENABLED=0).")
 	flag.BoolVar(&debug, "v", false, "Verbose information")
 
 	flag.Parse()
 
-	if e1 != nil {
-		fmt.Fprintln(os.Stderr, "PORT", e1)
-		flag.Usage()
-		os.Exit(1)
-	}
-	if e2 != nil {
-		fmt.Fprintln(os.Stderr, "TRUNCATE", e2)
+	if err := vars.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		flag.Usage()
 		os.Exit(1)
 	}
 
 	if debug {
 		fmt.Printf("PORT=%d\n", port)
+		fmt.Printf("REUSEPORT=%d\n", reuse)
+		fmt.Printf("TCP_PORT=%d\n", tcpPort)
+		fmt.Printf("PROXY=%v\n", proxy)
+		fmt.Printf("TRUSTED_PROXIES=%s\n", proxies)
+		fmt.Printf("TLS_PORT=%d\n", tlsPort)
+		fmt.Printf("RELP_PORT=%d\n", relpPort)
+		fmt.Printf("MULTICAST=%s\n", mcGroup)
+		fmt.Printf("MULTICAST_IFACE=%s\n", mcIface)
+		fmt.Printf("DEVLOG=%v\n", devLog)
+		fmt.Printf("CERT=%s\n", certFile)
+		fmt.Printf("KEY=%s\n", keyFile)
 		fmt.Printf("FILE=%s\n", file)
+		fmt.Printf("PRESET=%s\n", preset)
 		fmt.Printf("FORMAT=%s\n", format)
+		fmt.Printf("FORMATS=%s\n", formats)
 		fmt.Printf("RETAIN=%v\n", retain)
 		fmt.Printf("PRIORITY=%v\n", priority)
+		fmt.Printf("LOCK=%s\n", lockFile)
+		fmt.Printf("PEER=%s\n", peer)
+		fmt.Printf("STANDBY=%s\n", standby)
+		fmt.Printf("AUDIT=%s\n", audit)
+		fmt.Printf("MARK=%d\n", mark)
+		fmt.Printf("CONSOLE=%v\n", console)
+		fmt.Printf("JOURNALD=%v\n", journald)
+		fmt.Printf("RUN_USER=%s\n", runUser)
+		fmt.Printf("RUN_GROUP=%s\n", runGroup)
+		fmt.Printf("SANDBOX=%v\n", sandbox)
 	}
 }
 
@@ -72,44 +190,265 @@ func flags() {
 func main() {
 	flags()
 
-	s := syslog.NewServer(100)
+	if formats != "" {
+		f, err := os.Open(formats)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+		if err = syslog.LoadFormats(f); err != nil {
+			syslog.Logger.Fatalln(formats, err)
+		}
+		f.Close()
+	}
+	format = syslog.ResolveFormat(format)
+
+	if lockFile != "" {
+		if debug {
+			fmt.Println("Standby: waiting for", lockFile)
+		}
+		_, err := syslog.Lock(lockFile) // held until the process exits
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+		if debug {
+			fmt.Println("Active")
+		}
+	}
+
+	var secret []byte
+	if hbSecret != "" {
+		secret = []byte(hbSecret)
+	}
+
+	if standby != "" {
+		sb, err := syslog.NewStandby(standby, 3*heartbeatInterval, secret)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+		if debug {
+			fmt.Println("Standby: waiting for heartbeats to stop on", standby)
+		}
+		if _, err = sb.Wait(); err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+	}
+
+	if peer != "" {
+		_, err := syslog.StartHeartbeat(peer, heartbeatInterval, secret, nil)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+	}
+
+	s := syslog.NewServer()
+	if audit != "" {
+		af, err := os.OpenFile(audit, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+		s.SetAudit(af)
+	}
 	if debug {
 		s.AddHandler(syslog.DebugHandler{})
 	}
-	if file != "" {
+	if console {
+		s.AddHandler(syslog.NewConsoleHandler(format))
+	}
+	if journald {
+		s.AddHandler(syslog.NewJournalHandler(syslog.DefaultJournalSocket))
+	}
+	if preset != "" {
+		for _, h := range syslog.SyslogConfHandlers(preset, format, syslog.NewWallHandler(format)) {
+			s.AddHandler(h)
+		}
+	} else if file != "" {
 		fh := syslog.NewFileHandler(file, format)
 		fh.SetRotate(retain)
+		if err := fh.SetLocking(lockFile != ""); err != nil {
+			syslog.Logger.Fatalln(err)
+		}
 		s.AddHandler(fh)
 	} else {
 		s.AddHandler(syslog.PrintHandler(format))
 	}
 
 	var err error
+	var keyPair *syslog.KeyPair
 	filter := syslog.AcceptEverything
 	if priority != "" {
-		filter, err = syslog.ParsePriorityFilter(priority)
+		// unwanted priorities are discarded before the messages are fully parsed
+		pf, err := syslog.ParsePriority(priority)
 		if err != nil {
 			syslog.Logger.Fatalln(err)
 		}
+		s.SetPriorityFilter(pf)
 	}
 
-	err = s.ListenFilter(fmt.Sprintf(":%d", port), filter)
+	// When started by systemd socket activation (see syslog-lite.socket), the sockets are
+	// already bound; otherwise listen on the UDP port.
+	activated, err := s.ListenActivated(filter)
 	if err != nil {
 		syslog.Logger.Fatalln(err)
 	}
 
+	if len(activated) == 0 && reuse > 0 {
+		err = s.ListenReusePort(fmt.Sprintf(":%d", port), reuse, filter)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+	} else if len(activated) == 0 {
+		err = s.ListenFilter(fmt.Sprintf(":%d", port), filter)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+	} else if debug {
+		fmt.Println("Activated sockets:", activated)
+	}
+
+	if tcpPort > 0 && proxy {
+		err = s.ListenTCPProxy(fmt.Sprintf(":%d", tcpPort), trustedProxies(), filter)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+	} else if tcpPort > 0 {
+		err = s.ListenTCP(fmt.Sprintf(":%d", tcpPort), filter)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+	}
+
+	if tlsPort > 0 {
+		keyPair, err = syslog.LoadKeyPair(certFile, keyFile)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+		err = s.ListenTLS(fmt.Sprintf(":%d", tlsPort), keyPair.Config(), filter)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+	}
+
+	if mark > 0 {
+		s.StartMark(time.Duration(mark) * time.Minute)
+	}
+
+	if mcGroup != "" {
+		err = s.ListenMulticast(mcGroup, mcIface, filter)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+	}
+
+	if devLog {
+		err = s.ListenDevLog(syslog.DevLog, "", filter)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+	}
+
+	if relpPort > 0 {
+		err = s.ListenRELP(fmt.Sprintf(":%d", relpPort), nil, filter)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+	}
+
+	if runUser != "" {
+		if err = syslog.DropPrivileges(runUser, runGroup); err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+	}
+
+	if sandbox {
+		if err = restrict(); err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+	}
+
 	// Wait for terminating signal
 	sc := make(chan os.Signal, 2)
 	signal.Notify(sc, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
 
+	// Stop if every listener has failed
+	go func() {
+		if err := s.Wait(); err != nil {
+			sc <- syscall.SIGTERM
+		}
+	}()
+
 	for v := range sc {
 		switch v {
 		case syscall.SIGHUP:
 			s.SigHup()
+			if keyPair != nil {
+				keyPair.SigHup() // the certificate may have been renewed
+			}
 
 		default:
-			s.Shutdown()
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			err := s.ShutdownContext(ctx)
+			cancel()
+			if err != nil {
+				syslog.Logger.Println(err)
+				os.Exit(1)
+			}
 			os.Exit(0)
 		}
 	}
 }
+
+// restrict confines the server to the files it uses, once everything has been opened.
+func restrict() error {
+	readOnly := []string{"/etc"} // time zones and name resolution
+	var readWrite []string
+	for _, f := range []string{certFile, keyFile} {
+		if f != "" {
+			readOnly = append(readOnly, f)
+		}
+	}
+	for _, f := range []string{file, audit, lockFile} {
+		if f != "" {
+			readWrite = append(readWrite, logDir(f)) // log files are rotated
+		}
+	}
+	if preset != "" {
+		readOnly = append(readOnly, "/var/run/utmp")
+		readWrite = append(readWrite, preset)
+	}
+	if preset != "" || console {
+		readWrite = append(readWrite, "/dev") // the console and terminals
+	}
+	if journald {
+		readWrite = append(readWrite, "/dev/shm", os.TempDir()) // large journal entries
+	}
+
+	if err := syslog.RestrictFiles(readOnly, readWrite); err != nil {
+		return err
+	}
+	return syslog.RestrictSyscalls()
+}
+
+// logDir is the directory that holds the files of a filename template, i.e. the directory
+// above the first placeholder such as "%hostname%", which may expand to subdirectories.
+func logDir(template string) string {
+	if i := strings.IndexByte(template, '%'); i >= 0 {
+		template = template[:i]
+	}
+	return filepath.Dir(template)
+}
+
+// trustedProxies parses the networks of the load balancers that send PROXY headers.
+func trustedProxies() []net.IPNet {
+	var nets []net.IPNet
+	for _, p := range strings.Split(proxies, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			syslog.Logger.Fatalln(err)
+		}
+		nets = append(nets, *n)
+	}
+	return nets
+}
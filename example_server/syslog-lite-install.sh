@@ -15,7 +15,7 @@ sudo systemctl stop syslog-lite ||:
 
 sudo setcap 'cap_net_bind_service=+ep' syslog.$ARCH
 sudo cp -vf syslog.$ARCH /usr/local/bin/syslog-lite
-sudo cp -vf syslog-lite.service /etc/systemd/system/
+sudo cp -vf syslog-lite.service syslog-lite.socket /etc/systemd/system/
 sudo cp -vf syslog-lite.conf /etc/default/
 
 for d in $HOSTS; do
@@ -92,6 +92,11 @@ func ParseFacilities(list string) (Facilities, error) {
 	return fs, nil
 }
 
+// IsKnown returns true for the facilities defined in RFC5424, i.e. [Kern] to [Local7].
+func (f Facility) IsKnown() bool {
+	return f <= Local7
+}
+
 func (fs Facilities) Filter() Filter {
 	return func(m *Message) bool {
 		for _, s := range fs {
@@ -102,3 +107,57 @@ func (fs Facilities) Filter() Filter {
 		return false
 	}
 }
+
+// RestrictFacilities creates an accept filter for a listener that only accepts messages with
+// one of the facilities fs, such as local4 to local7 for a DMZ listener, and which also match
+// accept. Messages with any other facility are discarded before they are queued, and are
+// counted (see [Server.FacilityViolations]). If alert is not nil, it is called with each of
+// them, which helps to detect misconfigured or spoofing devices early; it must return quickly.
+func (s *Server) RestrictFacilities(fs Facilities, alert func(*Message), accept Filter) Filter {
+	allowed := fs.Filter()
+	return func(m *Message) bool {
+		if !allowed(m) {
+			s.facilityViolations.Add(1)
+			if alert != nil {
+				alert(m)
+			}
+			return false
+		}
+		return accept(m)
+	}
+}
+
+// FacilityViolations returns the number of messages discarded because their facility was
+// not allowed by a listener. See [Server.RestrictFacilities].
+func (s *Server) FacilityViolations() uint64 {
+	return s.facilityViolations.Load()
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// FacilityMapper alters the facility of a message. Some devices send priorities implying
+// a facility beyond [Local7]; these render as "unknown" unless they are remapped.
+// See [Server.SetFacilityMapper].
+type FacilityMapper func(Facility) Facility
+
+// ClampFacilities returns a [FacilityMapper] that maps every unknown facility to f.
+func ClampFacilities(f Facility) FacilityMapper {
+	return func(x Facility) Facility {
+		if x.IsKnown() {
+			return x
+		}
+		return f
+	}
+}
+
+// RemapFacilities returns a [FacilityMapper] that looks up each facility in table.
+// Facilities not in the table are unchanged, so unknown values are preserved and
+// remain available via the %f format directive.
+func RemapFacilities(table map[Facility]Facility) FacilityMapper {
+	return func(x Facility) Facility {
+		if f, exists := table[x]; exists {
+			return f
+		}
+		return x
+	}
+}
@@ -17,3 +17,32 @@ func TestFacilitiesFilter(t *testing.T) {
 	expect.Bool(fs.Filter()(&Message{Facility: User})).ToBeTrue(t)
 	expect.Bool(fs.Filter()(&Message{Facility: Auth})).ToBeFalse(t)
 }
+
+func TestServer_RestrictFacilities(t *testing.T) {
+	s := NewServer()
+	defer s.Shutdown()
+
+	var alerts []string
+	accept := s.RestrictFacilities(Facilities{Local4, Local5, Local6, Local7},
+		func(m *Message) { alerts = append(alerts, m.Hostname) },
+		Severities{Notice}.Filter())
+
+	expect.Bool(s.receive([]byte("<165>1 - fw1 app - - - ok"), nil, accept) != nil).ToBeTrue(t)
+	expect.Bool(s.receive([]byte("<166>1 - fw1 app - - - info"), nil, accept) != nil).ToBeFalse(t)
+	expect.Bool(s.receive([]byte("<37>1 - spoofer app - - - auth"), nil, accept) != nil).ToBeFalse(t)
+
+	expect.Number(s.FacilityViolations()).ToBe(t, 1)
+	expect.Slice(alerts).ToBe(t, "spoofer")
+}
+
+func TestFacilityMapper(t *testing.T) {
+	clamp := ClampFacilities(Local7)
+	expect.Number(clamp(User)).ToBe(t, User)
+	expect.Number(clamp(Facility(30))).ToBe(t, Local7)
+
+	remap := RemapFacilities(map[Facility]Facility{24: Local0, Mail: User})
+	expect.Number(remap(Facility(24))).ToBe(t, Local0)
+	expect.Number(remap(Mail)).ToBe(t, User)
+	expect.Number(remap(Facility(25))).ToBe(t, Facility(25))
+	expect.String(Facility(25).String()).ToBe(t, "unknown")
+}
@@ -0,0 +1,79 @@
+package syslog
+
+import (
+	"io"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// Fault describes a failure to be injected into a write, for testing how a pipeline
+// behaves when its outputs misbehave. The zero Fault injects nothing.
+type Fault struct {
+	Delay   time.Duration // how long to stall before writing
+	Err     error         // if not nil, the write fails with this error
+	Partial int           // when Err is set, how many bytes are written before failing
+}
+
+// FaultPolicy decides what fault, if any, to inject into each write made by a handler.
+// It is given the name of the file or address being written and the size of the write.
+// Fault policies are intended for resilience testing, not for production use; see
+// [FileHandler.SetFaultPolicy] and [ReplicationHandler.SetFaultPolicy].
+type FaultPolicy func(target string, size int) Fault
+
+// FailEvery returns a policy that injects fault f into every nth write, starting with
+// the nth.
+func FailEvery(n int, f Fault) FaultPolicy {
+	var count atomic.Int64
+	return func(string, int) Fault {
+		if n > 0 && count.Add(1)%int64(n) == 0 {
+			return f
+		}
+		return Fault{}
+	}
+}
+
+// FailRandomly returns a policy that injects fault f into writes with the given
+// probability, between 0 and 1.
+func FailRandomly(probability float64, f Fault) FaultPolicy {
+	return func(string, int) Fault {
+		if rand.Float64() < probability {
+			return f
+		}
+		return Fault{}
+	}
+}
+
+// faultyWriter injects the faults chosen by a policy into the writes to w.
+type faultyWriter struct {
+	w      io.Writer
+	target string
+	policy FaultPolicy
+}
+
+// withFaults wraps w if there is a fault policy; otherwise it returns w unchanged.
+func withFaults(w io.Writer, target string, policy FaultPolicy) io.Writer {
+	if policy == nil {
+		return w
+	}
+	return faultyWriter{w: w, target: target, policy: policy}
+}
+
+func (fw faultyWriter) Write(bs []byte) (int, error) {
+	f := fw.policy(fw.target, len(bs))
+	if f.Delay > 0 {
+		time.Sleep(f.Delay)
+	}
+	if f.Err == nil {
+		return fw.w.Write(bs)
+	}
+
+	n := 0
+	if f.Partial > 0 {
+		var err error
+		if n, err = fw.w.Write(bs[:min(f.Partial, len(bs))]); err != nil {
+			return n, err
+		}
+	}
+	return n, f.Err
+}
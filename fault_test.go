@@ -0,0 +1,73 @@
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestFaultyWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := withFaults(&buf, "buf", FailEvery(2, Fault{Err: errors.New("boom"), Partial: 3}))
+
+	n, err := w.Write([]byte("first\n"))
+	expect.Number(n).ToBe(t, 6)
+	expect.Error(err).ToBeNil(t)
+
+	n, err = w.Write([]byte("second\n"))
+	expect.Number(n).ToBe(t, 3)
+	expect.Error(err).ToContain(t, "boom")
+
+	expect.String(buf.String()).ToBe(t, "first\nsec")
+	expect.Bool(withFaults(&buf, "buf", nil) == &buf).ToBeTrue(t)
+}
+
+func TestFileHandler_SetFaultPolicy(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.log")
+	h := NewFileHandler(filename, "%C")
+	h.SetFaultPolicy(FailEvery(2, Fault{Err: errors.New("disk full")}))
+
+	for _, s := range []string{"a", "b", "c"} {
+		h.Handle(&Message{Content: s})
+	}
+	h.Handle(nil)
+
+	bs, err := os.ReadFile(filename)
+	expect.Error(err).ToBeNil(t)
+	expect.String(string(bs)).ToBe(t, "a\nc\n")
+}
+
+func TestReplicationHandler_SetFaultPolicy(t *testing.T) {
+	received := make(chan *Message, 2)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	expect.Error(s.ListenReplica("127.0.0.1:0", nil, AcceptEverything)).ToBeNil(t)
+
+	h := NewReplicationHandler(s.listeners[0].Addr().String(), nil)
+	h.SetTimeout(time.Second)
+	// the first write is lost, so the message must be resent on a new connection
+	writes := 0
+	h.SetFaultPolicy(func(string, int) Fault {
+		if writes++; writes == 1 {
+			return Fault{Err: errors.New("connection reset")}
+		}
+		return Fault{}
+	})
+	h.Handle(&Message{Version: 1, Hostname: "myhost", Content: "hello"})
+	h.Handle(nil)
+
+	expect.String((<-received).Content).ToBe(t, "hello")
+	expect.Number(len(received)).ToBe(t, 0)
+}
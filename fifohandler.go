@@ -0,0 +1,150 @@
+package syslog
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// FIFOHandler is a [Handler] that writes messages into a named pipe (FIFO), matching the
+// "|/path/to/fifo" action of classic syslog.conf that is used to feed analysers. The pipe
+// is created if it does not exist.
+//
+// Whilst no process is reading the pipe, messages are dropped, or retained in a bounded
+// buffer (see [FIFOHandler.SetBuffer]) and written when a reader appears. Dropped messages
+// are counted (see [FIFOHandler.Dropped]). All messages are passed on to subsequent
+// handlers. It is safe for concurrent use.
+type FIFOHandler struct {
+	acceptFunc Filter
+	format     string
+	path       string
+	limit      int
+	mu         sync.Mutex // guards the fields below
+	f          *os.File
+	pending    [][]byte
+	partial    bool // whether the first pending message has been partly written
+	dropped    atomic.Uint64
+}
+
+// NewFIFOHandler creates a handler that writes messages to the named pipe at path in the
+// specified format, e.g. [RFCFormat]. All messages are accepted.
+func NewFIFOHandler(path, format string) *FIFOHandler {
+	return &FIFOHandler{
+		acceptFunc: AcceptEverything,
+		format:     format,
+		path:       path,
+	}
+}
+
+// SetFilter changes the function used to decide which messages are written.
+func (h *FIFOHandler) SetFilter(acceptFunc Filter) {
+	h.acceptFunc = acceptFunc
+}
+
+// SetBuffer sets how many messages are retained whilst there is no reader. When the
+// buffer is full, the oldest messages are dropped. The default is zero, i.e. messages
+// are dropped immediately.
+func (h *FIFOHandler) SetBuffer(messages int) {
+	h.limit = max(messages, 0)
+}
+
+// Dropped returns the number of messages dropped because there was no reader.
+func (h *FIFOHandler) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+func (h *FIFOHandler) Handle(m *Message) *Message {
+	if m == nil {
+		h.close()
+	} else if h.acceptFunc(m) {
+		line := []byte(m.Format(h.format) + "\n")
+		h.mu.Lock()
+		h.pending = append(h.pending, line)
+		h.flush()
+		h.mu.Unlock()
+	}
+	return m
+}
+
+func (h *FIFOHandler) flush() {
+	if h.f == nil && !h.open() {
+		h.discardExcess()
+		return
+	}
+
+	for len(h.pending) > 0 {
+		n, err := writeNonBlocking(h.f, h.pending[0])
+		if n > 0 {
+			h.pending[0] = h.pending[0][n:]
+			h.partial = len(h.pending[0]) > 0
+		}
+		if err != nil {
+			if !wouldBlock(err) {
+				checkErr(h.closeFile(), "close", h.path) // the reader has gone away; re-open on the next message
+			}
+			break
+		}
+		if h.partial {
+			break // the pipe is full; the rest is written later
+		}
+		h.pending = h.pending[1:]
+	}
+
+	h.discardExcess()
+}
+
+// discardExcess drops the oldest messages beyond the limit, except that the rest of a
+// partly written message is kept so that the reader does not see a broken line.
+func (h *FIFOHandler) discardExcess() {
+	keep := 0
+	if h.partial {
+		keep = 1
+	}
+	if excess := len(h.pending) - max(h.limit, keep); excess > 0 {
+		h.dropped.Add(uint64(excess))
+		h.pending = append(h.pending[:keep], h.pending[keep+excess:]...)
+	}
+}
+
+func (h *FIFOHandler) open() bool {
+	if !fileExists(h.path) {
+		if checkErr(mkfifo(h.path), "mkfifo", h.path) {
+			return false
+		}
+	}
+
+	// opening fails with ENXIO if there is no reader
+	f, err := os.OpenFile(h.path, os.O_WRONLY|oNonBlock, 0)
+	if err != nil {
+		return false
+	}
+	h.f = f
+	return true
+}
+
+// Close closes the pipe; it is re-opened if another message is handled. It is safe to call
+// more than once.
+func (h *FIFOHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closeFile()
+}
+
+func (h *FIFOHandler) closeFile() error {
+	if h.f == nil {
+		return nil
+	}
+	if h.partial {
+		// a new reader would only get the end of the message
+		h.pending = h.pending[1:]
+		h.partial = false
+		h.dropped.Add(1)
+	}
+	err := h.f.Close()
+	h.f = nil
+	return err
+}
+
+func (h *FIFOHandler) close() {
+	checkErr(h.Close(), "close", h.path)
+}
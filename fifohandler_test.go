@@ -0,0 +1,73 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package syslog
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestFIFOHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fifo")
+	h := NewFIFOHandler(path, "%C")
+	h.SetBuffer(1)
+
+	// no reader yet
+	h.Handle(&Message{Content: "one"})
+	h.Handle(&Message{Content: "two"})
+	expect.Number(h.Dropped()).ToBe(t, 1)
+
+	r, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	expect.Error(err).ToBeNil(t)
+	defer r.Close()
+
+	h.Handle(&Message{Content: "three"})
+	h.Handle(nil)
+
+	lines := bufio.NewScanner(r)
+	expect.Bool(lines.Scan()).ToBeTrue(t)
+	expect.String(lines.Text()).ToBe(t, "two")
+	expect.Bool(lines.Scan()).ToBeTrue(t)
+	expect.String(lines.Text()).ToBe(t, "three")
+}
+
+func TestFIFOHandler_partialWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fifo")
+	expect.Error(mkfifo(path)).ToBeNil(t)
+	r, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	expect.Error(err).ToBeNil(t)
+	defer r.Close()
+
+	h := NewFIFOHandler(path, "%C")
+	h.SetBuffer(100)
+	defer h.Close()
+
+	// more than the pipe can hold, so that a message is only partly written
+	line := strings.Repeat("x", 10000)
+	for i := 0; i < 10; i++ {
+		h.Handle(&Message{Content: line})
+	}
+	expect.Bool(h.partial).ToBeTrue(t)
+
+	want := strings.Repeat(line+"\n", 10)
+	var got []byte
+	buf := make([]byte, 128*1024)
+	for len(got) < len(want) {
+		n, err := r.Read(buf)
+		expect.Error(err).ToBeNil(t)
+		got = append(got, buf[:n]...)
+
+		h.mu.Lock()
+		h.flush()
+		h.mu.Unlock()
+	}
+
+	expect.String(string(got)).ToBe(t, want)
+	expect.Number(h.Dropped()).ToBe(t, 0)
+}
@@ -5,23 +5,38 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 // FileHandler implements [Handler] interface such that messages are written into a
 // text file (or files). It properly handles logrotate HUP signal (closes a file and tries
 // to open/create new one). Alternatively, it can be configured to perform log file rotation.
+// It is safe for concurrent use (see [WithWorkers]).
 type FileHandler struct {
+	mu           sync.Mutex // guards the open files and the buffer
 	acceptFunc   Filter
 	fm           filenameMangler
-	f            map[fileID]io.StringWriter
+	f            map[fileID]io.Writer
+	unknown      Handler
 	format       string
+	encoder      Encoder
 	retain       int // built-in log rotation when in O_TRUNC mode
 	appendMode   int
 	propagateAll bool
+	locking      bool
+	faults       FaultPolicy
+	createdAt    time.Time
+	opened       map[string]time.Time // used to detect rotation by other instances
+	buf          []byte               // reused for each message
+	compression  CompressionPolicy
+	shardCount   int
+	shardKey     ShardKey
+	nextShard    int
+	shards       []*fileShard // started when the first shard file is opened
 }
 
 // NewFileHandler handles syslog messages by writing them to a file or files.
@@ -36,20 +51,28 @@ type FileHandler struct {
 // This will result in log messages being separated into multiple files or folders
 // according to the hostname and program name of each log message. Should a message
 // arrive with an unknown hostname or program name, "unknown" will be substituted in
-// either case.
+// either case; this can be changed using [FileHandler.SetFallback].
 //
 // By default, I/O errors are written to [os.Stderr] using [syslog.Logger].
 func NewFileHandler(filename, format string) *FileHandler {
 	h := &FileHandler{
 		fm:         newFilenameMangler(filename),
-		f:          make(map[fileID]io.StringWriter),
+		f:          make(map[fileID]io.Writer),
 		format:     format,
 		appendMode: os.O_APPEND,
 		acceptFunc: func(*Message) bool { return true },
+		createdAt:  time.Now(),
+		opened:     make(map[string]time.Time),
 	}
 	return h
 }
 
+// SetEncoder sets an [Encoder], such as a [CSVEncoder] or [Template], to render messages
+// instead of the format string. Use nil to revert to the format string.
+func (h *FileHandler) SetEncoder(e Encoder) {
+	h.encoder = e
+}
+
 // SetRotate configures the FileHandler to rotate pre-existing files before new ones
 // are opened. The number of pre-existing files to be retained is specified. Each
 // retained file is gzipped and follows the number sequence "file.log.1.gz",
@@ -70,6 +93,36 @@ func (h *FileHandler) SetRotate(retain int) {
 	}
 }
 
+// SetCompression sets the policy that chooses how each rotated file is compressed, e.g.
+// [AdaptiveCompression]; the file is sampled before it is compressed. Rotated files are
+// always gzip files, although the data may be stored uncompressed in them. Use nil for the
+// default, level 5.
+func (h *FileHandler) SetCompression(policy CompressionPolicy) {
+	h.compression = policy
+}
+
+// SetLocking enables advisory file locking, so that several collector instances can safely
+// share the same log files (e.g. two instances on one host for high availability). When
+// enabled, each message is written whilst holding a lock on its log file, and log rotation
+// is coordinated via a "file.log.lock" file alongside each log file so that only one
+// instance rotates it; the others notice and carry on in the new file. See also [FileLock].
+//
+// Locking is only supported on Linux, macOS and the BSDs; enabling it returns an error on
+// Windows and all other platforms.
+func (h *FileHandler) SetLocking(on bool) error {
+	if on && !lockSupported {
+		return errLockUnsupported
+	}
+	h.locking = on
+	return nil
+}
+
+// SetFaultPolicy injects faults into the writes to the log files, so that the behaviour of
+// a pipeline under failing storage can be tested. Use nil (the default) for normal operation.
+func (h *FileHandler) SetFaultPolicy(policy FaultPolicy) {
+	h.faults = policy
+}
+
 // SetFilter changes the function used to decide whether each message should be
 // processed or discarded. The acceptFunc determines which messages are written;
 // if this is nil, it accepts all messages.
@@ -77,6 +130,21 @@ func (h *FileHandler) SetFilter(acceptFunc Filter) {
 	h.acceptFunc = acceptFunc
 }
 
+// SetFallback changes the value substituted for a filename placeholder (e.g. "%hostname%")
+// when the corresponding message field is blank or unknown. The default is "unknown".
+// For example, the fallback for "%hostname%" could be "_quarantine".
+func (h *FileHandler) SetFallback(placeholder, value string) {
+	h.fm.fallback[placeholder] = value
+}
+
+// SetUnknownHandler sets a handler that receives, instead of the file, any message for
+// which a filename placeholder would need its fallback value. This allows such messages
+// to be investigated separately. The FileHandler owns the unknown handler, so it is shut
+// down when the FileHandler is shut down. If unknown is nil, fallback values are used.
+func (h *FileHandler) SetUnknownHandler(unknown Handler) {
+	h.unknown = unknown
+}
+
 // SetPropagateAll changes whether downstream handlers see all the rejected messages.
 // If propagateAll is true, downstream handles also see the accepted messages. Otherwise,
 // rejected messages are silently discarded (the default).
@@ -87,17 +155,39 @@ func (h *FileHandler) SetPropagateAll(propagateAll bool) {
 // SigHup closes any open files. If log rotation is enabled, it will occur as needed when
 // log files are re-opened. If 'logrotate' is being used, rotation will happen externally.
 func (h *FileHandler) SigHup() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	if h.f != nil {
 		checkErr(h.closeFiles())
-		h.f = nil
 		// file will re-open in next call to saveMessage
 	}
 }
 
+// Close closes any open files and the unknown handler, if there is one (see
+// [FileHandler.SetUnknownHandler]). Files are re-opened if another message is handled.
+// It is safe to call more than once.
+func (h *FileHandler) Close() error {
+	h.mu.Lock()
+	err := h.closeFiles()
+	h.stopShards()
+	h.mu.Unlock()
+	if h.unknown != nil {
+		err = errors.Join(err, closeHandler(h.unknown))
+	}
+	return err
+}
+
 func (h *FileHandler) Handle(m *Message) *Message {
 	if m == nil {
-		checkErr(h.closeFiles())
+		checkErr(h.Close())
 	} else if h.acceptFunc(m) {
+		if h.unknown != nil && h.fm.hasUnknown(m) {
+			m = h.unknown.Handle(m)
+			if h.propagateAll {
+				return m
+			}
+			return nil
+		}
 		h.saveMessage(m)
 		if h.propagateAll {
 			return m
@@ -123,32 +213,118 @@ func (h *FileHandler) closeFiles() error {
 	return nil
 }
 
+// fileExists reports whether path exists; errors other than non-existence are logged and
+// treated as the file being absent, so that the subsequent operation reports the problem.
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		log.Fatalln(err)
+		checkErr(err, "stat", path)
 	}
 	return err == nil
 }
 
 func (h *FileHandler) saveMessage(m *Message) {
-	id := h.fm.id(m)
-	f := h.f[id]
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	var err error
+	id := h.fm.id(m)
+	id.Shard = h.shard(m)
+	f, unlock := h.writer(id, m)
 	if f == nil {
-		filename := h.fm.name(m)
+		return
+	}
+	defer unlock()
 
-		f, err = h.openFile(filename)
-		if checkErr(err) {
+	var err error
+	if h.encoder != nil {
+		h.buf, err = h.encoder.AppendFormat(h.buf[:0], m)
+		if checkErr(err, "encode", h.fm.name(m)) {
 			return
 		}
+		h.buf = append(h.buf, '\n')
+	} else {
+		h.buf = append(m.AppendFormat(h.buf[:0], h.format), '\n')
+	}
+	if h.faults != nil {
+		f = withFaults(f, h.fm.name(m), h.faults)
+	}
+	checkErr2(f.Write(h.buf))
+}
+
+// writer gets the writer for a file, opening the file if necessary. When locking, the file
+// stays locked until unlock is called, and it is re-opened first if another instance has
+// rotated it since it was opened. Shard files are instead locked by their goroutines for
+// each write. It returns nil if the file cannot be opened or locked.
+func (h *FileHandler) writer(id fileID, m *Message) (w io.Writer, unlock func()) {
+	for {
+		f := h.f[id]
+		if f == nil {
+			filename := shardName(h.fm.name(m), id.Shard)
+
+			file, err := h.openFile(filename)
+			if checkErr(err) {
+				return nil, nil
+			}
+			h.writeHeader(file)
+
+			f = file
+			if id.Shard >= 0 {
+				f = h.shardFile(file, id.Shard)
+			}
+			h.f[id] = f
+		}
+
+		if !h.locking {
+			return f, func() {}
+		}
 
-		h.f[id] = f
+		file, sharded := f.(*os.File), false
+		if sw, ok := f.(*shardWriter); ok {
+			file, sharded = sw.f, true
+		} else if checkErr(flock(file, true), "lock", file.Name()) {
+			return nil, nil
+		}
+
+		if !replaced(file) {
+			if sharded {
+				return f, func() {}
+			}
+			return f, func() { funlock(file) }
+		}
+
+		// another instance has rotated the file; carry on in the new one
+		if !sharded {
+			funlock(file)
+		}
+		checkErr(f.(io.Closer).Close(), "close", file.Name())
+		delete(h.f, id)
 	}
+}
 
-	checkErr2(f.WriteString(m.Format(h.format)))
-	checkErr2(f.WriteString("\n"))
+// replaced is true if the name of an open file now refers to a different file, or to none,
+// e.g. because the file has been rotated.
+func replaced(f *os.File) bool {
+	current, err := os.Stat(f.Name())
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	open, err2 := f.Stat()
+	if err != nil || err2 != nil {
+		return false
+	}
+	return !os.SameFile(open, current)
+}
+
+// writeHeader writes the header of the encoder, if it has one, to a new or empty file.
+func (h *FileHandler) writeHeader(f *os.File) {
+	e, ok := h.encoder.(interface{ Header() []byte })
+	if !ok {
+		return
+	}
+	if fi, err := f.Stat(); checkErr(err, "stat", f.Name()) || fi.Size() > 0 {
+		return
+	}
+	checkErr2(f.Write(append(e.Header(), '\n')))
 }
 
 const tmp = ".tmp"
@@ -159,15 +335,51 @@ func (h *FileHandler) openFile(filename string) (*os.File, error) {
 	}
 
 	if h.appendMode == os.O_TRUNC {
-		if fileExists(filename) {
-			// rename so we can use a goroutine
-			if !checkErr(os.Rename(filename, filename+tmp), "mv", filename, filename+tmp) {
-				go h.logRotate(filename)
+		if h.locking {
+			l, err := Lock(filename + ".lock")
+			if err != nil {
+				return nil, err
+			}
+			defer l.Unlock()
+
+			previous, seen := h.opened[filename]
+			if !seen {
+				previous = h.createdAt
 			}
+
+			// unless another instance has already rotated this file since we last opened it
+			if !l.stamp().After(previous) {
+				h.rotate(filename)
+				checkErr(l.setStamp(time.Now()), "stamp", filename+".lock")
+			}
+		} else {
+			h.rotate(filename)
 		}
 	}
 
-	return os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|h.appendMode, 0620)
+	// always appending, so that instances sharing the file do not overwrite each other;
+	// it is emptied by rotation rather than by truncation
+	h.opened[filename] = time.Now()
+	return os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0620)
+}
+
+func (h *FileHandler) rotate(filename string) {
+	if fileExists(filename) {
+		if h.locking {
+			// wait for other instances to finish writing; they re-open the file afterwards
+			if f, err := os.Open(filename); !checkErr(err, "open", filename) {
+				defer f.Close()
+				if !checkErr(flock(f, true), "lock", filename) {
+					defer funlock(f)
+				}
+			}
+		}
+
+		// rename so we can use a goroutine
+		if !checkErr(os.Rename(filename, filename+tmp), "mv", filename, filename+tmp) {
+			go h.logRotate(filename)
+		}
+	}
 }
 
 func (h *FileHandler) logRotate(filename string) {
@@ -199,7 +411,11 @@ func (h *FileHandler) logRotate(filename string) {
 		return
 	}
 
-	gz, err := gzip.NewWriterLevel(o, 5) // level 5 is both quite good and quite fast
+	level := defaultCompressionLevel
+	if fi, err := in.Stat(); !checkErr(err, "stat", tmpFile) {
+		level = compressionLevel(h.compression, in, fi.Size())
+	}
+	gz, err := gzip.NewWriterLevel(o, level)
 	if err != nil {
 		Logger.Println("gzip", old, err)
 		return
@@ -238,6 +454,7 @@ type filenameMangler struct {
 	HasFacility    bool
 	HasSeverity    bool
 	template       string
+	fallback       map[string]string
 }
 
 const (
@@ -254,14 +471,30 @@ func newFilenameMangler(template string) filenameMangler {
 		HasFacility:    strings.Index(template, facilityPlaceholder) >= 0,
 		HasSeverity:    strings.Index(template, severityPlaceholder) >= 0,
 		template:       template,
+		fallback:       make(map[string]string),
 	}
 }
 
+func (fm filenameMangler) fallbackFor(placeholder string) string {
+	if f, exists := fm.fallback[placeholder]; exists {
+		return f
+	}
+	return "unknown"
+}
+
+// hasUnknown is true if any placeholder in the template would need its fallback value.
+func (fm filenameMangler) hasUnknown(m *Message) bool {
+	return (fm.HasHostname && isBlank(m.Hostname)) ||
+		(fm.HasApplication && isBlank(m.Application)) ||
+		(fm.HasFacility && !m.Facility.IsKnown())
+}
+
 type fileID struct {
 	Hostname    string
 	Application string
 	Facility    string
 	Severity    string
+	Shard       int
 }
 
 func (fm filenameMangler) id(m *Message) fileID {
@@ -284,24 +517,31 @@ func (fm filenameMangler) id(m *Message) fileID {
 func (fm filenameMangler) name(m *Message) string {
 	name := fm.template
 	if fm.HasHostname {
-		name = strings.ReplaceAll(name, hostnamePlaceholder, ifBlank(m.Hostname, "unknown"))
+		name = strings.ReplaceAll(name, hostnamePlaceholder, ifBlank(m.Hostname, fm.fallbackFor(hostnamePlaceholder)))
 	}
 	if fm.HasApplication {
-		name = strings.ReplaceAll(name, programNamePlaceholder, ifBlank(m.Application, "unknown"))
+		name = strings.ReplaceAll(name, programNamePlaceholder, ifBlank(m.Application, fm.fallbackFor(programNamePlaceholder)))
 	}
 	if fm.HasFacility {
-		name = strings.ReplaceAll(name, facilityPlaceholder, ifBlank(m.Facility.String(), "unknown"))
+		facility := m.Facility.String()
+		if !m.Facility.IsKnown() {
+			facility = fm.fallbackFor(facilityPlaceholder)
+		}
+		name = strings.ReplaceAll(name, facilityPlaceholder, facility)
 	}
 	if fm.HasSeverity {
-		name = strings.ReplaceAll(name, severityPlaceholder, ifBlank(m.Severity.String(), "unknown"))
+		name = strings.ReplaceAll(name, severityPlaceholder, ifBlank(m.Severity.String(), fm.fallbackFor(severityPlaceholder)))
 	}
 	return name
 }
 
 func ifBlank(s, d string) string {
-	switch s {
-	case "", "-":
+	if isBlank(s) {
 		return d
 	}
 	return s
 }
+
+func isBlank(s string) bool {
+	return s == "" || s == "-"
+}
@@ -3,6 +3,7 @@ package syslog
 import (
 	"github.com/rickb777/expect"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -24,6 +25,50 @@ func TestFilenameMangler(t *testing.T) {
 	expect.String(fm.name(&Message{})).ToBe(t, "/var/log/unknown/kern/unknown-emerg.log")
 }
 
+func TestFilenameMangler_fallback(t *testing.T) {
+	fm := newFilenameMangler("/var/log/%hostname%/%facility%/%programname%.log")
+	fm.fallback[hostnamePlaceholder] = "_quarantine"
+	fm.fallback[facilityPlaceholder] = "other"
+
+	m := &Message{Application: "-", Facility: Facility(30)}
+	expect.Bool(fm.hasUnknown(m)).ToBeTrue(t)
+	expect.String(fm.name(m)).ToBe(t, "/var/log/_quarantine/other/unknown.log")
+
+	m = &Message{Hostname: "myhost", Application: "myapp", Facility: Daemon}
+	expect.Bool(fm.hasUnknown(m)).ToBeFalse(t)
+	expect.String(fm.name(m)).ToBe(t, "/var/log/myhost/daemon/myapp.log")
+}
+
+func TestFileHandler_unknownHandler(t *testing.T) {
+	var seen []*Message
+	h := NewFileHandler("/nonexistent/%hostname%.log", RFCFormat)
+	h.SetUnknownHandler(handlerFunc(func(m *Message) *Message {
+		seen = append(seen, m)
+		return m
+	}))
+
+	m := &Message{Content: "no hostname"}
+	expect.Bool(h.Handle(m) == nil).ToBeTrue(t)
+	expect.Slice(seen).ToBe(t, m)
+}
+
+func TestFileHandler_SigHup(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	h := NewFileHandler(filename, "%C")
+	defer h.Close()
+
+	h.Handle(&Message{Content: "one"})
+	h.SigHup()
+	h.Handle(&Message{Content: "two"}) // re-opens the file
+
+	bs, err := os.ReadFile(filename)
+	expect.String(string(bs), err).ToBe(t, "one\ntwo\n")
+}
+
+type handlerFunc func(*Message) *Message
+
+func (f handlerFunc) Handle(m *Message) *Message { return f(m) }
+
 func TestLogrotate(t *testing.T) {
 	const filename = "./temp.log"
 	expect.Error(os.WriteFile(filename+tmp, []byte("this is file 1\n"), 0644)).ToBeNil(t)
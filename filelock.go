@@ -0,0 +1,83 @@
+package syslog
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned by [TryLock] when another process holds the lock.
+var ErrLocked = errors.New("file is locked by another process")
+
+var errLockUnsupported = errors.New("file locking is not supported on this platform")
+
+// FileLock is an advisory lock on a file. It is used to coordinate multiple collector
+// instances that run on the same host or share the same storage, e.g. for high availability.
+//
+// The operating system releases the lock automatically if the process dies, which makes
+// FileLock suitable for active/standby election: every instance calls [Lock] on the same
+// path and only the one that returns becomes active; the others wait in standby.
+type FileLock struct {
+	f *os.File
+}
+
+// Lock acquires an exclusive lock on the file at path, creating it if necessary. It blocks
+// until the lock is available.
+func Lock(path string) (*FileLock, error) {
+	return lockFile(path, true)
+}
+
+// TryLock acquires an exclusive lock on the file at path, creating it if necessary. If the
+// lock is held by another process, it returns [ErrLocked] immediately.
+func TryLock(path string) (*FileLock, error) {
+	return lockFile(path, false)
+}
+
+func lockFile(path string, block bool) (*FileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = flock(f, block); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &FileLock{f: f}, nil
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err1 := funlock(l.f)
+	err2 := l.f.Close()
+	l.f = nil
+	return errors.Join(err1, err2)
+}
+
+// stamp reads the time stored in the lock file, or the zero time if there is none.
+func (l *FileLock) stamp() time.Time {
+	if _, err := l.f.Seek(0, io.SeekStart); err != nil {
+		return time.Time{}
+	}
+	bs, err := io.ReadAll(l.f)
+	if err != nil {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(bs)))
+	return t
+}
+
+// setStamp stores a time in the lock file.
+func (l *FileLock) setStamp(t time.Time) error {
+	if err := l.f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := l.f.WriteAt([]byte(t.Format(time.RFC3339Nano)+"\n"), 0)
+	return err
+}
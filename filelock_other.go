@@ -0,0 +1,17 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package syslog
+
+import (
+	"os"
+)
+
+const lockSupported = false
+
+func flock(*os.File, bool) error {
+	return errLockUnsupported
+}
+
+func funlock(*os.File) error {
+	return errLockUnsupported
+}
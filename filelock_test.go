@@ -0,0 +1,63 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package syslog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestTryLock(t *testing.T) {
+	const filename = "./temp.lock"
+	defer os.Remove(filename)
+
+	l1, err := TryLock(filename)
+	expect.Error(err).ToBeNil(t)
+
+	_, err = TryLock(filename)
+	expect.Bool(err == ErrLocked).ToBeTrue(t)
+
+	tx := time.Date(2023, 10, 26, 15, 31, 1, 0, time.UTC)
+	expect.Error(l1.setStamp(tx)).ToBeNil(t)
+	expect.Any(l1.stamp()).ToBe(t, tx)
+
+	expect.Error(l1.Unlock()).ToBeNil(t)
+
+	l2, err := TryLock(filename)
+	expect.Error(err).ToBeNil(t)
+	expect.Error(l2.Unlock()).ToBeNil(t)
+
+	expect.Error(NewFileHandler("temp.log", RFCFormat).SetLocking(true)).ToBeNil(t)
+}
+
+func TestFileHandler_SetLocking_rotation(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	h1 := NewFileHandler(filename, "%C")
+	h2 := NewFileHandler(filename, "%C")
+	for _, h := range []*FileHandler{h1, h2} {
+		h.SetRotate(1)
+		expect.Error(h.SetLocking(true)).ToBeNil(t)
+		defer h.Close()
+	}
+
+	h1.Handle(&Message{Content: "a1"})
+	h2.Handle(&Message{Content: "b1"})
+
+	// h2 rotates; h1 notices and follows it into the new file
+	h2.SigHup()
+	h2.Handle(&Message{Content: "b2"})
+	h1.Handle(&Message{Content: "a2"})
+
+	bs, err := os.ReadFile(filename)
+	expect.String(string(bs), err).ToBe(t, "b2\na2\n")
+
+	// wait for the rotated file to be compressed
+	for i := 0; i < 100 && fileExists(filename+tmp); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	expect.Bool(fileExists(filename + ".1.gz")).ToBeTrue(t)
+}
@@ -1,8 +1,22 @@
 package syslog
 
+import "time"
+
 // Filter is a predicate function for messages.
 type Filter func(*Message) bool
 
+// PriorityFilter is a predicate function that only depends on the message priority. Unlike a
+// [Filter], it can be applied before the rest of the message has been parsed; see
+// [Server.SetPriorityFilter].
+type PriorityFilter func(Facility, Severity) bool
+
+// Filter converts the priority filter to a [Filter].
+func (pf PriorityFilter) Filter() Filter {
+	return func(m *Message) bool {
+		return pf(m.Facility, m.Severity)
+	}
+}
+
 // AcceptEverything is a no-op filter.
 func AcceptEverything(*Message) bool { return true }
 
@@ -30,3 +44,19 @@ func Any(fs ...Filter) Filter {
 		return false
 	}
 }
+
+// Not inverts a filter, so that it accepts the messages that f rejects.
+func Not(f Filter) Filter {
+	return func(m *Message) bool {
+		return !f(m)
+	}
+}
+
+// OlderThan accepts messages whose timestamp is more than age before the time they were
+// received, such as those replayed by a device from its buffer after an outage. Messages
+// without a timestamp are never old. See [RouteHandler].
+func OlderThan(age time.Duration) Filter {
+	return func(m *Message) bool {
+		return !m.Timestamp.IsZero() && m.Time.Sub(m.Timestamp) > age
+	}
+}
@@ -0,0 +1,134 @@
+package syslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// RFC3164Format renders messages in the style of RFC 3164, without the RFC 5424 version,
+// process ID, message ID and structured data. The content of messages received in RFC 3164
+// format keeps the colon that follows the tag.
+const RFC3164Format = "<%Z>%T %H %A %C"
+
+// formatVerbs lists the verbs of [Message.Format].
+const formatVerbs = "ACDEFfHMNPQRSTVvYZ%"
+
+var formatRegistry = struct {
+	sync.RWMutex
+	formats map[string]string
+}{formats: map[string]string{
+	"rfc":     RFCFormat,
+	"rfc3164": RFC3164Format,
+}}
+
+// RegisterFormat registers a format (see [Message.Format]) under a name, so that it can be
+// referred to by name in configuration files and flags; see [ResolveFormat]. A format that
+// is already registered under the name is replaced. [RFCFormat] and [RFC3164Format] are
+// registered as "rfc" and "rfc3164". An error is returned if the name is blank or contains
+// '%' or spaces, or the format uses an unknown verb.
+func RegisterFormat(name, format string) error {
+	if err := checkFormat(name, format); err != nil {
+		return err
+	}
+
+	formatRegistry.Lock()
+	defer formatRegistry.Unlock()
+	formatRegistry.formats[name] = format
+	return nil
+}
+
+// LookupFormat gets the format registered under a name.
+func LookupFormat(name string) (string, bool) {
+	formatRegistry.RLock()
+	defer formatRegistry.RUnlock()
+	format, found := formatRegistry.formats[name]
+	return format, found
+}
+
+// ResolveFormat gets the format registered under s if there is one, and otherwise returns
+// s itself, taking it to be a format. Flags and settings that accept either a format or the
+// name of one can be resolved with this.
+func ResolveFormat(s string) string {
+	if format, found := LookupFormat(s); found {
+		return format
+	}
+	return s
+}
+
+// FormatNames lists the names of the registered formats, sorted.
+func FormatNames() []string {
+	formatRegistry.RLock()
+	defer formatRegistry.RUnlock()
+	names := make([]string, 0, len(formatRegistry.formats))
+	for name := range formatRegistry.formats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// LoadFormats registers the formats defined in r, one per line as name=format, so that
+// teams can share the definitions between services, e.g.
+//
+//	# formats.conf
+//	audit = <%Z>%V %T %H %A %P %M %D %C
+//	brief = %T %H %A: %C
+//
+// Blank lines and those starting with '#' are ignored, as are spaces around the name and
+// the format. All the lines are checked; the formats are only registered if there are no
+// errors.
+func LoadFormats(r io.Reader) error {
+	type entry struct{ name, format string }
+	var entries []entry
+
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, format, found := strings.Cut(text, "=")
+		name, format = strings.TrimSpace(name), strings.TrimSpace(format)
+		if !found {
+			return fmt.Errorf("line %d: expected name=format", line)
+		}
+		if err := checkFormat(name, format); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry{name, format})
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	formatRegistry.Lock()
+	defer formatRegistry.Unlock()
+	for _, e := range entries {
+		formatRegistry.formats[e.name] = e.format
+	}
+	return nil
+}
+
+// checkFormat reports an invalid name, or unknown verbs in a format.
+func checkFormat(name, format string) error {
+	if name == "" || strings.ContainsAny(name, "% \t") {
+		return fmt.Errorf("%q: invalid format name", name)
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		if i == len(format) {
+			return fmt.Errorf("%s: format ends with %%", name)
+		}
+		if strings.IndexByte(formatVerbs, format[i]) < 0 {
+			return fmt.Errorf("%s: unknown format verb %%%c", name, format[i])
+		}
+	}
+	return nil
+}
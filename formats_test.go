@@ -0,0 +1,48 @@
+package syslog
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestRegisterFormat(t *testing.T) {
+	expect.String(ResolveFormat("rfc")).ToBe(t, RFCFormat)
+	expect.String(ResolveFormat("rfc3164")).ToBe(t, RFC3164Format)
+	expect.String(ResolveFormat("%H %C")).ToBe(t, "%H %C")
+
+	expect.Error(RegisterFormat("t-audit", "<%Z>%V %T %H %A %%")).ToBeNil(t)
+	format, found := LookupFormat("t-audit")
+	expect.Bool(found).ToBeTrue(t)
+	expect.String(format).ToBe(t, "<%Z>%V %T %H %A %%")
+	expect.Bool(slices.Contains(FormatNames(), "t-audit")).ToBeTrue(t)
+
+	expect.Error(RegisterFormat("", "%H")).ToContain(t, "invalid format name")
+	expect.Error(RegisterFormat("a b", "%H")).ToContain(t, "invalid format name")
+	expect.Error(RegisterFormat("bad", "%H %X")).ToContain(t, "bad: unknown format verb %X")
+	expect.Error(RegisterFormat("bad", "%H %")).ToContain(t, "bad: format ends with %")
+}
+
+func TestLoadFormats(t *testing.T) {
+	err := LoadFormats(strings.NewReader("# shared formats\n\nt-brief = %T %H %A: %C\n t-host=%H \n"))
+	expect.Error(err).ToBeNil(t)
+	expect.String(ResolveFormat("t-brief")).ToBe(t, "%T %H %A: %C")
+	expect.String(ResolveFormat("t-host")).ToBe(t, "%H")
+
+	err = LoadFormats(strings.NewReader("t-ok = %H\nt-bad = %W\n"))
+	expect.Error(err).ToContain(t, "line 2: t-bad: unknown format verb %W")
+	_, found := LookupFormat("t-ok")
+	expect.Bool(found).ToBeFalse(t)
+
+	expect.Error(LoadFormats(strings.NewReader("nonsense"))).ToContain(t, "line 1: expected name=format")
+}
+
+func TestRFC3164Format(t *testing.T) {
+	m, err := parseMessage([]byte("<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed"))
+	expect.Error(err).ToBeNil(t)
+	m.Timestamp = time.Date(2024, 10, 11, 22, 14, 15, 0, time.UTC)
+	expect.String(m.Format(RFC3164Format)).ToBe(t, "<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed")
+}
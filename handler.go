@@ -1,8 +1,17 @@
 package syslog
 
-import "fmt"
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+)
 
-// Handler handles syslog messages
+// Handler handles syslog messages. Handlers that hold resources may also implement
+// [io.Closer]; if so, the server calls Close instead of Handle(nil) when it shuts down.
 type Handler interface {
 	// Handle should return [Message] (maybe modified) for further processing by
 	// other handlers, or return nil. If Handle is called with nil message it
@@ -10,6 +19,26 @@ type Handler interface {
 	Handle(*Message) *Message
 }
 
+// LazyHandler is a [Handler] that can handle messages that have not yet been parsed, when the
+// server uses lazy parsing (see [WithLazyParsing]). The server calls HandleLazy instead of
+// Handle; the message may be forwarded using its Raw bytes, or filtered on its Facility and
+// Severity, without paying for parsing. Call [Message.Parse] if other fields are needed.
+// Handlers that are not lazy always receive parsed messages.
+type LazyHandler interface {
+	Handler
+	HandleLazy(*Message) *Message
+}
+
+// BatchHandler is a [Handler] that can also handle several messages at once, which suits
+// bulk outputs. The server passes each batch of received messages to HandleBatch instead of
+// calling Handle for each message. HandleBatch should return the messages (maybe modified)
+// for further processing by other handlers; it may reuse the backing array of ms. Handle is
+// still used for the nil message at shutdown.
+type BatchHandler interface {
+	Handler
+	HandleBatch(ms []*Message) []*Message
+}
+
 //-------------------------------------------------------------------------------------------------
 
 // PrintHandler is a [Handler] that prints every message to stdout in a specified format, for
@@ -18,6 +47,11 @@ type Handler interface {
 // Simply convert the format string to a PrintHandler to use it.
 type PrintHandler string
 
+// Close does nothing; it implements [io.Closer].
+func (p PrintHandler) Close() error {
+	return nil
+}
+
 func (p PrintHandler) Handle(m *Message) *Message {
 	if m != nil {
 		fmt.Println(m.Format(string(p)))
@@ -31,9 +65,135 @@ func (p PrintHandler) Handle(m *Message) *Message {
 // internal format rather than the usual syslog format. Use this for diagnostics, for example.
 type DebugHandler struct{}
 
+// Close does nothing; it implements [io.Closer].
+func (h DebugHandler) Close() error {
+	return nil
+}
+
 func (h DebugHandler) Handle(m *Message) *Message {
 	if m != nil {
 		fmt.Printf("%+v\n", *m)
 	}
 	return m
 }
+
+//-------------------------------------------------------------------------------------------------
+
+// FilterHandler wraps a [Handler] so that it only sees the messages accepted by the filter.
+// All messages are passed on to subsequent handlers, whether accepted or not (unless the
+// wrapped handler consumes them).
+func FilterHandler(accept Filter, h Handler) Handler {
+	return filterHandler{accept: accept, h: h}
+}
+
+type filterHandler struct {
+	accept Filter
+	h      Handler
+}
+
+// Close closes the wrapped handler.
+func (f filterHandler) Close() error {
+	return closeHandler(f.h)
+}
+
+func (f filterHandler) Handle(m *Message) *Message {
+	if m == nil || f.accept(m) {
+		return f.h.Handle(m)
+	}
+	return m
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// RouteHandler diverts the messages accepted by the filter to a separate chain of handlers.
+// For example, messages replayed by devices after an outage (see [OlderThan]) can be written
+// to a backfill file with different retention, keeping real-time dashboards clean. Diverted
+// messages are passed through the chain in turn, like the server's own handlers, but are not
+// passed on to subsequent handlers of the server. Other messages are passed on unchanged.
+func RouteHandler(accept Filter, chain ...Handler) Handler {
+	return routeHandler{accept: accept, chain: chain}
+}
+
+type routeHandler struct {
+	accept Filter
+	chain  []Handler
+}
+
+// Close closes the handlers in the chain.
+func (r routeHandler) Close() error {
+	var errs []error
+	for _, h := range r.chain {
+		errs = append(errs, closeHandler(h))
+	}
+	return errors.Join(errs...)
+}
+
+func (r routeHandler) Handle(m *Message) *Message {
+	if m == nil || !r.accept(m) {
+		return m
+	}
+
+	for _, h := range r.chain {
+		if m = h.Handle(m); m == nil {
+			break
+		}
+	}
+	return nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// PrivacyHandler wraps a [Handler], typically a forwarder to an analytics destination, so
+// that it only sees the metadata of each message: the header fields and structured data are
+// kept but the content is removed, for environments where message bodies must not leave the
+// host. If key is not empty, the content is replaced by its HMAC-SHA256 (in hex) instead, so
+// that identical messages can still be counted and correlated without being revealed.
+// The wrapped handler sees a copy; all messages are passed on unchanged to subsequent
+// handlers, e.g. to be written to local files.
+func PrivacyHandler(key []byte, h Handler) Handler {
+	return privacyHandler{key: key, h: h}
+}
+
+type privacyHandler struct {
+	key []byte
+	h   Handler
+}
+
+// Close closes the wrapped handler.
+func (p privacyHandler) Close() error {
+	return closeHandler(p.h)
+}
+
+func (p privacyHandler) Handle(m *Message) *Message {
+	if m == nil {
+		return p.h.Handle(nil)
+	}
+
+	c := *m
+	if checkErr(c.Parse(), "parse", "privacy") {
+		return m // the content cannot be separated from the headers
+	}
+
+	if len(p.key) > 0 && c.Content != "" {
+		mac := hmac.New(sha256.New, p.key)
+		mac.Write([]byte(c.Content))
+		c.Content = hex.EncodeToString(mac.Sum(nil))
+	} else {
+		c.Content = ""
+	}
+	c.Raw = nil // it contains the content
+	c.Annotations = maps.Clone(c.Annotations)
+
+	p.h.Handle(&c)
+	return m
+}
+
+// closeHandler shuts down a handler, using its Close method if it is an [io.Closer] or
+// otherwise by calling Handle with nil.
+func closeHandler(h Handler) error {
+	if c, ok := h.(io.Closer); ok {
+		return c.Close()
+	}
+	h.Handle(nil)
+	return nil
+}
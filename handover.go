@@ -0,0 +1,68 @@
+package syslog
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables that tell a process started by [Server.Handover] which file
+// descriptors are the listener sockets and which is used to report that it is ready.
+const (
+	handoverFDsVar   = "SYSLOG_HANDOVER_FDS"
+	handoverReadyVar = "SYSLOG_HANDOVER_READY"
+)
+
+// ListenHandedOver starts goroutines that receive syslog messages on the sockets handed over
+// by the process that started this one using [Server.Handover], then tells that process that
+// it can stop. Datagram sockets carry on receiving where the old process left off, so no
+// packets are lost. Stream listeners receive as for [Server.ListenListener], with RFC 6587
+// framing. The local addresses of the sockets are returned. An error is returned if this
+// process was not started by Handover. Only the messages matching accept are processed.
+func (s *Server) ListenHandedOver(accept Filter) ([]string, error) {
+	if s.shutDown.Load() {
+		panic("Server is already shut down")
+	}
+
+	value, found := os.LookupEnv(handoverFDsVar)
+	if !found {
+		return nil, errors.New("not started by a syslog handover")
+	}
+	os.Unsetenv(handoverFDsVar)
+
+	var addrs []string
+	var errs []error
+	for _, v := range strings.Split(value, ",") {
+		fd, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		f := os.NewFile(uintptr(fd), "handover")
+		addrs = append(addrs, socketAddr(f))
+		errs = append(errs, s.listenFile(f, accept))
+	}
+
+	if fd, err := strconv.Atoi(os.Getenv(handoverReadyVar)); err == nil {
+		os.Unsetenv(handoverReadyVar)
+		ready := os.NewFile(uintptr(fd), "ready")
+		checkErr2(ready.Write([]byte{1}))
+		ready.Close()
+	}
+	return addrs, errors.Join(errs...)
+}
+
+// socketAddr gets the local address of a socket file, or blank if it is not known.
+func socketAddr(f *os.File) string {
+	if l, err := net.FileListener(f); err == nil {
+		defer l.Close()
+		return l.Addr().String()
+	}
+	if c, err := net.FilePacketConn(f); err == nil {
+		defer c.Close()
+		return c.LocalAddr().String()
+	}
+	return ""
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package syslog
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// Handover is not supported on this platform.
+func (s *Server) Handover(ctx context.Context, cmd *exec.Cmd) error {
+	return errors.New("Handover is not supported on this platform")
+}
@@ -0,0 +1,119 @@
+//go:build unix
+
+package syslog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+// The new process is this test binary again, which receives one message and reports it.
+const handoverTestVar = "SYSLOG_HANDOVER_TEST"
+
+func TestServer_Handover(t *testing.T) {
+	if os.Getenv(handoverTestVar) != "" {
+		handoverChild()
+	}
+
+	s := NewServer(WithQueueLength(1))
+	defer s.Shutdown()
+	expect.Error(s.Listen("127.0.0.1:0")).ToBeNil(t)
+	addr := s.conns[0].LocalAddr().String()
+
+	var out bytes.Buffer
+	cmd := exec.Command(os.Args[0], "-test.run=^TestServer_Handover$")
+	cmd.Env = append(os.Environ(), handoverTestVar+"=1")
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	expect.Error(s.Handover(ctx, cmd)).ToBeNil(t)
+	s.Shutdown()
+
+	// sent after the old server has gone
+	c, err := net.Dial("udp", addr)
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+	_, err = c.Write([]byte("<13>1 - host app - - - after the handover"))
+	expect.Error(err).ToBeNil(t)
+
+	expect.Error(cmd.Wait()).Info(out.String()).ToBeNil(t)
+	expect.String(out.String()).ToContain(t, addr+" after the handover")
+}
+
+func TestServer_Handover_exited(t *testing.T) {
+	s := NewServer(WithQueueLength(1))
+	defer s.Shutdown()
+	expect.Error(s.Listen("127.0.0.1:0")).ToBeNil(t)
+	path := filepath.Join(t.TempDir(), "log")
+	expect.Error(s.ListenUnix(path, AcceptEverything)).ToBeNil(t)
+
+	cmd := exec.Command("/bin/true")
+	expect.Error(s.Handover(context.Background(), cmd)).ToContain(t, "exited")
+	cmd.Wait()
+
+	_, err := s.ListenHandedOver(AcceptEverything)
+	expect.Error(err).ToContain(t, "not started by a syslog handover")
+
+	// the handover failed, so the socket file is still removed
+	s.Shutdown()
+	_, err = os.Stat(path)
+	expect.Bool(os.IsNotExist(err)).ToBeTrue(t)
+}
+
+func handoverChild() {
+	s := NewServer(WithQueueLength(1))
+	received := make(chan *Message, 1)
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+
+	addrs, err := s.ListenHandedOver(AcceptEverything)
+	if err != nil || len(addrs) != 1 {
+		fmt.Println(addrs, err)
+		os.Exit(1)
+	}
+
+	select {
+	case m := <-received:
+		fmt.Println(addrs[0], m.Content)
+		os.Exit(0)
+	case <-time.After(10 * time.Second):
+		fmt.Println("timed out")
+		os.Exit(1)
+	}
+}
+
+func TestServer_listenerFiles(t *testing.T) {
+	s := NewServer()
+	defer s.Shutdown()
+
+	expect.Error(s.ListenTCP("127.0.0.1:0", AcceptEverything)).ToBeNil(t)
+	expect.Error(s.ListenTCPProxy("127.0.0.1:0", cidrs(t, "127.0.0.0/8"), AcceptEverything)).ToBeNil(t)
+	expect.Error(s.ListenRELP("127.0.0.1:0", nil, AcceptEverything)).ToBeNil(t)
+	expect.Error(s.ListenReplica("127.0.0.1:0", nil, AcceptEverything)).ToBeNil(t)
+
+	// only the plain syslog listener is handed over
+	files, unix, err := s.listenerFiles()
+	expect.Slice(files, err).ToHaveLength(t, 1)
+	expect.Slice(unix).ToHaveLength(t, 0)
+	defer closeFiles(files)
+
+	s.mu.Lock()
+	plain := s.listeners[0].Addr().String()
+	s.mu.Unlock()
+	expect.String(socketAddr(files[0])).ToBe(t, plain)
+}
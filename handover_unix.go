@@ -0,0 +1,159 @@
+//go:build unix
+
+package syslog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Handover starts cmd, normally a new version of this program, passing it the server's
+// listener sockets so that the collector can be upgraded without losing UDP packets: the new
+// process calls [Server.ListenHandedOver] and, whilst it is starting, datagrams wait in the
+// socket buffers. The datagram sockets are handed over, as are the plain TCP and Unix stream
+// listeners; stream connections in progress are not, so their clients must reconnect. TLS,
+// RELP, PROXY protocol and replica listeners are not handed over either; the new process
+// can open them itself, e.g. with [Server.ListenReusePort] or a TCP equivalent, before the
+// old one stops.
+//
+// Handover returns once the new process is receiving, or when ctx is done or the process
+// exits, which is reported as an error. This server keeps receiving meanwhile; the caller
+// should then shut it down, e.g. with [Server.Shutdown], and exit. Once the handover has
+// succeeded, the Unix stream sockets are no longer removed when this server shuts down.
+func (s *Server) Handover(ctx context.Context, cmd *exec.Cmd) error {
+	files, unix, err := s.listenerFiles()
+	if err != nil {
+		return err
+	}
+	defer closeFiles(files)
+
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+
+	fds := make([]string, len(files))
+	for i, f := range files {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+		fds[i] = strconv.Itoa(2 + len(cmd.ExtraFiles))
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, readyW)
+	cmd.Env = append(cmd.Environ(),
+		handoverFDsVar+"="+strings.Join(fds, ","),
+		fmt.Sprintf("%s=%d", handoverReadyVar, 2+len(cmd.ExtraFiles)))
+
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return err
+	}
+
+	// the new process writes to the pipe when it is ready; it reaches EOF if the process exits
+	result := make(chan error, 1)
+	go func() {
+		_, err := ready.Read(make([]byte, 1))
+		if err == io.EOF {
+			err = errors.New("the new process exited during the handover")
+		}
+		result <- err
+	}()
+
+	select {
+	case err = <-result:
+		if err == nil {
+			// the new process now owns the socket files
+			for _, ul := range unix {
+				ul.SetUnlinkOnClose(false)
+			}
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// listenerFiles gets duplicates of the server's sockets that can be handed over to another
+// process: its datagram sockets and those stream listeners that receive plain syslog, without
+// TLS or any other protocol such as PROXY, RELP or replication. The Unix stream listeners
+// among them are also returned.
+func (s *Server) listenerFiles() ([]*os.File, []*net.UnixListener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var files []*os.File
+	var unix []*net.UnixListener
+	add := func(x any) error {
+		sc, ok := x.(syscall.Conn)
+		if !ok {
+			return nil
+		}
+		f, err := dupSocket(sc)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		return nil
+	}
+
+	var errs []error
+	for _, c := range s.conns {
+		errs = append(errs, add(c))
+	}
+	for _, l := range s.listeners {
+		if _, other := s.protocols[l]; other {
+			continue
+		}
+		switch l := l.(type) {
+		case *net.TCPListener:
+			errs = append(errs, add(l))
+		case *net.UnixListener:
+			errs = append(errs, add(l))
+			unix = append(unix, l)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		closeFiles(files)
+		return nil, nil, err
+	}
+	return files, unix, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// dupSocket duplicates a socket. Unlike the File method of the net package, the result
+// can be passed to a child process without putting the socket into blocking mode, which
+// would stop this server's receivers from being interrupted when it shuts down.
+func dupSocket(sc syscall.Conn) (*os.File, error) {
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var nfd int
+	var dupErr error
+	err = rc.Control(func(fd uintptr) {
+		syscall.ForkLock.RLock()
+		defer syscall.ForkLock.RUnlock()
+		if nfd, dupErr = syscall.Dup(int(fd)); dupErr == nil {
+			syscall.CloseOnExec(nfd)
+		}
+	})
+	if err = errors.Join(err, dupErr); err != nil {
+		return nil, os.NewSyscallError("dup", err)
+	}
+	return os.NewFile(uintptr(nfd), "socket"), nil
+}
@@ -0,0 +1,196 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Annotation keys for messages received from the systemd journal; see [Server.ListenJournal].
+const (
+	SystemdUnitAnnotation   = "systemd-unit"
+	JournalCursorAnnotation = "journal-cursor"
+)
+
+// maxJournalField limits the size of binary fields in the journal export format.
+const maxJournalField = 1 << 20
+
+// ListenJournal starts a goroutine that follows the systemd journal and passes each new
+// entry to the handlers as a message, so that one collector can merge the journal with
+// syslog received over the network. It runs "journalctl --output=export --follow" with any
+// extra arguments given, e.g. "--unit=nginx.service" or "--after-cursor=..." to resume from
+// the cursor of a previous entry (see [JournalCursorAnnotation]); without a cursor, only new
+// entries are received. See [Server.ListenJournalReader] for how entries are converted.
+// The process is stopped when the server is shut down.
+func (s *Server) ListenJournal(accept Filter, args ...string) error {
+	args = append([]string{"--output=export", "--follow"}, args...)
+	if !hasCursorArg(args) {
+		args = append(args, "--lines=0")
+	}
+
+	cmd := exec.Command("journalctl", args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	s.ListenJournalReader(journalctl{cmd}, out, accept)
+	return nil
+}
+
+// journalctl stops the journalctl process when it is closed.
+type journalctl struct {
+	cmd *exec.Cmd
+}
+
+func (j journalctl) Close() error {
+	checkErr(j.cmd.Process.Kill(), "stop", "journalctl")
+	j.cmd.Wait() // the error reports that it was killed
+	return nil
+}
+
+// ListenJournalReader starts a goroutine that reads journal entries in the journal export
+// format (as written by "journalctl --output=export" or served by systemd-journal-gatewayd)
+// from r, and passes each entry to the handlers as a message. The priority, syslog facility
+// (or kern for kernel entries), identifier, PID, hostname, realtime timestamp and MESSAGE_ID
+// of each entry are preserved; the unit and the cursor are recorded as annotations
+// ([SystemdUnitAnnotation] and [JournalCursorAnnotation]). Entries without a facility have
+// the [User] facility, and those without a priority have [Notice] severity. Like MARK
+// messages (see [Server.StartMark]), the messages bypass the source ACL and rate limits;
+// only the messages matching accept are processed. If c is not nil, it is closed when the
+// server is shut down, which should make reading fail.
+func (s *Server) ListenJournalReader(c io.Closer, r io.Reader, accept Filter) {
+	if s.shutDown.Load() {
+		panic("Server is already shut down")
+	}
+
+	s.receivers.Add(1)
+	go func() {
+		defer s.receivers.Done()
+		s.readJournal(bufio.NewReader(r), accept)
+	}()
+
+	if c != nil {
+		go func() {
+			<-s.done
+			checkErr(c.Close(), "close", "journal")
+		}()
+	}
+}
+
+func (s *Server) readJournal(br *bufio.Reader, accept Filter) {
+	for {
+		fields, err := readJournalEntry(br)
+		if len(fields) > 0 {
+			if m := journalMessage(fields, s.clock()); accept(m) {
+				s.pushStream([]*Message{m})
+			}
+		}
+
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				if err != io.EOF {
+					s.logger.Println("Journal error:", err)
+				}
+			}
+			return
+		}
+	}
+}
+
+// readJournalEntry reads the fields of the next entry, which ends with a blank line.
+func readJournalEntry(br *bufio.Reader) (map[string]string, error) {
+	fields := make(map[string]string)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil {
+			return fields, err
+		}
+
+		line = line[:len(line)-1]
+		if len(line) == 0 {
+			if len(fields) == 0 {
+				continue
+			}
+			return fields, nil
+		}
+
+		if name, value, found := bytes.Cut(line, []byte{'='}); found {
+			fields[string(name)] = string(value)
+			continue
+		}
+
+		// a binary field: the name is followed by a little-endian 64-bit size, the data and LF
+		var size uint64
+		if err = binary.Read(br, binary.LittleEndian, &size); err != nil {
+			return fields, err
+		}
+		if size > maxJournalField {
+			return fields, fmt.Errorf("%s: journal field of %d bytes is too large", line, size)
+		}
+		value := make([]byte, size+1)
+		if _, err = io.ReadFull(br, value); err != nil {
+			return fields, err
+		}
+		if value[size] != '\n' {
+			return fields, errors.New("malformed binary field in journal export")
+		}
+		fields[string(line)] = string(value[:size])
+	}
+}
+
+// journalMessage converts a journal entry, received at time t, into a message.
+func journalMessage(fields map[string]string, t time.Time) *Message {
+	m := &Message{
+		Time:        t,
+		Facility:    User,
+		Severity:    Notice,
+		Version:     1,
+		Timestamp:   t,
+		Hostname:    fields["_HOSTNAME"],
+		Application: ifBlank(fields["SYSLOG_IDENTIFIER"], fields["_COMM"]),
+		ProcID:      ifBlank(fields["_PID"], fields["SYSLOG_PID"]),
+		MsgID:       fields["MESSAGE_ID"],
+		Content:     fields["MESSAGE"],
+	}
+	m.Size = len(m.Content)
+
+	if p, err := strconv.Atoi(fields["PRIORITY"]); err == nil && 0 <= p && p <= 7 {
+		m.Severity = Severity(p)
+	}
+	if f, err := strconv.Atoi(fields["SYSLOG_FACILITY"]); err == nil && 0 <= f && f < 24 {
+		m.Facility = Facility(f)
+	} else if fields["_TRANSPORT"] == "kernel" {
+		m.Facility = Kern
+	}
+	if us, err := strconv.ParseInt(fields["__REALTIME_TIMESTAMP"], 10, 64); err == nil {
+		m.Timestamp = time.UnixMicro(us)
+	}
+
+	if unit := ifBlank(fields["_SYSTEMD_UNIT"], fields["_SYSTEMD_USER_UNIT"]); unit != "" {
+		m.Annotate(SystemdUnitAnnotation, unit)
+	}
+	if cursor := fields["__CURSOR"]; cursor != "" {
+		m.Annotate(JournalCursorAnnotation, cursor)
+	}
+	return m
+}
+
+func hasCursorArg(args []string) bool {
+	return slices.ContainsFunc(args, func(a string) bool {
+		return strings.HasPrefix(a, "--cursor") || strings.HasPrefix(a, "--after-cursor")
+	})
+}
@@ -0,0 +1,58 @@
+package syslog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestServer_ListenJournalReader(t *testing.T) {
+	var export bytes.Buffer
+	export.WriteString("__CURSOR=s=abc;i=1\n__REALTIME_TIMESTAMP=1700000000123456\n_HOSTNAME=web01\n" +
+		"SYSLOG_IDENTIFIER=nginx\n_PID=812\n_SYSTEMD_UNIT=nginx.service\nPRIORITY=3\nSYSLOG_FACILITY=3\n" +
+		"MESSAGE=upstream timed out\n\n")
+
+	// a binary field, used for values containing newlines
+	msg := "line one\nline two"
+	export.WriteString("_TRANSPORT=kernel\n_HOSTNAME=web01\nPRIORITY=4\nMESSAGE\n")
+	binary.Write(&export, binary.LittleEndian, uint64(len(msg)))
+	export.WriteString(msg + "\n\n")
+
+	// no priority or facility
+	export.WriteString("_COMM=cron\nMESSAGE=tick\n\n")
+
+	received := make(chan *Message, 3)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+	s.ListenJournalReader(nil, &export, AcceptEverything)
+
+	m := <-received
+	expect.Any(m.Facility).ToBe(t, Daemon)
+	expect.Any(m.Severity).ToBe(t, Err)
+	expect.Bool(m.Timestamp.Equal(time.UnixMicro(1700000000123456))).ToBeTrue(t)
+	expect.String(m.Hostname).ToBe(t, "web01")
+	expect.String(m.Application).ToBe(t, "nginx")
+	expect.String(m.ProcID).ToBe(t, "812")
+	expect.String(m.Content).ToBe(t, "upstream timed out")
+	expect.String(m.Annotations[SystemdUnitAnnotation]).ToBe(t, "nginx.service")
+	expect.String(m.Annotations[JournalCursorAnnotation]).ToBe(t, "s=abc;i=1")
+
+	m = <-received
+	expect.Any(m.Facility).ToBe(t, Kern)
+	expect.Any(m.Severity).ToBe(t, Warning)
+	expect.String(m.Content).ToBe(t, msg)
+
+	m = <-received
+	expect.Any(m.Facility).ToBe(t, User)
+	expect.Any(m.Severity).ToBe(t, Notice)
+	expect.String(m.Application).ToBe(t, "cron")
+}
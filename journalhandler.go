@@ -0,0 +1,154 @@
+package syslog
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultJournalSocket is the socket of the native protocol of systemd-journald.
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+// JournalHandler is a [Handler] that writes messages into the local systemd journal using
+// its native protocol, for hosts where journald is the canonical store. The content becomes
+// MESSAGE, the severity PRIORITY, and the facility, application and process ID
+// SYSLOG_FACILITY, SYSLOG_IDENTIFIER and SYSLOG_PID, as journald itself records them for
+// local syslog messages. The hostname, timestamp and MsgID are kept in SYSLOG_HOSTNAME,
+// SYSLOG_TIMESTAMP and SYSLOG_MSGID, and each structured data parameter becomes a field
+// named after its SD-ID and parameter name, e.g. [origin ip="10.0.0.1"] gives SD_ORIGIN_IP.
+// The SD_ prefix stops senders from overwriting fields such as SYSLOG_IDENTIFIER.
+// Entries too large for a datagram are passed to journald in a temporary file (on Unix).
+//
+// All messages are passed on to subsequent handlers. It is safe for concurrent use.
+type JournalHandler struct {
+	acceptFunc Filter
+	socket     string
+	mu         sync.Mutex // guards conn
+	conn       *net.UnixConn
+}
+
+// NewJournalHandler creates a handler that writes messages to the journal via socket, which
+// is normally [DefaultJournalSocket]. All messages are accepted.
+func NewJournalHandler(socket string) *JournalHandler {
+	return &JournalHandler{
+		acceptFunc: AcceptEverything,
+		socket:     socket,
+	}
+}
+
+// SetFilter changes the function used to decide which messages are written.
+func (h *JournalHandler) SetFilter(acceptFunc Filter) {
+	h.acceptFunc = acceptFunc
+}
+
+func (h *JournalHandler) Handle(m *Message) *Message {
+	if m == nil {
+		checkErr(h.Close(), "close", h.socket)
+	} else if h.acceptFunc(m) && !checkErr(m.Parse(), "journal") {
+		checkErr(h.send(appendJournalEntry(nil, m)), "write", h.socket)
+	}
+	return m
+}
+
+func (h *JournalHandler) send(entry []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conn == nil {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: h.socket, Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+		h.conn = conn
+	}
+
+	_, err := h.conn.Write(entry)
+	if tooBigForDatagram(err) {
+		return sendJournalFile(h.conn, entry)
+	}
+	if err != nil {
+		h.closeConn() // e.g. journald restarted; re-open on the next message
+	}
+	return err
+}
+
+// Close closes the socket; it is re-opened if another message is handled.
+func (h *JournalHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closeConn()
+}
+
+func (h *JournalHandler) closeConn() error {
+	if h.conn == nil {
+		return nil
+	}
+	err := h.conn.Close()
+	h.conn = nil
+	return err
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// appendJournalEntry appends the fields of a message in the journal native protocol.
+func appendJournalEntry(bs []byte, m *Message) []byte {
+	bs = appendJournalField(bs, "MESSAGE", m.Content)
+	bs = appendJournalField(bs, "PRIORITY", strconv.Itoa(int(m.Severity)))
+	bs = appendJournalField(bs, "SYSLOG_FACILITY", strconv.Itoa(int(m.Facility)))
+	bs = appendJournalField(bs, "SYSLOG_IDENTIFIER", m.Application)
+	bs = appendJournalField(bs, "SYSLOG_PID", m.ProcID)
+	bs = appendJournalField(bs, "SYSLOG_HOSTNAME", m.Hostname)
+	bs = appendJournalField(bs, "SYSLOG_MSGID", m.MsgID)
+	if !m.Timestamp.IsZero() {
+		bs = appendJournalField(bs, "SYSLOG_TIMESTAMP", m.Timestamp.Format(time.RFC3339Nano))
+	}
+	elems, _ := parseStructuredData(m.Data)
+	for _, e := range elems {
+		for _, p := range e.Params {
+			bs = appendJournalField(bs, journalFieldName("SD_"+e.ID+"_"+p.Name), p.Value)
+		}
+	}
+	return bs
+}
+
+// appendJournalField appends a field, unless the value is blank or "-". Values containing
+// newlines are written in the binary form, with their size.
+func appendJournalField(bs []byte, name, value string) []byte {
+	if value == "" || value == "-" || name == "" {
+		return bs
+	}
+	bs = append(bs, name...)
+	if strings.IndexByte(value, '\n') < 0 {
+		bs = append(bs, '=')
+	} else {
+		bs = append(bs, '\n')
+		bs = binary.LittleEndian.AppendUint64(bs, uint64(len(value)))
+	}
+	bs = append(bs, value...)
+	return append(bs, '\n')
+}
+
+// journalFieldName converts s into a valid journal field name: upper-case letters, digits
+// and underscores, starting with a letter, at most 64 characters. The enterprise number of
+// an SD-ID is dropped. It returns blank if nothing remains.
+func journalFieldName(s string) string {
+	if at := strings.IndexByte(s, '@'); at >= 0 {
+		if us := strings.IndexByte(s[at:], '_'); us >= 0 {
+			s = s[:at] + s[at+us:]
+		}
+	}
+
+	var sb strings.Builder
+	for _, c := range strings.ToUpper(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', '0' <= c && c <= '9' && sb.Len() > 0:
+			sb.WriteRune(c)
+		case sb.Len() > 0:
+			sb.WriteByte('_')
+		}
+	}
+	return strings.TrimRight(sb.String()[:min(sb.Len(), 64)], "_")
+}
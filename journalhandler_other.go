@@ -0,0 +1,16 @@
+//go:build !unix
+
+package syslog
+
+import (
+	"errors"
+	"net"
+)
+
+func sendJournalFile(*net.UnixConn, []byte) error {
+	return errors.New("journal entry is too large for a datagram")
+}
+
+func tooBigForDatagram(error) bool {
+	return false
+}
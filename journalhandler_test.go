@@ -0,0 +1,88 @@
+//go:build unix
+
+package syslog
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestJournalHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	journald, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	expect.Error(err).ToBeNil(t)
+	defer journald.Close()
+
+	h := NewJournalHandler(path)
+	defer h.Close()
+	m, err := parseMessage([]byte(`<27>1 2024-03-01T12:00:00Z host app 123 ID47 [origin@32473 ip="10.0.0.1" note="a \"b\""][syslog identifier="spoof"] disk full`))
+	expect.Error(err).ToBeNil(t)
+	expect.Any(h.Handle(m)).ToBe(t, m)
+
+	buf := make([]byte, 4096)
+	n, err := journald.Read(buf)
+	expect.Error(err).ToBeNil(t)
+	expect.String(string(buf[:n])).ToBe(t, "MESSAGE=disk full\nPRIORITY=3\nSYSLOG_FACILITY=3\n"+
+		"SYSLOG_IDENTIFIER=app\nSYSLOG_PID=123\nSYSLOG_HOSTNAME=host\nSYSLOG_MSGID=ID47\n"+
+		"SYSLOG_TIMESTAMP=2024-03-01T12:00:00Z\nSD_ORIGIN_IP=10.0.0.1\nSD_ORIGIN_NOTE=a \"b\"\n"+
+		"SD_SYSLOG_IDENTIFIER=spoof\n")
+
+	// multi-line content uses the binary form
+	h.Handle(&Message{Severity: Info, Content: "a\nb"})
+	n, err = journald.Read(buf)
+	expect.Error(err).ToBeNil(t)
+	expect.String(string(buf[:n])).ToBe(t, "MESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\nPRIORITY=6\nSYSLOG_FACILITY=0\n")
+}
+
+func TestJournalHandler_large(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	journald, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	expect.Error(err).ToBeNil(t)
+	defer journald.Close()
+
+	h := NewJournalHandler(path)
+	defer h.Close()
+	content := strings.Repeat("x", 4<<20)
+	h.Handle(&Message{Severity: Info, Content: content})
+
+	journald.SetReadDeadline(time.Now().Add(5 * time.Second))
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := journald.ReadMsgUnix(nil, oob)
+	expect.Error(err).ToBeNil(t)
+	cmsgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	expect.Error(err).ToBeNil(t)
+	fds, err := syscall.ParseUnixRights(&cmsgs[0])
+	expect.Error(err).ToBeNil(t)
+
+	f := os.NewFile(uintptr(fds[0]), "entry")
+	defer f.Close()
+	f.Seek(0, io.SeekStart)
+	bs, err := io.ReadAll(f)
+	expect.Error(err).ToBeNil(t)
+	expect.Bool(strings.HasPrefix(string(bs), "MESSAGE="+content+"\n")).ToBeTrue(t)
+}
+
+func TestJournalFieldName(t *testing.T) {
+	cases := map[string]string{
+		"origin_ip":             "ORIGIN_IP",
+		"meta@32473_sequenceId": "META_SEQUENCEID",
+		"_x-y.z_":               "X_Y_Z",
+		"1abc_":                 "ABC",
+		"@_":                    "",
+		strings.Repeat("a", 70): strings.Repeat("A", 64),
+		"exampleSDID@32473_iut": "EXAMPLESDID_IUT",
+		"timeQuality_tzKnown":   "TIMEQUALITY_TZKNOWN",
+		"ex@1_a b":              "EX_A_B",
+	}
+	for s, want := range cases {
+		expect.String(journalFieldName(s)).Info(s).ToBe(t, want)
+	}
+}
@@ -0,0 +1,48 @@
+//go:build unix
+
+package syslog
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+// tooBigForDatagram is true when the socket refused an entry because of its size.
+func tooBigForDatagram(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// sendJournalFile passes an entry that is too large for a datagram to journald as the
+// descriptor of an unlinked temporary file, as sd_journal_send does.
+func sendJournalFile(conn *net.UnixConn, entry []byte) error {
+	f, err := os.CreateTemp("/dev/shm", "journal-*")
+	if err != nil {
+		f, err = os.CreateTemp("", "journal-*")
+		if err != nil {
+			return err
+		}
+	}
+	defer f.Close()
+	os.Remove(f.Name())
+
+	if _, err = f.Write(entry); err != nil {
+		return err
+	}
+
+	// WriteMsgUnix refuses connected datagram sockets
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rights := syscall.UnixRights(int(f.Fd()))
+	werr := rc.Write(func(fd uintptr) bool {
+		err = syscall.Sendmsg(int(fd), nil, rights, nil, 0)
+		return err != syscall.EAGAIN
+	})
+	if werr != nil {
+		return werr
+	}
+	return err
+}
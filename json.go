@@ -0,0 +1,253 @@
+package syslog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JSONSchema selects a version of the JSON encoding of messages. New versions add fields as
+// the message gains them, so downstream pipelines can keep reading an older schema until
+// they are ready to upgrade; see [MigrateJSON].
+type JSONSchema int
+
+const (
+	// JSONv1 has the header fields, structured data and content, with the facility and
+	// severity given by name, and no "schema" field.
+	JSONv1 JSONSchema = 1
+	// JSONv2 adds "schema":2 and the sequence number, size, numeric priority, syslog
+	// version, annotations and TLS peer identity.
+	JSONv2 JSONSchema = 2
+
+	// LatestJSONSchema is the most recent schema.
+	LatestJSONSchema = JSONv2
+)
+
+// ParseJSONSchema parses a schema name such as "v1" or "2"; "latest" selects
+// [LatestJSONSchema].
+func ParseJSONSchema(s string) (JSONSchema, error) {
+	if strings.EqualFold(s, "latest") {
+		return LatestJSONSchema, nil
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(s), "v"))
+	if err != nil || !JSONSchema(n).valid() {
+		return 0, fmt.Errorf("%q: unknown JSON schema", s)
+	}
+	return JSONSchema(n), nil
+}
+
+func (v JSONSchema) String() string {
+	return "v" + strconv.Itoa(int(v))
+}
+
+func (v JSONSchema) valid() bool {
+	return JSONv1 <= v && v <= LatestJSONSchema
+}
+
+//-------------------------------------------------------------------------------------------------
+
+type jsonV1 struct {
+	Time        time.Time `json:"time"`
+	Source      string    `json:"source,omitempty"`
+	Facility    string    `json:"facility"`
+	Severity    string    `json:"severity"`
+	Timestamp   time.Time `json:"timestamp"`
+	Hostname    string    `json:"hostname,omitempty"`
+	Application string    `json:"application,omitempty"`
+	ProcID      string    `json:"procid,omitempty"`
+	MsgID       string    `json:"msgid,omitempty"`
+	Data        string    `json:"data,omitempty"`
+	Content     string    `json:"content"`
+}
+
+type jsonV2 struct {
+	Schema JSONSchema `json:"schema"`
+	jsonV1
+	Sequence    uint64            `json:"sequence,omitempty"`
+	Size        int               `json:"size,omitempty"`
+	Priority    int               `json:"priority"`
+	Version     int               `json:"version"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	TLSPeer     *jsonPeer         `json:"tls_peer,omitempty"`
+}
+
+type jsonPeer struct {
+	CommonName  string   `json:"common_name,omitempty"`
+	DNSNames    []string `json:"dns_names,omitempty"`
+	URIs        []string `json:"uris,omitempty"`
+	SPIFFEID    string   `json:"spiffe_id,omitempty"`
+	Fingerprint string   `json:"fingerprint,omitempty"`
+}
+
+// AppendJSON appends the JSON encoding of the message in the given schema to bs. Messages
+// that have not been parsed (see [WithLazyParsing]) are parsed first.
+func (m *Message) AppendJSON(bs []byte, schema JSONSchema) ([]byte, error) {
+	if !schema.valid() {
+		return bs, fmt.Errorf("unknown JSON schema %d", schema)
+	}
+	if err := m.Parse(); err != nil {
+		return bs, err
+	}
+
+	v1 := jsonV1{
+		Time:        m.Time,
+		Facility:    m.Facility.String(),
+		Severity:    m.Severity.String(),
+		Timestamp:   m.Timestamp,
+		Hostname:    m.Hostname,
+		Application: m.Application,
+		ProcID:      m.ProcID,
+		MsgID:       m.MsgID,
+		Data:        m.Data,
+		Content:     m.Content,
+	}
+	if m.Source != nil {
+		v1.Source = m.Source.String()
+	}
+
+	var record any = v1
+	if schema == JSONv2 {
+		v2 := jsonV2{
+			Schema:      JSONv2,
+			jsonV1:      v1,
+			Sequence:    m.Sequence,
+			Size:        m.Size,
+			Priority:    int(m.Facility)*8 + int(m.Severity),
+			Version:     m.Version,
+			Annotations: m.Annotations,
+		}
+		if p := m.TLSPeer; p != nil {
+			v2.TLSPeer = &jsonPeer{p.CommonName, p.DNSNames, p.URIs, p.SPIFFEID, p.Fingerprint}
+		}
+		record = v2
+	}
+
+	js, err := json.Marshal(record)
+	if err != nil {
+		return bs, err
+	}
+	return append(bs, js...), nil
+}
+
+// ParseJSON decodes a message encoded by [Message.AppendJSON] in any schema, and also
+// returns the schema. The Source is a placeholder address that only records the string.
+func ParseJSON(bs []byte) (*Message, JSONSchema, error) {
+	var r jsonV2
+	if err := json.Unmarshal(bs, &r); err != nil {
+		return nil, 0, err
+	}
+
+	schema := r.Schema
+	if schema == 0 {
+		schema = JSONv1 // v1 has no schema field
+	}
+	if !schema.valid() {
+		return nil, 0, fmt.Errorf("unknown JSON schema %d", r.Schema)
+	}
+
+	m := &Message{
+		Time:        r.Time,
+		Timestamp:   r.Timestamp,
+		Hostname:    r.Hostname,
+		Application: r.Application,
+		ProcID:      r.ProcID,
+		MsgID:       r.MsgID,
+		Data:        r.Data,
+		Content:     r.Content,
+	}
+	if r.Source != "" {
+		m.Source = jsonAddr(r.Source)
+	}
+
+	var err error
+	if m.Facility, err = ParseFacility(r.Facility); err != nil {
+		return nil, 0, err
+	}
+	if m.Severity, err = ParseSeverity(r.Severity); err != nil {
+		return nil, 0, err
+	}
+
+	if schema == JSONv1 {
+		m.Version = 1
+		m.Size = len(m.Content)
+		return m, schema, nil
+	}
+
+	if r.Priority != int(m.Facility)*8+int(m.Severity) {
+		return nil, 0, errors.New("JSON priority does not match the facility and severity")
+	}
+	m.Sequence = r.Sequence
+	m.Size = r.Size
+	m.Version = r.Version
+	m.Annotations = r.Annotations
+	if p := r.TLSPeer; p != nil {
+		m.TLSPeer = &PeerIdentity{p.CommonName, p.DNSNames, p.URIs, p.SPIFFEID, p.Fingerprint}
+	}
+	return m, schema, nil
+}
+
+// MigrateJSON converts a message encoded by [Message.AppendJSON] in any schema to another
+// schema. Upgrading fills in the new fields as far as they can be derived, e.g. the priority;
+// downgrading drops the fields that the older schema does not have.
+func MigrateJSON(bs []byte, to JSONSchema) ([]byte, error) {
+	m, from, err := ParseJSON(bs)
+	if err != nil {
+		return nil, err
+	}
+	if from == to {
+		return bs, nil
+	}
+	return m.AppendJSON(nil, to)
+}
+
+// jsonAddr is the address of a message decoded from JSON.
+type jsonAddr string
+
+func (a jsonAddr) Network() string { return "json" }
+func (a jsonAddr) String() string  { return string(a) }
+
+//-------------------------------------------------------------------------------------------------
+
+// JSONHandler writes messages as JSON lines in a selected schema. It is safe for concurrent
+// use.
+type JSONHandler struct {
+	mu     sync.Mutex
+	w      io.Writer
+	schema JSONSchema
+	bs     []byte
+}
+
+// NewJSONHandler creates a handler that writes messages to w as JSON lines in the given
+// schema. If w is an [io.Closer], it is closed when the handler is closed.
+func NewJSONHandler(w io.Writer, schema JSONSchema) (*JSONHandler, error) {
+	if !schema.valid() {
+		return nil, fmt.Errorf("unknown JSON schema %d", schema)
+	}
+	return &JSONHandler{w: w, schema: schema}, nil
+}
+
+func (h *JSONHandler) Handle(m *Message) *Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if m == nil {
+		if c, ok := h.w.(io.Closer); ok {
+			checkErr(c.Close(), "close", "json")
+		}
+		return nil
+	}
+
+	bs, err := m.AppendJSON(h.bs[:0], h.schema)
+	if checkErr(err, "json") {
+		return m
+	}
+	h.bs = append(bs, '\n')
+	_, err = h.w.Write(h.bs)
+	checkErr(err, "write", "json")
+	return m
+}
@@ -0,0 +1,78 @@
+package syslog
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestJSONSchemaMigration(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	m := &Message{
+		Time:        t0,
+		Source:      &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 514},
+		Sequence:    42,
+		Size:        20,
+		Facility:    Daemon,
+		Severity:    Err,
+		Version:     1,
+		Timestamp:   t0,
+		Hostname:    "host",
+		Application: "app",
+		Content:     "disk full",
+		TLSPeer:     &PeerIdentity{CommonName: "client"},
+	}
+	m.Annotate("k", "v")
+
+	v1, err := m.AppendJSON(nil, JSONv1)
+	expect.Error(err).ToBeNil(t)
+	expect.String(string(v1)).ToBe(t, `{"time":"2024-03-01T12:00:00Z","source":"10.0.0.1:514","facility":"daemon","severity":"err",`+
+		`"timestamp":"2024-03-01T12:00:00Z","hostname":"host","application":"app","content":"disk full"}`)
+
+	v2, err := m.AppendJSON(nil, JSONv2)
+	expect.Error(err).ToBeNil(t)
+	expect.Bool(strings.HasPrefix(string(v2), `{"schema":2,"time":`)).ToBeTrue(t)
+	expect.Bool(strings.HasSuffix(string(v2), `"sequence":42,"size":20,"priority":27,"version":1,`+
+		`"annotations":{"k":"v"},"tls_peer":{"common_name":"client"}}`)).Info(string(v2)).ToBeTrue(t)
+
+	down, err := MigrateJSON(v2, JSONv1)
+	expect.Error(err).ToBeNil(t)
+	expect.String(string(down)).ToBe(t, string(v1))
+
+	up, err := MigrateJSON(v1, LatestJSONSchema)
+	expect.Error(err).ToBeNil(t)
+	p, schema, err := ParseJSON(up)
+	expect.Error(err).ToBeNil(t)
+	expect.Any(schema).ToBe(t, JSONv2)
+	expect.Any(p.Facility).ToBe(t, Daemon)
+	expect.Any(p.Severity).ToBe(t, Err)
+	expect.String(p.Source.String()).ToBe(t, "10.0.0.1:514")
+	expect.Number(p.Size).ToBe(t, 9)
+
+	_, _, err = ParseJSON([]byte(`{"schema":2,"facility":"daemon","severity":"err","priority":3}`))
+	expect.Error(err).ToContain(t, "priority")
+	_, _, err = ParseJSON([]byte(`{"schema":9}`))
+	expect.Error(err).ToContain(t, "unknown JSON schema")
+}
+
+func TestParseJSONSchema(t *testing.T) {
+	for s, want := range map[string]JSONSchema{"v1": JSONv1, "2": JSONv2, "V2": JSONv2, "latest": LatestJSONSchema} {
+		v, err := ParseJSONSchema(s)
+		expect.Error(err).Info(s).ToBeNil(t)
+		expect.Any(v).Info(s).ToBe(t, want)
+	}
+	_, err := ParseJSONSchema("v3")
+	expect.Error(err).ToContain(t, "unknown JSON schema")
+}
+
+func TestJSONHandler(t *testing.T) {
+	var sb strings.Builder
+	h, err := NewJSONHandler(&sb, JSONv1)
+	expect.Error(err).ToBeNil(t)
+	h.Handle(&Message{Facility: User, Severity: Info, Content: "a"})
+	h.Handle(&Message{Facility: User, Severity: Info, Content: "b"})
+	expect.Slice(strings.Split(sb.String(), "\n")).ToHaveLength(t, 3)
+}
@@ -0,0 +1,111 @@
+package syslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// DefaultJSONContentSDID is the SD-ID used by [JSONContentHandler] when its SDID is blank.
+const DefaultJSONContentSDID = "json@32473"
+
+// ContentJSON parses the content of the message as a JSON object, for access to its fields.
+// Numbers are [json.Number] values, so that large integers are not rounded. If the content
+// (after any colon and spaces that follow an RFC 3164 tag) does not begin with '{', the
+// result is nil without an error; an error is returned if it is not valid JSON.
+func (m *Message) ContentJSON() (map[string]any, error) {
+	if err := m.Parse(); err != nil {
+		return nil, err
+	}
+
+	content := jsonContent(m.Content)
+	if content == "" {
+		return nil, nil
+	}
+
+	d := json.NewDecoder(strings.NewReader(content))
+	d.UseNumber()
+	var fields map[string]any
+	if err := d.Decode(&fields); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, fmt.Errorf("%s: unexpected data after the JSON object", cropString(content, 40))
+	}
+	return fields, nil
+}
+
+// jsonContent gets content that appears to be a JSON object, or blank.
+func jsonContent(content string) string {
+	content = strings.TrimLeft(content, ": ")
+	if strings.HasPrefix(content, "{") {
+		return content
+	}
+	return ""
+}
+
+// JSONContentHandler is a [Handler] that copies the fields of messages whose content is a
+// JSON object (see [Message.ContentJSON]) into a structured data element, for filters,
+// handlers and outputs that understand structured data but not JSON. Nested objects are
+// flattened with dots, e.g. "user.id"; arrays are written as JSON, and null as blank.
+// Fields whose names cannot be SD parameter names, e.g. those longer than 32 bytes, are
+// dropped. The content is unchanged, as are other messages, including those with invalid
+// JSON.
+type JSONContentHandler struct {
+	// SDID is the SD-ID of the element added to the structured data, which should have the
+	// form name@enterprise-number. If blank, [DefaultJSONContentSDID] is used.
+	SDID string
+}
+
+// Close does nothing; it implements [io.Closer].
+func (h JSONContentHandler) Close() error {
+	return nil
+}
+
+func (h JSONContentHandler) Handle(m *Message) *Message {
+	if m == nil || !m.IsParsed() || jsonContent(m.Content) == "" {
+		return m
+	}
+
+	fields, err := m.ContentJSON()
+	if err != nil {
+		return m
+	}
+
+	var pairs []string
+	appendJSONFields(&pairs, "", fields)
+	m.addSDElement(ifBlank(h.SDID, DefaultJSONContentSDID), pairs...)
+	return m
+}
+
+// appendJSONFields appends the names and values of the fields, in order of name.
+func appendJSONFields(pairs *[]string, prefix string, fields map[string]any) {
+	for _, k := range slices.Sorted(maps.Keys(fields)) {
+		name := prefix + k
+		var value string
+		switch v := fields[k].(type) {
+		case map[string]any:
+			appendJSONFields(pairs, name+".", v)
+			continue
+		case nil:
+		case string:
+			value = v
+		case json.Number:
+			value = v.String()
+		case bool:
+			value = fmt.Sprint(v)
+		default:
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			enc.Encode(v)
+			value = strings.TrimSuffix(buf.String(), "\n")
+		}
+		if validSDName(name) {
+			*pairs = append(*pairs, name, value)
+		}
+	}
+}
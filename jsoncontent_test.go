@@ -0,0 +1,39 @@
+package syslog
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestMessage_ContentJSON(t *testing.T) {
+	m, err := parseMessage([]byte(`<14>Mar  1 12:00:00 web app[12]: {"level":"warn","id":12345678901234567890,"user":{"name":"bob"}}`))
+	expect.Error(err).ToBeNil(t)
+	fields, err := m.ContentJSON()
+	expect.Error(err).ToBeNil(t)
+	expect.Any(fields["level"]).ToBe(t, "warn")
+	expect.Any(fields["id"]).ToBe(t, json.Number("12345678901234567890"))
+	expect.Any(fields["user"].(map[string]any)["name"]).ToBe(t, "bob")
+
+	fields, err = (&Message{Content: "plain text"}).ContentJSON()
+	expect.Error(err).ToBeNil(t)
+	expect.Any(fields).ToBeNil(t)
+
+	_, err = (&Message{Content: `{"a":`}).ContentJSON()
+	expect.Error(err).ToContain(t, "unexpected EOF")
+	_, err = (&Message{Content: `{"a":1} {"b":2}`}).ContentJSON()
+	expect.Error(err).ToContain(t, "unexpected data after the JSON object")
+}
+
+func TestJSONContentHandler(t *testing.T) {
+	m := &Message{
+		Data:    `[x@1 a="b"]`,
+		Content: `{"msg":"say \"hi\"","n":1.5,"ok":true,"none":null,"tags":["a","<b>"],"http":{"status":200,"path":"/"},"this name is far too long to be an SD name":1}`,
+	}
+	m = JSONContentHandler{}.Handle(m)
+	expect.String(m.Data).ToBe(t, `[x@1 a="b"][json@32473 http.path="/" http.status="200" msg="say \"hi\"" n="1.5" none="" ok="true" tags="[\"a\",\"<b>\"\]"]`)
+
+	plain := &Message{Content: "{not json"}
+	expect.String(JSONContentHandler{SDID: "j@1"}.Handle(plain).Data).ToBe(t, "")
+}
@@ -0,0 +1,68 @@
+package syslog
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+// rawForwarder is a LazyHandler that records the raw bytes of each message.
+type rawForwarder struct {
+	raw    []string
+	parsed []bool
+}
+
+func (h *rawForwarder) Handle(m *Message) *Message { return m }
+
+func (h *rawForwarder) HandleLazy(m *Message) *Message {
+	h.raw = append(h.raw, string(m.Raw))
+	h.parsed = append(h.parsed, m.IsParsed())
+	return m
+}
+
+func TestWithLazyParsing(t *testing.T) {
+	s := NewServer(WithLazyParsing())
+	s.SetFacilityMapper(ClampFacilities(Local7))
+
+	lazy := &rawForwarder{}
+	var received []*Message
+	s.AddHandler(lazy)
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received = append(received, m)
+		}
+		return m
+	}))
+
+	for _, pkt := range []string{
+		"<250>1 2023-10-26T15:30:00Z host app 123 m1 - hello",
+		"<13>1 2023-99-26T15:30:00Z host app 123 m1 - bad timestamp",
+		"<1x>1 - host app - - - invalid PRI",
+	} {
+		if m := s.receive([]byte(pkt), nil, AcceptEverything); m != nil {
+			s.push(m)
+		}
+	}
+	s.Shutdown()
+
+	expect.Number(len(lazy.raw)).ToBe(t, 2)
+	expect.String(lazy.raw[0]).ToBe(t, "<250>1 2023-10-26T15:30:00Z host app 123 m1 - hello")
+	expect.Bool(lazy.parsed[0]).ToBeFalse(t)
+
+	expect.Number(len(received)).ToBe(t, 2)
+	expect.Bool(received[0].IsParsed()).ToBeTrue(t)
+	expect.String(received[0].Hostname).ToBe(t, "host")
+	expect.String(received[0].Content).ToBe(t, "hello")
+	expect.Any(received[0].Facility).ToBe(t, Local7) // mapped on receipt
+	expect.String(received[1].Annotations[OriginalTimestamp]).ToBe(t, "2023-99-26T15:30:00Z")
+}
+
+func TestWithLazyParsing_filtered(t *testing.T) {
+	s := NewServer(WithLazyParsing())
+	defer s.Shutdown()
+
+	// a listener filter other than AcceptEverything needs the whole message
+	m := s.receive([]byte("<13>1 - host app - - - hello"), nil, Severities{Notice}.Filter())
+	expect.Bool(m.IsParsed()).ToBeTrue(t)
+	expect.Bool(m.Raw == nil).ToBeTrue(t)
+}
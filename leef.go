@@ -0,0 +1,123 @@
+package syslog
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// DefaultLEEFSDID is the SD-ID used by [LEEFHandler] when its SDID is blank.
+const DefaultLEEFSDID = "leef@32473"
+
+// leefHeader names the header fields of LEEF, as structured data parameters.
+var leefHeader = []string{"leefVersion", "deviceVendor", "deviceProduct", "deviceVersion", "eventId"}
+
+// LEEFHandler is a [Handler] that decodes IBM QRadar Log Event Extended Format (LEEF)
+// messages into structured data, like [CEFHandler] does for CEF. The content of such a
+// message has one of the forms
+//
+//	LEEF:1.0|Vendor|Product|Version|EventID|Attributes
+//	LEEF:2.0|Vendor|Product|Version|EventID|Delimiter|Attributes
+//
+// where the attributes are key=value pairs separated by tabs in LEEF 1.0, or by the given
+// delimiter in LEEF 2.0 (a character, or its code in hex such as x5E or 0x5E). A structured
+// data element is added with the header fields as the parameters leefVersion, deviceVendor,
+// deviceProduct, deviceVersion and eventId, followed by the attributes, e.g.
+// [leef@32473 leefVersion="2.0" deviceVendor="Lancope" ... src="10.0.0.1"]. Attribute keys
+// that cannot be SD parameter names are dropped.
+//
+// As for CEF, a "LEEF" prefix that the RFC 3164 parser took for the application (or the
+// hostname) is restored to the content, and the application becomes the device product.
+// Other messages are unchanged.
+type LEEFHandler struct {
+	// SDID is the SD-ID of the element added to the structured data, which should have the
+	// form name@enterprise-number. If blank, [DefaultLEEFSDID] is used.
+	SDID string
+}
+
+// Close does nothing; it implements [io.Closer].
+func (h LEEFHandler) Close() error {
+	return nil
+}
+
+func (h LEEFHandler) Handle(m *Message) *Message {
+	if m == nil || !m.IsParsed() {
+		return m
+	}
+
+	content, misparsed := findPayload(m, "LEEF")
+	if content == "" {
+		return m
+	}
+	params, err := parseLEEF(content)
+	if err != nil {
+		return m
+	}
+
+	addPayload(m, ifBlank(h.SDID, DefaultLEEFSDID), params, content, misparsed)
+	return m
+}
+
+var errNotLEEF = errors.New("not LEEF")
+
+// parseLEEF splits LEEF into the header fields and the attributes.
+func parseLEEF(s string) ([]SDParam, error) {
+	s, found := strings.CutPrefix(s, "LEEF:")
+	if !found {
+		return nil, errNotLEEF
+	}
+
+	params := make([]SDParam, 0, len(leefHeader)+8)
+	for _, name := range leefHeader {
+		field, rest, found := strings.Cut(s, "|")
+		if !found {
+			return nil, errNotLEEF
+		}
+		params = append(params, SDParam{name, field})
+		s = rest
+	}
+
+	delimiter := "\t"
+	if !strings.HasPrefix(params[0].Value, "1.") {
+		field, rest, found := strings.Cut(s, "|")
+		if !found {
+			return nil, errNotLEEF
+		}
+		if field != "" {
+			d, err := leefDelimiter(field)
+			if err != nil {
+				return nil, err
+			}
+			delimiter = d
+		}
+		s = rest
+	}
+
+	for _, attr := range strings.Split(s, delimiter) {
+		if key, value, found := strings.Cut(attr, "="); found && key != "" {
+			params = append(params, SDParam{strings.TrimSpace(key), value})
+		}
+	}
+	return params, nil
+}
+
+// leefDelimiter decodes the delimiter field of LEEF 2.0.
+func leefDelimiter(field string) (string, error) {
+	if len(field) == 1 {
+		return field, nil
+	}
+
+	lower := strings.ToLower(field)
+	hex, found := strings.CutPrefix(lower, "0x")
+	if !found {
+		hex, found = strings.CutPrefix(lower, "x")
+	}
+	if !found {
+		return "", errNotLEEF
+	}
+	code, err := strconv.ParseUint(hex, 16, 8)
+	if err != nil || code == 0 {
+		return "", errNotLEEF
+	}
+	return string(rune(code)), nil
+}
@@ -0,0 +1,37 @@
+package syslog
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestLEEFHandler(t *testing.T) {
+	const header = `deviceVendor="Lancope" deviceProduct="StealthWatch" deviceVersion="1.0" eventId="41" `
+	cases := []struct {
+		in, host, app, content, data string
+	}{
+		{"<134>Feb  1 12:00:00 qr1 LEEF:1.0|Lancope|StealthWatch|1.0|41|src=10.0.0.1\tdst=10.0.0.2\tmsg=a=b c",
+			"qr1", "StealthWatch", "LEEF:1.0|Lancope|StealthWatch|1.0|41|src=10.0.0.1\tdst=10.0.0.2\tmsg=a=b c",
+			`[leef@32473 leefVersion="1.0" ` + header + `src="10.0.0.1" dst="10.0.0.2" msg="a=b c"]`},
+		{"<134>1 2024-01-01T00:00:00Z qr1 sw - - - LEEF:2.0|Lancope|StealthWatch|1.0|41|^|src=10.0.0.1^dst=10.0.0.2",
+			"qr1", "sw", "LEEF:2.0|Lancope|StealthWatch|1.0|41|^|src=10.0.0.1^dst=10.0.0.2",
+			`[leef@32473 leefVersion="2.0" ` + header + `src="10.0.0.1" dst="10.0.0.2"]`},
+		{"<134>1 2024-01-01T00:00:00Z qr1 sw - - - LEEF:2.0|Lancope|StealthWatch|1.0|41|0x7C|src=1|bad key=2",
+			"qr1", "sw", "LEEF:2.0|Lancope|StealthWatch|1.0|41|0x7C|src=1|bad key=2",
+			`[leef@32473 leefVersion="2.0" ` + header + `src="1"]`},
+		{"<134>1 2024-01-01T00:00:00Z qr1 sw - - - LEEF:2.0|Lancope|StealthWatch|1.0|41|xZZ|src=1",
+			"qr1", "sw", "LEEF:2.0|Lancope|StealthWatch|1.0|41|xZZ|src=1", "-"},
+		{"<134>1 2024-01-01T00:00:00Z qr1 sw - - - LEEF:1.0|truncated", "qr1", "sw", "LEEF:1.0|truncated", "-"},
+	}
+
+	for _, c := range cases {
+		m, err := parseMessage([]byte(c.in))
+		expect.Error(err).ToBeNil(t)
+		m = LEEFHandler{}.Handle(m)
+		expect.String(m.Hostname).Info(c.in).ToBe(t, c.host)
+		expect.String(m.Application).Info(c.in).ToBe(t, c.app)
+		expect.String(m.Content).Info(c.in).ToBe(t, c.content)
+		expect.String(m.Data).Info(c.in).ToBe(t, c.data)
+	}
+}
@@ -36,6 +36,16 @@ func Coverage() error {
 	return nil
 }
 
+// Bench runs the benchmarks in the bench package and writes the results to bench.txt;
+// compare results from different commits using benchstat.
+func Bench() error {
+	out, err := sh.Output("go", "test", "-run=NONE", "-bench=.", "-benchmem", "-count=6", "./bench")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile("bench.txt", []byte(out+"\n"), 0644)
+}
+
 // tests the module on both amd64 and i386 architectures for Linux and Windows
 func CrossCompile() error {
 	win := "build"
@@ -0,0 +1,52 @@
+package syslog
+
+import (
+	"os"
+	"time"
+)
+
+// MarkContent is the content of the messages generated by [Server.StartMark].
+const MarkContent = "-- MARK --"
+
+// StartMark starts a goroutine that injects a synthetic "MARK" message into the handler
+// chain at regular intervals, like the mark facility of classic syslogd. This allows
+// downstream consumers to distinguish "no traffic" from "pipeline dead".
+//
+// The MARK messages have the [Syslog] facility, [Info] severity, the local hostname and
+// application name "syslog". They bypass the listener filters, but not the handlers.
+// The goroutine stops when the server is shut down.
+func (s *Server) StartMark(interval time.Duration) {
+	if s.shutDown.Load() {
+		panic("Server is already shut down")
+	}
+
+	hostname, _ := os.Hostname()
+
+	s.receivers.Add(1)
+	go func() {
+		defer s.receivers.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.push(newMark(hostname, s.clock()))
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+func newMark(hostname string, t time.Time) *Message {
+	return &Message{
+		Time:        t,
+		Facility:    Syslog,
+		Severity:    Info,
+		Version:     1,
+		Timestamp:   t,
+		Hostname:    hostname,
+		Application: "syslog",
+		Content:     MarkContent,
+	}
+}
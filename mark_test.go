@@ -0,0 +1,27 @@
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestStartMark(t *testing.T) {
+	received := make(chan *Message, 10)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+
+	s.StartMark(10 * time.Millisecond)
+	m := <-received
+	s.Shutdown()
+
+	expect.String(m.Content).ToBe(t, MarkContent)
+	expect.Number(m.Facility).ToBe(t, Syslog)
+	expect.Number(m.Severity).ToBe(t, Info)
+}
@@ -1,7 +1,8 @@
 package syslog
 
 import (
-	"fmt"
+	"bytes"
+	"maps"
 	"net"
 	"strconv"
 	"strings"
@@ -11,8 +12,10 @@ import (
 // Message is a Syslog message. See https://www.rfc-editor.org/rfc/rfc5424
 // and its forerunner https://www.rfc-editor.org/rfc/rfc3164.
 type Message struct {
-	Time   time.Time // locally determined
-	Source net.Addr  // from network socket
+	Time     time.Time // locally determined
+	Source   net.Addr  // from network socket
+	Sequence uint64    // ingest sequence number, if enabled (see [Server.SetSequencing])
+	Size     int       // number of bytes received
 	//--- Header ---
 	Facility
 	Severity
@@ -24,6 +27,64 @@ type Message struct {
 	MsgID       string    // absent | 1*32PRINTUSASCII
 	Data        string    // structured data as defined in RFC 5424 like `[id item="value"]
 	Content     string    // message content
+	//--- Metadata ---
+	Annotations map[string]string // added during parsing and handling; not part of the message
+	TLSPeer     *PeerIdentity     // the verified client certificate, if any (see [MutualTLSConfig])
+	Raw         []byte            // the packet as received, only with lazy parsing (see [WithLazyParsing])
+
+	unparsed bool
+}
+
+// IsParsed returns false for a message that has not yet been parsed because the server uses
+// lazy parsing (see [WithLazyParsing]). Only Time, Source, Sequence, Size, Facility, Severity
+// and Raw are set until [Message.Parse] is called.
+func (m *Message) IsParsed() bool {
+	return !m.unparsed
+}
+
+// Parse completes the parsing of a message received with lazy parsing, filling in the
+// remaining fields from Raw. It does nothing if the message has already been parsed.
+func (m *Message) Parse() error {
+	if !m.unparsed {
+		return nil
+	}
+
+	p, err := parseMessageAt(m.Raw, m.Time)
+	if err != nil {
+		return err
+	}
+
+	// keep what was determined on receipt, which may differ from the raw packet
+	p.Source = m.Source
+	p.Sequence = m.Sequence
+	p.Size = m.Size
+	p.TLSPeer = m.TLSPeer
+	p.Facility = m.Facility
+	p.Raw = m.Raw
+	for k, v := range m.Annotations {
+		p.Annotate(k, v)
+	}
+
+	*m = *p
+	return nil
+}
+
+// Annotate adds metadata to the message. Annotations are not part of the syslog message
+// but can be used by filters and handlers.
+func (m *Message) Annotate(key, value string) {
+	if m.Annotations == nil {
+		m.Annotations = make(map[string]string)
+	}
+	m.Annotations[key] = value
+}
+
+// Clone returns a copy of the message that can be modified, or retained whilst the original
+// is modified, without affecting the original; the annotations and Raw are copied too.
+func (m *Message) Clone() *Message {
+	c := *m
+	c.Annotations = maps.Clone(m.Annotations)
+	c.Raw = bytes.Clone(m.Raw)
+	return &c
 }
 
 func (m *Message) Priority() int {
@@ -57,6 +118,24 @@ func (m *Message) RFC5424() string {
 	return m.format("<%Z>%V %T %H %A %P %M %D %C", v)
 }
 
+// appendRFC5424 renders the message strictly according to RFC5424, using NILVALUE for
+// blank fields and full timestamp precision, so that it can be parsed again without loss.
+func (m *Message) appendRFC5424(bs []byte) []byte {
+	bs = append(bs, '<')
+	bs = strconv.AppendInt(bs, int64(m.Priority()), 10)
+	bs = append(bs, ">1 "...)
+	bs = m.ts().AppendFormat(bs, time.RFC3339Nano)
+	for _, field := range []string{m.Hostname, m.Application, m.ProcID, m.MsgID, m.Data} {
+		bs = append(bs, ' ')
+		bs = append(bs, ifBlank(field, "-")...)
+	}
+	if m.Content != "" {
+		bs = append(bs, ' ')
+		bs = append(bs, m.Content...)
+	}
+	return bs
+}
+
 // RFCFormat produces RFC5424 renderings for v1 messages and a rendering quite similar
 // to RFC3164 for v0 messages, although RFC3164 is not very specific.
 const RFCFormat = "<%Z>%v %T %H %A %P %M %D %C"
@@ -67,11 +146,15 @@ const RFCFormat = "<%Z>%v %T %H %A %P %M %D %C"
 //   - %A = application (and process ID if version 0)
 //   - %C = message content
 //   - %D = structured data
+//   - %E = receive time as nanoseconds since the Unix epoch
 //   - %F = facility
+//   - %f = facility number (useful for unknown facilities)
 //   - %H = hostname
 //   - %M = message ID
 //   - %P = process ID (if version >0)
 //   - %N = source network address
+//   - %Q = ingest sequence number (if non-zero)
+//   - %R = receive time, RFC3339 with nanoseconds
 //   - %S = severity
 //   - %T = timestamp (varies according to version)
 //   - %V = version
@@ -82,18 +165,29 @@ const RFCFormat = "<%Z>%v %T %H %A %P %M %D %C"
 // AcceptEverything else is rendered into the result.
 //
 // Blank fields are omitted from the result. Leading spaces are elided before each
-// blank field so that the result is compact.
+// blank field so that the result is compact. Timestamps always use English month names,
+// regardless of the system locale.
 func (m *Message) Format(format string) string {
 	return m.format(format, m.Version)
 }
 
+// AppendFormat is like [Message.Format] but appends the rendering to a byte slice,
+// returning the extended slice. Reusing the slice avoids allocating for each message.
+func (m *Message) AppendFormat(bs []byte, format string) []byte {
+	return m.appendFormat(bs, format, m.Version)
+}
+
 func (m *Message) format(format string, version int) string {
-	sw := &buffer{}
-	bs := []byte(format)
+	return string(m.appendFormat(nil, format, version))
+}
+
+func (m *Message) appendFormat(bs []byte, format string, version int) []byte {
+	sw := &buffer{bs: bs, start: len(bs)}
 	space := false
 	esc := false
 
-	for _, b := range bs {
+	for i := 0; i < len(format); i++ {
+		b := format[i]
 		if b == '%' {
 			if esc {
 				sw.WriteByte(b)
@@ -106,7 +200,7 @@ func (m *Message) format(format string, version int) string {
 			sw.WriteByte(b)
 		}
 	}
-	return sw.String()
+	return sw.bs
 }
 
 func (m *Message) f1(sw *buffer, b byte, space bool, version int) bool {
@@ -114,7 +208,10 @@ func (m *Message) f1(sw *buffer, b byte, space bool, version int) bool {
 	case 'A':
 		if m.Application != "" {
 			if version == 0 && m.Application != "" && m.ProcID != "" {
-				fmt.Fprintf(sw, "%s[%s]", m.Application, m.ProcID)
+				sw.WriteString(m.Application)
+				sw.WriteByte('[')
+				sw.WriteString(m.ProcID)
+				sw.WriteByte(']')
 			} else {
 				sw.WriteString(m.Application)
 			}
@@ -138,9 +235,18 @@ func (m *Message) f1(sw *buffer, b byte, space bool, version int) bool {
 			space = true
 		}
 
+	case 'E':
+		if !m.Time.IsZero() {
+			sw.bs = strconv.AppendInt(sw.bs, m.Time.UnixNano(), 10)
+			space = true
+		}
+
 	case 'F':
 		sw.WriteString(m.Facility.String())
 
+	case 'f':
+		sw.bs = strconv.AppendInt(sw.bs, int64(m.Facility), 10)
+
 	case 'H':
 		if m.Hostname != "" {
 			sw.WriteString(m.Hostname)
@@ -167,6 +273,18 @@ func (m *Message) f1(sw *buffer, b byte, space bool, version int) bool {
 			}
 		}
 
+	case 'Q':
+		if m.Sequence > 0 {
+			sw.bs = strconv.AppendUint(sw.bs, m.Sequence, 10)
+			space = true
+		}
+
+	case 'R':
+		if !m.Time.IsZero() {
+			sw.bs = m.Time.AppendFormat(sw.bs, time.RFC3339Nano)
+			space = true
+		}
+
 	case 'S':
 		sw.WriteString(m.Severity.String())
 
@@ -175,30 +293,30 @@ func (m *Message) f1(sw *buffer, b byte, space bool, version int) bool {
 			sw.TrimRightFunc(func(x byte) bool {
 				return x == ' '
 			})
-			sw.WriteString(m.ts().Format(rfc3164LayoutNoYear))
+			sw.bs = m.ts().AppendFormat(sw.bs, rfc3164LayoutNoYear)
 		} else {
-			sw.WriteString(m.ts().Format(time.RFC3339))
+			sw.bs = m.ts().AppendFormat(sw.bs, time.RFC3339)
 		}
 		space = true
 
 	case 'V':
-		sw.WriteString(strconv.Itoa(version))
+		sw.bs = strconv.AppendInt(sw.bs, int64(version), 10)
 		space = true
 
 	case 'v':
 		if version > 0 {
-			sw.WriteString(strconv.Itoa(version))
+			sw.bs = strconv.AppendInt(sw.bs, int64(version), 10)
 			space = true
 		}
 
 	case 'Y':
 		if version == 0 {
-			sw.WriteString(strconv.Itoa(m.ts().Year()))
+			sw.bs = strconv.AppendInt(sw.bs, int64(m.ts().Year()), 10)
 			space = true
 		}
 
 	case 'Z':
-		sw.WriteString(strconv.Itoa(m.Priority()))
+		sw.bs = strconv.AppendInt(sw.bs, int64(m.Priority()), 10)
 		space = false
 
 	case ' ':
@@ -224,7 +342,8 @@ func (m *Message) ts() time.Time {
 //-------------------------------------------------------------------------------------------------
 
 type buffer struct {
-	bs []byte
+	bs    []byte
+	start int // trimming never goes below this
 }
 
 func (b *buffer) Len() int { return len(b.bs) }
@@ -235,7 +354,8 @@ func (b *buffer) WriteByte(c byte) error {
 }
 
 func (b *buffer) WriteString(s string) (int, error) {
-	return b.Write([]byte(s))
+	b.bs = append(b.bs, s...)
+	return len(s), nil
 }
 
 func (b *buffer) Write(bs []byte) (int, error) {
@@ -255,12 +375,12 @@ func (b *buffer) Last() byte {
 }
 
 func (b *buffer) TrimRightFunc(predicate func(byte) bool) {
-	for i := len(b.bs) - 1; i >= 0; i-- {
+	for i := len(b.bs) - 1; i >= b.start; i-- {
 		c := b.bs[i]
 		if !predicate(c) {
 			b.bs = b.bs[:i+1]
 			return
 		}
 	}
-	b.bs = b.bs[:0]
+	b.bs = b.bs[:b.start]
 }
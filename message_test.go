@@ -64,10 +64,11 @@ func TestMessage_String(t *testing.T) {
 }
 
 func TestMessage_Format(t *testing.T) {
-	tx := time.Date(2023, 10, 26, 15, 31, 1, 0, time.UTC)
+	tx := time.Date(2023, 10, 26, 15, 31, 1, 123456789, time.UTC)
 
 	m := Message{
 		Time:        tx,
+		Sequence:    42,
 		Facility:    User,
 		Severity:    Debug,
 		Version:     0,
@@ -95,7 +96,11 @@ func TestMessage_Format(t *testing.T) {
 		{f: "%D", v0: "[example@32473 eventSource=\"system\"]", v1: "[example@32473 eventSource=\"system\"]"},
 		{f: "%C", v0: "This is a sample syslog message", v1: "This is a sample syslog message"},
 		{f: "%F", v0: "user", v1: "user"},
+		{f: "%f", v0: "1", v1: "1"},
 		{f: "%S", v0: "debug", v1: "debug"},
+		{f: "%Q", v0: "42", v1: "42"},
+		{f: "%R", v0: "2023-10-26T15:31:01.123456789Z", v1: "2023-10-26T15:31:01.123456789Z"},
+		{f: "%E", v0: "1698334261123456789", v1: "1698334261123456789"},
 		{f: "%%", v0: "%", v1: "%"},
 	}
 	for _, c := range cases {
@@ -106,3 +111,19 @@ func TestMessage_Format(t *testing.T) {
 		expect.String(m.Format(c.f)).Info(c.f).ToBe(t, c.v1)
 	}
 }
+
+func TestMessage_AppendFormat(t *testing.T) {
+	m := Message{
+		Facility:  User,
+		Severity:  Debug,
+		Timestamp: time.Date(2023, 10, 26, 15, 30, 0, 0, time.UTC),
+		Content:   `:content`,
+	}
+
+	// trimming before the content must not reach into the existing prefix
+	bs := m.AppendFormat([]byte("prefix "), "%C")
+	expect.String(string(bs)).ToBe(t, "prefix :content")
+
+	bs = m.AppendFormat(bs[:0], "<%Z> %T %C")
+	expect.String(string(bs)).ToBe(t, "<15>Oct 26 15:30:00:content")
+}
@@ -0,0 +1,252 @@
+package syslog
+
+import (
+	"cmp"
+	"expvar"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MetricKind is the type of a metric derived by a [MetricHandler].
+type MetricKind int
+
+const (
+	// Counter metrics are increased by the value of each matching message.
+	Counter MetricKind = iota
+	// Gauge metrics are set to the value of the latest matching message.
+	Gauge
+)
+
+func (k MetricKind) String() string {
+	if k == Gauge {
+		return "gauge"
+	}
+	return "counter"
+}
+
+// defaultMaxSeries is used when [MetricRule.MaxSeries] is not specified.
+const defaultMaxSeries = 1000
+
+// OtherLabel is the label of the series that counts the messages with label values beyond
+// [MetricRule.MaxSeries].
+const OtherLabel = "other"
+
+// MetricRule describes how to derive one metric from messages. For example, to count
+// nginx 5xx responses per host:
+//
+//	status5xx, _ := CompileRegexpFilter(`" 5\d\d `, 0)
+//	rule := MetricRule{
+//		Name: "nginx_5xx_total",
+//		Filter: All(func(m *Message) bool { return m.Application == "nginx" },
+//			status5xx.Filter()),
+//		LabelName: "host",
+//		Label:     func(m *Message) string { return m.Hostname },
+//	}
+type MetricRule struct {
+	Name string     // the metric name, e.g. "nginx_5xx_total"
+	Kind MetricKind // the default is Counter
+	Help string     // optional description
+
+	// Filter selects the messages that affect the metric; if nil, all messages do.
+	Filter Filter
+
+	// Label, if not nil, splits the metric into a series for each distinct value it
+	// returns, e.g. the hostname. LabelName is the name of the label; the default is "label".
+	// Because senders can choose values such as their hostname, the number of series is
+	// limited to MaxSeries (the default is 1000); messages with further label values all
+	// go into a series labelled [OtherLabel].
+	Label     func(*Message) string
+	LabelName string
+	MaxSeries int
+
+	// Value extracts the value from each message, returning false if there is none, in
+	// which case the message is ignored. If nil, the value is 1, which counts the messages.
+	// See [ExtractValue].
+	Value func(*Message) (float64, bool)
+}
+
+// Metric is the current value of one series of a metric.
+type Metric struct {
+	Name  string
+	Kind  MetricKind
+	Label string // the label value, if the rule has a label
+	Value float64
+}
+
+type metricKey struct {
+	rule  int
+	label string
+}
+
+// MetricHandler is a [Handler] that derives simple metrics, such as counts of errors per
+// host, from the messages it sees, so that service-level indicators can be obtained
+// without an external log-to-metric pipeline. The metrics can be read using
+// [MetricHandler.Metrics], published with [expvar] (see [MetricHandler.Publish]), or served
+// in the Prometheus text format because MetricHandler is also an [http.Handler].
+// All messages are passed on to subsequent handlers. A MetricHandler is safe for
+// concurrent use.
+type MetricHandler struct {
+	rules  []MetricRule
+	mu     sync.Mutex
+	values map[metricKey]float64
+	series []int // the number of series of each rule
+}
+
+// NewMetricHandler creates a handler that maintains the metrics described by the rules.
+func NewMetricHandler(rules ...MetricRule) *MetricHandler {
+	return &MetricHandler{
+		rules:  rules,
+		values: make(map[metricKey]float64),
+		series: make([]int, len(rules)),
+	}
+}
+
+// ExtractValue returns a [MetricRule] value function that parses the numeric text matched
+// by a group of a regular expression in the message content, e.g. the response time in an
+// access log.
+func ExtractValue(re *regexp.Regexp, group int) func(*Message) (float64, bool) {
+	return func(m *Message) (float64, bool) {
+		match := re.FindStringSubmatch(m.Content)
+		if group >= len(match) {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(match[group], 64)
+		return v, err == nil
+	}
+}
+
+// Close does nothing; it implements [io.Closer].
+func (h *MetricHandler) Close() error {
+	return nil
+}
+
+func (h *MetricHandler) Handle(m *Message) *Message {
+	if m == nil {
+		return nil
+	}
+
+	for i, r := range h.rules {
+		if r.Filter != nil && !r.Filter(m) {
+			continue
+		}
+
+		v := 1.0
+		if r.Value != nil {
+			var ok bool
+			if v, ok = r.Value(m); !ok {
+				continue
+			}
+		}
+
+		key := metricKey{rule: i}
+		if r.Label != nil {
+			key.label = r.Label(m)
+		}
+
+		h.mu.Lock()
+		if _, exists := h.values[key]; !exists {
+			if r.Label != nil && h.series[i] >= cmp.Or(r.MaxSeries, defaultMaxSeries) {
+				key.label = OtherLabel
+			}
+			if _, exists = h.values[key]; !exists {
+				h.series[i]++
+			}
+		}
+		if r.Kind == Gauge {
+			h.values[key] = v
+		} else {
+			h.values[key] += v
+		}
+		h.mu.Unlock()
+	}
+	return m
+}
+
+// Metrics gets the current values of all the metrics, ordered by name and then label.
+func (h *MetricHandler) Metrics() []Metric {
+	h.mu.Lock()
+	ms := make([]Metric, 0, len(h.values))
+	for k, v := range h.values {
+		r := h.rules[k.rule]
+		ms = append(ms, Metric{Name: r.Name, Kind: r.Kind, Label: k.label, Value: v})
+	}
+	h.mu.Unlock()
+
+	slices.SortFunc(ms, func(a, b Metric) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Label, b.Label)
+	})
+	return ms
+}
+
+// Publish makes the metrics available as an [expvar] variable with the given name, which
+// must be unique. The variable is a map from metric name to value, or to a map of
+// label values to values for metrics that have labels.
+func (h *MetricHandler) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		vars := make(map[string]any)
+		for _, m := range h.Metrics() {
+			if h.hasLabel(m.Name) {
+				series, _ := vars[m.Name].(map[string]float64)
+				if series == nil {
+					series = make(map[string]float64)
+					vars[m.Name] = series
+				}
+				series[m.Label] = m.Value
+			} else {
+				vars[m.Name] = m.Value
+			}
+		}
+		return vars
+	}))
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (h *MetricHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	var b strings.Builder
+	described := make(map[string]bool)
+	for _, m := range h.Metrics() {
+		r := h.rule(m.Name)
+		if !described[m.Name] {
+			described[m.Name] = true
+			if r.Help != "" {
+				fmt.Fprintf(&b, "# HELP %s %s\n", m.Name, helpEscaper.Replace(r.Help))
+			}
+			fmt.Fprintf(&b, "# TYPE %s %s\n", m.Name, m.Kind)
+		}
+
+		b.WriteString(m.Name)
+		if r.Label != nil {
+			fmt.Fprintf(&b, "{%s=\"%s\"}", ifBlank(r.LabelName, "label"), labelEscaper.Replace(m.Label))
+		}
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(m.Value, 'g', -1, 64))
+		b.WriteByte('\n')
+	}
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// The Prometheus text format escapes only these characters; other characters, such as tabs
+// and non-ASCII letters, are written as they are.
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func (h *MetricHandler) rule(name string) MetricRule {
+	i := slices.IndexFunc(h.rules, func(r MetricRule) bool { return r.Name == name })
+	return h.rules[i]
+}
+
+func (h *MetricHandler) hasLabel(name string) bool {
+	return h.rule(name).Label != nil
+}
@@ -0,0 +1,75 @@
+package syslog
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestMetricHandler(t *testing.T) {
+	status5xx, err := CompileRegexpFilter(`" 5\d\d `, 0)
+	expect.Error(err).ToBeNil(t)
+
+	h := NewMetricHandler(
+		MetricRule{
+			Name:      "nginx_5xx_total",
+			Help:      "Server errors.",
+			Filter:    status5xx.Filter(),
+			LabelName: "host",
+			Label:     func(m *Message) string { return m.Hostname },
+		},
+		MetricRule{
+			Name:  "nginx_request_seconds",
+			Kind:  Gauge,
+			Value: ExtractValue(regexp.MustCompile(`rt=([0-9.]+)`), 1),
+		},
+	)
+
+	for _, m := range []*Message{
+		{Hostname: "web1", Content: `"GET / HTTP/1.1" 502 0 rt=0.5`},
+		{Hostname: "web1", Content: `"GET / HTTP/1.1" 200 10 rt=0.25`},
+		{Hostname: "web2", Content: `"GET / HTTP/1.1" 503 0`},
+		{Hostname: "web1", Content: `"GET / HTTP/1.1" 500 0 rt=1.5`},
+	} {
+		expect.Any(h.Handle(m)).ToBe(t, m)
+	}
+
+	expect.Slice(h.Metrics()).ToBe(t,
+		Metric{Name: "nginx_5xx_total", Label: "web1", Value: 2},
+		Metric{Name: "nginx_5xx_total", Label: "web2", Value: 1},
+		Metric{Name: "nginx_request_seconds", Kind: Gauge, Value: 1.5},
+	)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	expect.String(w.Body.String()).ToBe(t, `# HELP nginx_5xx_total Server errors.
+# TYPE nginx_5xx_total counter
+nginx_5xx_total{host="web1"} 2
+nginx_5xx_total{host="web2"} 1
+# TYPE nginx_request_seconds gauge
+nginx_request_seconds 1.5
+`)
+}
+
+func TestMetricHandler_labels(t *testing.T) {
+	h := NewMetricHandler(MetricRule{
+		Name:      "messages_total",
+		LabelName: "host",
+		Label:     func(m *Message) string { return m.Hostname },
+		MaxSeries: 3,
+	})
+
+	for _, host := range []string{"tab\there", "café", "tab\there", `q"\` + "\n", "web9"} {
+		h.Handle(&Message{Hostname: host})
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	expect.String(w.Body.String()).ToBe(t, "# TYPE messages_total counter\n"+
+		"messages_total{host=\"café\"} 1\n"+
+		"messages_total{host=\"other\"} 1\n"+
+		"messages_total{host=\"q\\\"\\\\\\n\"} 1\n"+
+		"messages_total{host=\"tab\there\"} 2\n")
+}
@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package syslog
+
+import "errors"
+
+func mkfifo(string) error {
+	return errors.New("named pipes are not supported on this platform")
+}
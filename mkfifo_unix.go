@@ -0,0 +1,9 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package syslog
+
+import "syscall"
+
+func mkfifo(path string) error {
+	return syscall.Mkfifo(path, 0620)
+}
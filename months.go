@@ -0,0 +1,61 @@
+package syslog
+
+import (
+	"strings"
+	"time"
+)
+
+// MonthNames lists the abbreviated names of the months, January first, in some locale.
+type MonthNames [12]string
+
+// Month name abbreviations used by some legacy devices.
+var (
+	GermanMonths  = MonthNames{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"}
+	FrenchMonths  = MonthNames{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."}
+	SpanishMonths = MonthNames{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sep", "oct", "nov", "dic"}
+	ItalianMonths = MonthNames{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"}
+	DutchMonths   = MonthNames{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"}
+)
+
+// monthAliases maps lower-case localised month names to months.
+var monthAliases = make(map[string]time.Month)
+
+// AddMonthNames allows RFC3164 timestamps containing localised month names to be parsed.
+// Names are matched case-insensitively and any trailing '.' is optional. Several locales
+// can be added, provided their names do not conflict. This should be called before any
+// messages are received.
+//
+// Messages are always rendered with English month names, regardless of the system locale
+// and of the names received.
+func AddMonthNames(names MonthNames) {
+	for i, n := range names {
+		monthAliases[monthKey(n)] = time.Month(i + 1)
+	}
+}
+
+func monthKey(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// englishMonth replaces a localised month name at the start of an RFC3164 timestamp
+// (which may be preceded by the year) with its English abbreviation.
+func englishMonth(s string) string {
+	if len(monthAliases) == 0 {
+		return s
+	}
+
+	start := 0
+	if len(s) > 5 && s[4] == ' ' && strings.Trim(s[:4], "0123456789") == "" {
+		start = 5 // skip the year
+	}
+
+	sp := strings.IndexByte(s[start:], ' ')
+	if sp <= 0 {
+		return s
+	}
+
+	if m, exists := monthAliases[monthKey(s[start:start+sp])]; exists {
+		return s[:start] + m.String()[:3] + s[start+sp:]
+	}
+	return s
+}
@@ -0,0 +1,26 @@
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestEnglishMonth(t *testing.T) {
+	AddMonthNames(GermanMonths)
+	AddMonthNames(FrenchMonths)
+
+	expect.String(englishMonth("Okt 11 22:14:15 host")).ToBe(t, "Oct 11 22:14:15 host")
+	expect.String(englishMonth("MÄR  5 22:14:15 host")).ToBe(t, "Mar  5 22:14:15 host")
+	expect.String(englishMonth("1990 Dez 22 10:52:01 host")).ToBe(t, "1990 Dec 22 10:52:01 host")
+	expect.String(englishMonth("févr 22 10:52:01 host")).ToBe(t, "Feb 22 10:52:01 host")
+	expect.String(englishMonth("Oct 11 22:14:15 host")).ToBe(t, "Oct 11 22:14:15 host")
+	expect.String(englishMonth("host: message")).ToBe(t, "host: message")
+
+	now = func() time.Time { return time.Date(2023, 10, 26, 15, 31, 1, 0, time.UTC) }
+	m, err := parseMessage([]byte("<34>déc. 24 22:14:15 mymachine su: hello"))
+	expect.Error(err).ToBeNil(t)
+	expect.Any(m.Timestamp).ToBe(t, time.Date(2023, 12, 24, 22, 14, 15, 0, time.UTC))
+	expect.String(m.Format("%T")).ToBe(t, "Dec 24 22:14:15")
+}
@@ -0,0 +1,85 @@
+package syslog
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// IDGenerator returns a new unique identifier each time it is called; see
+// [Server.SetMessageIDs]. [NewULID] and [NewUUID] are suitable.
+type IDGenerator func() string
+
+// IDAnnotation is the annotation key for the unique identifier of a message; see
+// [Server.SetMessageIDs].
+const IDAnnotation = "id"
+
+// SetMessageIDs assigns a unique identifier from gen to every accepted message, so that
+// downstream systems can deduplicate and cross-reference events. The identifier is always
+// recorded as an annotation ([IDAnnotation]). If sdID is blank, it is also placed in the
+// MsgID of messages that do not have one; RFC 5424 limits MsgID to 32 characters, which
+// suits [NewULID]. Otherwise, it is added to the structured data of every message as an
+// element such as [sdID id="..."]; sdID should have the form name@enterprise-number.
+// Messages that have not been parsed (see [WithLazyParsing]) are only annotated. A nil gen
+// disables identifiers. This must be set before calling [Server.Listen].
+func (s *Server) SetMessageIDs(gen IDGenerator, sdID string) {
+	s.idGenerator = gen
+	s.idElement = sdID
+}
+
+// assignID stamps a message with a new identifier.
+func (s *Server) assignID(m *Message) {
+	id := s.idGenerator()
+	m.Annotate(IDAnnotation, id)
+	if !m.IsParsed() {
+		return
+	}
+
+	switch {
+	case s.idElement != "":
+		m.addSDElement(s.idElement, "id", id)
+	case m.MsgID == "" || m.MsgID == "-":
+		m.MsgID = id
+	}
+}
+
+//-------------------------------------------------------------------------------------------------
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a new ULID (https://github.com/ulid/spec): 26 characters that sort in
+// order of creation, to the millisecond.
+func NewULID() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	rand.Read(b[6:])
+
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var id [26]byte
+	for i := len(id) - 1; i >= 0; i-- {
+		id[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(id[:])
+}
+
+// NewUUID returns a new version 7 UUID (RFC 9562), which sorts in order of creation, to
+// the millisecond.
+func NewUUID() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	rand.Read(b[6:])
+	b[6] = b[6]&0x0f | 0x70 // version 7
+	b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+
+	var id [36]byte
+	hex.Encode(id[0:8], b[0:4])
+	hex.Encode(id[9:13], b[4:6])
+	hex.Encode(id[14:18], b[6:8])
+	hex.Encode(id[19:23], b[8:10])
+	hex.Encode(id[24:], b[10:])
+	id[8], id[13], id[18], id[23] = '-', '-', '-', '-'
+	return string(id[:])
+}
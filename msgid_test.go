@@ -0,0 +1,59 @@
+package syslog
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestNewULID(t *testing.T) {
+	a := NewULID()
+	time.Sleep(2 * time.Millisecond)
+	b := NewULID()
+	expect.Bool(regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`).MatchString(a)).Info(a).ToBeTrue(t)
+	expect.Bool(a < b).Info(a, b).ToBeTrue(t)
+}
+
+func TestNewUUID(t *testing.T) {
+	a := NewUUID()
+	time.Sleep(2 * time.Millisecond)
+	b := NewUUID()
+	expect.Bool(regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(a)).Info(a).ToBeTrue(t)
+	expect.Bool(a < b).Info(a, b).ToBeTrue(t)
+}
+
+func TestServer_SetMessageIDs(t *testing.T) {
+	n := 0
+	gen := func() string {
+		n++
+		return string(rune('0' + n))
+	}
+
+	s := NewServer()
+	defer s.Shutdown()
+	s.SetMessageIDs(gen, "")
+
+	empty := &Message{}
+	own := &Message{MsgID: "ID47"}
+	lazy := &Message{unparsed: true}
+	s.assignID(empty)
+	s.assignID(own)
+	s.assignID(lazy)
+	expect.String(empty.MsgID).ToBe(t, "1")
+	expect.String(own.MsgID).ToBe(t, "ID47")
+	expect.String(own.Annotations[IDAnnotation]).ToBe(t, "2")
+	expect.String(lazy.MsgID).ToBe(t, "")
+	expect.String(lazy.Annotations[IDAnnotation]).ToBe(t, "3")
+
+	s.SetMessageIDs(gen, "id@32473")
+	nilData := &Message{MsgID: "ID47", Data: "-"}
+	withData := &Message{Data: `[a x="1"]`}
+	s.assignID(nilData)
+	s.assignID(withData)
+	expect.String(nilData.Data).ToBe(t, `[id@32473 id="4"]`)
+	expect.String(nilData.MsgID).ToBe(t, "ID47")
+	expect.String(withData.Data).ToBe(t, `[a x="1"][id@32473 id="5"]`)
+	expect.String(withData.MsgID).ToBe(t, "")
+}
@@ -0,0 +1,34 @@
+package syslog
+
+import (
+	"net"
+)
+
+// ListenMulticast starts a goroutine that receives syslog messages sent to a UDP multicast
+// group. group is the multicast host:port, e.g. "239.192.0.1:514". iface is the name of the
+// network interface on which to join the group, e.g. "eth0"; if blank, the system chooses.
+// Only the messages matching accept are processed.
+func (s *Server) ListenMulticast(group, iface string, accept Filter) error {
+	a, err := net.ResolveUDPAddr("udp", group)
+	if err != nil {
+		return err
+	}
+
+	var ifi *net.Interface
+	if iface != "" {
+		ifi, err = net.InterfaceByName(iface)
+		if err != nil {
+			return err
+		}
+	}
+
+	c, err := net.ListenMulticastUDP("udp", ifi, a)
+	if err != nil {
+		return err
+	}
+
+	s.listenPacketConn(c, accept, func() (net.PacketConn, error) {
+		return net.ListenMulticastUDP("udp", ifi, a)
+	})
+	return nil
+}
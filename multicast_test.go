@@ -0,0 +1,15 @@
+package syslog
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestListenMulticast_errors(t *testing.T) {
+	s := NewServer(WithQueueLength(1))
+	defer s.Shutdown()
+
+	expect.Error(s.ListenMulticast("239.192.0.1:514", "no-such-interface", AcceptEverything)).ToContain(t, "no such network interface")
+	expect.Error(s.ListenMulticast("not an address", "", AcceptEverything)).ToContain(t, "missing port")
+}
@@ -0,0 +1,26 @@
+//go:build !unix
+
+package syslog
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+const (
+	oNonBlock = 0
+	oNoCTTY   = 0
+)
+
+// nonBlockingTimeout is how long writes to pipes and terminals may wait.
+const nonBlockingTimeout = 10 * time.Millisecond
+
+func writeNonBlocking(f *os.File, bs []byte) (int, error) {
+	_ = f.SetWriteDeadline(time.Now().Add(nonBlockingTimeout)) // ignored for regular files
+	return f.Write(bs)
+}
+
+func wouldBlock(err error) bool {
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
@@ -0,0 +1,48 @@
+//go:build unix
+
+package syslog
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Flags for opening terminals and pipes without waiting for them, and without a terminal
+// becoming the controlling terminal of the process.
+const (
+	oNonBlock = syscall.O_NONBLOCK
+	oNoCTTY   = syscall.O_NOCTTY
+)
+
+// writeNonBlocking writes to a pipe or terminal opened with O_NONBLOCK without waiting for
+// it to become writable; if it cannot accept everything at once, the number of bytes written
+// is returned, with EAGAIN if it accepted none. This is needed because the Go runtime would
+// otherwise wait for it to become writable.
+func writeNonBlocking(f *os.File, bs []byte) (int, error) {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	var writeErr error
+	err = rc.Write(func(fd uintptr) bool {
+		for {
+			n, writeErr = syscall.Write(int(fd), bs)
+			if !errors.Is(writeErr, syscall.EINTR) {
+				return true // done, even if it would block
+			}
+		}
+	})
+	if err == nil {
+		err = writeErr
+	}
+	return max(n, 0), err
+}
+
+// wouldBlock is true for the error from a write that a terminal or pipe could not accept
+// at once, after which it can carry on being used.
+func wouldBlock(err error) bool {
+	return errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, syscall.EAGAIN)
+}
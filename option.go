@@ -0,0 +1,235 @@
+package syslog
+
+import (
+	"log"
+	"net"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Option configures a [Server] when it is created by [NewServer].
+type Option func(*Server)
+
+// defaultQueueLength is used when [WithQueueLength] is not specified.
+const defaultQueueLength = 100
+
+// defaultReadBufferSize is used when [WithReadBufferSize] is not specified. It is large
+// enough for any UDP datagram.
+const defaultReadBufferSize = 64 * 1024
+
+// defaultRestartAttempts is used when [WithRestart] is not specified.
+const defaultRestartAttempts = 10
+
+// defaultIdleTimeout is used when [WithIdleTimeout] is not specified.
+const defaultIdleTimeout = 10 * time.Minute
+
+// WithQueueLength sets the length of the internal queue between the receivers and the
+// handlers. It should be a small positive number; the default is 100.
+func WithQueueLength(qlen int) Option {
+	return func(s *Server) {
+		s.qlen = qlen
+	}
+}
+
+// WithRingQueue makes the internal queue a lock-free ring buffer instead of a channel. This
+// reduces the overhead per message at very high message rates (beyond about 500k per second);
+// otherwise the server behaves identically. The queue length is rounded up to a power of two.
+func WithRingQueue() Option {
+	return func(s *Server) {
+		s.ring = true
+	}
+}
+
+// OverflowPolicy determines what happens when a receiver finds the internal queue full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the receiver wait for room in the queue. Meanwhile, the kernel
+	// may drop incoming datagrams when its socket buffer fills, and stream senders are
+	// slowed down.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest discards the messages that do not fit in the queue.
+	OverflowDropNewest
+
+	// OverflowDropOldest discards the oldest queued messages to make room for new ones.
+	OverflowDropOldest
+)
+
+// WithOverflowPolicy sets what happens when the internal queue is full. The default is
+// [OverflowBlock]. Dropped messages are counted (see [Server.Dropped]). The policy applies to
+// datagrams; stream connections (TCP, TLS, RELP etc.) always wait for room so that their
+// senders are slowed down instead, unless [WithStreamBackpressure] disables this.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(s *Server) {
+		s.overflow = policy
+	}
+}
+
+// WithStreamBackpressure sets whether receivers on stream connections (TCP, TLS, RELP etc.)
+// stop reading while the internal queue is full, so that each sender's own queueing takes
+// effect rather than messages being dropped. This is on by default; when off, stream
+// connections follow the overflow policy like datagrams (see [WithOverflowPolicy]).
+func WithStreamBackpressure(on bool) Option {
+	return func(s *Server) {
+		s.noBackpressure = !on
+	}
+}
+
+// WithLazyParsing defers parsing each message until a handler needs it. Only the priority
+// is decoded on receipt, so pipelines that just forward raw bytes (see [Message.Raw]) or
+// filter on severity avoid the cost of parsing. [LazyHandler]s receive the messages as they
+// are; other handlers receive them parsed. Listeners whose filter is not [AcceptEverything]
+// still parse every message so that the filter can be applied.
+func WithLazyParsing() Option {
+	return func(s *Server) {
+		s.lazy = true
+	}
+}
+
+// WithWorkers sets the number of goroutines that call the handlers, so that slow handlers
+// (such as database writes or HTTP posts) do not hold up the whole pipeline. Messages are
+// assigned to workers by their hostname, or by their source address if they have no
+// hostname, so that the messages from any one sender keep their order. With more than one
+// worker, handlers must be safe for concurrent use, as the built-in handlers are. The
+// default is 1.
+func WithWorkers(n int) Option {
+	return func(s *Server) {
+		s.workers = n
+	}
+}
+
+// WithAutoscaling varies the number of goroutines that call the handlers between lo and hi
+// according to demand: a worker is added whenever the internal queue is more than half full,
+// and removed after the queue has stayed nearly empty for about a second. If hi is zero or
+// less, it is the number of CPUs available (GOMAXPROCS). As with [WithWorkers], messages from
+// any one sender keep their order, and handlers must be safe for concurrent use.
+func WithAutoscaling(lo, hi int) Option {
+	return func(s *Server) {
+		if hi <= 0 {
+			hi = runtime.GOMAXPROCS(0)
+		}
+		s.minWorkers = min(max(lo, 1), hi)
+		s.maxWorkers = hi
+	}
+}
+
+// WithParserCache remembers how each sender formats its messages, keyed by IP address, so
+// that later messages from the same sender are parsed using the format that is likely to
+// work first. This saves CPU time with large fleets of similar devices. size limits the
+// number of senders remembered. By default, there is no cache.
+func WithParserCache(size int) Option {
+	return func(s *Server) {
+		s.parserCache = newParserCache(size)
+	}
+}
+
+// WithReadBufferSize sets the size of the buffer each datagram receiver reads into, which
+// limits the size of datagrams that can be received; longer ones are truncated. The default
+// is 64KiB.
+func WithReadBufferSize(size int) Option {
+	return func(s *Server) {
+		s.readBufferSize = size
+	}
+}
+
+// WithMaxMessageSize limits the size of each message. A longer message is truncated to size
+// bytes, ending with [TruncationMarker], or discarded if truncate is false; either way it is
+// counted (see [Server.Oversized]). By default, the only limits are the read buffer size for
+// datagrams (see [WithReadBufferSize]) and 64KiB for frames on streams.
+func WithMaxMessageSize(size int, truncate bool) Option {
+	return func(s *Server) {
+		s.maxMessageSize = size
+		s.truncate = truncate
+	}
+}
+
+// WithReceiveBuffer sets the size of the kernel receive buffer (SO_RCVBUF) of each datagram
+// socket opened by the server or passed to it. Larger buffers avoid drops during bursts of
+// messages. The kernel may limit the size, e.g. to net.core.rmem_max on Linux, in which case
+// a warning is logged; see [Server.ReceiveBuffers] for the effective sizes. By default, the
+// kernel's default size is used.
+func WithReceiveBuffer(size int) Option {
+	return func(s *Server) {
+		s.receiveBuffer = size
+	}
+}
+
+// WithSocketPermissions sets the mode and owner of the socket files of Unix listeners (see
+// [Server.Listen] and [Server.ListenUnix]), so that other programs, such as rsyslog running as
+// another user, can send messages to them. A uid or gid of -1 leaves it unchanged. By default,
+// the mode depends on the umask of the process.
+//
+// In any case, a socket file left behind by a previous process, e.g. after a crash, is removed
+// before listening; a socket that is still in use is not.
+func WithSocketPermissions(mode os.FileMode, uid, gid int) Option {
+	return func(s *Server) {
+		s.socketPermissions = &socketPermissions{mode: mode, uid: uid, gid: gid}
+	}
+}
+
+// WithBatchedReads makes each UDP receiver read up to n datagrams per system call, using
+// recvmmsg on Linux. At high packet rates, the system call per datagram otherwise dominates
+// the CPU used. Each receiver has n buffers of the read buffer size (see
+// [WithReadBufferSize]). On other platforms, and for Unix sockets, this has no effect.
+func WithBatchedReads(n int) Option {
+	return func(s *Server) {
+		s.readBatch = n
+	}
+}
+
+// WithRestart sets how datagram listeners recover from errors. After a transient error,
+// such as EINTR or ENOBUFS, a listener pauses and then carries on reading; after other errors,
+// or if its Unix socket file is removed, it closes and re-opens its socket (when the server
+// opened it). The pauses grow exponentially from 10ms to 5s. A listener gives up after
+// the given number of consecutive failures, whereupon onFailure is called, if not nil (see
+// also [Server.Err]). The default is 10 attempts; zero disables restarting.
+func WithRestart(attempts int, onFailure func(addr net.Addr, err error)) Option {
+	return func(s *Server) {
+		s.restartAttempts = max(attempts, 0)
+		s.onListenerFailure = onFailure
+	}
+}
+
+// WithDeadline limits how long the handlers may spend on each message. After each handler,
+// any message on which the handlers have spent longer than d in total skips the remaining
+// handlers and is counted (see [Server.TimedOut]); if deadLetter is not nil, it is passed
+// there instead, e.g. to a [FileHandler] for later investigation. The server shuts down
+// the dead-letter handler along with the others. A handler that is already running is not
+// interrupted; see [Server.StartWatchdog] to detect those that never return. Time spent in a
+// [BatchHandler] is shared equally between the messages in the batch. By default, there is
+// no deadline.
+func WithDeadline(d time.Duration, deadLetter Handler) Option {
+	return func(s *Server) {
+		s.deadline = d
+		s.deadLetter = deadLetter
+	}
+}
+
+// WithIdleTimeout sets how long a stream connection (TCP, TLS, RELP etc.) may be idle
+// before the server closes it, so that connections abandoned by their senders, or opened
+// and never used, do not accumulate. Senders reconnect when they next have something to
+// send. The default is 10 minutes; zero disables the timeout.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.idleTimeout = max(d, 0)
+	}
+}
+
+// WithLogger sets the logger the server uses to report problems such as read errors and
+// invalid messages. The default is [Logger].
+func WithLogger(logger *log.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithClock sets the function that provides the receive time of each message, which is
+// also used to infer the year of RFC3164 timestamps. The default is [time.Now]. This is
+// mostly useful for testing.
+func WithClock(clock func() time.Time) Option {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
@@ -0,0 +1,81 @@
+package syslog
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestNewServer_options(t *testing.T) {
+	t0 := time.Date(2023, 10, 26, 15, 31, 1, 0, time.UTC)
+	buf := &bytes.Buffer{}
+	received := make(chan *Message, 1)
+
+	s := NewServer(
+		WithQueueLength(2),
+		WithReadBufferSize(40),
+		WithLogger(log.New(buf, "test: ", 0)),
+		WithClock(func() time.Time { return t0 }),
+	)
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.Error(err).ToBeNil(t)
+	s.ListenPacketConn(pc, AcceptEverything)
+
+	c, err := net.Dial("udp", pc.LocalAddr().String())
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+
+	c.Write([]byte("<1x>invalid"))
+	c.Write([]byte("<11>1 - host app - - - a message that is longer than the buffer"))
+
+	m := <-received
+	expect.String(m.Content).ToBe(t, "a message that is")
+	expect.Any(m.Time).ToBe(t, t0)
+	expect.String(buf.String()).ToContain(t, "test: ")
+}
+
+func TestWithMaxMessageSize(t *testing.T) {
+	long := []byte("<13>1 - host app - - - this message is far too long")
+
+	s := NewServer(WithMaxMessageSize(40, true))
+	defer s.Shutdown()
+	m := s.receive(long, nil, AcceptEverything)
+	expect.String(m.Content).ToBe(t, "thi"+TruncationMarker)
+	expect.Number(m.Size).ToBe(t, len(long))
+	expect.Bool(s.receive(long[:40], nil, AcceptEverything) != nil).ToBeTrue(t)
+	expect.Number(s.Oversized()).ToBe(t, 1)
+
+	s = NewServer(WithMaxMessageSize(40, false))
+	defer s.Shutdown()
+	expect.Bool(s.receive(long, nil, AcceptEverything) == nil).ToBeTrue(t)
+	expect.Number(s.Oversized()).ToBe(t, 1)
+}
+
+func TestWithReceiveBuffer(t *testing.T) {
+	s := NewServer(WithReceiveBuffer(64 * 1024))
+	defer s.Shutdown()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.Error(err).ToBeNil(t)
+	s.ListenPacketConn(pc, AcceptEverything)
+
+	size, found := s.ReceiveBuffers()[pc.LocalAddr().String()]
+	if runtime.GOOS == "windows" {
+		expect.Bool(found).ToBeFalse(t)
+	} else {
+		expect.Bool(found && size >= 64*1024).Info(size).ToBeTrue(t)
+	}
+}
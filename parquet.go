@@ -0,0 +1,555 @@
+package syslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultParquetRows is the number of messages that a [ParquetHandler] buffers for each
+// partition before writing a file.
+const DefaultParquetRows = 100_000
+
+// DefaultParquetTotalRows is the number of messages that a [ParquetHandler] buffers for all
+// partitions together before it writes the largest.
+const DefaultParquetTotalRows = 1_000_000
+
+// ParquetHandler is a [Handler] that archives messages in Apache Parquet files, which can be
+// queried directly by DuckDB, Athena, Spark and the like without re-parsing text. The files
+// are partitioned by the hour (of the receive time, in UTC) and hostname, using Hive-style
+// directories such as dir/date=2024-03-01/hour=12/host=web01/1709294400000000000.parquet.
+//
+// Each file has one column per message field: time, source, sequence, size, facility,
+// severity, priority, version, timestamp, hostname, application, procid, msgid, data and
+// content, with the annotations and TLS peer identity as JSON objects (annotations and
+// tls_peer). Raw is not stored. Selected structured data parameters can have columns of
+// their own; see [NewParquetHandler]. Blank fields are null. The pages are compressed
+// with gzip, unless a compression policy decides otherwise (see
+// [ParquetHandler.SetCompression]).
+//
+// Messages are buffered in memory, and written when a partition has [DefaultParquetRows]
+// messages (see [ParquetHandler.SetMaxRows]), when messages for a later hour arrive, and
+// on [ParquetHandler.Flush] and Close. So that many hosts, or spoofed hostnames, cannot
+// use up the memory, the largest partitions are also written whenever all the partitions
+// together hold more than [DefaultParquetTotalRows] messages (see
+// [ParquetHandler.SetMaxTotalRows]). Files are renamed into place once complete. All
+// messages are passed on to subsequent handlers. A ParquetHandler is safe for concurrent use.
+type ParquetHandler struct {
+	acceptFunc Filter
+	dir        string
+	columns    []parquetColumn
+	maxRows    int
+	maxTotal   int
+
+	compression CompressionPolicy
+	mu          sync.Mutex
+	partitions  map[parquetPartition][]*Message
+	total       int // the number of messages in all the partitions
+	latest      time.Time
+}
+
+type parquetPartition struct {
+	hour time.Time
+	host string
+}
+
+// NewParquetHandler creates a handler that writes Parquet files beneath dir. Each of the
+// sdColumns names a structured data parameter as "SD-ID/name", e.g. "origin/ip" or
+// "exampleSDID@32473/iut", which is given a column of its own named after it, e.g.
+// "sd_origin_ip" or "sd_examplesdid_iut" (the enterprise number is dropped). All messages
+// are accepted.
+func NewParquetHandler(dir string, sdColumns ...string) (*ParquetHandler, error) {
+	columns := parquetColumns()
+	for _, sd := range sdColumns {
+		id, param, found := strings.Cut(sd, "/")
+		name := strings.ToLower(journalFieldName(id + "_" + param))
+		if !found || id == "" || param == "" || name == "" {
+			return nil, fmt.Errorf("%s: structured data column must be SD-ID/name", sd)
+		}
+		columns = append(columns, parquetColumn{"sd_" + name, parquetByteArray, parquetUTF8, func(m *Message) any {
+			elems, _ := parseStructuredData(m.Data)
+			for _, e := range elems {
+				if v, found := e.Param(param); found && e.ID == id {
+					return v
+				}
+			}
+			return nil
+		}})
+	}
+
+	return &ParquetHandler{
+		acceptFunc: AcceptEverything,
+		dir:        dir,
+		columns:    columns,
+		maxRows:    DefaultParquetRows,
+		maxTotal:   DefaultParquetTotalRows,
+		partitions: make(map[parquetPartition][]*Message),
+	}, nil
+}
+
+// SetFilter changes the function used to decide which messages are written.
+func (h *ParquetHandler) SetFilter(acceptFunc Filter) {
+	h.acceptFunc = acceptFunc
+}
+
+// SetCompression sets the policy that chooses how each column of each file is compressed,
+// e.g. [AdaptiveCompression]; columns for which it chooses [gzip.NoCompression] are stored
+// uncompressed. Use nil for the default gzip level.
+func (h *ParquetHandler) SetCompression(policy CompressionPolicy) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.compression = policy
+}
+
+// SetMaxRows changes the number of messages buffered for each partition before a file is
+// written; larger files are queried more efficiently but use more memory.
+func (h *ParquetHandler) SetMaxRows(rows int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxRows = max(rows, 1)
+}
+
+// SetMaxTotalRows changes the number of messages buffered for all partitions together,
+// beyond which the largest partitions are written.
+func (h *ParquetHandler) SetMaxTotalRows(rows int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxTotal = max(rows, 1)
+}
+
+func (h *ParquetHandler) Handle(m *Message) *Message {
+	if m == nil {
+		checkErr(h.Close(), "parquet")
+	} else if h.acceptFunc(m) && !checkErr(m.Parse(), "parquet") {
+		h.add(m.Clone())
+	}
+	return m
+}
+
+func (h *ParquetHandler) add(m *Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p := parquetPartition{hour: m.Time.UTC().Truncate(time.Hour), host: m.Hostname}
+	if p.host == "" || p.host == "-" {
+		p.host = "unknown"
+	}
+	rows := append(h.partitions[p], m)
+	h.partitions[p] = rows
+	h.total++
+	if len(rows) >= h.maxRows {
+		checkErr(h.write(p), "parquet")
+	}
+	for h.total > h.maxTotal {
+		checkErr(h.write(h.largest()), "parquet")
+	}
+
+	if p.hour.After(h.latest) {
+		// the previous hours are complete
+		h.latest = p.hour
+		for q := range h.partitions {
+			if q.hour.Before(p.hour) {
+				checkErr(h.write(q), "parquet")
+			}
+		}
+	}
+}
+
+// Flush writes all the buffered messages.
+func (h *ParquetHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var err error
+	for p := range h.partitions {
+		if e := h.write(p); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Close writes all the buffered messages; it is safe to call more than once.
+func (h *ParquetHandler) Close() error {
+	return h.Flush()
+}
+
+// largest finds the partition with the most messages.
+func (h *ParquetHandler) largest() parquetPartition {
+	var largest parquetPartition
+	most := -1
+	for p, rows := range h.partitions {
+		if len(rows) > most {
+			largest, most = p, len(rows)
+		}
+	}
+	return largest
+}
+
+// write writes the messages of one partition to a new file. The messages are discarded if
+// the file cannot be written.
+func (h *ParquetHandler) write(p parquetPartition) error {
+	rows := h.partitions[p]
+	delete(h.partitions, p)
+	h.total -= len(rows)
+	if len(rows) == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(h.dir, "date="+p.hour.Format(time.DateOnly), fmt.Sprintf("hour=%02d", p.hour.Hour()),
+		"host="+url.PathEscape(p.host))
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+
+	bs, err := encodeParquet(h.columns, rows, h.compression)
+	if err != nil {
+		return err
+	}
+
+	base := filepath.Join(dir, strconv.FormatInt(rows[0].Time.UnixNano(), 10))
+	name := base + ".parquet"
+	for i := 1; fileExists(name); i++ {
+		name = fmt.Sprintf("%s-%d.parquet", base, i)
+	}
+	if err = os.WriteFile(name+tmp, bs, 0640); err != nil {
+		return err
+	}
+	return os.Rename(name+tmp, name)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Parquet physical types, converted types and other constants from parquet.thrift.
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetNone            = -1
+	parquetUTF8            = 0
+	parquetTimestampMicros = 10
+	parquetJSON            = 19
+
+	parquetOptional     = 1
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetUncompressed = 0
+	parquetGzip         = 2
+	parquetDataPage     = 0
+	parquetMagic        = "PAR1"
+	parquetCreatedBy    = "github.com/rickb777/syslog"
+)
+
+// parquetColumn describes a column: value returns the value for a message, which is a string,
+// int32, int64 or time.Time, or nil for null.
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	value     func(*Message) any
+}
+
+func parquetColumns() []parquetColumn {
+	str := func(s string) any {
+		if s == "" || s == "-" {
+			return nil
+		}
+		return s
+	}
+	ts := func(t time.Time) any {
+		if t.IsZero() {
+			return nil
+		}
+		return t
+	}
+	js := func(v any, empty bool) any {
+		if empty {
+			return nil
+		}
+		bs, _ := json.Marshal(v)
+		return string(bs)
+	}
+
+	return []parquetColumn{
+		{"time", parquetInt64, parquetTimestampMicros, func(m *Message) any { return ts(m.Time) }},
+		{"source", parquetByteArray, parquetUTF8, func(m *Message) any {
+			if m.Source == nil {
+				return nil
+			}
+			return m.Source.String()
+		}},
+		{"sequence", parquetInt64, parquetNone, func(m *Message) any {
+			if m.Sequence == 0 {
+				return nil
+			}
+			return int64(m.Sequence)
+		}},
+		{"size", parquetInt32, parquetNone, func(m *Message) any { return int32(m.Size) }},
+		{"facility", parquetByteArray, parquetUTF8, func(m *Message) any { return m.Facility.String() }},
+		{"severity", parquetByteArray, parquetUTF8, func(m *Message) any { return m.Severity.String() }},
+		{"priority", parquetInt32, parquetNone, func(m *Message) any { return int32(m.Priority()) }},
+		{"version", parquetInt32, parquetNone, func(m *Message) any { return int32(m.Version) }},
+		{"timestamp", parquetInt64, parquetTimestampMicros, func(m *Message) any { return ts(m.Timestamp) }},
+		{"hostname", parquetByteArray, parquetUTF8, func(m *Message) any { return str(m.Hostname) }},
+		{"application", parquetByteArray, parquetUTF8, func(m *Message) any { return str(m.Application) }},
+		{"procid", parquetByteArray, parquetUTF8, func(m *Message) any { return str(m.ProcID) }},
+		{"msgid", parquetByteArray, parquetUTF8, func(m *Message) any { return str(m.MsgID) }},
+		{"data", parquetByteArray, parquetUTF8, func(m *Message) any { return str(m.Data) }},
+		{"content", parquetByteArray, parquetUTF8, func(m *Message) any { return str(m.Content) }},
+		{"annotations", parquetByteArray, parquetJSON, func(m *Message) any {
+			return js(m.Annotations, len(m.Annotations) == 0)
+		}},
+		{"tls_peer", parquetByteArray, parquetJSON, func(m *Message) any {
+			if p := m.TLSPeer; p != nil {
+				return js(jsonPeer{p.CommonName, p.DNSNames, p.URIs, p.SPIFFEID, p.Fingerprint}, false)
+			}
+			return nil
+		}},
+	}
+}
+
+// encodeParquet encodes messages as a Parquet file with one row group, in which each column
+// chunk is a single data page using PLAIN encoding, gzipped unless the policy says not to.
+func encodeParquet(columns []parquetColumn, rows []*Message, policy CompressionPolicy) ([]byte, error) {
+	bs := []byte(parquetMagic)
+
+	type chunk struct {
+		offset, size, compressed int64
+		codec                    int32
+	}
+	chunks := make([]chunk, len(columns))
+	var total int64
+
+	for i, c := range columns {
+		page, err := encodeParquetPage(c, rows)
+		if err != nil {
+			return nil, err
+		}
+		level, codec := gzip.DefaultCompression, int32(parquetGzip)
+		if policy != nil {
+			level = policy(page[:min(len(page), compressionSample)])
+		}
+		compressed := page
+		if level == gzip.NoCompression {
+			codec = parquetUncompressed
+		} else if compressed, err = gzipBytes(page, level); err != nil {
+			return nil, err
+		}
+
+		var th thriftWriter
+		th.i32(1, parquetDataPage)
+		th.i32(2, int32(len(page)))
+		th.i32(3, int32(len(compressed)))
+		th.beginStruct(5) // DataPageHeader
+		th.i32(1, int32(len(rows)))
+		th.i32(2, parquetPlain)
+		th.i32(3, parquetRLE)
+		th.i32(4, parquetRLE)
+		th.endStruct()
+		th.stop()
+
+		chunks[i] = chunk{
+			offset:     int64(len(bs)),
+			size:       int64(len(th.bs) + len(page)),
+			compressed: int64(len(th.bs) + len(compressed)),
+			codec:      codec,
+		}
+		total += chunks[i].size
+		bs = append(bs, th.bs...)
+		bs = append(bs, compressed...)
+	}
+
+	var th thriftWriter
+	th.i32(1, 1) // version
+	th.beginList(2, thriftStruct, len(columns)+1)
+	th.beginElement() // the root of the schema
+	th.binary(4, "schema")
+	th.i32(5, int32(len(columns)))
+	th.endStruct()
+	for _, c := range columns {
+		th.beginElement()
+		th.i32(1, c.typ)
+		th.i32(3, parquetOptional)
+		th.binary(4, c.name)
+		if c.converted != parquetNone {
+			th.i32(6, c.converted)
+		}
+		th.endStruct()
+	}
+	th.i64(3, int64(len(rows)))
+	th.beginList(4, thriftStruct, 1)
+	th.beginElement() // RowGroup
+	th.beginList(1, thriftStruct, len(columns))
+	for i, c := range columns {
+		th.beginElement() // ColumnChunk
+		th.i64(2, chunks[i].offset)
+		th.beginStruct(3) // ColumnMetaData
+		th.i32(1, c.typ)
+		th.beginList(2, thriftI32, 2)
+		th.element32(parquetPlain)
+		th.element32(parquetRLE)
+		th.beginList(3, thriftBinary, 1)
+		th.elementBinary(c.name)
+		th.i32(4, chunks[i].codec)
+		th.i64(5, int64(len(rows)))
+		th.i64(6, chunks[i].size)
+		th.i64(7, chunks[i].compressed)
+		th.i64(9, chunks[i].offset)
+		th.endStruct()
+		th.endStruct()
+	}
+	th.i64(2, total)
+	th.i64(3, int64(len(rows)))
+	th.endStruct()
+	th.binary(6, parquetCreatedBy)
+	th.stop()
+
+	bs = append(bs, th.bs...)
+	bs = binary.LittleEndian.AppendUint32(bs, uint32(len(th.bs)))
+	return append(bs, parquetMagic...), nil
+}
+
+// encodeParquetPage encodes the definition levels and non-null values of a column.
+func encodeParquetPage(c parquetColumn, rows []*Message) ([]byte, error) {
+	// the definition levels use the RLE/bit-packed hybrid encoding with bit width 1, written
+	// as a single bit-packed run of groups of 8, preceded by its length
+	groups := (len(rows) + 7) / 8
+	levels := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	levels = append(levels, make([]byte, groups)...)
+	header := len(levels) - groups
+
+	var values []byte
+	for i, m := range rows {
+		v := c.value(m)
+		if v == nil {
+			continue
+		}
+		levels[header+i/8] |= 1 << (i % 8)
+
+		switch v := v.(type) {
+		case string:
+			values = binary.LittleEndian.AppendUint32(values, uint32(len(v)))
+			values = append(values, v...)
+		case int32:
+			values = binary.LittleEndian.AppendUint32(values, uint32(v))
+		case int64:
+			values = binary.LittleEndian.AppendUint64(values, uint64(v))
+		case time.Time:
+			values = binary.LittleEndian.AppendUint64(values, uint64(v.UnixMicro()))
+		default:
+			return nil, fmt.Errorf("%s: unsupported value %T", c.name, v)
+		}
+	}
+
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	page = append(page, levels...)
+	return append(page, values...), nil
+}
+
+func gzipBytes(bs []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gz.Write(bs); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol, as used by Parquet metadata.
+type thriftWriter struct {
+	bs    []byte
+	last  int16   // the previous field ID in the current struct
+	stack []int16 // the previous field IDs of the enclosing structs
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	if delta := id - w.last; 0 < delta && delta <= 15 {
+		w.bs = append(w.bs, byte(delta)<<4|typ)
+	} else {
+		w.bs = append(w.bs, typ)
+		w.bs = binary.AppendVarint(w.bs, int64(id))
+	}
+	w.last = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.bs = binary.AppendVarint(w.bs, int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.bs = binary.AppendVarint(w.bs, v)
+}
+
+func (w *thriftWriter) binary(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.elementBinary(s)
+}
+
+func (w *thriftWriter) beginStruct(id int16) {
+	w.field(id, thriftStruct)
+	w.beginElement()
+}
+
+func (w *thriftWriter) endStruct() {
+	w.stop()
+	w.last = w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+}
+
+func (w *thriftWriter) stop() {
+	w.bs = append(w.bs, 0)
+}
+
+func (w *thriftWriter) beginList(id int16, elem byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.bs = append(w.bs, byte(n)<<4|elem)
+	} else {
+		w.bs = append(w.bs, 0xf0|elem)
+		w.bs = binary.AppendUvarint(w.bs, uint64(n))
+	}
+}
+
+// beginElement starts a struct that is an element of a list.
+func (w *thriftWriter) beginElement() {
+	w.stack = append(w.stack, w.last)
+	w.last = 0
+}
+
+func (w *thriftWriter) element32(v int32) {
+	w.bs = binary.AppendVarint(w.bs, int64(v))
+}
+
+func (w *thriftWriter) elementBinary(s string) {
+	w.bs = binary.AppendUvarint(w.bs, uint64(len(s)))
+	w.bs = append(w.bs, s...)
+}
@@ -0,0 +1,219 @@
+package syslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestParquetHandler(t *testing.T) {
+	dir := t.TempDir()
+	h, err := NewParquetHandler(dir, "origin/ip")
+	expect.Error(err).ToBeNil(t)
+
+	t0 := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	for i := range 10 {
+		m := &Message{
+			Time:      t0.Add(time.Duration(i) * time.Second),
+			Source:    &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 514},
+			Facility:  Daemon,
+			Severity:  Info,
+			Version:   1,
+			Timestamp: t0,
+			Hostname:  "web01",
+			Content:   "message " + string(rune('a'+i)),
+		}
+		if i == 3 {
+			m.Data = `[origin ip="192.0.2.1"]`
+			m.Annotate("k", "v")
+		}
+		h.Handle(m)
+	}
+
+	// a message for the next hour completes the previous one
+	h.Handle(&Message{Time: t0.Add(time.Hour), Content: "later"})
+	files, _ := filepath.Glob(filepath.Join(dir, "date=2024-03-01", "hour=12", "host=web01", "*.parquet"))
+	expect.Slice(files).ToHaveLength(t, 1)
+
+	expect.Error(h.Close()).ToBeNil(t)
+	later, _ := filepath.Glob(filepath.Join(dir, "date=2024-03-01", "hour=13", "host=unknown", "*.parquet"))
+	expect.Slice(later).ToHaveLength(t, 1)
+
+	bs, err := os.ReadFile(files[0])
+	expect.Error(err).ToBeNil(t)
+	expect.String(string(bs[:4])).ToBe(t, "PAR1")
+	expect.String(string(bs[len(bs)-4:])).ToBe(t, "PAR1")
+
+	footer := int(binary.LittleEndian.Uint32(bs[len(bs)-8:]))
+	meta := readThriftStruct(bytes.NewReader(bs[len(bs)-8-footer : len(bs)-8]))
+	expect.Any(meta[3]).ToBe(t, int64(10)) // num_rows
+
+	schema := meta[2].([]any)
+	columns := parquetColumns()
+	expect.Slice(schema).ToHaveLength(t, len(columns)+2)
+	expect.Any(schema[len(schema)-1].(map[int16]any)[4]).ToBe(t, "sd_origin_ip")
+
+	chunks := meta[4].([]any)[0].(map[int16]any)[1].([]any)
+	values := func(col int) []any {
+		cmd := chunks[col].(map[int16]any)[3].(map[int16]any)
+		r := bytes.NewReader(bs[cmd[9].(int64):])
+		page := readThriftStruct(r)
+		expect.Any(page[1]).ToBe(t, int64(0))
+		compressed := make([]byte, page[3].(int64))
+		io.ReadFull(r, compressed)
+		gz, err := gzip.NewReader(bytes.NewReader(compressed))
+		expect.Error(err).ToBeNil(t)
+		data, err := io.ReadAll(gz)
+		expect.Error(err).ToBeNil(t)
+		expect.Number(len(data)).ToBe(t, int(page[2].(int64)))
+		return decodeParquetPage(data, int(page[5].(map[int16]any)[1].(int64)), cmd[1].(int64))
+	}
+
+	expect.Any(values(0)[9]).ToBe(t, t0.Add(9*time.Second).UnixMicro())
+	expect.Any(values(1)[0]).ToBe(t, "10.0.0.1:514")
+	expect.Any(values(2)[0]).ToBe(t, nil)
+	expect.Any(values(4)[0]).ToBe(t, "daemon")
+	expect.Any(values(6)[0]).ToBe(t, int64(30))
+	expect.Any(values(14)[9]).ToBe(t, "message j")
+	expect.Slice(values(15)).ToBe(t, nil, nil, nil, `{"k":"v"}`, nil, nil, nil, nil, nil, nil)
+	expect.Slice(values(len(columns))).ToBe(t, nil, nil, nil, "192.0.2.1", nil, nil, nil, nil, nil, nil)
+}
+
+func TestParquetHandler_SetCompression(t *testing.T) {
+	dir := t.TempDir()
+	h, err := NewParquetHandler(dir)
+	expect.Error(err).ToBeNil(t)
+	h.SetCompression(FixedCompression(gzip.NoCompression))
+
+	t0 := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	h.Handle(&Message{Time: t0, Hostname: "web01", Content: "hello"})
+	expect.Error(h.Close()).ToBeNil(t)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*", "*.parquet"))
+	expect.Slice(files).ToHaveLength(t, 1)
+	bs, err := os.ReadFile(files[0])
+	expect.Error(err).ToBeNil(t)
+
+	footer := int(binary.LittleEndian.Uint32(bs[len(bs)-8:]))
+	meta := readThriftStruct(bytes.NewReader(bs[len(bs)-8-footer : len(bs)-8]))
+	chunks := meta[4].([]any)[0].(map[int16]any)[1].([]any)
+	content := chunks[14].(map[int16]any)[3].(map[int16]any)
+	expect.Any(content[4]).ToBe(t, int64(parquetUncompressed))
+
+	r := bytes.NewReader(bs[content[9].(int64):])
+	page := readThriftStruct(r)
+	expect.Any(page[2]).ToBe(t, page[3])
+	data := make([]byte, page[3].(int64))
+	io.ReadFull(r, data)
+	expect.Slice(decodeParquetPage(data, 1, content[1].(int64))).ToBe(t, "hello")
+}
+
+func TestParquetHandler_SetMaxTotalRows(t *testing.T) {
+	dir := t.TempDir()
+	h, err := NewParquetHandler(dir)
+	expect.Error(err).ToBeNil(t)
+	h.SetMaxTotalRows(3)
+
+	t0 := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	for _, host := range []string{"web01", "web02", "web01", "web03"} {
+		h.Handle(&Message{Time: t0, Hostname: host, Content: "hello"})
+	}
+
+	// the largest partition was written to make room
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*", "*.parquet"))
+	expect.Slice(files).ToHaveLength(t, 1)
+	expect.String(filepath.Base(filepath.Dir(files[0]))).ToBe(t, "host=web01")
+	expect.Number(h.total).ToBe(t, 2)
+
+	expect.Error(h.Close()).ToBeNil(t)
+	expect.Number(h.total).ToBe(t, 0)
+}
+
+func TestNewParquetHandler_badColumn(t *testing.T) {
+	_, err := NewParquetHandler(t.TempDir(), "noslash")
+	expect.Error(err).ToContain(t, "SD-ID/name")
+}
+
+// decodeParquetPage decodes the definition levels and PLAIN values of an optional column.
+func decodeParquetPage(data []byte, n int, typ int64) []any {
+	size := binary.LittleEndian.Uint32(data)
+	levels := bytes.NewReader(data[4 : 4+size])
+	header, _ := binary.ReadUvarint(levels)
+	packed := make([]byte, header>>1)
+	io.ReadFull(levels, packed)
+
+	values := data[4+size:]
+	result := make([]any, n)
+	for i := range result {
+		if packed[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		switch typ {
+		case parquetInt32:
+			result[i] = int64(int32(binary.LittleEndian.Uint32(values)))
+			values = values[4:]
+		case parquetInt64:
+			result[i] = int64(binary.LittleEndian.Uint64(values))
+			values = values[8:]
+		case parquetByteArray:
+			l := binary.LittleEndian.Uint32(values)
+			result[i] = string(values[4 : 4+l])
+			values = values[4+l:]
+		}
+	}
+	return result
+}
+
+// readThriftStruct decodes a struct in the Thrift compact protocol, as far as Parquet needs.
+func readThriftStruct(r *bytes.Reader) map[int16]any {
+	fields := make(map[int16]any)
+	var id int16
+	for {
+		b, _ := r.ReadByte()
+		if b == 0 {
+			return fields
+		}
+		if delta := int16(b >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, _ := binary.ReadVarint(r)
+			id = int16(v)
+		}
+		fields[id] = readThriftValue(r, b&0x0f)
+	}
+}
+
+func readThriftValue(r *bytes.Reader, typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		v, _ := binary.ReadVarint(r)
+		return v
+	case thriftBinary:
+		n, _ := binary.ReadUvarint(r)
+		bs := make([]byte, n)
+		io.ReadFull(r, bs)
+		return string(bs)
+	case thriftList:
+		b, _ := r.ReadByte()
+		n := uint64(b >> 4)
+		if n == 15 {
+			n, _ = binary.ReadUvarint(r)
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = readThriftValue(r, b&0x0f)
+		}
+		return list
+	case thriftStruct:
+		return readThriftStruct(r)
+	}
+	panic("unsupported thrift type")
+}
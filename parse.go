@@ -2,24 +2,46 @@ package syslog
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"net/netip"
 	"strconv"
 	"strings"
 	"time"
 	"unicode"
-
-	"github.com/rickb777/iso8601/v3"
+	"unicode/utf8"
 )
 
+// ParseMessage parses a syslog message in RFC 5424 or RFC 3164 format, as the server does
+// for each packet it receives. Time and Timestamp are set to the current time, unless the
+// message has a timestamp. A packet without a PRI part is given the priority user.notice
+// and, unless it begins with an RFC 3164 timestamp, becomes the content in its entirety.
+func ParseMessage(pkt []byte) (*Message, error) {
+	return parseMessage(pkt)
+}
+
 func parseMessage(pkt []byte) (*Message, error) {
-	var n int
-	ts := now()
+	return parseMessageAt(pkt, now())
+}
+
+// parseMessageAt parses a packet received at time ts.
+func parseMessageAt(pkt []byte, ts time.Time) (*Message, error) {
+	return parseMessageHinted(pkt, ts, nil)
+}
+
+// parseMessageHinted parses a packet using, and updating, hints about the dialect of
+// its sender. Hints are not used if h is nil.
+func parseMessageHinted(pkt []byte, ts time.Time, h *dialect) (*Message, error) {
 	m := Message{
 		Time:      ts,
 		Timestamp: ts,
 	}
 
-	bs := bytes.TrimRightFunc(pkt, isNulCrLf)
+	bs := trimNulCrLf(pkt)
+	if len(bs) == 0 {
+		return nil, errors.New("empty message")
+	}
+	raw := bs
 
 	bom := findBOM(bs)
 	if bom >= 0 { // Byte Order Mark was found
@@ -27,35 +49,74 @@ func parseMessage(pkt []byte) (*Message, error) {
 		bs = bs[:bom]
 	}
 
-	s := string(bs)
-
 	//---------- Parse priority (if it exists)
-	prio := 13 // default priority
-
-	// we treat PRI as optional although RFC3164 and RFC5424 require it to be present
-	if s[0] == '<' {
-		n = 1 + strings.IndexByte(s[1:], '>')
-		if n > 1 && n < 5 {
-			p, err := strconv.Atoi(s[1:n])
-			if err != nil {
-				return nil, fmt.Errorf("%s: message has invalid priority (%s)",
-					s[1:n], cropString(s, 50))
-			}
-			prio = p
-			s = s[n+1:]
-		}
+	prio, n, err := parsePriority(bs)
+	if err != nil {
+		return nil, err
 	}
 
+	s := string(bs[n:])
+
 	m.Severity = Severity(prio & 0x07)
 	m.Facility = Facility(prio >> 3)
 
 	if strings.HasPrefix(s, "1 ") {
 		m.Version = 1
 		s = s[2:]
-		return parseRFC5424Message(&m, s, bom >= 0)
+		return parseRFC5424Message(&m, s, bom >= 0, h)
+	}
+
+	if n == 0 {
+		return parseUnprioritised(&m, s, string(raw), h)
+	}
+
+	return parseRFC3164Message(&m, s, h)
+}
+
+// parseUnprioritised parses a packet without a PRI part. As RFC3164 section 4.3.3 says a
+// relay should, it has the default priority and the time of receipt, and the whole packet
+// is the content. However, if the packet begins with an RFC3164 timestamp, the sender has
+// merely omitted the PRI, so the rest of the header is parsed as usual.
+func parseUnprioritised(m *Message, s, raw string, h *dialect) (*Message, error) {
+	if p, err := parseRFC3164Message(m, s, h); err == nil && !p.Timestamp.Equal(p.Time) {
+		return p, nil
 	}
 
-	return parseRFC3164Message(&m, s)
+	*m = Message{
+		Time:      m.Time,
+		Timestamp: m.Time,
+		Facility:  m.Facility,
+		Severity:  m.Severity,
+		Content:   raw,
+	}
+	return m, nil
+}
+
+// defaultPriority is user.notice, which RFC3164 says relays should assume when the PRI is missing.
+const defaultPriority = 13
+
+// parsePriority decodes the PRI part at the start of a packet without allocating, returning
+// the priority and the number of bytes it occupied. A packet without a PRI part has the
+// default priority.
+func parsePriority(bs []byte) (prio, n int, err error) {
+	// we treat PRI as optional although RFC3164 and RFC5424 require it to be present
+	if len(bs) == 0 || bs[0] != '<' {
+		return defaultPriority, 0, nil
+	}
+
+	end := bytes.IndexByte(bs[1:min(len(bs), 5)], '>') + 1
+	if end < 2 {
+		return defaultPriority, 0, nil
+	}
+
+	for _, c := range bs[1:end] {
+		if c < '0' || c > '9' {
+			return 0, 0, fmt.Errorf("%s: message has invalid priority (%s)",
+				bs[1:end], cropString(string(bs), 50))
+		}
+		prio = prio*10 + int(c-'0')
+	}
+	return prio, end + 1, nil
 }
 
 //-------------------------------------------------------------------------------------------------
@@ -65,35 +126,52 @@ const (
 	rfc3164LayoutWithYear = "2006 Jan _2 15:04:05"
 )
 
-func parseRFC3164Message(m *Message, s string) (*Message, error) {
-	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+func parseRFC3164Message(m *Message, s string, h *dialect) (*Message, error) {
+	s = trimLeftSpace(s)
+	if !h.has(dialectEnglish) {
+		translated := englishMonth(s)
+		if translated == s {
+			h.set(dialectEnglish)
+		}
+		s = translated
+	}
 
 	if len(s) > 15 && s[15] == ' ' {
 		// date without year
-		ts, err := time.Parse(rfc3164LayoutNoYear, s[:15])
-		if err == nil {
-			if ts.Year() == 0 {
+		ts, err := parseTolerantly(m, s[:15], func(v string) (time.Time, error) {
+			ts, err := time.Parse(rfc3164LayoutNoYear, v)
+			if err == nil && ts.Year() == 0 {
 				// There will be unavoidable race errors at the very end of
 				// December 31st / start of January 1st.
 				ts = ts.AddDate(m.Time.Year(), 0, 0)
 			}
+			return ts, err
+		})
+		if err == nil {
 			m.Timestamp = ts
 			s = s[15:]
 		}
 	} else if len(s) > 20 && s[20] == ' ' {
 		// date with year
-		ts, err := time.Parse(rfc3164LayoutWithYear, s[:20])
+		ts, err := parseTolerantly(m, s[:20], func(v string) (time.Time, error) {
+			return time.Parse(rfc3164LayoutWithYear, v)
+		})
 		if err == nil {
 			m.Timestamp = ts
 			s = s[20:]
 		}
 	}
 
-	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	if m.Timestamp.Equal(m.Time) {
+		// no timestamp was found, so maybe the month names have changed
+		h.clear(dialectEnglish)
+	}
+
+	s = trimLeftSpace(s)
 
 	if strings.HasPrefix(s, "TZ") {
 		sp := nextSpace(s)
-		if 0 < sp && sp <= len(s) {
+		if 0 < sp && sp < len(s) {
 			tz, err := strconv.Atoi(s[2:sp])
 			if err == nil && -12 <= tz && tz <= 12 {
 				m.Timestamp = m.Timestamp.In(time.FixedZone(s[:sp], tz*3600))
@@ -102,48 +180,120 @@ func parseRFC3164Message(m *Message, s string) (*Message, error) {
 		}
 	}
 
-	colon := indexRune(s, ':')
+	s = skipTimeZone(m, s)
+
+	// an IP address is recognised as the hostname; IPv6 addresses contain colons
+	hostKnown := false
+	if word, rest, found := strings.Cut(s, " "); found {
+		if _, err := netip.ParseAddr(word); err == nil {
+			m.Hostname, s, hostKnown = word, rest, true
+		}
+	}
+
+	// a tag with a PID need not be followed by a colon
+	if cutPIDTag(m, s) {
+		return m, nil
+	}
+	if host, rest, found := strings.Cut(s, " "); found && !hostKnown && cutPIDTag(m, rest) {
+		m.Hostname = host
+		return m, nil
+	}
+
+	colon := indexUnescaped(s, ':')
 	if colon < 0 {
 		m.Content = s
 		return m, nil
 	}
 
+	words := strings.Split(s[:colon], " ")
+	switch {
+	case hostKnown && len(words) == 1:
+		m.Application, m.ProcID = splitTag(words[0])
+	case hostKnown:
+		m.Content = s
+		return m, nil
+	default:
+		m.Hostname = words[0]
+		if len(words) > 1 {
+			m.Application, m.ProcID = splitTag(words[len(words)-1])
+		}
+	}
 	m.Content = s[colon:]
-	s = s[:colon]
+	return m, nil
+}
 
-	words := strings.Split(s, " ")
-	if len(words) > 0 {
-		m.Hostname = words[0]
+// rfc3164Zones are the time zone abbreviations that some senders put after the timestamp.
+// They are ambiguous (CST is used in America and in China), so they are not applied.
+var rfc3164Zones = map[string]bool{
+	"UT": true, "UTC": true, "GMT": true, "Z": true,
+	"EST": true, "EDT": true, "CST": true, "CDT": true, "MST": true, "MDT": true, "PST": true, "PDT": true,
+	"AKST": true, "AKDT": true, "HST": true, "AST": true, "ADT": true, "NST": true, "NDT": true,
+	"WET": true, "WEST": true, "BST": true, "IST": true, "CET": true, "CEST": true, "EET": true, "EEST": true,
+	"MSK": true, "JST": true, "KST": true, "HKT": true, "SGT": true,
+	"AWST": true, "ACST": true, "ACDT": true, "AEST": true, "AEDT": true, "NZST": true, "NZDT": true,
+}
+
+// skipTimeZone skips a time zone abbreviation after the timestamp, so that it is not taken
+// for the hostname, together with a year that follows it, which replaces the assumed year.
+func skipTimeZone(m *Message, s string) string {
+	zone, rest, found := strings.Cut(s, " ")
+	if !found || !rfc3164Zones[zone] || m.Timestamp.Equal(m.Time) {
+		return s
 	}
 
-	if len(words) > 1 {
-		last := words[len(words)-1]
-		if strings.HasSuffix(last, "]") {
-			l := strings.IndexByte(last, '[')
-			if l > 0 {
-				m.Application = last[:l]
-				m.ProcID = last[l+1 : len(last)-1]
-			} else {
-				m.Application = last
-			}
-		} else {
-			m.Application = last
+	s = trimLeftSpace(rest)
+	if y, rest, found := strings.Cut(s, " "); found && len(y) == 4 && leadingDigits(y) == 4 {
+		year, _ := strconv.Atoi(y)
+		m.Timestamp = m.Timestamp.AddDate(year-m.Timestamp.Year(), 0, 0)
+		s = trimLeftSpace(rest)
+	}
+	return s
+}
+
+// cutPIDTag recognises a tag with a PID such as "app[123]" at the start of s, followed by
+// a colon, a space or nothing, and sets the application, PID and content.
+func cutPIDTag(m *Message, s string) bool {
+	r := strings.IndexByte(s, ']')
+	if r < 0 || (r+1 < len(s) && s[r+1] != ':' && s[r+1] != ' ') {
+		return false
+	}
+
+	tag := s[:r+1]
+	l := strings.IndexByte(tag, '[')
+	if l <= 0 || l+2 > r || strings.ContainsAny(tag, " :") {
+		return false
+	}
+
+	m.Application, m.ProcID = tag[:l], tag[l+1:r]
+	m.Content = s[r+1:]
+	return true
+}
+
+// splitTag splits an RFC3164 tag such as "app[123]" into the application and process ID.
+func splitTag(tag string) (application, procID string) {
+	if strings.HasSuffix(tag, "]") {
+		if l := strings.IndexByte(tag, '['); l > 0 {
+			return tag[:l], tag[l+1 : len(tag)-1]
 		}
 	}
-	return m, nil
+	return tag, ""
 }
 
 //-------------------------------------------------------------------------------------------------
 
-func parseRFC5424Message(m *Message, s string, hasBOM bool) (*Message, error) {
+func parseRFC5424Message(m *Message, s string, hasBOM bool, h *dialect) (*Message, error) {
 	if strings.HasPrefix(s, "- ") {
 		s = s[2:] // no time field
 	} else {
 		sp := strings.IndexByte(s, ' ')
 		if sp >= 0 {
-			ts, err := iso8601.ParseString(s[:sp])
+			ts, err := parseTolerantly(m, s[:sp], h.parseRFC5424Timestamp)
 			if err == nil {
-				m.Timestamp = ts.Time
+				m.Timestamp = ts
+				s = s[sp+1:]
+			} else if looksLikeTimestamp(s[:sp]) {
+				// keep the receive time rather than failing the whole message
+				m.Annotate(OriginalTimestamp, s[:sp])
 				s = s[sp+1:]
 			}
 		}
@@ -158,26 +308,18 @@ func parseRFC5424Message(m *Message, s string, hasBOM bool) (*Message, error) {
 		m.Data = "-"
 		s = s[2:]
 	} else if strings.HasPrefix(s, "[") {
-		r := indexRune(s, ']')
-		for r >= 0 {
-			if r == len(s)-1 {
-				m.Data = s
-				s = s[r+1:]
+		// the elements are adjacent, e.g. [a x="1"][b y="2"]; an unterminated one is content
+		r := indexUnescaped(s, ']')
+		for r >= 0 && r+1 < len(s) && s[r+1] == '[' {
+			r2 := indexUnescaped(s[r+1:], ']')
+			if r2 < 0 {
 				break
-			} else if 0 < r && r < len(s) {
-				l := indexRune(s[r:], '[')
-				if l > 0 {
-					r2 := indexRune(s[r+l:], ']')
-					if r2 >= 0 {
-						r += r2 + l
-					}
-				} else {
-					r++
-					m.Data = s[:r]
-					s = s[r+1:]
-					r = indexRune(s, ']')
-				}
 			}
+			r += 1 + r2
+		}
+		if r >= 0 {
+			m.Data = s[:r+1]
+			s = s[r+1:]
 		}
 	}
 
@@ -193,13 +335,63 @@ func parseRFC5424Message(m *Message, s string, hasBOM bool) (*Message, error) {
 
 //-------------------------------------------------------------------------------------------------
 
+// OriginalTimestamp is the annotation key used to record a message's timestamp when it
+// had to be normalised (e.g. a leap second) or could not be parsed.
+const OriginalTimestamp = "original-timestamp"
+
+// parseTolerantly parses a timestamp, tolerating technically valid but unusual values
+// such as leap seconds (23:59:60) and end-of-day (24:00:00). These are normalised and the
+// original string is recorded as an annotation.
+func parseTolerantly(m *Message, v string, parse func(string) (time.Time, error)) (time.Time, error) {
+	ts, err := parse(v)
+	if err == nil {
+		return ts, nil
+	}
+
+	normalised, adjustment := normaliseTime(v)
+	if adjustment == 0 {
+		return ts, err
+	}
+
+	ts, err = parse(normalised)
+	if err != nil {
+		return ts, err
+	}
+
+	m.Annotate(OriginalTimestamp, v)
+	return ts.Add(adjustment), nil
+}
+
+// normaliseTime replaces a leap second ("hh:mm:60") with the previous second, and
+// end-of-day ("24:00:00") with the last second of the day, returning the adjustment
+// needed after parsing.
+func normaliseTime(v string) (string, time.Duration) {
+	for i := 0; i+8 <= len(v); i++ {
+		if v[i+2] != ':' || v[i+5] != ':' {
+			continue
+		}
+		switch {
+		case v[i+6:i+8] == "60":
+			return v[:i+6] + "59" + v[i+8:], time.Second
+		case v[i:i+8] == "24:00:00":
+			return v[:i] + "23:59:59" + v[i+8:], time.Second
+		}
+	}
+	return v, 0
+}
+
+// looksLikeTimestamp is true for an RFC3339-like string, which starts with a year.
+func looksLikeTimestamp(v string) bool {
+	return len(v) >= 10 && v[4] == '-' && v[7] == '-' && strings.Trim(v[:4], "0123456789") == ""
+}
+
 func nextField(s string, field *string) string {
 	if strings.HasPrefix(s, "- ") { // NILVALUE
 		*field = "-"
 		s = s[2:]
 	} else {
 		sp := nextSpace(s)
-		if 0 < sp && sp <= len(s) && s[0] != '[' {
+		if 0 < sp && sp < len(s) && s[0] != '[' {
 			*field = s[:sp]
 			s = s[sp+1:]
 		}
@@ -207,11 +399,13 @@ func nextField(s string, field *string) string {
 	return s
 }
 
+// nextSpace finds the next space, or returns 0 on meeting any character outside PRINTUSASCII.
 func nextSpace(s string) int {
-	for i, r := range s {
-		if r == ' ' {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == ' ' {
 			return i
-		} else if r < 32 || r > 126 {
+		} else if c < 32 || c > 126 {
 			return 0 // anything outside PRINTASCII %d33-126
 		}
 	}
@@ -219,12 +413,17 @@ func nextSpace(s string) int {
 	return len(s) // not found
 }
 
-// indexRune finds the next c in s, skipping any characters escaped with '\'.
-func indexRune(s string, c rune) int {
-	esc := false
+// indexUnescaped finds the next ASCII character c in s, skipping any characters escaped
+// with '\'.
+func indexUnescaped(s string, c byte) int {
+	i := strings.IndexByte(s, c)
+	if i < 0 || strings.IndexByte(s[:i], '\\') < 0 {
+		return i // the usual case: no escapes to consider
+	}
 
-	for i, r := range s {
-		switch r {
+	esc := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
 		case '\\':
 			esc = !esc
 		case c:
@@ -240,6 +439,30 @@ func indexRune(s string, c rune) int {
 	return -1 // not found
 }
 
+// trimNulCrLf removes trailing NUL, CR and LF bytes.
+func trimNulCrLf(bs []byte) []byte {
+	n := len(bs)
+	for n > 0 && (bs[n-1] == 0 || bs[n-1] == '\r' || bs[n-1] == '\n') {
+		n--
+	}
+	return bs[:n]
+}
+
+// trimLeftSpace removes leading white space, scanning ASCII bytewise.
+func trimLeftSpace(s string) string {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == ' ' || ('\t' <= c && c <= '\r'):
+			continue
+		case c >= utf8.RuneSelf:
+			return strings.TrimLeftFunc(s[i:], unicode.IsSpace)
+		default:
+			return s[i:]
+		}
+	}
+	return ""
+}
+
 func cropString(s string, crop int) string {
 	if len(s) > crop {
 		return s[:crop] + "..."
@@ -252,7 +475,7 @@ func findBOM(bs []byte) int {
 	// We don't care about the possibility of 0xEF occurring more than once because the
 	// header part is always only 7-bit ASCII, so any subsequent 0xEF will be after the BOM.
 	bom := bytes.IndexByte(bs, 0xEF)
-	if 0 <= bom && bs[bom+1] == 0xBB && bs[bom+2] == 0xBF {
+	if 0 <= bom && bom+2 < len(bs) && bs[bom+1] == 0xBB && bs[bom+2] == 0xBF {
 		return bom
 	}
 	return -1
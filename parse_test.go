@@ -47,12 +47,12 @@ func TestParseMessage(t *testing.T) {
 				Severity:    Notice,
 				Version:     0,
 				Timestamp:   time.Date(2023, 2, 5, 17, 32, 18, 0, time.UTC),
-				Hostname:    "", // no requirement to recognise the IP address as a hostname
+				Hostname:    "10.0.0.99", // no requirement to recognise the IP address as a hostname, but we do
 				Application: "",
 				ProcID:      "",
 				MsgID:       "",
 				Data:        ``,
-				Content:     `10.0.0.99 Use the BFG!`, // contains the IP address
+				Content:     `Use the BFG!`,
 			},
 		},
 		{
@@ -64,8 +64,8 @@ func TestParseMessage(t *testing.T) {
 				Facility:    Local4,
 				Severity:    Notice,
 				Version:     0,
-				Timestamp:   time.Date(2023, 8, 24, 5, 34, 0, 0, time.UTC),
-				Hostname:    "CST", // because time zone is not expected in RFC3164
+				Timestamp:   time.Date(1987, 8, 24, 5, 34, 0, 0, time.UTC),
+				Hostname:    "mymachine", // the time zone is skipped, although not expected in RFC3164
 				Application: "myproc",
 				ProcID:      "10",
 				MsgID:       "",
@@ -92,6 +92,100 @@ func TestParseMessage(t *testing.T) {
 			},
 		},
 
+		{
+			name: "RFC3164 with IPv6 hostname",
+			in:   []byte(`<34>Oct 11 22:14:15 2001:db8::1 sshd[42]: accepted`),
+			m: Message{
+				Time:        tx,
+				Facility:    Auth,
+				Severity:    Crit,
+				Timestamp:   time.Date(2023, 10, 11, 22, 14, 15, 0, time.UTC),
+				Hostname:    "2001:db8::1",
+				Application: "sshd",
+				ProcID:      "42",
+				Content:     `: accepted`,
+			},
+		},
+		{
+			name: "RFC3164 with tag but no colon",
+			in:   []byte(`<34>Oct 11 22:14:15 mymachine cron[7] job done: ok`),
+			m: Message{
+				Time:        tx,
+				Facility:    Auth,
+				Severity:    Crit,
+				Timestamp:   time.Date(2023, 10, 11, 22, 14, 15, 0, time.UTC),
+				Hostname:    "mymachine",
+				Application: "cron",
+				ProcID:      "7",
+				Content:     ` job done: ok`,
+			},
+		},
+		{
+			name: "RFC3164 with tag but no hostname",
+			in:   []byte(`<34>Oct 11 22:14:15 cron[7]: job done`),
+			m: Message{
+				Time:        tx,
+				Facility:    Auth,
+				Severity:    Crit,
+				Timestamp:   time.Date(2023, 10, 11, 22, 14, 15, 0, time.UTC),
+				Application: "cron",
+				ProcID:      "7",
+				Content:     `: job done`,
+			},
+		},
+
+		{
+			name: "RFC3164 without PRI",
+			in:   []byte(`Oct 11 22:14:15 mymachine su: no PRI`),
+			m: Message{
+				Time:        tx,
+				Facility:    User,
+				Severity:    Notice,
+				Timestamp:   time.Date(2023, 10, 11, 22, 14, 15, 0, time.UTC),
+				Hostname:    "mymachine",
+				Application: "su",
+				Content:     `: no PRI`,
+			},
+		},
+		{
+			name: "without PRI or header",
+			in:   []byte(`kernel: eth0 link up`),
+			m: Message{
+				Time:      tx,
+				Facility:  User,
+				Severity:  Notice,
+				Timestamp: tx,
+				Content:   `kernel: eth0 link up`,
+			},
+		},
+		{
+			name: "with unterminated PRI",
+			in:   []byte(`<1234>mymachine su: x`),
+			m: Message{
+				Time:      tx,
+				Facility:  User,
+				Severity:  Notice,
+				Timestamp: tx,
+				Content:   `<1234>mymachine su: x`,
+			},
+		},
+
+		{
+			name: "RFC3164 with leap second",
+			in:   []byte(`<34>Dec 31 23:59:60 mymachine su: leap`),
+			m: Message{
+				Time:        tx,
+				Facility:    Auth,
+				Severity:    Crit,
+				Version:     0,
+				Timestamp:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				Hostname:    "mymachine",
+				Application: "su",
+				Content:     `: leap`,
+				Annotations: map[string]string{OriginalTimestamp: "Dec 31 23:59:60"},
+			},
+		},
+
 		//------------------------------ RFC 5424 ------------------------------
 		{
 			name: "RFC5424 example 1: with BOM but no structured data",
@@ -181,6 +275,42 @@ func TestParseMessage(t *testing.T) {
 				Content:     ``,
 			},
 		},
+		{
+			name: "RFC5424 with leap second",
+			in:   []byte(`<165>1 2016-12-31T23:59:60Z mymachine.example.com evntslog - ID47 - leap`),
+			m: Message{
+				Time:        tx,
+				Facility:    Local4,
+				Severity:    Notice,
+				Version:     1,
+				Timestamp:   time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+				Hostname:    "mymachine.example.com",
+				Application: "evntslog",
+				ProcID:      "-",
+				MsgID:       "ID47",
+				Data:        `-`,
+				Content:     `leap`,
+				Annotations: map[string]string{OriginalTimestamp: "2016-12-31T23:59:60Z"},
+			},
+		},
+		{
+			name: "RFC5424 with invalid timestamp",
+			in:   []byte(`<165>1 2016-13-45T99:00:00Z mymachine.example.com evntslog - ID47 - bad`),
+			m: Message{
+				Time:        tx,
+				Facility:    Local4,
+				Severity:    Notice,
+				Version:     1,
+				Timestamp:   tx,
+				Hostname:    "mymachine.example.com",
+				Application: "evntslog",
+				ProcID:      "-",
+				MsgID:       "ID47",
+				Data:        `-`,
+				Content:     `bad`,
+				Annotations: map[string]string{OriginalTimestamp: "2016-13-45T99:00:00Z"},
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -192,3 +322,83 @@ func TestParseMessage(t *testing.T) {
 func concat(a, b, c []byte) []byte {
 	return append(a, append(b, c...)...)
 }
+
+func TestParsePriority(t *testing.T) {
+	cases := []struct {
+		in      string
+		prio, n int
+	}{
+		{in: "<34>1 ...", prio: 34, n: 4},
+		{in: "<0>x", prio: 0, n: 3},
+		{in: "<191>x", prio: 191, n: 5},
+		{in: "<1234>x", prio: defaultPriority, n: 0},
+		{in: "<>x", prio: defaultPriority, n: 0},
+		{in: "<34", prio: defaultPriority, n: 0},
+		{in: "no PRI", prio: defaultPriority, n: 0},
+		{in: "", prio: defaultPriority, n: 0},
+	}
+	for _, c := range cases {
+		prio, n, err := parsePriority([]byte(c.in))
+		expect.Error(err).Info(c.in).ToBeNil(t)
+		expect.Number(prio).Info(c.in).ToBe(t, c.prio)
+		expect.Number(n).Info(c.in).ToBe(t, c.n)
+	}
+
+	_, _, err := parsePriority([]byte("<1x>"))
+	expect.Error(err).ToContain(t, "1x: message has invalid priority")
+
+	bs := []byte("<34>1 ...")
+	allocs := testing.AllocsPerRun(10, func() { parsePriority(bs) })
+	expect.Number(allocs).ToBe(t, 0.0)
+}
+
+func TestParseMessage_degenerate(t *testing.T) {
+	_, err := parseMessage([]byte("\r\n\x00"))
+	expect.Error(err).ToContain(t, "empty message")
+
+	// these once panicked and looped forever respectively
+	m, err := parseMessage([]byte("<4>1 host"))
+	expect.Error(err).ToBeNil(t)
+	expect.String(m.Content).ToBe(t, "host")
+
+	m, err = parseMessage([]byte(`<34>1 - - - - - [x a="b"][y `))
+	expect.Error(err).ToBeNil(t)
+	expect.String(m.Data).ToBe(t, `[x a="b"]`)
+	expect.String(m.Content).ToBe(t, "[y ")
+
+	m, err = parseMessage([]byte("<13>Oct 11 22:14:15 TZ5"))
+	expect.Error(err).ToBeNil(t)
+
+	m, err = parseMessage([]byte("<13>Oct 11 22:14:15 host app: ends with \xEF"))
+	expect.Error(err).ToBeNil(t)
+	expect.String(m.Content).ToContain(t, "ends with \xEF")
+}
+
+func TestScanningHelpers(t *testing.T) {
+	expect.Number(indexUnescaped(`a\:b:c`, ':')).ToBe(t, 4)
+	expect.Number(indexUnescaped(`a\\:b`, ':')).ToBe(t, 3)
+	expect.Number(indexUnescaped(`a\]`, ']')).ToBe(t, -1)
+	expect.Number(indexUnescaped(`abc`, ':')).ToBe(t, -1)
+
+	expect.Number(nextSpace("host app")).ToBe(t, 4)
+	expect.Number(nextSpace("hösté app")).ToBe(t, 0)
+	expect.Number(nextSpace("host")).ToBe(t, 4)
+
+	expect.String(string(trimNulCrLf([]byte("abc\r\n\x00")))).ToBe(t, "abc")
+	expect.String(string(trimNulCrLf([]byte("\n")))).ToBe(t, "")
+
+	expect.String(trimLeftSpace(" \t\r\nabc ")).ToBe(t, "abc ")
+	expect.String(trimLeftSpace("   abc")).ToBe(t, "abc")
+	expect.String(trimLeftSpace("  ")).ToBe(t, "")
+}
+
+func BenchmarkScanningHelpers(b *testing.B) {
+	const header = `myhost.example.com myapp 12345 m1 [ex@32473 a="b"] This is a sample: syslog message`
+	bs := []byte("<34>1 2023-10-26T15:30:00Z myhost myapp - - - hello\r\n\x00")
+	for i := 0; i < b.N; i++ {
+		nextSpace(header)
+		indexUnescaped(header, ':')
+		trimNulCrLf(bs)
+		trimLeftSpace("   " + header[:1])
+	}
+}
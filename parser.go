@@ -0,0 +1,63 @@
+package syslog
+
+import (
+	"errors"
+	"time"
+)
+
+// Parser parses packets in a wire format, so that formats other than RFC 5424 and RFC 3164,
+// e.g. with vendor-specific prefixes or length-prefixed binary records, can be received;
+// see [Server.SetParsers].
+type Parser interface {
+	// Parse parses a packet received at time t. It returns nil without an error if the
+	// packet is not in its format, so that the next parser can try it. The Time, Source,
+	// Size and TLSPeer of the message are set by the server.
+	Parse(pkt []byte, t time.Time) (*Message, error)
+}
+
+// ParserFunc is a function that is a [Parser].
+type ParserFunc func(pkt []byte, t time.Time) (*Message, error)
+
+func (f ParserFunc) Parse(pkt []byte, t time.Time) (*Message, error) {
+	return f(pkt, t)
+}
+
+// BuiltinParser is the parser for RFC 5424 and RFC 3164 that the server uses by default;
+// it is placed among the parsers given to [Server.SetParsers]. It accepts almost any text,
+// taking it as RFC 3164 when it is not RFC 5424, so parsers after it only see the packets
+// that it rejects, e.g. those with an invalid priority. It does not use the dialect hints
+// of [WithParserCache].
+var BuiltinParser Parser = ParserFunc(parseMessageAt)
+
+// errNotParsed is reported for a packet that no parser recognised.
+var errNotParsed = errors.New("no parser recognised the message")
+
+// SetParsers sets the parsers that are tried in order on each packet, until one of them
+// returns a message. Include [BuiltinParser] to try RFC 5424 and RFC 3164, e.g. after a
+// parser for a vendor's format; if all the parsers fail, the first error is reported.
+// With no parsers, only the built-in parser is used, as by default.
+//
+// Custom parsers decide the priority, so when any are set, the priority filter (see
+// [Server.SetPriorityFilter]) is applied after parsing, and messages are not parsed lazily
+// (see [WithLazyParsing]). This must be set before calling [Server.Listen].
+func (s *Server) SetParsers(parsers ...Parser) {
+	s.parsers = parsers
+}
+
+// parseWith tries each of the server's parsers in turn.
+func (s *Server) parseWith(bs []byte, t time.Time) (*Message, error) {
+	var first error
+	for _, p := range s.parsers {
+		m, err := p.Parse(bs, t)
+		if err == nil && m != nil {
+			return m, nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	if first == nil {
+		first = errNotParsed
+	}
+	return nil, first
+}
@@ -0,0 +1,54 @@
+package syslog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+// vendorParser parses a made-up format: "VND|" and a 2-byte length, then the content.
+var vendorParser = ParserFunc(func(pkt []byte, t time.Time) (*Message, error) {
+	rest, found := bytes.CutPrefix(pkt, []byte("VND|"))
+	if !found {
+		return nil, nil
+	}
+	if len(rest) < 2 || int(binary.BigEndian.Uint16(rest)) != len(rest)-2 {
+		return nil, errors.New("bad vendor record")
+	}
+	return &Message{Facility: Local3, Severity: Warning, Timestamp: t, Content: string(rest[2:])}, nil
+})
+
+func TestServer_SetParsers(t *testing.T) {
+	s := NewServer(WithLazyParsing())
+	s.SetParsers(vendorParser, BuiltinParser)
+	addr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 514}
+
+	m := s.receive([]byte("VND|\x00\x05hello"), addr, AcceptEverything)
+	expect.Bool(m != nil).ToBeTrue(t)
+	expect.Bool(m.IsParsed()).ToBeTrue(t)
+	expect.Number(m.Facility).ToBe(t, Local3)
+	expect.String(m.Content).ToBe(t, "hello")
+	expect.Any(m.Source).ToBe(t, net.Addr(addr))
+	expect.Number(m.Size).ToBe(t, 11)
+
+	m = s.receive([]byte("<34>1 - host app - - - standard"), addr, AcceptEverything)
+	expect.String(m.Content).ToBe(t, "standard")
+
+	// the priority filter applies to the parsed priority
+	s.SetPriorityFilter(func(f Facility, _ Severity) bool { return f != Local3 })
+	expect.Any(s.receive([]byte("VND|\x00\x05hello"), addr, AcceptEverything)).ToBeNil(t)
+	expect.Bool(s.receive([]byte("<34>1 - host app - - - standard"), addr, AcceptEverything) != nil).ToBeTrue(t)
+
+	// without the built-in parser, other packets are rejected
+	s.SetParsers(vendorParser)
+	m, err := s.parseWith([]byte("VND|\x00"), time.Now())
+	expect.Any(m).ToBeNil(t)
+	expect.Error(err).ToContain(t, "bad vendor record")
+	_, err = s.parseWith([]byte("<34>1 - host app - - - standard"), time.Now())
+	expect.Bool(errors.Is(err, errNotParsed)).ToBeTrue(t)
+}
@@ -0,0 +1,39 @@
+package syslog
+
+import "path/filepath"
+
+// SyslogConfHandlers builds a chain of handlers replicating the traditional layout of
+// /etc/syslog.conf (as found on Debian and similar), writing files into dir:
+//
+//	auth,authpriv.*            auth.log
+//	*.*;auth,authpriv.none     syslog
+//	kern.*                     kern.log
+//	mail.*                     mail.log
+//	*.emerg                    emerg handler
+//
+// The files use the specified format, e.g. [RFCFormat]. Emergency messages are also
+// passed to the emerg handler, which would typically broadcast them to all users (see
+// [WallHandler]); it may be nil. Add the handlers to a [Server] in order.
+func SyslogConfHandlers(dir, format string, emerg Handler) []Handler {
+	auth := Facilities{Auth, Authpriv}.Filter()
+
+	file := func(name string, accept Filter) Handler {
+		h := NewFileHandler(filepath.Join(dir, name), format)
+		h.SetFilter(accept)
+		h.SetPropagateAll(true)
+		return h
+	}
+
+	handlers := []Handler{
+		file("auth.log", auth),
+		file("syslog", Not(auth)),
+		file("kern.log", Facilities{Kern}.Filter()),
+		file("mail.log", Facilities{Mail}.Filter()),
+	}
+
+	if emerg != nil {
+		handlers = append(handlers, FilterHandler(Severities{Emerg}.Filter(), emerg))
+	}
+
+	return handlers
+}
@@ -0,0 +1,47 @@
+package syslog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestSyslogConfHandlers(t *testing.T) {
+	dir := t.TempDir()
+	var emergencies []*Message
+	handlers := SyslogConfHandlers(dir, "%F.%S %C", handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			emergencies = append(emergencies, m)
+		}
+		return m
+	}))
+
+	messages := []*Message{
+		{Facility: Auth, Severity: Info, Content: "login"},
+		{Facility: User, Severity: Notice, Content: "hello"},
+		{Facility: Kern, Severity: Emerg, Content: "panic"},
+	}
+	for _, m := range messages {
+		for _, h := range handlers {
+			if h.Handle(m) == nil {
+				break
+			}
+		}
+	}
+	for _, h := range handlers {
+		h.Handle(nil)
+	}
+
+	read := func(name string) string {
+		bs, _ := os.ReadFile(filepath.Join(dir, name))
+		return string(bs)
+	}
+
+	expect.String(read("auth.log")).ToBe(t, "auth.info login\n")
+	expect.String(read("syslog")).ToBe(t, "user.notice hello\nkern.emerg panic\n")
+	expect.String(read("kern.log")).ToBe(t, "kern.emerg panic\n")
+	expect.String(read("mail.log")).ToBe(t, "")
+	expect.Slice(emergencies).ToBe(t, messages[2])
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package syslog
+
+import "errors"
+
+// DropPrivileges is not supported on this platform.
+func DropPrivileges(username, groupname string) error {
+	return errors.New("DropPrivileges is not supported on this platform")
+}
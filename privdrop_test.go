@@ -0,0 +1,16 @@
+//go:build unix
+
+package syslog
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+// Successfully dropping privileges would affect the whole test process, so only the
+// failures are tested here.
+func TestDropPrivileges(t *testing.T) {
+	expect.Error(DropPrivileges("no-such-user-exists", "")).ToContain(t, "no-such-user-exists")
+	expect.Error(DropPrivileges("root", "no-such-group-exists")).ToContain(t, "no-such-group-exists")
+}
@@ -0,0 +1,63 @@
+//go:build unix
+
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// DropPrivileges changes the user and group of the process, typically from root after
+// binding privileged ports such as 514, so that the collector does not run as root. If
+// groupname is blank, the user's primary group is used. Supplementary groups are removed.
+// All threads of the process are affected.
+//
+// Call this after all the privileged sockets have been opened. Datagram listeners opened
+// by the server cannot be re-opened afterwards (see [WithRestart]), so a listener that
+// fails will stop. Messages may arrive before privileges are dropped; to avoid processing
+// these as root, open the sockets with [net.ListenPacket] and [net.Listen] and pass them to
+// [Server.ListenPacketConn] and [Server.ListenListener] after calling DropPrivileges.
+func DropPrivileges(username, groupname string) error {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return err
+	}
+
+	gid := u.Gid
+	if groupname != "" {
+		g, err := user.LookupGroup(groupname)
+		if err != nil {
+			return err
+		}
+		gid = g.Gid
+	}
+
+	uidN, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("%s: unsupported user ID %q", username, u.Uid)
+	}
+	gidN, err := strconv.Atoi(gid)
+	if err != nil {
+		return fmt.Errorf("%s: unsupported group ID %q", groupname, gid)
+	}
+
+	// the group must be changed first, whilst the process still has permission
+	if err = syscall.Setgroups([]int{gidN}); err != nil {
+		return os.NewSyscallError("setgroups", err)
+	}
+	if err = syscall.Setgid(gidN); err != nil {
+		return os.NewSyscallError("setgid", err)
+	}
+	if err = syscall.Setuid(uidN); err != nil {
+		return os.NewSyscallError("setuid", err)
+	}
+
+	if uidN != 0 && syscall.Setuid(0) == nil {
+		return errors.New("privileges could not be dropped")
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+)
+
+// frontEndFDVar names the environment variable that tells the processing process which
+// file descriptor is its end of the socket pair; see [RunFrontEnd].
+const frontEndFDVar = "SYSLOG_FRONTEND_FD"
+
+// ListenFrontEnd starts a goroutine that receives syslog messages relayed by a privileged
+// front-end process that started this process using [RunFrontEnd]. The messages keep the
+// address of their original sender. An error is returned if this process was not started
+// by a front end. Only the messages matching accept are processed.
+func (s *Server) ListenFrontEnd(accept Filter) error {
+	fd, err := strconv.Atoi(os.Getenv(frontEndFDVar))
+	if err != nil {
+		return errors.New("not started by a syslog front end")
+	}
+	os.Unsetenv(frontEndFDVar)
+
+	f := os.NewFile(uintptr(fd), "frontend")
+	defer f.Close() // the net package uses a duplicate
+
+	c, err := net.FilePacketConn(f)
+	if err != nil {
+		return err
+	}
+	s.ListenPacketConn(frontEndConn{c}, accept)
+	return nil
+}
+
+// relayDatagrams forwards each datagram received on from to the processing process, preceded
+// by the address of its sender.
+func relayDatagrams(from net.PacketConn, to net.Conn) error {
+	buf := make([]byte, defaultReadBufferSize)
+	for {
+		n, addr, err := from.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		pkt, err := appendSender(nil, addr)
+		if err != nil {
+			continue // not an IP address, so there is no sender to record
+		}
+		if _, err = to.Write(append(pkt, buf[:n]...)); err != nil {
+			return err
+		}
+	}
+}
+
+// appendSender appends the sender's address, preceded by its length.
+func appendSender(bs []byte, addr net.Addr) ([]byte, error) {
+	ua, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a UDP address", addr)
+	}
+	ap, err := ua.AddrPort().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	bs = append(bs, byte(len(ap)))
+	return append(bs, ap...), nil
+}
+
+// frontEndConn reads the datagrams relayed by a front end, giving the address of each
+// original sender instead of the front end's.
+type frontEndConn struct {
+	net.PacketConn
+}
+
+func (c frontEndConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, _, err := c.PacketConn.ReadFrom(p)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if n > 0 && n > int(p[0]) {
+			var ap netip.AddrPort
+			if ap.UnmarshalBinary(p[1:1+p[0]]) == nil {
+				header := 1 + int(p[0])
+				return copy(p, p[header:n]), net.UDPAddrFromAddrPort(ap), nil
+			}
+		}
+		// otherwise, the datagram is malformed and ignored
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package syslog
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// RunFrontEnd is not supported on this platform.
+func RunFrontEnd(addr string, cmd *exec.Cmd) error {
+	return errors.New("RunFrontEnd is not supported on this platform")
+}
@@ -0,0 +1,54 @@
+//go:build unix
+
+package syslog
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestServer_ListenFrontEnd(t *testing.T) {
+	s := NewServer(WithQueueLength(1))
+	defer s.Shutdown()
+	expect.Error(s.ListenFrontEnd(AcceptEverything)).ToContain(t, "not started by a syslog front end")
+
+	// the front end's half
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_DGRAM, 0)
+	expect.Error(err).ToBeNil(t)
+	local := os.NewFile(uintptr(fds[0]), "frontend")
+	conn, err := net.FileConn(local)
+	expect.Error(err).ToBeNil(t)
+	local.Close()
+	defer conn.Close()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.Error(err).ToBeNil(t)
+	defer pc.Close()
+	go relayDatagrams(pc, conn)
+
+	// the processing half
+	received := make(chan *Message, 1)
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	t.Setenv(frontEndFDVar, strconv.Itoa(fds[1]))
+	expect.Error(s.ListenFrontEnd(AcceptEverything)).ToBeNil(t)
+
+	c, err := net.Dial("udp", pc.LocalAddr().String())
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+	_, err = c.Write([]byte("<13>1 - host app - - - relayed"))
+	expect.Error(err).ToBeNil(t)
+
+	m := <-received
+	expect.String(m.Content).ToBe(t, "relayed")
+	expect.String(m.Source.String()).ToBe(t, c.LocalAddr().String())
+}
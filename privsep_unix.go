@@ -0,0 +1,60 @@
+//go:build unix
+
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// RunFrontEnd is the privileged half of a pair of processes that separate privileges: it
+// binds a UDP address, such as port 514, then starts cmd, the unprivileged processing
+// process, which calls [Server.ListenFrontEnd]. Each datagram is relayed as-is, with the
+// address of its sender, over a socket pair. So only this small relay runs with privileges;
+// the parser and handlers do not. The processing process should be started with reduced
+// privileges, e.g. by setting cmd.SysProcAttr.Credential. RunFrontEnd returns when the
+// processing process exits.
+func RunFrontEnd(addr string, cmd *exec.Cmd) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return os.NewSyscallError("socketpair", err)
+	}
+	local, remote := os.NewFile(uintptr(fds[0]), "frontend"), os.NewFile(uintptr(fds[1]), "backend")
+
+	conn, err := net.FileConn(local)
+	local.Close()
+	if err != nil {
+		remote.Close()
+		return err
+	}
+	defer conn.Close()
+
+	cmd.ExtraFiles = append(cmd.ExtraFiles, remote)
+	cmd.Env = append(cmd.Environ(), fmt.Sprintf("%s=%d", frontEndFDVar, 2+len(cmd.ExtraFiles)))
+	err = cmd.Start()
+	remote.Close()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		err := relayDatagrams(pc, conn)
+		if !errors.Is(err, net.ErrClosed) {
+			checkErr(err, "relay from", pc.LocalAddr().String())
+		}
+	}()
+
+	err = cmd.Wait()
+	pc.Close() // stops the relay
+	return err
+}
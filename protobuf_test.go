@@ -0,0 +1,47 @@
+package syslog
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestProtobufEncoder(t *testing.T) {
+	m := &Message{
+		Timestamp:   time.UnixMicro(300),
+		Facility:    Daemon,
+		Severity:    Emerg,
+		Version:     1,
+		Application: "app",
+		Content:     "hi",
+	}
+	m.Annotate("k", "v")
+
+	bs, err := NewProtobufEncoder().AppendFormat(nil, m)
+	expect.Error(err).ToBeNil(t)
+
+	fields := make(map[uint64][]any)
+	for len(bs) > 0 {
+		tag, n := binary.Uvarint(bs)
+		v, n2 := binary.Uvarint(bs[n:])
+		bs = bs[n+n2:]
+		if tag&7 == 2 {
+			fields[tag>>3] = append(fields[tag>>3], string(bs[:v]))
+			bs = bs[v:]
+		} else {
+			fields[tag>>3] = append(fields[tag>>3], v)
+		}
+	}
+
+	// Emerg is zero and omitted, as are the blank strings and the zero time
+	expect.Map(fields).ToBe(t, map[uint64][]any{
+		3:  {uint64(Daemon)},
+		5:  {uint64(1)},
+		6:  {uint64(300)},
+		8:  {"app"},
+		12: {"hi"},
+		15: {"\x0a\x01k\x12\x01v"},
+	})
+}
@@ -0,0 +1,176 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyHeaderTimeout limits how long a client may take to send its PROXY protocol header.
+const proxyHeaderTimeout = 10 * time.Second
+
+// proxyV2Signature starts every PROXY protocol version 2 header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ListenTCPProxy is like [Server.ListenTCP] but is intended to be used behind a load balancer
+// such as HAProxy or AWS NLB. Every connection must start with a PROXY protocol header
+// (version 1 or 2), from which the original client address is recorded in [Message.Source]
+// instead of the load balancer's address. Connections without a valid header are closed.
+// Only the messages matching accept are processed.
+//
+// The header is only believed from the load balancers, which must be within the trusted
+// networks; connections from anywhere else are closed, because a client could otherwise
+// claim any address and so evade the source ACL and rate limits. The load balancers'
+// own addresses must also be allowed by the source ACL (see [Server.SetSourceACL]).
+func (s *Server) ListenTCPProxy(addr string, trusted []net.IPNet, accept Filter) error {
+	if len(trusted) == 0 {
+		return errors.New("PROXY protocol requires trusted proxies")
+	}
+	proxies := &sourceACL{allow: toPrefixes(trusted)}
+
+	l, err := s.listenStream("proxy", "tcp", addr, nil)
+	if err != nil {
+		return err
+	}
+
+	go s.acceptStreams(l, func(c net.Conn) {
+		s.proxyReceiver(c, proxies, accept)
+	})
+	return nil
+}
+
+func (s *Server) proxyReceiver(c net.Conn, proxies *sourceACL, acceptFunc Filter) {
+	if !s.acl.allows(c.RemoteAddr()) || !proxies.allows(c.RemoteAddr()) {
+		s.denied.Add(1)
+		s.audit.record(&Message{Time: s.clock(), Source: c.RemoteAddr()}, verdictDenied, nil)
+		return
+	}
+
+	r := bufio.NewReaderSize(c, maxFrameLength)
+
+	c.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+	src, err := readProxyHeader(r)
+	if err != nil {
+		if !s.shutDown.Load() {
+			s.logger.Println("PROXY header error:", c.RemoteAddr(), err)
+		}
+		return
+	}
+	c.SetReadDeadline(time.Time{})
+
+	if src == nil {
+		// a health check from the load balancer itself
+		src = c.RemoteAddr()
+	}
+
+	s.readFrames(r, c, src, acceptFunc)
+}
+
+// readProxyHeader reads a PROXY protocol header and returns the original source address.
+// The address is nil if the header does not specify one, as for UNKNOWN or LOCAL connections.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(proxyV2Signature))
+	if err == nil && bytes.Equal(sig, proxyV2Signature) {
+		return readProxyV2(r)
+	}
+	return readProxyV1(r)
+}
+
+// readProxyV1 reads a human-readable header, e.g. "PROXY TCP4 192.0.2.1 198.51.100.1 56324 514\r\n".
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	const maxV1Length = 107
+
+	var line []byte
+	for len(line) < maxV1Length {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+
+	s, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, errors.New("malformed v1 header")
+	}
+
+	fields := strings.Split(s, " ")
+	if fields[0] != "PROXY" || len(fields) < 2 {
+		return nil, errors.New("missing header")
+	}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("malformed v1 header %q", s)
+		}
+		ip := net.ParseIP(fields[2])
+		port, err := strconv.ParseUint(fields[4], 10, 16)
+		if ip == nil || err != nil {
+			return nil, fmt.Errorf("malformed v1 header %q", s)
+		}
+		return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+	}
+	return nil, fmt.Errorf("unsupported protocol %q", fields[1])
+}
+
+// readProxyV2 reads a binary header.
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+
+	verCmd, family := hdr[12], hdr[13]
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("unsupported version %d", verCmd>>4)
+	}
+
+	switch verCmd & 0xF {
+	case 0: // LOCAL
+		return nil, nil
+	case 1: // PROXY
+	default:
+		return nil, fmt.Errorf("unsupported command %d", verCmd&0xF)
+	}
+
+	switch family >> 4 {
+	case 1: // IPv4
+		if len(body) < 12 {
+			return nil, errors.New("short v2 header")
+		}
+		return proxyV2Addr(family, net.IP(body[0:4]), binary.BigEndian.Uint16(body[8:])), nil
+	case 2: // IPv6
+		if len(body) < 36 {
+			return nil, errors.New("short v2 header")
+		}
+		return proxyV2Addr(family, net.IP(body[0:16]), binary.BigEndian.Uint16(body[32:])), nil
+	}
+	// AF_UNSPEC and AF_UNIX carry no useful network address
+	return nil, nil
+}
+
+func proxyV2Addr(family byte, ip net.IP, port uint16) net.Addr {
+	ip = bytes.Clone(ip)
+	if family&0xF == 2 { // DGRAM
+		return &net.UDPAddr{IP: ip, Port: int(port)}
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}
+}
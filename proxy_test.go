@@ -0,0 +1,101 @@
+package syslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestReadProxyHeader(t *testing.T) {
+	v2 := func(verCmd, family byte, body ...byte) string {
+		return string(proxyV2Signature) + string([]byte{verCmd, family, 0, byte(len(body))}) + string(body)
+	}
+
+	cases := []struct {
+		in, exp string
+	}{
+		{in: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 514\r\n", exp: "192.0.2.1:56324"},
+		{in: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 514\r\n", exp: "[2001:db8::1]:56324"},
+		{in: "PROXY UNKNOWN\r\n", exp: "<nil>"},
+		{in: v2(0x21, 0x11, 192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 2, 2), exp: "192.0.2.1:56324"},
+		{in: v2(0x21, 0x12, 192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 2, 2), exp: "192.0.2.1:56324"},
+		{in: v2(0x20, 0x00), exp: "<nil>"},
+	}
+	for _, c := range cases {
+		r := bufio.NewReader(strings.NewReader(c.in + "<13>rest"))
+		a, err := readProxyHeader(r)
+		expect.Error(err).Info(c.in).ToBeNil(t)
+		expect.String(fmt.Sprint(a)).Info(c.in).ToBe(t, c.exp)
+
+		rest, _ := io.ReadAll(r)
+		expect.String(string(rest)).Info(c.in).ToBe(t, "<13>rest")
+	}
+
+	for _, in := range []string{
+		"<13>Oct 11 22:14:15 myhost myapp: no header\n",
+		"PROXY TCP4 192.0.2.1\r\n",
+		"PROXY UDP4 192.0.2.1 198.51.100.1 56324 514\r\n",
+		v2(0x11, 0x11),
+		v2(0x21, 0x11, 192, 0, 2, 1),
+	} {
+		_, err := readProxyHeader(bufio.NewReader(strings.NewReader(in)))
+		expect.Bool(err != nil).Info(in).ToBeTrue(t)
+	}
+}
+
+func TestListenTCPProxy(t *testing.T) {
+	received := make(chan *Message, 1)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	expect.Error(s.ListenTCPProxy("127.0.0.1:0", nil, AcceptEverything)).ToContain(t, "trusted")
+	expect.Error(s.ListenTCPProxy("127.0.0.1:0", cidrs(t, "127.0.0.0/8"), AcceptEverything)).ToBeNil(t)
+
+	s.mu.Lock()
+	addr := s.listeners[0].Addr().String()
+	s.mu.Unlock()
+
+	c, err := net.Dial("tcp", addr)
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+
+	_, err = io.WriteString(c, "PROXY TCP4 192.0.2.1 198.51.100.1 56324 514\r\n<13>1 - host app - - - hello\n")
+	expect.Error(err).ToBeNil(t)
+
+	m := <-received
+	expect.String(m.Content).ToBe(t, "hello")
+	expect.String(m.NetSrc()).ToBe(t, "192.0.2.1")
+}
+
+func TestListenTCPProxy_untrusted(t *testing.T) {
+	s := NewServer()
+	defer s.Shutdown()
+
+	expect.Error(s.ListenTCPProxy("127.0.0.1:0", cidrs(t, "192.0.2.0/24"), AcceptEverything)).ToBeNil(t)
+
+	s.mu.Lock()
+	addr := s.listeners[0].Addr().String()
+	s.mu.Unlock()
+
+	c, err := net.Dial("tcp", addr)
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+
+	// the header is not believed from a client, so the connection is closed
+	_, err = io.WriteString(c, "PROXY TCP4 192.0.2.1 198.51.100.1 56324 514\r\n<13>1 - host app - - - hello\n")
+	expect.Error(err).ToBeNil(t)
+	_, err = c.Read(make([]byte, 1))
+	expect.Bool(err != nil).ToBeTrue(t)
+	expect.Number(s.Denied()).ToBe(t, 1)
+}
@@ -0,0 +1,253 @@
+package syslog
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// messageQueue carries messages from the receivers (many producers) to the goroutine
+// that calls the handlers (a single consumer).
+type messageQueue interface {
+	// put adds a batch of messages, blocking while the queue is full. The queue
+	// takes ownership of ms.
+	put(ms []*Message)
+
+	// offer adds as many of the messages as there is room for without blocking, returning
+	// how many were added; the queue takes ownership of them.
+	offer(ms []*Message) int
+
+	// evict removes the oldest queued message(s) to make room, returning them, or nil
+	// if the queue is empty.
+	evict() []*Message
+
+	// take appends the queued messages to batch, up to its capacity, blocking until at
+	// least one is available. It returns an empty batch once the queue is closed and empty.
+	take(batch []*Message) []*Message
+
+	// close is called once all the producers have finished.
+	close()
+
+	// length and capacity indicate how full the queue is.
+	length() int
+	capacity() int
+}
+
+// maxBatch limits how many messages are taken from the queue at once.
+const maxBatch = 64
+
+//-------------------------------------------------------------------------------------------------
+
+// chanQueue passes whole batches through a channel, so its length is a number of batches.
+type chanQueue chan []*Message
+
+func newChanQueue(qlen int) chanQueue {
+	return make(chan []*Message, qlen)
+}
+
+func (q chanQueue) put(ms []*Message) {
+	q <- ms
+}
+
+// offer is all or nothing because the batch is queued as a whole.
+func (q chanQueue) offer(ms []*Message) int {
+	select {
+	case q <- ms:
+		return len(ms)
+	default:
+		return 0
+	}
+}
+
+func (q chanQueue) evict() []*Message {
+	select {
+	case ms := <-q:
+		return ms
+	default:
+		return nil
+	}
+}
+
+func (q chanQueue) take(batch []*Message) []*Message {
+	return append(batch, <-q...)
+}
+
+func (q chanQueue) close() {
+	close(q)
+}
+
+func (q chanQueue) length() int   { return len(q) }
+func (q chanQueue) capacity() int { return cap(q) }
+
+//-------------------------------------------------------------------------------------------------
+
+// ringQueue is a bounded lock-free ring buffer, after Dmitry Vyukov's bounded MPMC queue.
+// Each cell holds a sequence number that tells producers and consumers whose turn it is to
+// use the cell. There is a single consumer except when producers evict old messages.
+type ringQueue struct {
+	cells    []ringCell
+	mask     uint64
+	tail     atomic.Uint64 // next position to write; shared by the producers
+	head     atomic.Uint64 // next position to read
+	sleeping atomic.Bool   // set while the consumer is waiting for messages
+	closed   atomic.Bool
+	wake     chan struct{}
+}
+
+type ringCell struct {
+	seq atomic.Uint64
+	m   *Message
+}
+
+// newRingQueue creates a ring buffer; its size is qlen rounded up to a power of two.
+func newRingQueue(qlen int) *ringQueue {
+	size := uint64(2)
+	for size < uint64(qlen) {
+		size <<= 1
+	}
+
+	q := &ringQueue{
+		cells: make([]ringCell, size),
+		mask:  size - 1,
+		wake:  make(chan struct{}, 1),
+	}
+	for i := range q.cells {
+		q.cells[i].seq.Store(uint64(i))
+	}
+	return q
+}
+
+func (q *ringQueue) put(ms []*Message) {
+	for _, m := range ms {
+		q.put1(m)
+	}
+}
+
+func (q *ringQueue) put1(m *Message) {
+	for spins := 0; !q.tryPut(m); spins++ {
+		// full: wait for the consumer to catch up
+		q.notify()
+		backoff(spins)
+	}
+}
+
+func (q *ringQueue) offer(ms []*Message) int {
+	for i, m := range ms {
+		if !q.tryPut(m) {
+			return i
+		}
+	}
+	return len(ms)
+}
+
+// tryPut adds a message unless the queue is full.
+func (q *ringQueue) tryPut(m *Message) bool {
+	for {
+		pos := q.tail.Load()
+		cell := &q.cells[pos&q.mask]
+		seq := cell.seq.Load()
+
+		switch {
+		case seq == pos:
+			if q.tail.CompareAndSwap(pos, pos+1) {
+				cell.m = m
+				cell.seq.Store(pos + 1)
+				q.notify()
+				return true
+			}
+
+		case seq < pos:
+			return false
+		}
+	}
+}
+
+// tryTake removes the oldest message unless the queue is empty.
+func (q *ringQueue) tryTake() *Message {
+	for {
+		pos := q.head.Load()
+		cell := &q.cells[pos&q.mask]
+		seq := cell.seq.Load()
+
+		switch {
+		case seq == pos+1:
+			if q.head.CompareAndSwap(pos, pos+1) {
+				m := cell.m
+				cell.m = nil
+				cell.seq.Store(pos + q.mask + 1)
+				return m
+			}
+
+		case seq < pos+1:
+			return nil
+		}
+	}
+}
+
+func (q *ringQueue) evict() []*Message {
+	if m := q.tryTake(); m != nil {
+		return []*Message{m}
+	}
+	return nil
+}
+
+func (q *ringQueue) notify() {
+	if q.sleeping.Load() {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (q *ringQueue) take(batch []*Message) []*Message {
+	for {
+		batch = q.takeAvailable(batch)
+		if len(batch) > 0 {
+			return batch
+		}
+
+		// Announce that we are sleeping, then check again so that a message put just
+		// before the announcement is not missed.
+		q.sleeping.Store(true)
+		closed := q.closed.Load()
+		batch = q.takeAvailable(batch)
+		if len(batch) > 0 || closed {
+			q.sleeping.Store(false)
+			return batch
+		}
+		<-q.wake
+		q.sleeping.Store(false)
+	}
+}
+
+func (q *ringQueue) takeAvailable(batch []*Message) []*Message {
+	for len(batch) < cap(batch) {
+		m := q.tryTake()
+		if m == nil {
+			break
+		}
+		batch = append(batch, m)
+	}
+	return batch
+}
+
+func (q *ringQueue) close() {
+	q.closed.Store(true)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *ringQueue) length() int   { return int(q.tail.Load() - q.head.Load()) }
+func (q *ringQueue) capacity() int { return len(q.cells) }
+
+// backoff yields to other goroutines, sleeping briefly when spinning has gone on a while.
+func backoff(spins int) {
+	if spins < 100 {
+		runtime.Gosched()
+	} else {
+		time.Sleep(50 * time.Microsecond)
+	}
+}
@@ -0,0 +1,161 @@
+package syslog
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestRingQueue(t *testing.T) {
+	const producers, each = 4, 1000
+	q := newRingQueue(10)
+	expect.Number(len(q.cells)).ToBe(t, 16)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				q.put([]*Message{{Hostname: strconv.Itoa(p), Sequence: uint64(i)}})
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		q.close()
+	}()
+
+	next := make(map[string]uint64)
+	total := 0
+	batch := make([]*Message, 0, maxBatch)
+	for {
+		batch = q.take(batch[:0])
+		if len(batch) == 0 {
+			break
+		}
+		for _, m := range batch {
+			// each producer's messages arrive in order
+			expect.Number(m.Sequence).Info(m.Hostname).ToBe(t, next[m.Hostname])
+			next[m.Hostname]++
+			total++
+		}
+	}
+	expect.Number(total).ToBe(t, producers*each)
+}
+
+func TestNewRingServer(t *testing.T) {
+	var received []string
+	s := NewServer(WithQueueLength(4), WithRingQueue())
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received = append(received, m.Content)
+		}
+		return m
+	}))
+
+	for i := 0; i < 10; i++ {
+		s.push(&Message{Content: strconv.Itoa(i)})
+	}
+	s.Shutdown()
+
+	expect.Number(len(received)).ToBe(t, 10)
+	expect.String(received[9]).ToBe(t, "9")
+}
+
+func BenchmarkQueue(b *testing.B) {
+	for _, c := range []struct {
+		name string
+		q    func() messageQueue
+	}{
+		{name: "chan", q: func() messageQueue { return newChanQueue(1024) }},
+		{name: "ring", q: func() messageQueue { return newRingQueue(1024) }},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			q := c.q()
+			m := &Message{}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				batch := make([]*Message, 0, maxBatch)
+				for {
+					batch = q.take(batch[:0])
+					if len(batch) == 0 {
+						return
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					q.put([]*Message{m})
+				}
+			})
+			q.close()
+			<-done
+		})
+	}
+}
+
+func TestRingQueue_offerAndEvict(t *testing.T) {
+	q := newRingQueue(2)
+	ms := []*Message{{Content: "1"}, {Content: "2"}, {Content: "3"}}
+
+	expect.Number(q.offer(ms)).ToBe(t, 2)
+	expect.String(q.evict()[0].Content).ToBe(t, "1")
+	expect.Number(q.offer(ms[2:])).ToBe(t, 1)
+
+	batch := q.take(make([]*Message, 0, maxBatch))
+	expect.Number(len(batch)).ToBe(t, 2)
+	expect.String(batch[0].Content).ToBe(t, "2")
+	expect.String(batch[1].Content).ToBe(t, "3")
+	expect.Bool(q.evict() == nil).ToBeTrue(t)
+}
+
+func TestServer_overflowPolicy(t *testing.T) {
+	for _, c := range []struct {
+		policy OverflowPolicy
+		ring   bool
+		exp    string
+	}{
+		{policy: OverflowDropNewest, exp: "0 1"},
+		{policy: OverflowDropOldest, exp: "0 4"},
+		{policy: OverflowDropNewest, ring: true, exp: "0 1 2"},
+		{policy: OverflowDropOldest, ring: true, exp: "0 3 4"},
+	} {
+		opts := []Option{WithQueueLength(1), WithOverflowPolicy(c.policy)}
+		if c.ring {
+			opts = append(opts, WithRingQueue())
+		}
+		s := NewServer(opts...)
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		var received []string
+		s.AddHandler(handlerFunc(func(m *Message) *Message {
+			if m != nil {
+				if m.Content == "0" {
+					close(started)
+					<-release
+				}
+				received = append(received, m.Content)
+			}
+			return m
+		}))
+
+		s.push(&Message{Content: "0"})
+		<-started // the dispatcher is now busy
+		for i := 1; i < 5; i++ {
+			s.push(&Message{Content: strconv.Itoa(i)})
+		}
+		close(release)
+		s.Shutdown()
+
+		expect.String(strings.Join(received, " ")).Info(c).ToBe(t, c.exp)
+		expect.Number(s.Dropped()).Info(c).ToBe(t, uint64(5-len(received)))
+	}
+}
@@ -0,0 +1,203 @@
+package syslog
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultQuirksSDID is the SD-ID used by [QuirksParser] when its SDID is blank.
+const DefaultQuirksSDID = "quirks@32473"
+
+// QuirksParser is a [Parser] for the RFC 3164 variants sent by network devices, which the
+// built-in parser would lump into the content. Cisco IOS and ASA send messages such as
+//
+//	<189>123: router1: *Mar  1 18:46:11.123 UTC: %SYS-5-CONFIG_I: Configured from console
+//	<166>Mar 01 2024 12:00:00 asa01 : %ASA-6-302013: Built outbound TCP connection
+//
+// where the sequence number, the origin hostname, the timestamp and its marker ("*" if the
+// clock is not authoritative, "." if it is no longer synchronised) are each optional, and
+// the %FACILITY-SEVERITY-MNEMONIC tag identifies the event. Juniper devices send the usual
+// RFC 3164 header followed by a tag such as UI_COMMIT:.
+//
+// The mnemonic becomes the MsgID and the content is the text after the tag. A structured
+// data element records the other parts, e.g.
+// [quirks@32473 sequence="123" marker="*" deviceFacility="SYS" deviceSeverity="5"]. The
+// timestamp is taken as UTC, whatever zone is named.
+//
+// Packets without such a tag are left to the next parser, so it is used before the
+// built-in parser:
+//
+//	s.SetParsers(syslog.QuirksParser{}, syslog.BuiltinParser)
+type QuirksParser struct {
+	// SDID is the SD-ID of the element added to the structured data, which should have the
+	// form name@enterprise-number. If blank, [DefaultQuirksSDID] is used.
+	SDID string
+}
+
+func (p QuirksParser) Parse(pkt []byte, t time.Time) (*Message, error) {
+	bs := trimNulCrLf(pkt)
+	prio, n, err := parsePriority(bs)
+	if err != nil || len(bs) == 0 {
+		return nil, nil // the built-in parser reports these
+	}
+
+	s := trimLeftSpace(string(bs[n:]))
+	if strings.HasPrefix(s, "1 ") {
+		return nil, nil // RFC 5424
+	}
+
+	m := &Message{
+		Time:      t,
+		Timestamp: t,
+		Severity:  Severity(prio & 0x07),
+		Facility:  Facility(prio >> 3),
+	}
+
+	params, ok := parseCiscoMessage(m, s)
+	if !ok {
+		params, ok = parseJuniperMessage(m, bs, t)
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	if len(params) > 0 {
+		m.addSDElement(ifBlank(p.SDID, DefaultQuirksSDID), params...)
+	}
+	return m, nil
+}
+
+// parseCiscoMessage parses the optional prefixes used by Cisco devices, up to the tag,
+// returning pairs of SD parameter names and values.
+func parseCiscoMessage(m *Message, s string) ([]string, bool) {
+	var params []string
+
+	if d := leadingDigits(s); d > 0 && strings.HasPrefix(s[d:], ": ") {
+		params = append(params, "sequence", s[:d])
+		s = s[d+2:]
+	}
+
+	// the origin hostname is followed by the marker, the timestamp or the tag
+	if i := strings.Index(s, ": "); i > 0 && strings.IndexAny(s, " *.%") != 0 && !strings.Contains(s[:i], " ") {
+		rest := s[i+2:]
+		if strings.IndexAny(rest, "*.%") == 0 || isCiscoTimestamp(rest, m) {
+			m.Hostname = s[:i]
+			s = rest
+		}
+	}
+
+	if strings.IndexAny(s, "*.") == 0 {
+		params = append(params, "marker", s[:1])
+		s = s[1:]
+	}
+
+	if !strings.HasPrefix(s, "%") {
+		if i := strings.Index(s, ": "); i > 0 {
+			ts, host, ok := parseCiscoTimestamp(s[:i], m.Time)
+			if ok {
+				m.Timestamp = ts
+				m.Hostname = ifBlank(host, m.Hostname)
+				s = s[i+2:]
+			}
+		}
+	}
+
+	tag, content, ok := strings.Cut(strings.TrimPrefix(s, "%"), ": ")
+	if !ok || !strings.HasPrefix(s, "%") {
+		return nil, false
+	}
+
+	// the facility may itself contain hyphens, e.g. %C4K_EBM-4-HOSTFLAPPING
+	parts := strings.Split(tag, "-")
+	for i := len(parts) - 2; i > 0; i-- {
+		if sev := parts[i]; len(sev) == 1 && '0' <= sev[0] && sev[0] <= '7' {
+			m.MsgID = cropString(strings.Join(parts[i+1:], "-"), 32)
+			m.Content = content
+			return append(params, "deviceFacility", strings.Join(parts[:i], "-"), "deviceSeverity", sev), true
+		}
+	}
+	return nil, false
+}
+
+func isCiscoTimestamp(s string, m *Message) bool {
+	i := strings.Index(s, ": ")
+	if i < 0 {
+		return false
+	}
+	_, _, ok := parseCiscoTimestamp(s[:i], m.Time)
+	return ok
+}
+
+// parseCiscoTimestamp parses a timestamp such as "Mar  1 18:46:11.123 UTC" or, as ASA
+// sends, "Mar 01 2024 12:00:00 asa01", where the hostname follows the timestamp.
+func parseCiscoTimestamp(v string, now time.Time) (ts time.Time, host string, ok bool) {
+	f := strings.Fields(v)
+	if len(f) < 3 {
+		return ts, "", false
+	}
+
+	var err error
+	if len(f) > 3 && len(f[2]) == 4 && leadingDigits(f[2]) == 4 {
+		ts, err = time.Parse("Jan 2 2006 15:04:05", strings.Join(f[:4], " "))
+		f = f[4:]
+	} else {
+		ts, err = time.Parse("Jan 2 15:04:05", strings.Join(f[:3], " "))
+		ts = ts.AddDate(now.Year(), 0, 0)
+		f = f[3:]
+	}
+	if err != nil {
+		return ts, "", false
+	}
+
+	for _, w := range f {
+		switch {
+		case isZoneName(w):
+		case host == "":
+			host = w
+		default:
+			return ts, "", false
+		}
+	}
+	return ts, host, true
+}
+
+// isZoneName detects abbreviations such as UTC and CEST.
+func isZoneName(w string) bool {
+	if len(w) < 1 || len(w) > 5 {
+		return false
+	}
+	for _, c := range w {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseJuniperMessage parses an RFC 3164 message whose content begins with a Junos tag,
+// which is in capitals with at least one underscore, e.g. "UI_COMMIT:".
+func parseJuniperMessage(m *Message, pkt []byte, t time.Time) ([]string, bool) {
+	p, err := parseMessageAt(pkt, t)
+	if err != nil || p.Application == "" {
+		return nil, false
+	}
+
+	tag, content, _ := strings.Cut(strings.TrimLeft(p.Content, ": "), ":")
+	if !strings.Contains(tag, "_") || strings.Trim(tag, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_") != "" {
+		return nil, false
+	}
+
+	*m = *p
+	m.MsgID = cropString(tag, 32)
+	m.Content = trimLeftSpace(content)
+	return nil, true
+}
+
+func leadingDigits(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,58 @@
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestQuirksParser(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		in, host, msgID, content, data string
+		ts                             time.Time
+	}{
+		{"<189>123: router1: *Mar  1 18:46:11.123 UTC: %SYS-5-CONFIG_I: Configured from console",
+			"router1", "CONFIG_I", "Configured from console",
+			`[quirks@32473 sequence="123" marker="*" deviceFacility="SYS" deviceSeverity="5"]`,
+			time.Date(2024, 3, 1, 18, 46, 11, 123e6, time.UTC)},
+		{"<189>45: .Mar  1 18:46:11: %LINEPROTO-5-UPDOWN: Line protocol on Interface Gi0/1, changed state to up",
+			"", "UPDOWN", "Line protocol on Interface Gi0/1, changed state to up",
+			`[quirks@32473 sequence="45" marker="." deviceFacility="LINEPROTO" deviceSeverity="5"]`,
+			time.Date(2024, 3, 1, 18, 46, 11, 0, time.UTC)},
+		{"<166>Mar 01 2023 12:00:00 asa01 : %ASA-6-302013: Built outbound TCP connection",
+			"asa01", "302013", "Built outbound TCP connection",
+			`[quirks@32473 deviceFacility="ASA" deviceSeverity="6"]`,
+			time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{"<188>10.1.2.3: %C4K_EBM-4-HOSTFLAPPING: Host is flapping",
+			"10.1.2.3", "HOSTFLAPPING", "Host is flapping",
+			`[quirks@32473 deviceFacility="C4K_EBM" deviceSeverity="4"]`, now},
+		{"<28>Mar  1 12:00:00 router1 mgd[1234]: UI_COMMIT: User 'root' requested 'commit'",
+			"router1", "UI_COMMIT", "User 'root' requested 'commit'", "",
+			time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		m, err := QuirksParser{}.Parse([]byte(c.in), now)
+		expect.Error(err).Info(c.in).ToBeNil(t)
+		expect.String(m.Hostname).Info(c.in).ToBe(t, c.host)
+		expect.String(m.MsgID).Info(c.in).ToBe(t, c.msgID)
+		expect.String(m.Content).Info(c.in).ToBe(t, c.content)
+		expect.String(m.Data).Info(c.in).ToBe(t, c.data)
+		expect.Any(m.Timestamp).Info(c.in).ToBe(t, c.ts)
+	}
+}
+
+func TestQuirksParser_other(t *testing.T) {
+	for _, in := range []string{
+		"<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8",
+		"<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - BOM'su root' failed",
+		"<189>123: %NOT A TAG: hello",
+		"<ab>123: %SYS-5-CONFIG_I: bad priority",
+	} {
+		m, err := QuirksParser{}.Parse([]byte(in), time.Now())
+		expect.Error(err).Info(in).ToBeNil(t)
+		expect.Any(m).Info(in).ToBeNil(t)
+	}
+}
@@ -0,0 +1,245 @@
+package syslog
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RateLimit configures per-source rate limiting; see [Server.SetRateLimit].
+type RateLimit struct {
+	// Rate is the sustained number of messages per second accepted from each source.
+	Rate float64
+
+	// Burst is the number of messages a source may send in quick succession before the
+	// sustained rate applies.
+	Burst int
+
+	// ByHostname identifies sources by the hostname in each message instead of the IP
+	// address it came from. This is useful behind relays, but means that every message
+	// is parsed before it can be discarded.
+	ByHostname bool
+
+	// Summary, if not zero, is how often a warning message is injected for each source
+	// that has exceeded its rate, saying how many of its messages were discarded.
+	Summary time.Duration
+
+	// Backfill, if not nil, is a separate limit for messages whose timestamps are more than
+	// BackfillAge old when they arrive, such as those replayed by a device from its buffer
+	// after an outage (see [OlderThan]). Usually it is gentler, so that the backlog drains
+	// steadily without starving real-time messages. Only its Rate and Burst are used.
+	// Because the timestamp is needed, every message is parsed before it can be discarded.
+	Backfill    *RateLimit
+	BackfillAge time.Duration
+}
+
+// rateLimiter is a token bucket for each source. A nil rateLimiter allows everything.
+type rateLimiter struct {
+	RateLimit
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	dropped uint64 // since the last summary
+	rate    float64
+	burst   int
+}
+
+// maxRateLimitSources limits how many sources are remembered; beyond this, one is forgotten
+// for each new source (see [rateLimiter.evict]).
+const maxRateLimitSources = 64 * 1024
+
+// evictionSample is how many buckets are examined to choose the one to forget.
+const evictionSample = 16
+
+// SetRateLimit limits the rate at which messages are accepted from each source, using
+// a token bucket for each, so that one chatty device cannot drown everything else.
+// Messages beyond the limit are discarded and counted (see [Server.RateLimited]). If
+// rl.Summary is set, this starts a goroutine that injects the summaries; it stops when the
+// server is shut down. This must be called before calling [Server.Listen]. It panics
+// unless Rate is positive and Burst is at least 1, as would otherwise discard everything.
+func (s *Server) SetRateLimit(rl RateLimit) {
+	if s.shutDown.Load() {
+		panic("Server is already shut down")
+	}
+	if !rl.valid() || (rl.Backfill != nil && !rl.Backfill.valid()) {
+		panic("RateLimit needs a positive Rate and a Burst of at least 1")
+	}
+
+	s.limiter = &rateLimiter{RateLimit: rl, buckets: make(map[string]*tokenBucket)}
+	if rl.Summary > 0 {
+		s.startRateLimitSummaries(rl.Summary)
+	}
+}
+
+// valid is true if a limit accepts some messages.
+func (rl RateLimit) valid() bool {
+	return rl.Rate > 0 && rl.Burst >= 1
+}
+
+// RateLimited returns the number of messages discarded because their source exceeded its
+// rate limit. See [Server.SetRateLimit].
+func (s *Server) RateLimited() uint64 {
+	return s.rateLimited.Load()
+}
+
+// needsParsing is true if messages must be parsed before the limit can be applied.
+func (rl *rateLimiter) needsParsing() bool {
+	return rl.ByHostname || rl.Backfill != nil
+}
+
+// allowMessage applies the rate limit to a parsed message. Backfilled messages have their
+// own buckets, so they are counted separately.
+func (s *Server) allowMessage(m *Message, t time.Time) bool {
+	key := sourceIP(m.Source)
+	if s.limiter.ByHostname {
+		key = m.Hostname
+	}
+
+	if b := s.limiter.Backfill; b != nil && key != "" && OlderThan(s.limiter.BackfillAge)(m) {
+		return s.allowSourceOf(m.Source, key+" (backfill)", t, m.Size, *b)
+	}
+	return s.allowSourceOf(m.Source, key, t, m.Size, s.limiter.RateLimit)
+}
+
+// allowSourceOf applies a rate limit to a packet from a source identified by key. Sources
+// without a key, such as local Unix sockets, are not limited.
+func (s *Server) allowSourceOf(addr net.Addr, key string, t time.Time, size int, limit RateLimit) bool {
+	if key == "" || s.limiter.allow(key, t, limit) {
+		return true
+	}
+	s.rateLimited.Add(1)
+	s.audit.record(&Message{Time: t, Source: addr, Size: size}, verdictRateLimited, nil)
+	return false
+}
+
+func (rl *rateLimiter) allow(key string, t time.Time, limit RateLimit) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	b := rl.buckets[key]
+	if b == nil {
+		if len(rl.buckets) >= maxRateLimitSources {
+			rl.evict(t)
+		}
+		b = &tokenBucket{tokens: float64(limit.Burst), updated: t, rate: limit.Rate, burst: limit.Burst}
+		rl.buckets[key] = b
+	}
+
+	b.refill(t)
+	if b.tokens < 1 {
+		b.dropped++
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *tokenBucket) refill(t time.Time) {
+	if elapsed := t.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = min(float64(b.burst), b.tokens+elapsed*b.rate)
+		b.updated = t
+	}
+}
+
+// evict forgets one bucket, chosen from a few at random (map iteration order is random), so
+// the cost does not grow with the number of sources. An idle bucket, one that has refilled
+// completely and has nothing to report, is preferred; otherwise the least recently used of
+// those examined goes, along with its count of dropped messages.
+func (rl *rateLimiter) evict(t time.Time) {
+	var victim string
+	var oldest time.Time
+	n := 0
+	for key, b := range rl.buckets {
+		if b.dropped == 0 && b.tokens+t.Sub(b.updated).Seconds()*b.rate >= float64(b.burst) {
+			victim = key
+			break
+		}
+		if n == 0 || b.updated.Before(oldest) {
+			victim, oldest = key, b.updated
+		}
+		if n++; n == evictionSample {
+			break
+		}
+	}
+	delete(rl.buckets, victim)
+}
+
+// takeDropped gets and resets the number of messages dropped from each source.
+func (rl *rateLimiter) takeDropped() map[string]uint64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	dropped := make(map[string]uint64)
+	for key, b := range rl.buckets {
+		if b.dropped > 0 {
+			dropped[key] = b.dropped
+			b.dropped = 0
+		}
+	}
+	return dropped
+}
+
+func (s *Server) startRateLimitSummaries(interval time.Duration) {
+	hostname, _ := os.Hostname()
+
+	s.receivers.Add(1)
+	go func() {
+		defer s.receivers.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.pushRateLimitSummaries(hostname)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Server) pushRateLimitSummaries(hostname string) {
+	dropped := s.limiter.takeDropped()
+	keys := make([]string, 0, len(dropped))
+	for key := range dropped {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	t := s.clock()
+	for _, key := range keys {
+		s.push(newRateLimitSummary(hostname, t, key, dropped[key]))
+	}
+}
+
+func newRateLimitSummary(hostname string, t time.Time, source string, dropped uint64) *Message {
+	return &Message{
+		Time:        t,
+		Facility:    Syslog,
+		Severity:    Warning,
+		Version:     1,
+		Timestamp:   t,
+		Hostname:    hostname,
+		Application: "syslog",
+		Content:     fmt.Sprintf("rate limit exceeded: %d messages from %s were discarded", dropped, source),
+	}
+}
+
+// sourceIP identifies the sender of a packet by its IP address, or returns blank if it
+// has none.
+func sourceIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.String()
+	case *net.TCPAddr:
+		return a.IP.String()
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+package syslog
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestServer_SetRateLimit(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	summaries := make(chan *Message, 10)
+	s := NewServer(WithClock(func() time.Time { return now }))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			summaries <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+	s.SetRateLimit(RateLimit{Rate: 1, Burst: 2})
+
+	pkt := []byte("<13>1 - host app - - - hello")
+	chatty := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 514}
+	quiet := &net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: 514}
+	receive := func(addr net.Addr) bool {
+		return s.receive(pkt, addr, AcceptEverything) != nil
+	}
+
+	expect.Bool(receive(chatty)).ToBeTrue(t)
+	expect.Bool(receive(chatty)).ToBeTrue(t)
+	expect.Bool(receive(chatty)).ToBeFalse(t)
+	expect.Bool(receive(quiet)).ToBeTrue(t)
+	expect.Bool(receive(&net.UnixAddr{Name: "/dev/log"})).ToBeTrue(t)
+
+	now = now.Add(time.Second)
+	expect.Bool(receive(chatty)).ToBeTrue(t)
+	expect.Bool(receive(chatty)).ToBeFalse(t)
+	expect.Number(s.RateLimited()).ToBe(t, 2)
+
+	s.pushRateLimitSummaries("collector")
+	m := <-summaries
+	expect.String(m.Content).ToBe(t, "rate limit exceeded: 2 messages from 10.0.0.1 were discarded")
+	expect.Any(m.Severity).ToBe(t, Warning)
+}
+
+func TestServer_SetRateLimit_byHostname(t *testing.T) {
+	s := NewServer()
+	defer s.Shutdown()
+	s.SetRateLimit(RateLimit{Rate: 0.001, Burst: 1, ByHostname: true})
+
+	relay := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 514}
+	expect.Bool(s.receive([]byte("<13>1 - host1 app - - - a"), relay, AcceptEverything) != nil).ToBeTrue(t)
+	expect.Bool(s.receive([]byte("<13>1 - host2 app - - - b"), relay, AcceptEverything) != nil).ToBeTrue(t)
+	expect.Bool(s.receive([]byte("<13>1 - host1 app - - - c"), relay, AcceptEverything) != nil).ToBeFalse(t)
+}
+
+func TestServer_SetRateLimit_backfill(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewServer(WithClock(func() time.Time { return now }))
+	defer s.Shutdown()
+	s.SetRateLimit(RateLimit{Rate: 0.001, Burst: 1, Backfill: &RateLimit{Rate: 0.001, Burst: 2}, BackfillAge: time.Hour})
+
+	device := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 514}
+	recent := []byte("<13>1 2024-01-01T11:59:00Z host app - - - recent")
+	old := []byte("<13>1 2024-01-01T09:00:00Z host app - - - old")
+
+	expect.Bool(s.receive(recent, device, AcceptEverything) != nil).ToBeTrue(t)
+	expect.Bool(s.receive(recent, device, AcceptEverything) != nil).ToBeFalse(t)
+	expect.Bool(s.receive(old, device, AcceptEverything) != nil).ToBeTrue(t)
+	expect.Bool(s.receive(old, device, AcceptEverything) != nil).ToBeTrue(t)
+	expect.Bool(s.receive(old, device, AcceptEverything) != nil).ToBeFalse(t)
+
+	dropped := s.limiter.takeDropped()
+	expect.Number(dropped["10.0.0.1"]).ToBe(t, 1)
+	expect.Number(dropped["10.0.0.1 (backfill)"]).ToBe(t, 1)
+}
+
+func TestServer_SetRateLimit_invalid(t *testing.T) {
+	s := NewServer()
+	defer s.Shutdown()
+
+	for _, rl := range []RateLimit{
+		{Rate: 1},
+		{Burst: 10},
+		{Rate: -1, Burst: 10},
+		{Rate: 1, Burst: 1, Backfill: &RateLimit{Rate: 1}},
+	} {
+		panicked := func() (panicked bool) {
+			defer func() { panicked = recover() != nil }()
+			s.SetRateLimit(rl)
+			return false
+		}()
+		expect.Bool(panicked).ToBeTrue(t)
+	}
+	expect.Bool(s.limiter == nil).ToBeTrue(t)
+}
+
+func TestRateLimiter_evict(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	rl := &rateLimiter{buckets: make(map[string]*tokenBucket)}
+	limit := RateLimit{Rate: 1, Burst: 1}
+
+	for i := 1; i < maxRateLimitSources; i++ {
+		rl.allow(strconv.Itoa(i), now, limit)
+	}
+	expect.Bool(rl.allow("noisy", now.Add(time.Hour), limit)).ToBeTrue(t)
+	expect.Bool(rl.allow("noisy", now.Add(time.Hour), limit)).ToBeFalse(t)
+
+	// the idle sources go first
+	for i := 0; i < 1000; i++ {
+		rl.allow("new"+strconv.Itoa(i), now.Add(2*time.Hour), limit)
+	}
+
+	expect.Map(rl.buckets).ToHaveLength(t, maxRateLimitSources)
+	expect.Number(rl.buckets["noisy"].dropped).ToBe(t, 1)
+}
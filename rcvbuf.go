@@ -0,0 +1,53 @@
+package syslog
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// tuneConn applies the socket options of the server to a datagram socket.
+func (s *Server) tuneConn(c net.PacketConn) {
+	if s.receiveBuffer <= 0 {
+		return
+	}
+
+	rb, ok := c.(interface{ SetReadBuffer(int) error })
+	if !ok || checkErr(rb.SetReadBuffer(s.receiveBuffer), "set receive buffer of", c.LocalAddr().String()) {
+		return
+	}
+
+	// the kernel silently limits the size, e.g. to net.core.rmem_max on Linux
+	if size, err := receiveBufferSize(c); err == nil && size < s.receiveBuffer {
+		s.logger.Printf("Listener %s: receive buffer is %d bytes, less than the %d requested\n",
+			c.LocalAddr(), size, s.receiveBuffer)
+	}
+}
+
+// ReceiveBuffers gets the effective size of the kernel receive buffer (SO_RCVBUF) of each
+// datagram socket, keyed by its local address. Sockets whose size cannot be determined,
+// e.g. on platforms that do not support it, are omitted. See [WithReceiveBuffer].
+func (s *Server) ReceiveBuffers() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sizes := make(map[string]int, len(s.conns))
+	for _, c := range s.conns {
+		if size, err := receiveBufferSize(c); err == nil {
+			sizes[c.LocalAddr().String()] = size
+		}
+	}
+	return sizes
+}
+
+func receiveBufferSize(c net.PacketConn) (int, error) {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	return getReceiveBuffer(rc)
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package syslog
+
+import (
+	"errors"
+	"syscall"
+)
+
+func getReceiveBuffer(syscall.RawConn) (int, error) {
+	return 0, errors.ErrUnsupported
+}
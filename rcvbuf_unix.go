@@ -0,0 +1,17 @@
+//go:build unix
+
+package syslog
+
+import "syscall"
+
+func getReceiveBuffer(c syscall.RawConn) (int, error) {
+	var size int
+	var err error
+	cerr := c.Control(func(fd uintptr) {
+		size, err = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+	})
+	if cerr != nil {
+		return 0, cerr
+	}
+	return size, err
+}
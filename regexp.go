@@ -0,0 +1,119 @@
+package syslog
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRegexpMaxInput is the number of bytes of each message that a [RegexpFilter]
+// examines if no other limit is given.
+const DefaultRegexpMaxInput = 8 * 1024
+
+// maxRegexpInstructions limits the size of the compiled program of a [RegexpFilter], which
+// bounds the work done per byte of input; very large programs come from large counted
+// repetitions such as "(error|warning|fatal){1000}".
+const maxRegexpInstructions = 20000
+
+// RegexpFilter matches messages against a regular expression supplied by an operator,
+// guarding against patterns and content that would use excessive CPU. Go regular
+// expressions use RE2 syntax and run in time linear in the input, so backreferences and
+// look-around are rejected when the pattern is compiled; in addition, overly large patterns
+// are rejected and only the first part of each message is examined. Statistics about the
+// matching are kept (see [RegexpFilter.Stats]). A RegexpFilter is safe for concurrent use.
+type RegexpFilter struct {
+	re        *regexp.Regexp
+	maxInput  int
+	calls     atomic.Uint64
+	matches   atomic.Uint64
+	truncated atomic.Uint64
+	total     atomic.Int64 // nanoseconds
+	slowest   atomic.Int64 // nanoseconds
+}
+
+// RegexpStats describes the work done by a [RegexpFilter].
+type RegexpStats struct {
+	Calls     uint64        // number of strings matched
+	Matches   uint64        // number of strings that matched
+	Truncated uint64        // number of strings longer than the input limit
+	Total     time.Duration // total time spent matching
+	Slowest   time.Duration // longest time spent on a single string
+}
+
+// CompileRegexpFilter compiles a pattern for use as a filter. Only the first maxInput bytes
+// of each string are matched; if maxInput is zero or less, [DefaultRegexpMaxInput] applies.
+// An error is returned if the pattern is invalid, uses syntax that RE2 does not support, or
+// is too complex.
+func CompileRegexpFilter(pattern string, maxInput int) (*RegexpFilter, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: only RE2 regular expression syntax is supported: %w", pattern, err)
+	}
+
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		return nil, err
+	}
+	if len(prog.Inst) > maxRegexpInstructions {
+		return nil, fmt.Errorf("%s: regular expression is too complex", cropString(pattern, 50))
+	}
+
+	if maxInput <= 0 {
+		maxInput = DefaultRegexpMaxInput
+	}
+	return &RegexpFilter{re: re, maxInput: maxInput}, nil
+}
+
+// Match reports whether the first part of s matches the regular expression.
+func (f *RegexpFilter) Match(s string) bool {
+	if len(s) > f.maxInput {
+		s = s[:f.maxInput]
+		f.truncated.Add(1)
+	}
+
+	started := time.Now()
+	matched := f.re.MatchString(s)
+	elapsed := int64(time.Since(started))
+
+	f.calls.Add(1)
+	if matched {
+		f.matches.Add(1)
+	}
+	f.total.Add(elapsed)
+	for {
+		slowest := f.slowest.Load()
+		if elapsed <= slowest || f.slowest.CompareAndSwap(slowest, elapsed) {
+			break
+		}
+	}
+	return matched
+}
+
+// Filter gets a [Filter] that accepts the messages whose content matches.
+func (f *RegexpFilter) Filter() Filter {
+	return func(m *Message) bool {
+		return f.Match(m.Content)
+	}
+}
+
+// Stats gets the statistics accumulated so far.
+func (f *RegexpFilter) Stats() RegexpStats {
+	return RegexpStats{
+		Calls:     f.calls.Load(),
+		Matches:   f.matches.Load(),
+		Truncated: f.truncated.Load(),
+		Total:     time.Duration(f.total.Load()),
+		Slowest:   time.Duration(f.slowest.Load()),
+	}
+}
+
+// String returns the source text of the regular expression.
+func (f *RegexpFilter) String() string {
+	return f.re.String()
+}
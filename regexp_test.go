@@ -0,0 +1,35 @@
+package syslog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestCompileRegexpFilter(t *testing.T) {
+	_, err := CompileRegexpFilter(`(a)\1`, 0)
+	expect.Error(err).ToContain(t, "only RE2 regular expression syntax is supported")
+
+	_, err = CompileRegexpFilter(`foo(?=bar)`, 0)
+	expect.Error(err).ToContain(t, "only RE2 regular expression syntax is supported")
+
+	_, err = CompileRegexpFilter(`(error|warning|fatal){1000}`, 0)
+	expect.Error(err).ToContain(t, "too complex")
+
+	f, err := CompileRegexpFilter(`fail(ed|ure)`, 16)
+	expect.Error(err).ToBeNil(t)
+	expect.String(f.String()).ToBe(t, `fail(ed|ure)`)
+
+	accept := f.Filter()
+	expect.Bool(accept(&Message{Content: "login failed"})).ToBeTrue(t)
+	expect.Bool(accept(&Message{Content: "ok"})).ToBeFalse(t)
+	// the match is beyond the input limit
+	expect.Bool(accept(&Message{Content: strings.Repeat(" ", 16) + "failure"})).ToBeFalse(t)
+
+	stats := f.Stats()
+	expect.Number(stats.Calls).ToBe(t, 3)
+	expect.Number(stats.Matches).ToBe(t, 1)
+	expect.Number(stats.Truncated).ToBe(t, 1)
+	expect.Bool(stats.Slowest <= stats.Total).ToBeTrue(t)
+}
@@ -0,0 +1,158 @@
+package syslog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+)
+
+// ListenRELP starts a goroutine that receives syslog messages using the Reliable Event
+// Logging Protocol (RELP), as used by the rsyslog omrelp module, on a specified host:port
+// address. If cfg is not nil, connections use TLS.
+//
+// Each message is acknowledged only after it has been queued for the handlers, so senders
+// get at-least-once delivery. Only the messages matching accept are processed; messages
+// rejected by accept or by the priority filter (see [Server.SetPriorityFilter]) are also
+// acknowledged. Messages that are lost instead, because they are invalid, too long, denied
+// by the source ACL, rate limited or dropped when the queue is full, are answered with an
+// error so that the sender can keep them. A queued message that is later evicted by
+// [OverflowDropOldest] has already been acknowledged.
+func (s *Server) ListenRELP(addr string, cfg *tls.Config, accept Filter) error {
+	l, err := s.listenStream("relp", "tcp", addr, cfg)
+	if err != nil {
+		return err
+	}
+
+	go s.acceptStreams(l, func(c net.Conn) {
+		s.relpReceiver(c, accept)
+	})
+	return nil
+}
+
+const relpOffers = "\nrelp_version=0\nrelp_software=github.com/rickb777/syslog\ncommands=syslog"
+
+func (s *Server) relpReceiver(c net.Conn, acceptFunc Filter) {
+	peer := tlsPeer(c)
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	for {
+		s.setIdleDeadline(c)
+		txnr, command, data, err := readRELPFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrDeadlineExceeded) && !s.shutDown.Load() {
+				s.logger.Println("RELP error:", c.RemoteAddr(), err)
+			}
+			return
+		}
+
+		switch command {
+		case "open":
+			err = writeRELPResponse(w, txnr, "200 OK"+relpOffers)
+		case "syslog":
+			if s.enqueue(data, c.RemoteAddr(), peer, acceptFunc) {
+				err = writeRELPResponse(w, txnr, "200 OK")
+			} else {
+				err = writeRELPResponse(w, txnr, "500 message not accepted")
+			}
+		case "close":
+			_ = writeRELPResponse(w, txnr, "")
+			return
+		default:
+			err = writeRELPResponse(w, txnr, "500 unsupported command "+command)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// RELP limits the transaction number to 9 digits and the command to 32 characters.
+const (
+	maxRELPTxnrLength    = 9
+	maxRELPCommandLength = 32
+)
+
+// readRELPFrame reads one RELP frame, i.e. TXNR SP COMMAND SP DATALEN [SP DATA] LF.
+func readRELPFrame(r *bufio.Reader) (txnr int, command string, data []byte, err error) {
+	header, err := readRELPToken(r, maxRELPTxnrLength)
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	txnr, err = strconv.Atoi(header)
+	if err != nil || txnr < 0 {
+		return 0, "", nil, fmt.Errorf("%q: invalid RELP transaction number", cropString(header, 10))
+	}
+
+	command, err = readRELPToken(r, maxRELPCommandLength)
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	length := 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, "", nil, err
+		}
+		if b == '\n' {
+			if length > 0 {
+				return 0, "", nil, errors.New("RELP frame is missing its data")
+			}
+			return txnr, command, nil, nil
+		}
+		if b == ' ' {
+			break
+		}
+		if b < '0' || b > '9' || length*10+int(b-'0') > maxFrameLength {
+			return 0, "", nil, errors.New("invalid RELP data length")
+		}
+		length = length*10 + int(b-'0')
+	}
+
+	data = make([]byte, length+1)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return 0, "", nil, err
+	}
+	if data[length] != '\n' {
+		return 0, "", nil, errors.New("RELP frame is missing its trailer")
+	}
+	return txnr, command, data[:length], nil
+}
+
+// readRELPToken reads a token of at most limit bytes, up to and excluding a space.
+func readRELPToken(r *bufio.Reader, limit int) (string, error) {
+	var token []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == ' ' {
+			return string(token), nil
+		}
+		if len(token) == limit {
+			return "", fmt.Errorf("%q: RELP header field is too long", cropString(string(token), 10))
+		}
+		token = append(token, b)
+	}
+}
+
+func writeRELPResponse(w *bufio.Writer, txnr int, data string) error {
+	var err error
+	if data == "" {
+		_, err = fmt.Fprintf(w, "%d rsp 0\n", txnr)
+	} else {
+		_, err = fmt.Fprintf(w, "%d rsp %d %s\n", txnr, len(data), data)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,110 @@
+package syslog
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestListenRELP(t *testing.T) {
+	received := make(chan *Message, 1)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	expect.Error(s.ListenRELP("127.0.0.1:0", nil, AcceptEverything)).ToBeNil(t)
+
+	c, err := net.Dial("tcp", s.listeners[0].Addr().String())
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+	r := bufio.NewReader(c)
+
+	offers := "relp_version=0\nrelp_software=test\ncommands=syslog"
+	fmt.Fprintf(c, "1 open %d %s\n", len(offers), offers)
+	txnr, command, data, err := readRELPFrame(r)
+	expect.Number(txnr, err).ToBe(t, 1)
+	expect.String(command).ToBe(t, "rsp")
+	expect.String(relpStatus(data)).ToBe(t, "200")
+
+	msg := "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - hello"
+	fmt.Fprintf(c, "2 syslog %d %s\n", len(msg), msg)
+	txnr, _, data, err = readRELPFrame(r)
+	expect.Number(txnr, err).ToBe(t, 2)
+	expect.String(string(data)).ToBe(t, "200 OK")
+
+	m := <-received
+	expect.String(m.Content).ToBe(t, "hello")
+
+	fmt.Fprintf(c, "3 close 0\n")
+	txnr, _, data, err = readRELPFrame(r)
+	expect.Number(txnr, err).ToBe(t, 3)
+	expect.Number(len(data)).ToBe(t, 0)
+}
+
+func TestListenRELP_notQueued(t *testing.T) {
+	started := make(chan struct{}, 1)
+	gate := make(chan struct{})
+	s := NewServer(WithQueueLength(1), WithOverflowPolicy(OverflowDropNewest), WithStreamBackpressure(false))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			started <- struct{}{}
+			<-gate
+		}
+		return m
+	}))
+	defer s.Shutdown()
+	defer close(gate)
+
+	accept := func(m *Message) bool { return m.Hostname != "unwanted" }
+	expect.Error(s.ListenRELP("127.0.0.1:0", nil, accept)).ToBeNil(t)
+
+	c, err := net.Dial("tcp", s.listeners[0].Addr().String())
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+	r := bufio.NewReader(c)
+
+	send := func(txnr int, msg string) string {
+		fmt.Fprintf(c, "%d syslog %d %s\n", txnr, len(msg), msg)
+		_, _, data, err := readRELPFrame(r)
+		expect.Error(err).ToBeNil(t)
+		return relpStatus(data)
+	}
+
+	expect.String(send(1, "<1x> invalid priority")).ToBe(t, "500")
+	expect.String(send(2, "<13>1 - unwanted app - - - rejected")).ToBe(t, "200")
+	expect.String(send(3, "<13>1 - host app - - - handled")).ToBe(t, "200")
+	<-started
+	expect.String(send(4, "<13>1 - host app - - - queued")).ToBe(t, "200")
+	expect.String(send(5, "<13>1 - host app - - - dropped")).ToBe(t, "500")
+	expect.Number(s.Dropped()).ToBe(t, 1)
+}
+
+// relpStatus extracts the status code from RELP response data.
+func relpStatus(data []byte) string {
+	status, _, _ := strings.Cut(string(data), " ")
+	return status
+}
+
+func TestReadRELPFrame_limits(t *testing.T) {
+	for in, want := range map[string]string{
+		"1234567890 syslog 1 x\n":               "RELP header field is too long",
+		"1 " + strings.Repeat("c", 33) + " 0\n": "RELP header field is too long",
+		"1 syslog 65537 x\n":                    "invalid RELP data length",
+		"x syslog 1 x\n":                        "invalid RELP transaction number",
+	} {
+		_, _, _, err := readRELPFrame(bufio.NewReader(strings.NewReader(in)))
+		expect.Error(err).Info(in).ToContain(t, want)
+	}
+
+	_, _, data, err := readRELPFrame(bufio.NewReader(strings.NewReader("123456789 syslog 65536 " + strings.Repeat("x", 65536) + "\n")))
+	expect.Number(len(data), err).ToBe(t, 65536)
+}
@@ -0,0 +1,244 @@
+package syslog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const replicaAck = "ok\n"
+
+// The wait after the peer fails doubles from replicaRetryMin to replicaRetryMax.
+const (
+	replicaRetryMin = time.Second
+	replicaRetryMax = time.Minute
+)
+
+// ReplicationHandler is a [Handler] that streams every message to a peer collector, which
+// receives them using [Server.ListenReplica]. Each message is acknowledged by the peer; a
+// message that is not acknowledged is resent once after reconnecting, so the two collectors
+// hold identical data. Messages are always passed on to subsequent handlers.
+//
+// If the peer cannot be reached, messages are not replicated for a while, so that a dead
+// peer does not hold up the pipeline: a second at first, doubling after each further
+// failure up to a minute. The messages skipped are counted (see [ReplicationHandler.Dropped]).
+//
+// Messages are sent in the latest JSON schema (see [Message.AppendJSON]), so the peer gets
+// the receive time, source, sequence number and annotations as well as the syslog fields.
+// Some things are lost: the Raw bytes of messages received with lazy parsing, which are
+// parsed first; the type of the Source, which arrives as a placeholder holding its string
+// form; and the TLS peer identity, which the peer replaces with that of this collector.
+// A message whose encoding is longer than 64 KiB cannot be replicated; it is logged and
+// skipped. It is safe for concurrent use.
+type ReplicationHandler struct {
+	addr        string
+	cfg         *tls.Config
+	timeout     time.Duration
+	faults      FaultPolicy
+	mu          sync.Mutex // guards the fields below
+	conn        net.Conn
+	r           *bufio.Reader
+	msg         []byte
+	buf         []byte
+	backoff     time.Duration // the current wait after a failure
+	nextAttempt time.Time     // when the peer may be tried again after a failure
+	dropped     atomic.Uint64
+}
+
+// NewReplicationHandler creates a handler that replicates messages to the peer at addr,
+// which is a TCP host:port. If cfg is not nil, the connection uses TLS.
+func NewReplicationHandler(addr string, cfg *tls.Config) *ReplicationHandler {
+	return &ReplicationHandler{
+		addr:    addr,
+		cfg:     cfg,
+		timeout: 10 * time.Second,
+	}
+}
+
+// SetTimeout changes how long to wait for the peer to connect or acknowledge a message.
+// The default is 10 seconds.
+func (h *ReplicationHandler) SetTimeout(timeout time.Duration) {
+	h.timeout = timeout
+}
+
+// SetFaultPolicy injects faults into the writes to the peer, so that the behaviour of a
+// pipeline under a failing network can be tested. Use nil (the default) for normal operation.
+func (h *ReplicationHandler) SetFaultPolicy(policy FaultPolicy) {
+	h.faults = policy
+}
+
+// Dropped returns the number of messages that were not replicated because the peer could
+// not be reached.
+func (h *ReplicationHandler) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+func (h *ReplicationHandler) Handle(m *Message) *Message {
+	if m == nil {
+		h.close()
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Now().Before(h.nextAttempt) {
+		h.dropped.Add(1)
+		return m
+	}
+
+	var err error
+	h.msg, err = m.AppendJSON(h.msg[:0], LatestJSONSchema)
+	if err == nil && len(h.msg) > maxFrameLength {
+		err = fmt.Errorf("%d bytes: message is too large", len(h.msg))
+	}
+	if err != nil {
+		Logger.Println("replicate", h.addr, err)
+		return m
+	}
+	h.buf = appendOctetCounted(h.buf[:0], h.msg)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if h.conn == nil {
+			if err = h.connect(); err != nil {
+				break // the peer is down, so resending would not help
+			}
+		}
+		if err = h.send(); err == nil {
+			h.backoff = 0
+			return m
+		}
+		checkErr(h.closeConn(), "close", h.addr)
+	}
+
+	h.backoff = min(max(2*h.backoff, replicaRetryMin), replicaRetryMax)
+	h.nextAttempt = time.Now().Add(h.backoff)
+	h.dropped.Add(1)
+	Logger.Println("replicate", h.addr, err, "- retrying in", h.backoff)
+	return m
+}
+
+func (h *ReplicationHandler) send() error {
+	if err := h.conn.SetDeadline(time.Now().Add(h.timeout)); err != nil {
+		return err
+	}
+
+	if _, err := withFaults(h.conn, h.addr, h.faults).Write(h.buf); err != nil {
+		return err
+	}
+
+	ack, err := h.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if ack != replicaAck {
+		return fmt.Errorf("%q: unexpected acknowledgement", ack)
+	}
+	return nil
+}
+
+func (h *ReplicationHandler) connect() (err error) {
+	d := &net.Dialer{Timeout: h.timeout}
+	if h.cfg != nil {
+		h.conn, err = tls.DialWithDialer(d, "tcp", h.addr, h.cfg)
+	} else {
+		h.conn, err = d.Dial("tcp", h.addr)
+	}
+	if err != nil {
+		return err
+	}
+	h.r = bufio.NewReader(h.conn)
+	return nil
+}
+
+// Close closes the connection to the peer; it is re-opened if another message is handled.
+// It is safe to call more than once.
+func (h *ReplicationHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closeConn()
+}
+
+func (h *ReplicationHandler) closeConn() error {
+	if h.conn == nil {
+		return nil
+	}
+	err := h.conn.Close()
+	h.conn = nil
+	h.r = nil
+	return err
+}
+
+func (h *ReplicationHandler) close() {
+	checkErr(h.Close(), "close", h.addr)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// ListenReplica starts a goroutine that receives messages from a [ReplicationHandler] on
+// a peer collector. addr is a TCP host:port. If cfg is not nil, connections use TLS.
+// Each message is acknowledged after it has been queued for the handlers. The messages
+// have already been received by the peer, so they are queued as they are, keeping their
+// time, source and sequence number; only the ACL and accept apply.
+func (s *Server) ListenReplica(addr string, cfg *tls.Config, accept Filter) error {
+	l, err := s.listenStream("replica", "tcp", addr, cfg)
+	if err != nil {
+		return err
+	}
+
+	go s.acceptStreams(l, func(c net.Conn) {
+		s.replicaReceiver(c, accept)
+	})
+	return nil
+}
+
+func (s *Server) replicaReceiver(c net.Conn, acceptFunc Filter) {
+	peer := tlsPeer(c)
+	r := bufio.NewReader(c)
+	for {
+		s.setIdleDeadline(c)
+		frame, err := readOctetCounted(r)
+		if err != nil {
+			s.logReadError(c, err)
+			return
+		}
+
+		if m := s.receiveReplica(frame, c.RemoteAddr(), peer, acceptFunc); m != nil {
+			s.queue.put([]*Message{m}) // waits for room, and keeps the sequence number
+		}
+
+		if _, err = io.WriteString(c, replicaAck); err != nil {
+			return
+		}
+	}
+}
+
+// receiveReplica decodes a message sent by a peer, returning nil if it is not accepted.
+// The message gets the TLS peer identity of the peer, if any, because the identity in the
+// message cannot be verified.
+func (s *Server) receiveReplica(bs []byte, addr net.Addr, peer *PeerIdentity, acceptFunc Filter) *Message {
+	if !s.acl.allows(addr) {
+		s.denied.Add(1)
+		s.audit.record(&Message{Time: s.clock(), Source: addr, Size: len(bs)}, verdictDenied, nil)
+		return nil
+	}
+
+	m, _, err := ParseJSON(bs)
+	if err != nil {
+		s.logger.Println("Replica error:", addr, err)
+		s.audit.record(&Message{Time: s.clock(), Source: addr, Size: len(bs)}, verdictInvalid, nil)
+		return nil
+	}
+	m.TLSPeer = peer
+
+	if !acceptFunc(m) {
+		s.audit.record(m, verdictRejected, nil)
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,92 @@
+package syslog
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestReplicationHandler(t *testing.T) {
+	received := make(chan *Message, 1)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	expect.Error(s.ListenReplica("127.0.0.1:0", nil, AcceptEverything)).ToBeNil(t)
+
+	m := &Message{
+		Time:        time.Date(2023, 10, 26, 15, 30, 1, 0, time.UTC),
+		Source:      &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 514},
+		Sequence:    42,
+		Size:        123,
+		Facility:    User,
+		Severity:    Info,
+		Version:     1,
+		Timestamp:   time.Date(2023, 10, 26, 15, 30, 0, 0, time.UTC),
+		Hostname:    "myhost",
+		Application: "myapp",
+		Content:     "hello",
+		Annotations: map[string]string{"k": "v"},
+	}
+
+	h := NewReplicationHandler(s.listeners[0].Addr().String(), nil)
+	h.SetTimeout(time.Second)
+	expect.Any(h.Handle(m)).ToBe(t, m)
+
+	r := <-received
+	expect.String(r.Hostname).ToBe(t, "myhost")
+	expect.String(r.Content).ToBe(t, "hello")
+	expect.Any(r.Timestamp).ToBe(t, m.Timestamp)
+	expect.Any(r.Time).ToBe(t, m.Time)
+	expect.String(r.Source.String()).ToBe(t, "10.0.0.1:514")
+	expect.Number(r.Sequence).ToBe(t, 42)
+	expect.Number(r.Size).ToBe(t, 123)
+	expect.Map(r.Annotations).ToBe(t, m.Annotations)
+
+	h.Handle(nil)
+}
+
+func TestReplicationHandler_backoff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	expect.Error(err).ToBeNil(t)
+	addr := l.Addr().String()
+	l.Close() // nothing is listening
+
+	h := NewReplicationHandler(addr, nil)
+	h.SetTimeout(time.Second)
+	defer h.Close()
+
+	m := &Message{Hostname: "myhost", Content: "hello"}
+	expect.Any(h.Handle(m)).ToBe(t, m)
+	expect.Number(h.Dropped()).ToBe(t, 1)
+
+	// skipped without trying the peer
+	started := time.Now()
+	expect.Any(h.Handle(m)).ToBe(t, m)
+	expect.Number(h.Dropped()).ToBe(t, 2)
+	expect.Bool(time.Since(started) < replicaRetryMin).ToBeTrue(t)
+}
+
+func TestReceiveReplica_tlsPeer(t *testing.T) {
+	s := NewServer()
+	defer s.Shutdown()
+
+	claimed := &Message{Hostname: "myhost", Content: "hello", TLSPeer: &PeerIdentity{CommonName: "admin"}}
+	bs, err := claimed.AppendJSON(nil, LatestJSONSchema)
+	expect.Error(err).ToBeNil(t)
+
+	src := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}
+	m := s.receiveReplica(bs, src, nil, AcceptEverything)
+	expect.Any(m.TLSPeer).ToBeNil(t)
+
+	peer := &PeerIdentity{CommonName: "collector2"}
+	m = s.receiveReplica(bs, src, peer, AcceptEverything)
+	expect.String(m.TLSPeer.CommonName).ToBe(t, "collector2")
+}
@@ -0,0 +1,124 @@
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+)
+
+const (
+	minRestartDelay = 10 * time.Millisecond
+	maxRestartDelay = 5 * time.Second
+)
+
+// socketCheckInterval is how often an idle Unix datagram listener checks that its socket
+// file still exists.
+var socketCheckInterval = 5 * time.Second
+
+// restartConn handles a read error on c. After a transient error it pauses; otherwise it
+// re-opens the socket, retrying with exponential backoff. It returns the socket to read
+// from next, or nil if the server has shut down or the listener has given up.
+func (s *Server) restartConn(c net.PacketConn, err error, reopen func() (net.PacketConn, error), failures *int) net.PacketConn {
+	addr := c.LocalAddr()
+	if !isTransient(err) && reopen != nil {
+		c.Close() // release the address so that it can be bound again
+	}
+
+	for {
+		*failures++
+		if *failures > s.restartAttempts || (!isTransient(err) && reopen == nil) {
+			s.giveUp(addr, err)
+			return nil
+		}
+
+		s.logger.Printf("Listener %s: %v; retrying\n", addr, err)
+		if !s.pause(restartDelay(*failures)) {
+			return nil
+		}
+
+		if isTransient(err) {
+			return c
+		}
+
+		nc, rerr := reopen()
+		if rerr == nil {
+			s.tuneConn(nc)
+			return s.replaceConn(c, nc)
+		}
+		err = rerr
+	}
+}
+
+// giveUp reports that a listener has stopped because of err.
+func (s *Server) giveUp(addr net.Addr, err error) {
+	s.fail(fmt.Errorf("read %s: %w", addr, err))
+	if s.onListenerFailure != nil {
+		s.onListenerFailure(addr, err)
+	}
+}
+
+// pause waits for d, returning false if the server is shut down meanwhile.
+func (s *Server) pause(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// replaceConn puts nc in place of c, unless the server has shut down.
+func (s *Server) replaceConn(c, nc net.PacketConn) net.PacketConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutDown.Load() {
+		nc.Close()
+		return nil
+	}
+
+	if i := slices.Index(s.conns, c); i >= 0 {
+		s.conns[i] = nc
+	} else {
+		s.conns = append(s.conns, nc)
+	}
+	return nc
+}
+
+// restartDelay is the pause before the nth attempt to recover.
+func restartDelay(n int) time.Duration {
+	if n > 10 {
+		return maxRestartDelay
+	}
+	return min(minRestartDelay<<(n-1), maxRestartDelay)
+}
+
+// isTransient is true for errors after which a socket can carry on being used.
+func isTransient(err error) bool {
+	if transient, ok := transientErrno(err); ok {
+		return transient
+	}
+
+	var te interface{ Temporary() bool }
+	return errors.As(err, &te) && te.Temporary()
+}
+
+// watchedPath is the path of the socket file of a Unix datagram listener that can be
+// re-opened, or blank otherwise.
+func watchedPath(c net.PacketConn, reopen func() (net.PacketConn, error)) string {
+	if a, ok := c.LocalAddr().(*net.UnixAddr); ok && reopen != nil && a.Name != "" && a.Name[0] != '@' {
+		return a.Name
+	}
+	return ""
+}
+
+// idleDeadline is the read deadline for a listener that has nothing to flush.
+func idleDeadline(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	return time.Now().Add(socketCheckInterval)
+}
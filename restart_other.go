@@ -0,0 +1,7 @@
+//go:build !unix
+
+package syslog
+
+func transientErrno(error) (transient, ok bool) {
+	return false, false
+}
@@ -0,0 +1,103 @@
+package syslog
+
+import (
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+// flakyConn fails with EINTR on its first few reads.
+type flakyConn struct {
+	net.PacketConn
+	failures int
+}
+
+func (c *flakyConn) ReadFrom(bs []byte) (int, net.Addr, error) {
+	if c.failures > 0 {
+		c.failures--
+		return 0, nil, &net.OpError{Op: "read", Net: "udp", Err: os.NewSyscallError("recvfrom", syscall.EINTR)}
+	}
+	return c.PacketConn.ReadFrom(bs)
+}
+
+func receiveInto(s *Server) chan *Message {
+	received := make(chan *Message, 1)
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	return received
+}
+
+func TestServer_restart_transient(t *testing.T) {
+	s := NewServer(WithLogger(log.New(io.Discard, "", 0)))
+	received := receiveInto(s)
+	defer s.Shutdown()
+
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.Error(err).ToBeNil(t)
+	s.ListenPacketConn(&flakyConn{PacketConn: c, failures: 2}, AcceptEverything)
+
+	w, err := net.Dial("udp", c.LocalAddr().String())
+	expect.Error(err).ToBeNil(t)
+	defer w.Close()
+	_, err = io.WriteString(w, "<13>1 - host app - - - hello")
+	expect.Error(err).ToBeNil(t)
+
+	expect.String((<-received).Content).ToBe(t, "hello")
+	expect.Error(s.Err()).ToBeNil(t)
+}
+
+func TestServer_restart_giveUp(t *testing.T) {
+	failed := make(chan error, 1)
+	s := NewServer(WithLogger(log.New(io.Discard, "", 0)), WithRestart(2, func(_ net.Addr, err error) {
+		failed <- err
+	}))
+	defer s.Shutdown()
+
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.Error(err).ToBeNil(t)
+	s.ListenPacketConn(&flakyConn{PacketConn: c, failures: 3}, AcceptEverything)
+
+	expect.Error(<-failed).ToContain(t, "interrupted system call")
+	expect.Error(s.Wait()).ToContain(t, "interrupted system call")
+}
+
+func TestServer_restart_socketRemoved(t *testing.T) {
+	interval := socketCheckInterval
+	socketCheckInterval = 10 * time.Millisecond
+	defer func() { socketCheckInterval = interval }()
+
+	s := NewServer(WithLogger(log.New(io.Discard, "", 0)))
+	received := receiveInto(s)
+	defer s.Shutdown()
+
+	path := filepath.Join(t.TempDir(), "log")
+	expect.Error(s.Listen(path)).ToBeNil(t)
+	expect.Error(os.Remove(path)).ToBeNil(t)
+
+	// the socket file reappears once the listener has re-opened it
+	var w net.Conn
+	var err error
+	for i := 0; i < 100; i++ {
+		if w, err = net.Dial("unixgram", path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	expect.Error(err).ToBeNil(t)
+	defer w.Close()
+
+	_, err = io.WriteString(w, "<13>1 - host app - - - again")
+	expect.Error(err).ToBeNil(t)
+	expect.String((<-received).Content).ToBe(t, "again")
+}
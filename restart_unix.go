@@ -0,0 +1,22 @@
+//go:build unix
+
+package syslog
+
+import (
+	"errors"
+	"syscall"
+)
+
+// transientErrno classifies an error from a system call; ok is false for other errors.
+func transientErrno(err error) (transient, ok bool) {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false, false
+	}
+
+	switch errno {
+	case syscall.EINTR, syscall.EAGAIN, syscall.ENOBUFS, syscall.ENOMEM, syscall.ECONNREFUSED:
+		return true, true
+	}
+	return false, true
+}
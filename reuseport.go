@@ -0,0 +1,40 @@
+package syslog
+
+import (
+	"context"
+	"net"
+	"runtime"
+)
+
+// ListenReusePort opens n UDP sockets bound to the same address using SO_REUSEPORT and
+// starts one receiver goroutine for each. The kernel balances incoming datagrams across
+// the sockets, so reception is no longer limited to a single core. Messages from any one
+// sender are normally kept on the same socket. Only the messages matching accept are
+// processed.
+//
+// If n is zero or less, it is the number of CPUs available (GOMAXPROCS). An error is returned
+// on platforms that do not support SO_REUSEPORT.
+func (s *Server) ListenReusePort(addr string, n int, accept Filter) error {
+	if s.shutDown.Load() {
+		panic("Server is already shut down")
+	}
+
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+
+	lc := net.ListenConfig{Control: reusePort}
+	for i := 0; i < n; i++ {
+		c, err := lc.ListenPacket(context.Background(), "udp", addr)
+		if err != nil {
+			return err
+		}
+		// when the port was chosen by the system, bind the remaining sockets to the same one
+		addr = c.LocalAddr().String()
+
+		s.listenPacketConn(c, accept, func() (net.PacketConn, error) {
+			return lc.ListenPacket(context.Background(), "udp", addr)
+		})
+	}
+	return nil
+}
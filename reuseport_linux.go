@@ -0,0 +1,6 @@
+//go:build linux && !(mips || mipsle || mips64 || mips64le)
+
+package syslog
+
+// syscall.SO_REUSEPORT is missing for some Linux architectures.
+const soReusePort = 0xf
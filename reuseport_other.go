@@ -0,0 +1,12 @@
+//go:build !(darwin || dragonfly || freebsd || netbsd || openbsd || linux)
+
+package syslog
+
+import (
+	"errors"
+	"syscall"
+)
+
+func reusePort(network, address string, c syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is not supported on this platform")
+}
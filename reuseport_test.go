@@ -0,0 +1,42 @@
+//go:build linux
+
+package syslog
+
+import (
+	"net"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestServer_ListenReusePort(t *testing.T) {
+	received := make(chan *Message, 10)
+	s := NewServer(WithQueueLength(10))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	err := s.ListenReusePort("127.0.0.1:0", 4, AcceptEverything)
+	expect.Error(err).ToBeNil(t)
+
+	s.mu.Lock()
+	conns := s.conns
+	s.mu.Unlock()
+	expect.Number(len(conns)).ToBe(t, 4)
+	addr := conns[0].LocalAddr().String()
+	for _, c := range conns[1:] {
+		expect.String(c.LocalAddr().String()).ToBe(t, addr)
+	}
+
+	c, err := net.Dial("udp", addr)
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+	c.Write([]byte("<11>1 - host app - - - hello"))
+
+	m := <-received
+	expect.String(m.Content).ToBe(t, "hello")
+}
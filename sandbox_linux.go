@@ -0,0 +1,219 @@
+//go:build linux && (amd64 || arm64)
+
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// RestrictSyscalls installs a seccomp filter on all threads of the process that denies the
+// system calls a log collector never needs, such as execve, ptrace, mount and loading kernel
+// modules or BPF programs, so that a compromised collector has fewer ways to attack the host.
+// Denied calls fail with EPERM. The filter cannot be removed, so call this after all the
+// sockets and files have been opened and privileges have been dropped (see [DropPrivileges]);
+// no commands can be run afterwards. See also [RestrictFiles].
+func RestrictSyscalls() error {
+	prog := seccompProgram()
+	fprog := sockFprog{len: uint16(len(prog)), filter: &prog[0]}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// required to install a filter without CAP_SYS_ADMIN; the other threads are set by TSYNC
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("seccomp: %w", errno)
+	}
+
+	r, _, errno := syscall.Syscall(sysSeccomp, seccompSetModeFilter, seccompFilterFlagTsync, uintptr(unsafe.Pointer(&fprog)))
+	if errno != 0 {
+		return fmt.Errorf("seccomp: %w", errno)
+	}
+	if r != 0 {
+		return fmt.Errorf("seccomp: thread %d could not be synchronised", r)
+	}
+	return nil
+}
+
+// RestrictFiles uses Landlock to confine all threads of the process to the given files and
+// directories (including everything beneath them); other paths cannot be opened, created or
+// removed. The readOnly paths can only be read; the readWrite paths can also be written,
+// created, renamed and removed, e.g. the directories containing log files that are rotated.
+// Programs cannot be executed from any path. Files that are already open are not affected,
+// and neither are network sockets.
+//
+// As with [RestrictSyscalls], the restriction cannot be removed, so call this after
+// everything else has been set up. Landlock requires Linux 5.13 or later, with Landlock
+// enabled, and the program must be built without cgo (CGO_ENABLED=0) so that every thread
+// can be restricted.
+func RestrictFiles(readOnly, readWrite []string) error {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return fmt.Errorf("landlock is not available: %w", errno)
+	}
+
+	handled := uint64(landlockAccessFSv1)
+	if abi >= 2 {
+		handled |= landlockAccessFSRefer
+	}
+	if abi >= 3 {
+		handled |= landlockAccessFSTruncate
+	}
+
+	attr := landlockRulesetAttr{handledAccessFS: handled}
+	ruleset, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock: %w", errno)
+	}
+	defer syscall.Close(int(ruleset))
+
+	read := uint64(landlockAccessFSReadFile | landlockAccessFSReadDir)
+	write := handled &^ (landlockAccessFSExecute | landlockAccessFSMakeChar | landlockAccessFSMakeBlock)
+
+	for _, path := range readOnly {
+		if err := landlockAllow(int(ruleset), path, read); err != nil {
+			return err
+		}
+	}
+	for _, path := range readWrite {
+		if err := landlockAllow(int(ruleset), path, write); err != nil {
+			return err
+		}
+	}
+
+	if err := allThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); err != nil {
+		return err
+	}
+	return allThreadsSyscall(sysLandlockRestrictSelf, ruleset, 0, 0)
+}
+
+// landlockAllow adds a rule allowing access to a path and everything beneath it.
+func landlockAllow(ruleset int, path string, access uint64) error {
+	fd, err := syscall.Open(path, oPath|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "landlock", Path: path, Err: err}
+	}
+	defer syscall.Close(fd)
+
+	var st syscall.Stat_t
+	if err = syscall.Fstat(fd, &st); err != nil {
+		return &os.PathError{Op: "landlock", Path: path, Err: err}
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		access &= landlockAccessFile // directory rights are invalid for other files
+	}
+
+	attr := landlockPathBeneathAttr{allowedAccess: access, parentFD: int32(fd)}
+	_, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(ruleset), landlockRulePathBeneath,
+		uintptr(unsafe.Pointer(&attr)), 0, 0, 0)
+	if errno != 0 {
+		return &os.PathError{Op: "landlock", Path: path, Err: errno}
+	}
+	return nil
+}
+
+func allThreadsSyscall(trap, a1, a2, a3 uintptr) error {
+	_, _, errno := syscall.AllThreadsSyscall(trap, a1, a2, a3)
+	switch errno {
+	case 0:
+		return nil
+	case syscall.ENOTSUP:
+		return errors.New("landlock: threads cannot be restricted in programs built with cgo (use CGO_ENABLED=0)")
+	default:
+		return fmt.Errorf("landlock: %w", errno)
+	}
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// seccompProgram builds a BPF program that checks the architecture and then compares the
+// system call number with each denied call.
+func seccompProgram() []sockFilter {
+	var checks []sockFilter
+	if x32SyscallBit != 0 {
+		checks = append(checks, sockFilter{code: bpfJmp | bpfJge | bpfK, k: x32SyscallBit})
+	}
+	for _, nr := range deniedSyscalls {
+		checks = append(checks, sockFilter{code: bpfJmp | bpfJeq | bpfK, k: nr})
+	}
+
+	prog := []sockFilter{
+		{code: bpfLd | bpfW | bpfAbs, k: seccompDataArch},
+		{code: bpfJmp | bpfJeq | bpfK, jt: 1, k: auditArch},
+		{code: bpfRet | bpfK, k: seccompRetErrno | uint32(syscall.EPERM)},
+		{code: bpfLd | bpfW | bpfAbs, k: seccompDataNr},
+	}
+	for i, c := range checks {
+		c.jt = uint8(len(checks) - i) // jump over the remaining checks and the allow
+		prog = append(prog, c)
+	}
+	return append(prog,
+		sockFilter{code: bpfRet | bpfK, k: seccompRetAllow},
+		sockFilter{code: bpfRet | bpfK, k: seccompRetErrno | uint32(syscall.EPERM)},
+	)
+}
+
+type sockFilter struct {
+	code uint16
+	jt   uint8
+	jf   uint8
+	k    uint32
+}
+
+type sockFprog struct {
+	len    uint16
+	filter *sockFilter
+}
+
+type landlockRulesetAttr struct {
+	handledAccessFS uint64
+}
+
+// landlockPathBeneathAttr is packed by the kernel, which reads only the first 12 bytes.
+type landlockPathBeneathAttr struct {
+	allowedAccess uint64
+	parentFD      int32
+}
+
+const (
+	prSetNoNewPrivs = 38
+	oPath           = 0x200000
+
+	seccompSetModeFilter   = 1
+	seccompFilterFlagTsync = 1
+	seccompRetAllow        = 0x7fff0000
+	seccompRetErrno        = 0x00050000
+	seccompDataNr          = 0 // offsets in struct seccomp_data
+	seccompDataArch        = 4
+
+	bpfLd  = 0x00
+	bpfJmp = 0x05
+	bpfRet = 0x06
+	bpfW   = 0x00
+	bpfAbs = 0x20
+	bpfJeq = 0x10
+	bpfJge = 0x30
+	bpfK   = 0x00
+
+	sysLandlockCreateRuleset = 444 // the same on all architectures
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1
+	landlockRulePathBeneath      = 1
+
+	landlockAccessFSExecute   = 1 << 0
+	landlockAccessFSWriteFile = 1 << 1
+	landlockAccessFSReadFile  = 1 << 2
+	landlockAccessFSReadDir   = 1 << 3
+	landlockAccessFSMakeChar  = 1 << 6
+	landlockAccessFSMakeBlock = 1 << 11
+	landlockAccessFSv1        = 1<<13 - 1 // all the rights of ABI version 1
+	landlockAccessFSRefer     = 1 << 13   // ABI version 2
+	landlockAccessFSTruncate  = 1 << 14   // ABI version 3
+	landlockAccessFile        = landlockAccessFSExecute | landlockAccessFSWriteFile | landlockAccessFSReadFile | landlockAccessFSTruncate
+)
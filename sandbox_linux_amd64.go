@@ -0,0 +1,41 @@
+package syslog
+
+const (
+	auditArch     = 0xc000003e // AUDIT_ARCH_X86_64
+	x32SyscallBit = 0x40000000 // x32 system calls are denied
+	sysSeccomp    = 317
+)
+
+// deniedSyscalls are denied by [RestrictSyscalls].
+var deniedSyscalls = []uint32{
+	59,  // execve
+	322, // execveat
+	101, // ptrace
+	310, // process_vm_readv
+	311, // process_vm_writev
+	165, // mount
+	166, // umount2
+	155, // pivot_root
+	161, // chroot
+	272, // unshare
+	308, // setns
+	246, // kexec_load
+	320, // kexec_file_load
+	175, // init_module
+	313, // finit_module
+	176, // delete_module
+	321, // bpf
+	298, // perf_event_open
+	323, // userfaultfd
+	304, // open_by_handle_at
+	248, // add_key
+	249, // request_key
+	250, // keyctl
+	167, // swapon
+	168, // swapoff
+	169, // reboot
+	163, // acct
+	135, // personality
+	172, // iopl
+	173, // ioperm
+}
@@ -0,0 +1,39 @@
+package syslog
+
+const (
+	auditArch     = 0xc00000b7 // AUDIT_ARCH_AARCH64
+	x32SyscallBit = 0          // not applicable
+	sysSeccomp    = 277
+)
+
+// deniedSyscalls are denied by [RestrictSyscalls].
+var deniedSyscalls = []uint32{
+	221, // execve
+	281, // execveat
+	117, // ptrace
+	270, // process_vm_readv
+	271, // process_vm_writev
+	40,  // mount
+	39,  // umount2
+	41,  // pivot_root
+	51,  // chroot
+	97,  // unshare
+	268, // setns
+	104, // kexec_load
+	294, // kexec_file_load
+	105, // init_module
+	273, // finit_module
+	106, // delete_module
+	280, // bpf
+	241, // perf_event_open
+	282, // userfaultfd
+	265, // open_by_handle_at
+	217, // add_key
+	218, // request_key
+	219, // keyctl
+	224, // swapon
+	225, // swapoff
+	142, // reboot
+	89,  // acct
+	92,  // personality
+}
@@ -0,0 +1,15 @@
+//go:build !linux || !(amd64 || arm64)
+
+package syslog
+
+import "errors"
+
+// RestrictSyscalls is not supported on this platform.
+func RestrictSyscalls() error {
+	return errors.New("RestrictSyscalls is not supported on this platform")
+}
+
+// RestrictFiles is not supported on this platform.
+func RestrictFiles(readOnly, readWrite []string) error {
+	return errors.New("RestrictFiles is not supported on this platform")
+}
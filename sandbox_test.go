@@ -0,0 +1,88 @@
+//go:build linux && (amd64 || arm64)
+
+package syslog
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+// The sandbox affects the whole process, so each test runs itself again in a child process
+// that applies the restriction and then reports what it could do.
+const sandboxTestVar = "SYSLOG_SANDBOX_TEST"
+
+func TestRestrictSyscalls(t *testing.T) {
+	if os.Getenv(sandboxTestVar) == "syscalls" {
+		if err := RestrictSyscalls(); err != nil {
+			sandboxExit("unavailable: " + err.Error())
+		}
+		err := exec.Command("/bin/true").Run()
+		sandboxExit("exec: " + errorText(errors.Unwrap(err)))
+	}
+
+	out := runSandboxTest(t, "syscalls")
+	expect.String(out).ToBe(t, "exec: "+syscall.EPERM.Error())
+}
+
+func TestRestrictFiles(t *testing.T) {
+	dir := os.Getenv("SYSLOG_SANDBOX_DIR")
+	if os.Getenv(sandboxTestVar) == "files" {
+		ro, rw := filepath.Join(dir, "ro"), filepath.Join(dir, "rw")
+		if err := RestrictFiles([]string{ro}, []string{rw}); err != nil {
+			sandboxExit("unavailable: " + err.Error())
+		}
+		_, readErr := os.ReadFile(filepath.Join(ro, "a"))
+		writeErr := os.WriteFile(filepath.Join(rw, "b"), nil, 0600)
+		denyErr := os.WriteFile(filepath.Join(ro, "b"), nil, 0600)
+		_, outsideErr := os.ReadFile(filepath.Join(dir, "c"))
+		sandboxExit(strings.Join([]string{errorText(readErr), errorText(writeErr),
+			errorText(errors.Unwrap(denyErr)), errorText(errors.Unwrap(outsideErr))}, ","))
+	}
+
+	dir = t.TempDir()
+	expect.Error(os.Mkdir(filepath.Join(dir, "ro"), 0700)).ToBeNil(t)
+	expect.Error(os.Mkdir(filepath.Join(dir, "rw"), 0700)).ToBeNil(t)
+	expect.Error(os.WriteFile(filepath.Join(dir, "ro", "a"), nil, 0600)).ToBeNil(t)
+	expect.Error(os.WriteFile(filepath.Join(dir, "c"), nil, 0600)).ToBeNil(t)
+	t.Setenv("SYSLOG_SANDBOX_DIR", dir)
+
+	out := runSandboxTest(t, "files")
+	denied := syscall.EACCES.Error()
+	expect.String(out).ToBe(t, "ok,ok,"+denied+","+denied)
+}
+
+func runSandboxTest(t *testing.T, mode string) string {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$")
+	cmd.Env = append(os.Environ(), sandboxTestVar+"="+mode)
+	bs, err := cmd.Output()
+	expect.Error(err).ToBeNil(t)
+
+	out := string(bs)
+	if i := strings.Index(out, "\n"); i >= 0 {
+		out = out[:i]
+	}
+	if strings.HasPrefix(out, "unavailable: ") {
+		t.Skip(out)
+	}
+	return out
+}
+
+func sandboxExit(result string) {
+	os.Stdout.WriteString(result + "\n")
+	os.Exit(0)
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return err.Error()
+}
@@ -0,0 +1,130 @@
+package syslog
+
+import (
+	"slices"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ScriptAnnotation is the annotation key for the predominant script (writing system) of a
+// message's content, such as "Latin", "Cyrillic" or "Han"; see [ScriptHandler].
+const ScriptAnnotation = "script"
+
+// EncodingAnnotation is the annotation key for the encoding of a message's content: "ascii",
+// "utf-8", or "unknown" for content that is not valid UTF-8, e.g. from a legacy code page.
+const EncodingAnnotation = "encoding"
+
+// CJK lists the scripts of Chinese, Japanese and Korean, for use with [Script].
+var CJK = []string{"Han", "Hiragana", "Katakana", "Hangul"}
+
+// scripts are the scripts that are detected, named as in [unicode.Scripts]. Letters of any
+// other script are counted as "Other"; content without letters is "Common".
+var scripts = []unicodeScript{
+	{"Latin", unicode.Latin},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Greek", unicode.Greek},
+	{"Arabic", unicode.Arabic},
+	{"Hebrew", unicode.Hebrew},
+	{"Han", unicode.Han},
+	{"Hiragana", unicode.Hiragana},
+	{"Katakana", unicode.Katakana},
+	{"Hangul", unicode.Hangul},
+	{"Thai", unicode.Thai},
+	{"Devanagari", unicode.Devanagari},
+}
+
+type unicodeScript struct {
+	name  string
+	table *unicode.RangeTable
+}
+
+// maxScriptLetters limits how much of a long message is examined.
+const maxScriptLetters = 256
+
+// ScriptHandler is a [Handler] that annotates each message with the script of its content
+// ([ScriptAnnotation]) and its encoding ([EncodingAnnotation]), so that messages can be routed
+// by region, e.g. using [Script] with [RouteHandler]. The script is the one used by most
+// letters of the content; Japanese text may be "Han", "Hiragana" or "Katakana".
+type ScriptHandler struct{}
+
+// Close does nothing; it implements [io.Closer].
+func (h ScriptHandler) Close() error {
+	return nil
+}
+
+func (h ScriptHandler) Handle(m *Message) *Message {
+	if m != nil {
+		annotateScript(m)
+	}
+	return m
+}
+
+// Script accepts messages whose content is mostly written in one of the named scripts, e.g.
+// Script("Cyrillic") or Script(CJK...). The script is detected if the message has not been
+// annotated by a [ScriptHandler], so this can also be used by listeners.
+func Script(names ...string) Filter {
+	return func(m *Message) bool {
+		return slices.Contains(names, annotateScript(m))
+	}
+}
+
+// annotateScript detects the script and encoding of the content, unless they are already
+// known, and returns the script.
+func annotateScript(m *Message) string {
+	if script, exists := m.Annotations[ScriptAnnotation]; exists {
+		return script
+	}
+
+	script, encoding := detectScript(m.Content)
+	m.Annotate(ScriptAnnotation, script)
+	m.Annotate(EncodingAnnotation, encoding)
+	return script
+}
+
+func detectScript(s string) (script, encoding string) {
+	counts := make([]int, len(scripts)+1) // the last is for other scripts
+	letters := 0
+	ascii := true
+	for _, r := range s {
+		if r >= utf8.RuneSelf {
+			ascii = false
+		}
+		if letters == maxScriptLetters || !unicode.IsLetter(r) || unicode.In(r, unicode.Common, unicode.Inherited) {
+			continue // e.g. the Japanese prolonged sound mark is shared by several scripts
+		}
+
+		letters++
+		i := 0
+		if r >= utf8.RuneSelf { // ASCII letters are Latin
+			i = slices.IndexFunc(scripts, func(sc unicodeScript) bool { return unicode.Is(sc.table, r) })
+			if i < 0 {
+				i = len(scripts)
+			}
+		}
+		counts[i]++
+	}
+
+	switch {
+	case ascii:
+		encoding = "ascii"
+	case utf8.ValidString(s):
+		encoding = "utf-8"
+	default:
+		encoding = "unknown"
+	}
+
+	if letters == 0 {
+		return "Common", encoding
+	}
+
+	best := 0
+	for i, n := range counts {
+		if n > counts[best] {
+			best = i
+		}
+	}
+	if best == len(scripts) {
+		return "Other", encoding
+	}
+	return scripts[best].name, encoding
+}
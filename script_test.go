@@ -0,0 +1,39 @@
+package syslog
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestScriptHandler(t *testing.T) {
+	cases := []struct {
+		content, script, encoding string
+	}{
+		{"disk full", "Latin", "ascii"},
+		{"Ошибка диска: disk", "Cyrillic", "utf-8"},
+		{"ディスクエラー", "Katakana", "utf-8"},
+		{"ディスクがいっぱいです", "Hiragana", "utf-8"},
+		{"磁盘已满", "Han", "utf-8"},
+		{"디스크가 가득 찼습니다", "Hangul", "utf-8"},
+		{"Δίσκος", "Greek", "utf-8"},
+		{"ሰላም", "Other", "utf-8"},
+		{"12:34 -> 56", "Common", "ascii"},
+		{"caf\xe9", "Latin", "unknown"},
+	}
+	for _, c := range cases {
+		m := ScriptHandler{}.Handle(&Message{Content: c.content})
+		expect.String(m.Annotations[ScriptAnnotation]).Info(c.content).ToBe(t, c.script)
+		expect.String(m.Annotations[EncodingAnnotation]).Info(c.content).ToBe(t, c.encoding)
+	}
+}
+
+func TestScript(t *testing.T) {
+	cjk := Script(CJK...)
+	expect.Bool(cjk(&Message{Content: "磁盘已满"})).ToBeTrue(t)
+	expect.Bool(cjk(&Message{Content: "disk full"})).ToBeFalse(t)
+
+	// an existing annotation is used
+	m := &Message{Content: "disk full", Annotations: map[string]string{ScriptAnnotation: "Cyrillic"}}
+	expect.Bool(Script("Cyrillic")(m)).ToBeTrue(t)
+}
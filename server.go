@@ -1,36 +1,166 @@
 package syslog
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net"
+	"os"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // Server handles UDP or Unix datagrams. Each received packet is parsed to obtain the syslog message.
 // The message is then passed along the [Handler] chain (see [Server.AddHandler]).
 //
 // The handlers follow the "Chain of Responsibility" design pattern.
+//
+// Ordering: the handlers are called sequentially from a single goroutine, so every handler
+// sees messages in the same order. With several workers (see [WithWorkers]), this holds for
+// the messages from each sender but not between senders. Messages are dispatched in small
+// batches: each handler handles the whole batch before the next handler sees it (see
+// [BatchHandler]). Messages received on any one listener keep the order in which they were
+// read from the socket; messages from different listeners are interleaved in whatever order
+// they reach the internal queue. Timestamps are not a reliable indicator of arrival order
+// because they often collide (or come from unsynchronised senders); use
+// [Server.SetSequencing] if downstream systems need to re-establish arrival order.
 type Server struct {
-	conns      []net.PacketConn
-	queue      chan *Message
-	handlers   []Handler
-	acceptFunc Filter
-	shutDown   atomic.Bool
+	mu                 sync.Mutex
+	conns              []net.PacketConn
+	listeners          []net.Listener
+	protocols          map[net.Listener]string // of the listeners that do not receive plain syslog
+	streams            map[net.Conn]struct{}
+	receivers          sync.WaitGroup
+	serving            sync.WaitGroup // datagram receivers and stream accept loops
+	errs               []error        // why receivers stopped, other than shutdown
+	done               chan struct{}
+	queue              messageQueue
+	chain              atomic.Pointer[[]*namedHandler]
+	chainMu            sync.Mutex   // serialises changes to the chain
+	inFlight           sync.RWMutex // held for reading whilst a batch is being dispatched
+	acceptFunc         Filter
+	shutDown           atomic.Bool
+	sequencing         bool
+	sequence           atomic.Uint64
+	idGenerator        IDGenerator
+	idElement          string
+	dropped            atomic.Uint64
+	facilities         FacilityMapper
+	priorityFilter     PriorityFilter
+	parsers            []Parser
+	acl                *sourceACL
+	denied             atomic.Uint64
+	limiter            *rateLimiter
+	rateLimited        atomic.Uint64
+	facilityViolations atomic.Uint64
+	oversized          atomic.Uint64
+	audit              *auditor
+	watchdog           *watchdog
+	drained            chan struct{}
+	timedOut           atomic.Uint64
+
+	// set by options
+	qlen              int
+	ring              bool
+	overflow          OverflowPolicy
+	noBackpressure    bool
+	lazy              bool
+	workers           int
+	minWorkers        int // autoscaling bounds, if maxWorkers > 0
+	maxWorkers        int
+	activeWorkers     atomic.Int32
+	deadline          time.Duration
+	deadLetter        Handler
+	parserCache       *parserCache
+	readBufferSize    int
+	readBatch         int
+	maxMessageSize    int
+	receiveBuffer     int
+	socketPermissions *socketPermissions
+	truncate          bool
+	restartAttempts   int
+	idleTimeout       time.Duration
+	onListenerFailure func(net.Addr, error)
+	logger            *log.Logger
+	clock             func() time.Time
+
+	shutdownTimeout time.Duration
 }
 
-// NewServer creates an idle server. The internal queue length can be specified and should be a
-// small positive number.
-func NewServer(qlen int) *Server {
+// NewServer creates an idle server configured by any options given. Receivers pass messages
+// to the handlers in small batches (of those that arrive close together), so the queue length
+// (see [WithQueueLength]) is a number of batches.
+func NewServer(opts ...Option) *Server {
 	s := &Server{
-		queue: make(chan *Message, qlen),
+		qlen:            defaultQueueLength,
+		readBufferSize:  defaultReadBufferSize,
+		restartAttempts: defaultRestartAttempts,
+		idleTimeout:     defaultIdleTimeout,
+		logger:          Logger,
+		clock:           time.Now,
+		protocols:       make(map[net.Listener]string),
+		streams:         make(map[net.Conn]struct{}),
+		done:            make(chan struct{}),
+		drained:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.ring {
+		s.queue = newRingQueue(s.qlen)
+	} else {
+		s.queue = newChanQueue(s.qlen)
 	}
+
 	go s.passToHandlers()
 	return s
 }
 
-// AddHandler adds h to the internal ordered list of handlers.
+// AddHandler adds h to the internal ordered list of handlers. To be able to remove or
+// replace it later, use [Server.AddNamedHandler] instead.
 func (s *Server) AddHandler(h Handler) {
-	s.handlers = append(s.handlers, h)
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+	s.storeChain(append(slices.Clip(s.handlerChain()), &namedHandler{Handler: h}))
+}
+
+// SetSequencing enables or disables stamping each accepted message with a monotonically
+// increasing [Message.Sequence] number, starting from 1. This must be set before calling
+// [Server.Listen].
+func (s *Server) SetSequencing(on bool) {
+	s.sequencing = on
+}
+
+// SetFacilityMapper sets a function that adjusts the facility of every message as soon as
+// it has been parsed, before any filters are applied. Use this to clamp or remap the
+// unknown facilities that some devices send (see [ClampFacilities] and [RemapFacilities]).
+// This must be set before calling [Server.Listen].
+func (s *Server) SetFacilityMapper(fm FacilityMapper) {
+	s.facilities = fm
+}
+
+// SetPriorityFilter sets a filter that is applied to every packet on all listeners as soon as
+// its priority has been decoded, before the rest of it is parsed. Rejected packets are
+// discarded cheaply, which is worthwhile when most traffic is unwanted. The facility mapper
+// (see [Server.SetFacilityMapper]) is applied first. This must be set before calling
+// [Server.Listen].
+func (s *Server) SetPriorityFilter(pf PriorityFilter) {
+	s.priorityFilter = pf
+}
+
+// SetAudit enables an audit trail that records, for every packet received, its source,
+// size, the verdict of the listener's filter and the handlers that processed it. Records
+// are written to w as JSON lines. This must be set before calling [Server.Listen].
+func (s *Server) SetAudit(w io.Writer) {
+	s.audit = newAuditor(w)
 }
 
 // Listen starts goroutine that receives syslog messages on a specified address.
@@ -48,92 +178,578 @@ func (s *Server) ListenFilter(addr string, accept Filter) error {
 		panic("Server is already shut down")
 	}
 
-	var c net.PacketConn
+	c, err := s.openPacketConn(addr)
+	if err != nil {
+		return err
+	}
+
+	s.listenPacketConn(c, accept, func() (net.PacketConn, error) {
+		return s.openPacketConn(addr)
+	})
+	return nil
+}
+
+// openPacketConn opens a UDP socket if addr is host:port, or a Unix datagram socket otherwise.
+func (s *Server) openPacketConn(addr string) (net.PacketConn, error) {
 	if strings.IndexRune(addr, ':') >= 0 {
 		a, err := net.ResolveUDPAddr("udp", addr)
 		if err != nil {
-			return err
-		}
-		//fmt.Println("Listening on", a)
-		c, err = net.ListenUDP("udp", a)
-		if err != nil {
-			return err
+			return nil, err
 		}
+		return net.ListenUDP("udp", a)
+	}
+
+	return s.listenUnixgram(addr)
+}
+
+// ListenContext is like [Server.ListenFilter] but the socket is closed when ctx ends, after
+// which no more messages are received on it. The server and its other listeners keep running;
+// see [Server.ShutdownContext] to stop the server itself.
+func (s *Server) ListenContext(ctx context.Context, addr string, accept Filter) error {
+	if s.shutDown.Load() {
+		panic("Server is already shut down")
+	}
+
+	network := "unixgram"
+	if strings.IndexRune(addr, ':') >= 0 {
+		network = "udp"
 	} else {
-		a, err := net.ResolveUnixAddr("unixgram", addr)
-		if err != nil {
+		s.removeStaleSocket(network, addr)
+	}
+
+	var lc net.ListenConfig
+	c, err := lc.ListenPacket(ctx, network, addr)
+	if err != nil {
+		return err
+	}
+
+	if network == "unixgram" {
+		if err = s.setSocketPermissions(addr); err != nil {
+			c.Close()
 			return err
 		}
-		c, err = net.ListenUnixgram("unixgram", a)
-		if err != nil {
-			return err
+	}
+
+	s.ListenPacketConn(c, accept)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.closeConn(c)
+		case <-s.done:
 		}
+	}()
+	return nil
+}
+
+// closeConn closes one datagram socket and forgets it.
+func (s *Server) closeConn(c net.PacketConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns = slices.DeleteFunc(s.conns, func(x net.PacketConn) bool { return x == c })
+	checkErr(c.Close(), "close", c.LocalAddr().String())
+}
+
+// ListenPacketConn starts a goroutine that receives syslog messages on a connection supplied
+// by the caller, such as a socket created with a custom configuration or passed from
+// another process. The server takes ownership of c and closes it on shutdown.
+// Only the messages matching accept are processed.
+func (s *Server) ListenPacketConn(c net.PacketConn, accept Filter) {
+	s.listenPacketConn(c, accept, nil)
+}
+
+// listenPacketConn starts a receiver for c; reopen, if not nil, opens a replacement for c
+// after an error.
+func (s *Server) listenPacketConn(c net.PacketConn, accept Filter, reopen func() (net.PacketConn, error)) {
+	if s.shutDown.Load() {
+		panic("Server is already shut down")
 	}
+
+	s.tuneConn(c)
+	s.mu.Lock()
 	s.conns = append(s.conns, c)
+	s.mu.Unlock()
 
-	go receiver(c, s.queue, accept, func() bool { return !s.shutDown.Load() })
-	return nil
+	s.receivers.Add(1)
+	s.serving.Add(1)
+	go s.receiver(c, accept, reopen)
 }
 
 // SigHup passes a hang-up signal to all handlers. This typically is used for log rotation etc.
 func (s *Server) SigHup() {
-	for _, h := range s.handlers {
-		if hu, ok := h.(interface{ SigHup() }); ok {
+	for _, h := range s.handlerChain() {
+		if hu, ok := h.Handler.(interface{ SigHup() }); ok {
 			hu.SigHup()
 		}
 	}
 }
 
-// Shutdown stops the server.
+// SetShutdownTimeout limits how long [Server.Shutdown] waits in each of its stages: first
+// for queued messages to be drained through the handlers, then for each handler in turn
+// to clean up. A handler that does not finish in time is logged and abandoned, so the
+// whole shutdown completes within (1 + number of handlers) × timeout. This is useful for
+// orchestrators that kill processes that do not exit promptly. By default, there is no
+// time limit.
+func (s *Server) SetShutdownTimeout(timeout time.Duration) {
+	s.shutdownTimeout = timeout
+}
+
+// Shutdown stops the server. It stops receiving messages, waits for the queued messages
+// to be handled, then shuts down each handler in turn. See [Server.SetShutdownTimeout].
+// Any problems are logged; use [Server.Close] or [Server.ShutdownContext] to receive them
+// as an error instead.
 func (s *Server) Shutdown() {
-	s.shutDown.Store(true)
+	if err := s.Close(); err != nil {
+		s.logger.Println(err)
+	}
+}
+
+// Close stops the server like [Server.Shutdown] and returns any problems as an error; see
+// [Server.ShutdownContext]. It implements [io.Closer], so closing a server more than once
+// has no further effect.
+func (s *Server) Close() error {
+	return s.shutdown(s.waitFor)
+}
+
+// ShutdownContext stops the server like [Server.Shutdown], but the whole shutdown is limited
+// by ctx instead of the shutdown timeout. Queued messages are drained through all the handlers
+// unless ctx ends first. Any messages that could not be handled, handlers that did not shut
+// down, and errors from closing the sockets are reported in the returned error.
+// Shutting down a server more than once has no further effect.
+func (s *Server) ShutdownContext(ctx context.Context) error {
+	return s.shutdown(func(finished chan struct{}) error {
+		select {
+		case <-finished:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+func (s *Server) shutdown(wait func(chan struct{}) error) error {
+	if s.shutDown.Swap(true) {
+		return nil
+	}
+
+	var errs []error
+	close(s.done)
+
+	s.mu.Lock()
 	for _, c := range s.conns {
-		err := c.Close()
-		if err != nil {
-			Logger.Fatalln(err)
+		if err := c.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
 		}
 	}
-	close(s.queue)
 	s.conns = nil
-	for _, h := range s.handlers {
-		h.Handle(nil)
+	s.mu.Unlock()
+
+	s.closeStreams()
+	s.receivers.Wait()
+	s.queue.close()
+
+	if err := wait(s.drained); err != nil {
+		errs = append(errs, fmt.Errorf("queued messages were not handled: %w", err))
+	}
+
+	s.chainMu.Lock()
+	chain := s.handlerChain()
+	s.chain.Store(nil)
+	s.chainMu.Unlock()
+	if s.deadLetter != nil {
+		chain = append(slices.Clip(chain), &namedHandler{Handler: s.deadLetter, name: "dead-letter"})
+	}
+
+	for _, h := range chain {
+		finished := make(chan struct{})
+		var err error
+		go func() {
+			defer close(finished)
+			err = closeHandler(h.Handler)
+		}()
+		if werr := wait(finished); werr != nil {
+			errs = append(errs, fmt.Errorf("handler %s did not shut down: %w", h.label(), werr))
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("handler %s: %w", h.label(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// waitFor waits for a shutdown stage to finish within the shutdown timeout, if any.
+func (s *Server) waitFor(finished chan struct{}) error {
+	if s.shutdownTimeout <= 0 {
+		<-finished
+		return nil
+	}
+
+	timer := time.NewTimer(s.shutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-finished:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("timed out after %v", s.shutdownTimeout)
 	}
-	s.handlers = nil
 }
 
-func isNulCrLf(r rune) bool {
-	return r == 0 || r == '\r' || r == '\n'
+// push queues a message for the handlers.
+func (s *Server) push(m *Message) {
+	s.pushBatch([]*Message{m})
 }
 
-func (s *Server) passToHandlers() {
-	for m := range s.queue {
-		for _, h := range s.handlers {
-			m = h.Handle(m)
-			if m == nil {
-				break
+// pushBatch queues several messages for the handlers; the queue takes ownership of ms.
+// When the queue is full, the overflow policy applies.
+func (s *Server) pushBatch(ms []*Message) {
+	s.pushWith(ms, s.overflow)
+}
+
+// pushStream queues messages read from a stream connection. When the queue is full, the
+// receiver waits for room (so it stops reading, which slows the sender down) unless stream
+// backpressure is disabled.
+// It returns the number of messages queued, which are the first of ms.
+func (s *Server) pushStream(ms []*Message) int {
+	if s.noBackpressure {
+		return s.pushWith(ms, s.overflow)
+	}
+	return s.pushWith(ms, OverflowBlock)
+}
+
+func (s *Server) pushWith(ms []*Message, overflow OverflowPolicy) int {
+	if s.sequencing {
+		for _, m := range ms {
+			m.Sequence = s.sequence.Add(1)
+		}
+	}
+	if s.idGenerator != nil {
+		for _, m := range ms {
+			s.assignID(m)
+		}
+	}
+
+	switch overflow {
+	case OverflowDropNewest:
+		n := s.queue.offer(ms)
+		s.drop(ms[n:])
+		return n
+
+	case OverflowDropOldest:
+		n := len(ms)
+		for len(ms) > 0 {
+			k := s.queue.offer(ms)
+			ms = ms[k:]
+			if len(ms) > 0 {
+				s.drop(s.queue.evict())
 			}
 		}
+		return n
+
+	default:
+		s.queue.put(ms)
+		return len(ms)
 	}
 }
 
-func receiver(c net.PacketConn, queue chan *Message, acceptFunc Filter, running func() bool) {
-	buf := make([]byte, 64*1024)
+func (s *Server) drop(ms []*Message) {
+	s.dropped.Add(uint64(len(ms)))
+	for _, m := range ms {
+		s.audit.record(m, verdictDropped, nil)
+	}
+}
+
+// Wait blocks until every listener has stopped receiving, either because the server has
+// been shut down or because of an error, and then returns [Server.Err]. It returns
+// immediately if there are no listeners.
+func (s *Server) Wait() error {
+	s.serving.Wait()
+	return s.Err()
+}
+
+// Err returns the errors that stopped any listeners, joined together, or nil if there were
+// none. Stopping because of [Server.Shutdown] is not an error.
+func (s *Server) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.errs...)
+}
+
+// fail records and logs an error that stopped a listener.
+func (s *Server) fail(err error) {
+	s.logger.Println(err)
+	s.mu.Lock()
+	s.errs = append(s.errs, err)
+	s.mu.Unlock()
+}
+
+// TimedOut returns the number of messages that exceeded the processing deadline and so
+// skipped the remaining handlers. See [WithDeadline].
+func (s *Server) TimedOut() uint64 {
+	return s.timedOut.Load()
+}
+
+// Oversized returns the number of messages that were truncated or discarded because they
+// were too long. See [WithMaxMessageSize].
+func (s *Server) Oversized() uint64 {
+	return s.oversized.Load()
+}
+
+// Dropped returns the number of messages dropped because the internal queue was full.
+// See [WithOverflowPolicy].
+func (s *Server) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Server) passToHandlers() {
+	defer close(s.drained)
+	if s.workers > 1 || s.maxWorkers > 0 {
+		s.distribute()
+		return
+	}
+
+	d := &dispatcher{goroutine: goroutineID()}
+	batch := make([]*Message, 0, maxBatch)
 	for {
-		n, addr, err := c.ReadFrom(buf)
-		if err != nil {
-			if running() {
-				Logger.Println("Read error:", err)
-			}
+		batch = s.queue.take(batch[:0])
+		if len(batch) == 0 {
 			return
 		}
 
-		bs := buf[:n]
-		m, err := parseMessage(bs)
+		d.dispatch(s, batch)
+		clear(batch)
+	}
+}
+
+// batchDelay limits how long a datagram receiver holds a partial batch of messages.
+const batchDelay = time.Millisecond
+
+func (s *Server) receiver(c net.PacketConn, acceptFunc Filter, reopen func() (net.PacketConn, error)) {
+	defer s.receivers.Done()
+	defer s.serving.Done()
+	r := s.newPacketReader(c)
+	batch := make([]*Message, 0, maxBatch)
+	path := watchedPath(c, reopen)
+	failures := 0
+
+	flush := func() {
+		if len(batch) > 0 {
+			s.pushBatch(batch)
+			batch = make([]*Message, 0, maxBatch)
+		}
+	}
+
+	if path != "" {
+		c.SetReadDeadline(idleDeadline(path))
+	}
+	for {
+		n, err := r.read()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			// no more messages arrived in time to complete the batch
+			flush()
+			if path == "" || fileExists(path) {
+				c.SetReadDeadline(idleDeadline(path))
+				continue
+			}
+			err = fmt.Errorf("%s: socket file has been removed", path)
+		}
 		if err != nil {
-			Logger.Println(err.Error())
-		} else if acceptFunc(m) {
-			m.Source = addr
-			queue <- m
+			flush()
+			if s.shutDown.Load() || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if nc := s.restartConn(c, err, reopen, &failures); nc == nil {
+				return
+			} else if nc != c {
+				c, r = nc, s.newPacketReader(nc)
+			}
+			c.SetReadDeadline(idleDeadline(path))
+			continue
+		}
+
+		failures = 0
+		for i := range n {
+			bs, addr := r.packet(i)
+			if m := s.receive(bs, addr, acceptFunc); m != nil {
+				if len(batch) == 0 {
+					c.SetReadDeadline(time.Now().Add(batchDelay))
+				}
+				batch = append(batch, m)
+				if len(batch) == cap(batch) {
+					flush()
+					c.SetReadDeadline(idleDeadline(path))
+				}
+			}
+		}
+	}
+}
+
+// enqueue parses a frame read from a stream and queues the message, if accepted, for the
+// handlers. It reports false if the message was lost, i.e. it was neither queued nor
+// rejected by a filter (see [Server.admit]), or was dropped because the queue was full.
+func (s *Server) enqueue(bs []byte, addr net.Addr, peer *PeerIdentity, acceptFunc Filter) bool {
+	m, lost := s.admit(bs, addr, peer, acceptFunc)
+	if m == nil {
+		return !lost
+	}
+	return s.pushStream([]*Message{m}) == 1
+}
+
+// parse parses a packet, using the sender's dialect hints if the parser cache is enabled.
+func (s *Server) parse(bs []byte, addr net.Addr, t time.Time) (*Message, error) {
+	if s.parsers != nil {
+		return s.parseWith(bs, t)
+	}
+	if s.parserCache == nil {
+		return parseMessageAt(bs, t)
+	}
+
+	ip, h := s.parserCache.get(addr)
+	if h == nil {
+		return parseMessageAt(bs, t)
+	}
+
+	before := *h
+	m, err := parseMessageHinted(bs, t, h)
+	if *h != before {
+		s.parserCache.put(ip, *h)
+	}
+	return m, err
+}
+
+// receiveLazily decodes only the priority, returning nil if it is invalid.
+func (s *Server) receiveLazily(bs []byte, addr net.Addr, t time.Time) *Message {
+	prio, _, err := parsePriority(bs)
+	if err != nil {
+		return nil
+	}
+
+	m := &Message{
+		Time:     t,
+		Source:   addr,
+		Size:     len(bs),
+		Facility: Facility(prio >> 3),
+		Severity: Severity(prio & 0x07),
+		Raw:      bytes.Clone(bs),
+		unparsed: true,
+	}
+	if s.facilities != nil {
+		m.Facility = s.facilities(m.Facility)
+	}
+	return m
+}
+
+// TruncationMarker ends each message that was truncated because it was too long; see
+// [WithMaxMessageSize].
+const TruncationMarker = "...[truncated]"
+
+// truncateMessage shortens a packet to limit bytes, ending with the truncation marker.
+func truncateMessage(bs []byte, limit int) []byte {
+	n := limit - len(TruncationMarker)
+	if n < 0 {
+		return bs[:limit]
+	}
+	return append(bs[:n:n], TruncationMarker...)
+}
+
+func isAcceptEverything(f Filter) bool {
+	return reflect.ValueOf(f).Pointer() == reflect.ValueOf(AcceptEverything).Pointer()
+}
+
+// acceptPriority applies the priority filter; packets with invalid priorities are accepted
+// here so that they are reported by the parser.
+func (s *Server) acceptPriority(bs []byte) bool {
+	prio, _, err := parsePriority(bs)
+	if err != nil {
+		return true
+	}
+
+	f := Facility(prio >> 3)
+	if s.facilities != nil {
+		f = s.facilities(f)
+	}
+	return s.priorityFilter(f, Severity(prio&0x07))
+}
+
+// receive parses a packet and returns the message if it is accepted, or nil otherwise.
+func (s *Server) receive(bs []byte, addr net.Addr, acceptFunc Filter) *Message {
+	return s.receiveFrom(bs, addr, nil, acceptFunc)
+}
+
+// receiveFrom is like receive for a packet from a sender whose TLS client certificate has
+// been verified, so that its identity is known to the accept filter.
+func (s *Server) receiveFrom(bs []byte, addr net.Addr, peer *PeerIdentity, acceptFunc Filter) *Message {
+	m, _ := s.admit(bs, addr, peer, acceptFunc)
+	return m
+}
+
+// admit is like receiveFrom, and also reports whether a packet for which it returns nil was
+// lost, i.e. denied by the source ACL, too long, rate limited or invalid, rather than
+// rejected by the priority filter or acceptFunc.
+func (s *Server) admit(bs []byte, addr net.Addr, peer *PeerIdentity, acceptFunc Filter) (m *Message, lost bool) {
+	t := s.clock()
+	if !s.acl.allows(addr) {
+		s.denied.Add(1)
+		s.audit.record(&Message{Time: t, Source: addr, Size: len(bs)}, verdictDenied, nil)
+		return nil, true
+	}
+
+	size := len(bs)
+	if s.maxMessageSize > 0 && size > s.maxMessageSize {
+		s.oversized.Add(1)
+		if !s.truncate {
+			s.audit.record(&Message{Time: t, Source: addr, Size: size}, verdictRejected, nil)
+			return nil, true
+		}
+		bs = truncateMessage(bs, s.maxMessageSize)
+	}
+
+	if s.priorityFilter != nil && s.parsers == nil && !s.acceptPriority(bs) {
+		if s.audit != nil {
+			s.audit.record(&Message{Time: t, Source: addr, Size: size}, verdictRejected, nil)
 		}
+		return nil, false
+	}
+
+	if s.limiter != nil && !s.limiter.needsParsing() && !s.allowSourceOf(addr, sourceIP(addr), t, size, s.limiter.RateLimit) {
+		return nil, true
+	}
+
+	if s.lazy && s.parsers == nil && isAcceptEverything(acceptFunc) {
+		if m = s.receiveLazily(bs, addr, t); m != nil {
+			m.Size = size
+			m.TLSPeer = peer
+			if s.limiter != nil && s.limiter.needsParsing() && !s.allowSourceOf(addr, sourceIP(addr), t, size, s.limiter.RateLimit) {
+				return nil, true // the hostname and timestamp are not known yet
+			}
+			return m, false
+		}
+	}
+
+	m, err := s.parse(bs, addr, t)
+	if err != nil {
+		s.logger.Println(err.Error())
+		s.audit.record(&Message{Time: t, Source: addr, Size: size}, verdictInvalid, nil)
+		return nil, true
+	}
+
+	if s.facilities != nil {
+		m.Facility = s.facilities(m.Facility)
+	}
+	if s.priorityFilter != nil && s.parsers != nil && !s.priorityFilter(m.Facility, m.Severity) {
+		s.audit.record(&Message{Time: t, Source: addr, Size: size}, verdictRejected, nil)
+		return nil, false
+	}
+
+	m.Source = addr
+	m.Size = size
+	m.TLSPeer = peer
+
+	if !acceptFunc(m) {
+		s.audit.record(m, verdictRejected, nil)
+		return nil, false
+	}
+
+	if s.limiter != nil && s.limiter.needsParsing() && !s.allowMessage(m, t) {
+		return nil, true
 	}
+	return m, false
 }
@@ -0,0 +1,262 @@
+package syslog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestServer_Shutdown_timeout(t *testing.T) {
+	var cleanedUp bool
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m == nil {
+			select {} // hangs forever
+		}
+		return m
+	}))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m == nil {
+			cleanedUp = true
+		}
+		return m
+	}))
+	s.SetShutdownTimeout(20 * time.Millisecond)
+
+	t0 := time.Now()
+	s.Shutdown()
+	expect.Bool(time.Since(t0) < time.Second).ToBeTrue(t)
+	expect.Bool(cleanedUp).ToBeTrue(t)
+}
+
+func TestServer_ListenPacketConn(t *testing.T) {
+	received := make(chan *Message, 1)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	expect.Error(err).ToBeNil(t)
+	s.ListenPacketConn(pc, Severities{Err}.Filter())
+
+	c, err := net.Dial("udp", pc.LocalAddr().String())
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+	c.Write([]byte("<14>1 - host app - - - rejected"))
+	c.Write([]byte("<11>1 - host app - - - accepted"))
+
+	m := <-received
+	expect.String(m.Content).ToBe(t, "accepted")
+	expect.String(m.NetSrc()).ToBe(t, "127.0.0.1")
+}
+
+func TestServer_ShutdownContext(t *testing.T) {
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m == nil {
+			select {} // hangs forever
+		}
+		return m
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := s.ShutdownContext(ctx)
+	expect.Error(err).ToContain(t, "handler syslog.handlerFunc did not shut down: context deadline exceeded")
+	expect.Bool(errors.Is(err, context.DeadlineExceeded)).ToBeTrue(t)
+
+	// a second shutdown does nothing
+	expect.Error(s.ShutdownContext(context.Background())).ToBeNil(t)
+}
+
+func TestServer_ListenContext(t *testing.T) {
+	s := NewServer(WithQueueLength(1))
+	defer s.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	expect.Error(s.ListenContext(ctx, "127.0.0.1:0", AcceptEverything)).ToBeNil(t)
+
+	s.mu.Lock()
+	expect.Number(len(s.conns)).ToBe(t, 1)
+	s.mu.Unlock()
+
+	cancel()
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		n := len(s.conns)
+		s.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("socket was not closed")
+}
+
+func TestServer_SetPriorityFilter(t *testing.T) {
+	s := NewServer()
+	pf, err := ParsePriority("user.err")
+	expect.Error(err).ToBeNil(t)
+	s.SetPriorityFilter(pf)
+	defer s.Shutdown()
+
+	expect.Bool(s.receive([]byte("<14>1 - host app - - - info"), nil, AcceptEverything) == nil).ToBeTrue(t)
+	expect.Bool(s.receive([]byte("<11>1 - host app - - - error"), nil, AcceptEverything) != nil).ToBeTrue(t)
+}
+
+func TestServer_ReplaceHandler(t *testing.T) {
+	s := NewServer(WithQueueLength(1))
+	defer s.Shutdown()
+
+	first := make(chan *Message, 2)
+	second := make(chan *Message, 1)
+	closed := make(chan string, 2)
+	recorder := func(name string, ch chan *Message) Handler {
+		return handlerFunc(func(m *Message) *Message {
+			if m == nil {
+				closed <- name
+			} else {
+				ch <- m
+			}
+			return m
+		})
+	}
+
+	expect.Error(s.AddNamedHandler("out", recorder("first", first))).ToBeNil(t)
+	expect.Error(s.AddNamedHandler("out", recorder("first", first))).ToContain(t, "already in use")
+	expect.Error(s.AddNamedHandler("", recorder("first", first))).ToContain(t, "needs a name")
+
+	s.push(&Message{Content: "a"})
+	expect.String((<-first).Content).ToBe(t, "a")
+
+	expect.Error(s.ReplaceHandler("out", recorder("second", second))).ToBeNil(t)
+	expect.String(<-closed).ToBe(t, "first")
+
+	s.push(&Message{Content: "b"})
+	expect.String((<-second).Content).ToBe(t, "b")
+
+	expect.Error(s.RemoveHandler("out")).ToBeNil(t)
+	expect.String(<-closed).ToBe(t, "second")
+	expect.Error(s.RemoveHandler("out")).ToContain(t, "no such handler")
+	expect.Error(s.ReplaceHandler("out", recorder("third", first))).ToContain(t, "no such handler")
+}
+
+// brokenConn is a packet connection that fails on every read.
+type brokenConn struct {
+	net.PacketConn
+}
+
+func (brokenConn) ReadFrom([]byte) (int, net.Addr, error) {
+	return 0, nil, errors.New("device removed")
+}
+
+func (brokenConn) LocalAddr() net.Addr { return &net.UDPAddr{Port: 514} }
+
+func (brokenConn) Close() error { return nil }
+
+func TestServer_Wait(t *testing.T) {
+	s := NewServer(WithLogger(log.New(io.Discard, "", 0)))
+	expect.Error(s.Wait()).ToBeNil(t)
+
+	s.ListenPacketConn(brokenConn{}, AcceptEverything)
+	expect.Error(s.Wait()).ToContain(t, "device removed")
+	expect.Error(s.Err()).ToContain(t, "read :514: device removed")
+	s.Shutdown()
+
+	s = NewServer()
+	expect.Error(s.Listen("127.0.0.1:0")).ToBeNil(t)
+	s.Shutdown()
+	expect.Error(s.Wait()).ToBeNil(t)
+}
+
+// closerFunc is a handler that reports an error when it is closed.
+type closerFunc func() error
+
+func (closerFunc) Handle(m *Message) *Message { return m }
+
+func (f closerFunc) Close() error { return f() }
+
+func TestServer_Close(t *testing.T) {
+	var _ = []io.Closer{
+		&Server{}, &FileHandler{}, &FIFOHandler{}, &ConsoleHandler{}, &WallHandler{},
+		&ReplicationHandler{}, PrintHandler(""), DebugHandler{}, filterHandler{}, routeHandler{},
+	}
+
+	closed := 0
+	s := NewServer()
+	s.AddHandler(FilterHandler(AcceptEverything, closerFunc(func() error {
+		closed++
+		return errors.New("flush failed")
+	})))
+
+	expect.Error(s.Close()).ToContain(t, "flush failed")
+	expect.Error(s.Close()).ToBeNil(t)
+	expect.Number(closed).ToBe(t, 1)
+
+	h := NewFileHandler(filepath.Join(t.TempDir(), "test.log"), RFCFormat)
+	h.Handle(&Message{Content: "hello"})
+	expect.Error(h.Close()).ToBeNil(t)
+	expect.Error(h.Close()).ToBeNil(t)
+}
+
+func TestRouteHandler(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var backfill []string
+	h := RouteHandler(OlderThan(time.Hour),
+		handlerFunc(func(m *Message) *Message {
+			m.Annotate("route", "backfill")
+			return m
+		}),
+		handlerFunc(func(m *Message) *Message {
+			backfill = append(backfill, m.Content+" "+m.Annotations["route"])
+			return m
+		}),
+	)
+
+	recent := &Message{Time: now, Timestamp: now.Add(-time.Minute), Content: "recent"}
+	old := &Message{Time: now, Timestamp: now.Add(-2 * time.Hour), Content: "old"}
+	undated := &Message{Time: now, Content: "undated"}
+
+	expect.Any(h.Handle(recent)).ToBe(t, recent)
+	expect.Any(h.Handle(undated)).ToBe(t, undated)
+	expect.Bool(h.Handle(old) == nil).ToBeTrue(t)
+	expect.Slice(backfill).ToBe(t, "old backfill")
+}
+
+func TestPrivacyHandler(t *testing.T) {
+	var forwarded []*Message
+	forwarder := handlerFunc(func(m *Message) *Message {
+		forwarded = append(forwarded, m)
+		return m
+	})
+
+	m := &Message{Hostname: "myhost", Application: "app", Data: `[id a="1"]`, Content: "secret"}
+	expect.Any(PrivacyHandler(nil, forwarder).Handle(m)).ToBe(t, m)
+	expect.Any(PrivacyHandler([]byte("key"), forwarder).Handle(m)).ToBe(t, m)
+	expect.String(m.Content).ToBe(t, "secret")
+
+	expect.Number(len(forwarded)).ToBe(t, 2)
+	expect.String(forwarded[0].Hostname).ToBe(t, "myhost")
+	expect.String(forwarded[0].Data).ToBe(t, `[id a="1"]`)
+	expect.String(forwarded[0].Content).ToBe(t, "")
+	expect.Number(len(forwarded[1].Content)).ToBe(t, 64)
+
+	// unparsed messages are parsed so that the content can be removed from the raw bytes
+	lazy := &Message{Raw: []byte("<13>1 - myhost app - - - secret"), unparsed: true}
+	PrivacyHandler(nil, forwarder).Handle(lazy)
+	expect.String(forwarded[2].Application).ToBe(t, "app")
+	expect.String(forwarded[2].Content).ToBe(t, "")
+	expect.Number(len(forwarded[2].Raw)).ToBe(t, 0)
+}
@@ -3,33 +3,44 @@ package syslog
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 )
 
 // ParsePriorityFilter parses a priority such as "user.info,warn,error"
 func ParsePriorityFilter(pri string) (Filter, error) {
+	pf, err := ParsePriority(pri)
+	if err != nil {
+		return nil, err
+	}
+	return pf.Filter(), nil
+}
+
+// ParsePriority parses a priority such as "user.info,warn,error" like [ParsePriorityFilter],
+// but gives a [PriorityFilter].
+func ParsePriority(pri string) (PriorityFilter, error) {
 	parts := strings.Split(pri, ".")
 	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
 		return nil, fmt.Errorf("%s: invalid priority filter\n"+
 			"Must be like \"*.*\" | \"user.info\" | \"kern,auth.*\" etc.\n", pri)
 	}
 
-	if parts[0] == "*" && parts[1] == "*" {
-		return AcceptEverything, nil
-	} else if parts[0] != "*" && parts[1] != "*" {
-		fs, err1 := ParseFacilities(parts[0])
-		ss, err2 := ParseSeverities(parts[1])
-		if err1 != nil || err2 != nil {
-			return nil, errors.Join(err1, err2)
-		}
-		return All(fs.Filter(), ss.Filter()), nil
-	} else if parts[0] == "*" && parts[1] != "*" {
-		ss, err := ParseSeverities(parts[1])
-		return ss.Filter(), err
-	} else {
-		fs, err := ParseFacilities(parts[0])
-		return fs.Filter(), err
+	var fs Facilities
+	var ss Severities
+	var err1, err2 error
+	if parts[0] != "*" {
+		fs, err1 = ParseFacilities(parts[0])
+	}
+	if parts[1] != "*" {
+		ss, err2 = ParseSeverities(parts[1])
 	}
+	if err1 != nil || err2 != nil {
+		return nil, errors.Join(err1, err2)
+	}
+
+	return func(f Facility, s Severity) bool {
+		return (fs == nil || slices.Contains(fs, f)) && (ss == nil || slices.Contains(ss, s))
+	}, nil
 }
 
 //-------------------------------------------------------------------------------------------------
@@ -0,0 +1,178 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// ShardKey gets the key by which messages are assigned to shards; see [FileHandler.SetShards].
+type ShardKey func(*Message) string
+
+// shardQueueLength is the number of writes that can wait for each shard.
+const shardQueueLength = 1024
+
+// SetShards spreads the messages for each file across n shard files, named by appending
+// ".0" to ".n-1" to the filename, e.g. app.log.0 to app.log.3. Each shard is written by its
+// own goroutine through a buffer, which is flushed whenever the shard has nothing more to
+// write, so one very busy application can be written faster than to a single file. The
+// shard is chosen by the hash of key, so the messages with the same key stay in order in
+// the same shard; if key is nil, messages are dealt to the shards in turn. Messages are
+// still rendered in the order they are handled.
+//
+// Shard files are rotated and locked like other files (see [FileHandler.SetLocking]), except
+// that each buffered write is locked rather than each message. Closing the handler, or
+// calling SetShards again, flushes and closes the shard files. Use n < 2 to stop sharding.
+func (h *FileHandler) SetShards(n int, key ShardKey) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopShards()
+	h.shardCount = 0
+	h.shardKey = key
+	if n > 1 {
+		h.shardCount = n
+	}
+}
+
+// shard chooses the shard for a message, or -1 if sharding is disabled.
+func (h *FileHandler) shard(m *Message) int {
+	if h.shardCount == 0 {
+		return -1
+	}
+	if h.shardKey == nil {
+		shard := h.nextShard
+		h.nextShard = (shard + 1) % h.shardCount
+		return shard
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(h.shardKey(m)))
+	return int(hash.Sum32() % uint32(h.shardCount))
+}
+
+// shardFile opens a writer for a shard file, starting the shard goroutines if necessary.
+func (h *FileHandler) shardFile(f *os.File, shard int) *shardWriter {
+	if h.shards == nil {
+		h.shards = make([]*fileShard, h.shardCount)
+		for i := range h.shards {
+			h.shards[i] = startShard()
+		}
+	}
+	var w io.Writer = f
+	if h.locking {
+		w = lockedFile{f}
+	}
+	return &shardWriter{shard: h.shards[shard], f: f, w: bufio.NewWriter(w)}
+}
+
+// stopShards flushes and closes the shard files that are still open, then stops the shard
+// goroutines.
+func (h *FileHandler) stopShards() {
+	for id, f := range h.f {
+		if sw, ok := f.(*shardWriter); ok {
+			checkErr(sw.Close(), "close", sw.f.Name())
+			delete(h.f, id)
+		}
+	}
+	for _, s := range h.shards {
+		close(s.ops)
+		s.stopped.Wait()
+	}
+	h.shards = nil
+}
+
+func shardName(filename string, shard int) string {
+	if shard < 0 {
+		return filename
+	}
+	return filename + "." + strconv.Itoa(shard)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// fileShard is a goroutine that writes to the files of one shard.
+type fileShard struct {
+	ops     chan shardOp
+	stopped sync.WaitGroup
+}
+
+// shardOp writes data to a file, or closes it if closed is not nil.
+type shardOp struct {
+	sw     *shardWriter
+	data   []byte
+	closed chan error
+}
+
+func startShard() *fileShard {
+	s := &fileShard{ops: make(chan shardOp, shardQueueLength)}
+	s.stopped.Add(1)
+	go s.run()
+	return s
+}
+
+func (s *fileShard) run() {
+	defer s.stopped.Done()
+
+	unflushed := make(map[*shardWriter]struct{})
+	for op := range s.ops {
+		if op.closed != nil {
+			delete(unflushed, op.sw)
+			err := op.sw.w.Flush()
+			if e := op.sw.f.Close(); err == nil {
+				err = e
+			}
+			op.closed <- err
+			continue
+		}
+
+		if op.sw.w.Buffered() > 0 && len(op.data) > op.sw.w.Available() {
+			// so that each line is written whole, not split between writes
+			checkErr(op.sw.w.Flush(), "write", op.sw.f.Name())
+		}
+		_, err := op.sw.w.Write(op.data)
+		checkErr(err, "write", op.sw.f.Name())
+		unflushed[op.sw] = struct{}{}
+
+		if len(s.ops) == 0 {
+			for sw := range unflushed {
+				checkErr(sw.w.Flush(), "write", sw.f.Name())
+			}
+			clear(unflushed)
+		}
+	}
+}
+
+// shardWriter is an open shard file, written by its shard's goroutine.
+type shardWriter struct {
+	shard *fileShard
+	f     *os.File
+	w     *bufio.Writer // only used by the shard goroutine
+}
+
+// lockedFile writes to a file whilst holding a lock on it.
+type lockedFile struct {
+	*os.File
+}
+
+func (f lockedFile) Write(bs []byte) (int, error) {
+	if err := flock(f.File, true); err != nil {
+		return 0, err
+	}
+	defer funlock(f.File)
+	return f.File.Write(bs)
+}
+
+func (sw *shardWriter) Write(bs []byte) (int, error) {
+	sw.shard.ops <- shardOp{sw: sw, data: bytes.Clone(bs)}
+	return len(bs), nil
+}
+
+// Close flushes and closes the file after the writes that are waiting.
+func (sw *shardWriter) Close() error {
+	closed := make(chan error, 1)
+	sw.shard.ops <- shardOp{sw: sw, closed: closed}
+	return <-closed
+}
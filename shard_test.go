@@ -0,0 +1,112 @@
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestFileHandler_SetShards(t *testing.T) {
+	dir := t.TempDir()
+	h := NewFileHandler(filepath.Join(dir, "%programname%.log"), "%P %C")
+	h.SetShards(4, func(m *Message) string { return m.ProcID })
+
+	for i := range 400 {
+		h.Handle(&Message{Version: 1, Application: "big", ProcID: fmt.Sprint(i % 8), Content: fmt.Sprint(i)})
+	}
+	h.Handle(&Message{Application: "small", ProcID: "1", Content: "x"})
+	expect.Error(h.Close()).ToBeNil(t)
+
+	total := 0
+	owner := make(map[string]string) // ProcID to shard file
+	for i := range 4 {
+		name := filepath.Join(dir, fmt.Sprintf("big.log.%d", i))
+		bs, err := os.ReadFile(name)
+		expect.Error(err).ToBeNil(t)
+
+		last := make(map[string]int)
+		for _, line := range strings.Split(strings.TrimSpace(string(bs)), "\n") {
+			if line == "" {
+				continue
+			}
+			var pid string
+			var n int
+			fmt.Sscan(line, &pid, &n)
+			if o, seen := owner[pid]; seen && o != name {
+				t.Errorf("%s is in %s and %s", pid, o, name)
+			}
+			owner[pid] = name
+			if prev, seen := last[pid]; seen && prev > n {
+				t.Errorf("%s: %d is after %d", name, n, prev)
+			}
+			last[pid] = n
+			total++
+		}
+	}
+	expect.Number(total).ToBe(t, 400)
+	expect.Number(len(owner)).ToBe(t, 8)
+
+	small, _ := filepath.Glob(filepath.Join(dir, "small.log.*"))
+	expect.Slice(small).ToHaveLength(t, 1)
+	_, err := os.Stat(filepath.Join(dir, "big.log"))
+	expect.Bool(os.IsNotExist(err)).ToBeTrue(t)
+}
+
+func TestFileHandler_SetShards_roundRobin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	h := NewFileHandler(path, "%C")
+	h.SetShards(3, nil)
+
+	for i := range 7 {
+		h.Handle(&Message{Content: fmt.Sprint(i)})
+	}
+	h.SigHup()
+	h.Handle(&Message{Content: "after"})
+	expect.Error(h.Close()).ToBeNil(t)
+
+	for i, want := range []string{"0\n3\n6\n", "1\n4\n", "2\n5\n"} {
+		bs, err := os.ReadFile(fmt.Sprintf("%s.%d", path, i))
+		expect.Error(err).ToBeNil(t)
+		if i == 1 {
+			want += "after\n"
+		}
+		expect.String(string(bs)).ToBe(t, want)
+	}
+
+	h.SetShards(0, nil)
+	h.Handle(&Message{Content: "unsharded"})
+	expect.Error(h.Close()).ToBeNil(t)
+	bs, _ := os.ReadFile(path)
+	expect.String(string(bs)).ToBe(t, "unsharded\n")
+}
+
+func TestFileHandler_SetShards_whilstOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	h := NewFileHandler(path, "%C")
+	h.SetShards(2, nil)
+	h.Handle(&Message{Content: "a"})
+	h.Handle(&Message{Content: "b"})
+	var open []*os.File
+	for _, f := range h.f {
+		open = append(open, f.(*shardWriter).f)
+	}
+	expect.Slice(open).ToHaveLength(t, 2)
+
+	// the open shard files are flushed and closed
+	h.SetShards(0, nil)
+	expect.Number(len(h.f)).ToBe(t, 0)
+	for _, f := range open {
+		_, err := f.Write([]byte("x"))
+		expect.Bool(errors.Is(err, os.ErrClosed)).ToBeTrue(t)
+	}
+	for i, want := range []string{"a\n", "b\n"} {
+		bs, err := os.ReadFile(fmt.Sprintf("%s.%d", path, i))
+		expect.String(string(bs), err).ToBe(t, want)
+	}
+	expect.Error(h.Close()).ToBeNil(t)
+}
@@ -0,0 +1,150 @@
+package syslog
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// IdentitySource supplies a workload identity for TLS, such as an X.509 SPIFFE Verifiable
+// Identity Document (SVID), and the bundle of CA certificates used to verify peers. Both
+// may change at any time, e.g. when they are rotated by a service mesh. The Go standard
+// library does not implement the SPIFFE Workload API, so this is provided using a
+// third-party package, for example by adapting *workloadapi.X509Source from
+// github.com/spiffe/go-spiffe/v2:
+//
+//	func (a adapter) Certificate() (*tls.Certificate, error) {
+//		svid, err := a.source.GetX509SVID()
+//		if err != nil {
+//			return nil, err
+//		}
+//		cert := &tls.Certificate{PrivateKey: svid.PrivateKey, Leaf: svid.Certificates[0]}
+//		for _, c := range svid.Certificates {
+//			cert.Certificate = append(cert.Certificate, c.Raw)
+//		}
+//		return cert, nil
+//	}
+//
+//	func (a adapter) TrustBundle() (*x509.CertPool, error) {
+//		bundle, err := a.source.GetX509BundleForTrustDomain(a.trustDomain)
+//		if err != nil {
+//			return nil, err
+//		}
+//		pool := x509.NewCertPool()
+//		for _, c := range bundle.X509Authorities() {
+//			pool.AddCert(c)
+//		}
+//		return pool, nil
+//	}
+type IdentitySource interface {
+	Certificate() (*tls.Certificate, error)
+	TrustBundle() (*x509.CertPool, error)
+}
+
+// SPIFFEServerConfig creates a configuration for TLS listeners, such as [Server.ListenTLS],
+// that presents the current identity from src and requires each client to present an
+// identity verified by the current trust bundle. If authorize is not nil, it must also
+// accept the client's SPIFFE ID, e.g. "spiffe://example.org/router". The SPIFFE ID of
+// each client is attached to its messages (see [PeerIdentity]).
+func SPIFFEServerConfig(src IdentitySource, authorize func(id string) bool) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			bundle, err := src.TrustBundle()
+			if err != nil {
+				return nil, err
+			}
+			return &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+					return src.Certificate()
+				},
+				ClientAuth: tls.RequireAndVerifyClientCert,
+				ClientCAs:  bundle,
+				VerifyConnection: func(cs tls.ConnectionState) error {
+					return authorizeSPIFFE(cs.PeerCertificates[0], authorize)
+				},
+			}, nil
+		},
+	}
+}
+
+// SPIFFEClientConfig creates a configuration for forwarders, such as [ReplicationHandler],
+// that presents the current identity from src and verifies the server using the current
+// trust bundle. SPIFFE identities do not contain hostnames, so the server is identified
+// by its SPIFFE ID instead, which authorize must accept, if it is not nil.
+func SPIFFEClientConfig(src IdentitySource, authorize func(id string) bool) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return src.Certificate()
+		},
+		// the standard verification requires a hostname, so it is replaced
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifySPIFFEPeer(src, rawCerts, authorize)
+		},
+	}
+}
+
+func verifySPIFFEPeer(src IdentitySource, rawCerts [][]byte, authorize func(string) bool) error {
+	bundle, err := src.TrustBundle()
+	if err != nil {
+		return err
+	}
+
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		if certs[i], err = x509.ParseCertificate(raw); err != nil {
+			return err
+		}
+	}
+	if len(certs) == 0 {
+		return errors.New("no peer certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		Roots:         bundle,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		return err
+	}
+	return authorizeSPIFFE(certs[0], authorize)
+}
+
+func authorizeSPIFFE(cert *x509.Certificate, authorize func(string) bool) error {
+	id := spiffeID(cert)
+	if id == "" {
+		return errors.New("peer certificate has no SPIFFE ID")
+	}
+	if authorize != nil && !authorize(id) {
+		return fmt.Errorf("%s: SPIFFE ID is not authorised", id)
+	}
+	return nil
+}
+
+// spiffeID gets the SPIFFE ID of a certificate, which is its only URI SAN, or returns
+// blank if it has none.
+func spiffeID(cert *x509.Certificate) string {
+	if len(cert.URIs) != 1 || cert.URIs[0].Scheme != "spiffe" {
+		return ""
+	}
+	return cert.URIs[0].String()
+}
+
+// SPIFFETrustDomain returns an authoriser for [SPIFFEServerConfig] or [SPIFFEClientConfig]
+// that accepts every SPIFFE ID in a trust domain, such as "example.org".
+func SPIFFETrustDomain(td string) func(id string) bool {
+	return func(id string) bool {
+		u, err := url.Parse(id)
+		return err == nil && u.Host == td
+	}
+}
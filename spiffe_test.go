@@ -0,0 +1,61 @@
+package syslog
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+// staticIdentity is a stand-in for a SPIFFE Workload API client.
+type staticIdentity struct {
+	cert   tls.Certificate
+	bundle *x509.CertPool
+}
+
+func (s staticIdentity) Certificate() (*tls.Certificate, error) { return &s.cert, nil }
+func (s staticIdentity) TrustBundle() (*x509.CertPool, error)   { return s.bundle, nil }
+
+func TestSPIFFE(t *testing.T) {
+	collector, _ := testCertificateWithURIs(t, "collector", "spiffe://example.org/collector")
+	router, _ := testCertificateWithURIs(t, "router", "spiffe://example.org/router")
+	bundle := x509.NewCertPool()
+	bundle.AddCert(collector.Leaf)
+	bundle.AddCert(router.Leaf)
+
+	received := make(chan *Message, 1)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	cfg := SPIFFEServerConfig(staticIdentity{collector, bundle}, SPIFFETrustDomain("example.org"))
+	expect.Error(s.ListenReplica("127.0.0.1:0", cfg, AcceptEverything)).ToBeNil(t)
+	addr := s.listeners[0].Addr().String()
+
+	// the router does not trust another server identity
+	c, err := tls.Dial("tcp", addr, SPIFFEClientConfig(staticIdentity{router, bundle},
+		func(id string) bool { return id == "spiffe://example.org/other" }))
+	expect.Error(err).ToContain(t, "not authorised")
+	if c != nil {
+		c.Close()
+	}
+
+	h := NewReplicationHandler(addr, SPIFFEClientConfig(staticIdentity{router, bundle},
+		func(id string) bool { return id == "spiffe://example.org/collector" }))
+	h.SetTimeout(time.Second)
+	defer h.Close()
+	h.Handle(&Message{Facility: User, Severity: Info, Version: 1, Hostname: "router", Content: "hello"})
+
+	m := <-received
+	expect.String(m.Content).ToBe(t, "hello")
+	expect.String(m.TLSPeer.SPIFFEID).ToBe(t, "spiffe://example.org/router")
+
+	expect.Bool(SPIFFETrustDomain("example.org")("spiffe://other.org/router")).ToBeFalse(t)
+}
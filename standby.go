@@ -0,0 +1,179 @@
+package syslog
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+var heartbeatMagic = []byte("SYSLOG-HB\n")
+
+// A heartbeat is the magic, then the state. With a secret, the state is preceded by the
+// time it was sent (8 bytes, big-endian Unix nanoseconds) and followed by an HMAC-SHA256
+// of everything before it.
+const heartbeatTimeSize = 8
+
+// Heartbeat periodically sends UDP heartbeats from an active instance to a standby peer
+// (see [Standby]). Each heartbeat carries an opaque state snapshot, for example the
+// configuration or the position reached in a disk spool, which is handed to the standby
+// when it takes over.
+//
+// Heartbeats are authenticated with a secret shared with the standby. Without one, anyone
+// who can send datagrams to the standby can hold it back, or give it a false state.
+type Heartbeat struct {
+	conn     net.Conn
+	secret   []byte
+	state    func() []byte
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// StartHeartbeat starts sending heartbeats to the standby peer at the specified UDP address.
+// The secret must match the standby's; it may be nil if the network is trusted. The state
+// function is called for every heartbeat; it may be nil. The state must fit within a
+// single datagram.
+func StartHeartbeat(peer string, interval time.Duration, secret []byte, state func() []byte) (*Heartbeat, error) {
+	c, err := net.Dial("udp", peer)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Heartbeat{
+		conn:   c,
+		secret: secret,
+		state:  state,
+		stop:   make(chan struct{}),
+	}
+	go h.run(interval)
+	return h, nil
+}
+
+func (h *Heartbeat) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.send()
+		select {
+		case <-ticker.C:
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+func (h *Heartbeat) send() {
+	pkt := bytes.Clone(heartbeatMagic)
+	if h.secret != nil {
+		pkt = binary.BigEndian.AppendUint64(pkt, uint64(time.Now().UnixNano()))
+	}
+	if h.state != nil {
+		pkt = append(pkt, h.state()...)
+	}
+	if h.secret != nil {
+		mac := hmac.New(sha256.New, h.secret)
+		mac.Write(pkt)
+		pkt = mac.Sum(pkt)
+	}
+	// errors are expected whilst the standby is down, so they are ignored
+	_, _ = h.conn.Write(pkt)
+}
+
+// Stop stops sending heartbeats. The standby will take over after its timeout expires.
+// It is safe to call more than once.
+func (h *Heartbeat) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.stop)
+		h.conn.Close()
+	})
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Standby receives heartbeats from an active instance (see [Heartbeat]) and detects when
+// it has failed.
+type Standby struct {
+	conn     net.PacketConn
+	timeout  time.Duration
+	secret   []byte
+	state    []byte
+	lastSent uint64 // the time in the last authenticated heartbeat, to reject replays
+	// OnTakeover, if not nil, is called when the active instance is deemed to have failed,
+	// e.g. to move a virtual IP address to this host. It receives the last state snapshot.
+	OnTakeover func(state []byte) error
+}
+
+// NewStandby listens for heartbeats on the specified UDP address. The active instance is
+// deemed to have failed if no heartbeat arrives within the timeout, which should be
+// several times the heartbeat interval. If secret is not nil, heartbeats that were not
+// sent with the same secret, or that are replayed, are ignored.
+func NewStandby(addr string, timeout time.Duration, secret []byte) (*Standby, error) {
+	c, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Standby{conn: c, timeout: timeout, secret: secret}, nil
+}
+
+// Wait blocks until the active instance fails, then returns the last state snapshot it
+// sent. Waiting does not time out until at least one heartbeat has been received, so the
+// standby can be started before the active instance. After Wait returns, the caller
+// should start its listeners and so take over from the failed instance.
+func (s *Standby) Wait() ([]byte, error) {
+	defer s.conn.Close()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		// only genuine heartbeats extend the deadline
+		if state, ok := s.verify(buf[:n]); ok {
+			s.state = bytes.Clone(state)
+			if err = s.conn.SetReadDeadline(time.Now().Add(s.timeout)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if s.OnTakeover != nil {
+		if err := s.OnTakeover(s.state); err != nil {
+			return s.state, err
+		}
+	}
+	return s.state, nil
+}
+
+// verify checks a heartbeat, returning its state.
+func (s *Standby) verify(pkt []byte) (state []byte, ok bool) {
+	body, ok := bytes.CutPrefix(pkt, heartbeatMagic)
+	if !ok || s.secret == nil {
+		return body, ok
+	}
+
+	if len(body) < heartbeatTimeSize+sha256.Size {
+		return nil, false
+	}
+	signed, sum := pkt[:len(pkt)-sha256.Size], pkt[len(pkt)-sha256.Size:]
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(signed)
+	if !hmac.Equal(mac.Sum(nil), sum) {
+		return nil, false
+	}
+
+	sent := binary.BigEndian.Uint64(body)
+	if sent <= s.lastSent {
+		return nil, false
+	}
+	s.lastSent = sent
+	return body[heartbeatTimeSize : len(body)-sha256.Size], true
+}
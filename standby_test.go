@@ -0,0 +1,88 @@
+package syslog
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestStandby(t *testing.T) {
+	sb, err := NewStandby("127.0.0.1:0", 100*time.Millisecond, nil)
+	expect.Error(err).ToBeNil(t)
+
+	var handedOver []byte
+	sb.OnTakeover = func(state []byte) error {
+		handedOver = state
+		return nil
+	}
+
+	hb, err := StartHeartbeat(sb.conn.LocalAddr().String(), 10*time.Millisecond, nil, func() []byte {
+		return []byte("spool=1234")
+	})
+	expect.Error(err).ToBeNil(t)
+
+	time.AfterFunc(50*time.Millisecond, hb.Stop)
+
+	state, err := sb.Wait()
+	expect.String(string(state), err).ToBe(t, "spool=1234")
+	expect.String(string(handedOver)).ToBe(t, "spool=1234")
+	hb.Stop()
+}
+
+func TestStandby_secret(t *testing.T) {
+	secret := []byte("shared secret")
+	sb, err := NewStandby("127.0.0.1:0", 100*time.Millisecond, secret)
+	expect.Error(err).ToBeNil(t)
+	addr := sb.conn.LocalAddr().String()
+
+	hb, err := StartHeartbeat(addr, 10*time.Millisecond, secret, func() []byte {
+		return []byte("spool=1234")
+	})
+	expect.Error(err).ToBeNil(t)
+	time.AfterFunc(50*time.Millisecond, hb.Stop)
+
+	// forged heartbeats do not hold the standby back
+	forger, err := StartHeartbeat(addr, 10*time.Millisecond, []byte("guess"), func() []byte {
+		return []byte("spool=0")
+	})
+	expect.Error(err).ToBeNil(t)
+	defer forger.Stop()
+
+	state, err := sb.Wait()
+	expect.String(string(state), err).ToBe(t, "spool=1234")
+}
+
+func TestStandby_verify(t *testing.T) {
+	secret := []byte("shared secret")
+	c, _ := net.Pipe()
+	hb := &Heartbeat{secret: secret, state: func() []byte { return []byte("x") }}
+	hb.conn = &capture{Conn: c}
+	hb.send()
+	pkt := hb.conn.(*capture).last
+
+	sb := &Standby{secret: secret}
+	state, ok := sb.verify(pkt)
+	expect.Bool(ok).ToBeTrue(t)
+	expect.String(string(state)).ToBe(t, "x")
+
+	// replayed
+	_, ok = sb.verify(pkt)
+	expect.Bool(ok).ToBeFalse(t)
+
+	// unauthenticated
+	_, ok = sb.verify([]byte("SYSLOG-HB\nx"))
+	expect.Bool(ok).ToBeFalse(t)
+}
+
+// capture is a connection that records the last packet written.
+type capture struct {
+	net.Conn
+	last []byte
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	c.last = p
+	return len(p), nil
+}
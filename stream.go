@@ -0,0 +1,301 @@
+package syslog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// maxFrameLength limits the size of octet-counted frames.
+const maxFrameLength = 64 * 1024
+
+// maxLengthDigits limits the MSG-LEN of octet-counted frames, which is at most maxFrameLength.
+const maxLengthDigits = 6
+
+// ListenTCP starts a goroutine that receives syslog messages over TCP on a specified
+// host:port address. Both framing methods of RFC 6587 are supported on each connection:
+// octet-counting and non-transparent framing (each message terminated by LF).
+// Only the messages matching accept are processed.
+func (s *Server) ListenTCP(addr string, accept Filter) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s.ListenListener(l, accept)
+	return nil
+}
+
+// ListenListener starts a goroutine that accepts stream connections from a listener
+// supplied by the caller, such as a custom TCP, TLS or Unix listener, or one passed from
+// another process. Both framing methods of RFC 6587 are supported. The server takes
+// ownership of l and closes it on shutdown. Only the messages matching accept are processed.
+func (s *Server) ListenListener(l net.Listener, accept Filter) {
+	if s.shutDown.Load() {
+		panic("Server is already shut down")
+	}
+
+	s.addListener(l)
+	go s.acceptStreams(l, func(c net.Conn) {
+		s.streamReceiver(c, accept)
+	})
+}
+
+// ListenUnix starts a goroutine that receives syslog messages on a stream-mode
+// (SOCK_STREAM) Unix domain socket at the specified path, as used by some daemons and
+// C libraries for /dev/log. Octet-counting framing and non-transparent framing (each
+// message terminated by LF or NUL) are supported. For datagram-mode sockets, use
+// [Server.Listen] instead. Only the messages matching accept are processed.
+func (s *Server) ListenUnix(path string, accept Filter) error {
+	l, err := s.listenUnix(path)
+	if err != nil {
+		return err
+	}
+
+	s.ListenListener(l, accept)
+	return nil
+}
+
+// listenStream opens a listener for a protocol other than plain syslog, such as RELP. The
+// protocol is recorded so that the listener is not handed over (see [Server.Handover]).
+func (s *Server) listenStream(protocol, network, addr string, cfg *tls.Config) (net.Listener, error) {
+	if s.shutDown.Load() {
+		panic("Server is already shut down")
+	}
+
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg != nil {
+		l = tls.NewListener(l, cfg)
+	}
+
+	s.mu.Lock()
+	s.protocols[l] = protocol
+	s.mu.Unlock()
+	s.addListener(l)
+	return l, nil
+}
+
+func (s *Server) addListener(l net.Listener) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, l)
+	s.mu.Unlock()
+	s.serving.Add(1)
+}
+
+// acceptStreams accepts connections until the listener is closed, running a receiver
+// goroutine for each one.
+func (s *Server) acceptStreams(l net.Listener, receiver func(net.Conn)) {
+	defer s.serving.Done()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if !s.shutDown.Load() {
+				s.fail(fmt.Errorf("accept %s: %w", l.Addr(), err))
+			}
+			return
+		}
+
+		if !s.addStream(c) {
+			c.Close()
+			return
+		}
+
+		go func() {
+			defer s.removeStream(c)
+			receiver(c)
+		}()
+	}
+}
+
+func (s *Server) addStream(c net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutDown.Load() {
+		return false
+	}
+	s.streams[c] = struct{}{}
+	s.receivers.Add(1)
+	return true
+}
+
+func (s *Server) removeStream(c net.Conn) {
+	c.Close()
+	s.mu.Lock()
+	delete(s.streams, c)
+	s.mu.Unlock()
+	s.receivers.Done()
+}
+
+func (s *Server) closeStreams() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range s.listeners {
+		checkErr(l.Close(), "close", l.Addr().String())
+	}
+	s.listeners = nil
+	for c := range s.streams {
+		c.Close()
+	}
+}
+
+func (s *Server) streamReceiver(c net.Conn, acceptFunc Filter) {
+	r := bufio.NewReaderSize(c, maxFrameLength)
+	s.readFrames(r, c, c.RemoteAddr(), acceptFunc)
+}
+
+// readFrames reads frames from a connection until it is closed; src is recorded as the source
+// of each message.
+// Messages are queued in batches of those that have already arrived.
+func (s *Server) readFrames(r *bufio.Reader, c net.Conn, src net.Addr, acceptFunc Filter) {
+	peer := tlsPeer(c)
+	batch := make([]*Message, 0, maxBatch)
+	for {
+		s.setIdleDeadline(c)
+		frame, err := readFrame(r)
+		if len(frame) > 0 {
+			if m := s.receiveFrom(frame, src, peer, acceptFunc); m != nil {
+				batch = append(batch, m)
+			}
+		}
+		if len(batch) > 0 && (len(batch) == cap(batch) || r.Buffered() == 0 || err != nil) {
+			s.pushStream(batch)
+			batch = make([]*Message, 0, maxBatch)
+		}
+		if err != nil {
+			s.logReadError(c, err)
+			return
+		}
+	}
+}
+
+// setIdleDeadline limits how long a stream connection may be idle; see [WithIdleTimeout].
+func (s *Server) setIdleDeadline(c net.Conn) {
+	if s.idleTimeout > 0 {
+		c.SetReadDeadline(time.Now().Add(s.idleTimeout))
+	}
+}
+
+// logReadError reports an error that ended a stream connection, except for the usual ones.
+func (s *Server) logReadError(c net.Conn, err error) {
+	if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrDeadlineExceeded) && !s.shutDown.Load() {
+		s.logger.Println("Read error:", c.RemoteAddr(), err)
+	}
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// readFrame reads one frame, which may use either of the framing methods of RFC 6587.
+// Octet-counted frames start with MSG-LEN SP whereas syslog messages usually start with '<',
+// so the method is easily determined for each frame; a message without a PRI part that
+// starts with a digit is read as a non-transparent frame unless it looks like MSG-LEN SP.
+// A final frame without a trailer is returned along with [io.EOF].
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case '1' <= b[0] && b[0] <= '9':
+			n, width, ok, _ := peekFrameLength(r)
+			if !ok {
+				return readNonTransparent(r)
+			}
+			return readOctets(r, n, width)
+		case b[0] == '\n' || b[0] == '\r' || b[0] == 0:
+			_, _ = r.ReadByte() // skip empty lines between frames
+		default:
+			return readNonTransparent(r)
+		}
+	}
+}
+
+// readNonTransparent reads one frame using the non-transparent framing method of
+// RFC 6587, in which each message is terminated by LF. NUL is also accepted as a
+// terminator because it is used by some C libraries on stream-mode Unix sockets.
+func readNonTransparent(r *bufio.Reader) ([]byte, error) {
+	var frame []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return frame, err
+		}
+
+		if b == '\n' || b == 0 {
+			return frame, nil
+		}
+
+		if len(frame) == maxFrameLength {
+			return nil, fmt.Errorf("%q: frame is too long", cropString(string(frame), 50))
+		}
+		frame = append(frame, b)
+	}
+}
+
+// readOctetCounted reads one frame using the octet-counting method of RFC 6587, i.e.
+// MSG-LEN SP SYSLOG-MSG.
+func readOctetCounted(r *bufio.Reader) ([]byte, error) {
+	n, width, ok, err := peekFrameLength(r)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		b, _ := r.Peek(min(r.Buffered(), maxLengthDigits+1))
+		return nil, fmt.Errorf("%q: invalid frame length", b)
+	}
+	return readOctets(r, n, width)
+}
+
+// peekFrameLength looks for MSG-LEN SP, returning the length and the number of bytes it
+// occupies; ok is false if it is absent or too long. At most maxLengthDigits+1 bytes are
+// examined.
+func peekFrameLength(r *bufio.Reader) (n, width int, ok bool, err error) {
+	for width <= maxLengthDigits {
+		b, err := r.Peek(width + 1)
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		switch c := b[width]; {
+		case '0' <= c && c <= '9' && width < maxLengthDigits:
+			n = n*10 + int(c-'0')
+			width++
+		case c == ' ' && width > 0 && b[0] != '0' && n <= maxFrameLength:
+			return n, width + 1, true, nil
+		default:
+			return 0, 0, false, nil
+		}
+	}
+	return 0, 0, false, nil
+}
+
+// readOctets reads a frame of n bytes after its MSG-LEN SP, which occupies width bytes.
+func readOctets(r *bufio.Reader, n, width int) ([]byte, error) {
+	if _, err := r.Discard(width); err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// appendOctetCounted appends one frame using the octet-counting method of RFC 6587.
+func appendOctetCounted(bs []byte, msg []byte) []byte {
+	bs = strconv.AppendInt(bs, int64(len(msg)), 10)
+	bs = append(bs, ' ')
+	return append(bs, msg...)
+}
@@ -0,0 +1,126 @@
+package syslog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestReadFrame(t *testing.T) {
+	in := "<34>1 - host app - - - first\n" +
+		"<34>1 - host app - - - zero\x00" +
+		"30 <34>1 - host app - - - sec\nond" +
+		"\r\n<34>Oct 11 22:14:15 mymachine su: third"
+	r := bufio.NewReader(strings.NewReader(in))
+
+	f, err := readFrame(r)
+	expect.String(string(f), err).ToBe(t, "<34>1 - host app - - - first")
+
+	f, err = readFrame(r)
+	expect.String(string(f), err).ToBe(t, "<34>1 - host app - - - zero")
+
+	f, err = readFrame(r)
+	expect.String(string(f), err).ToBe(t, "<34>1 - host app - - - sec\nond")
+
+	f, err = readFrame(r)
+	expect.String(string(f)).ToBe(t, "<34>Oct 11 22:14:15 mymachine su: third")
+	expect.Bool(err == io.EOF).ToBeTrue(t)
+
+	// without a valid MSG-LEN SP, a frame that starts with a digit is terminated by LF
+	r = bufio.NewReader(strings.NewReader("99999999 x\n2024-01-01 no PRI\n12345678901234567890"))
+	f, err = readFrame(r)
+	expect.String(string(f), err).ToBe(t, "99999999 x")
+	f, err = readFrame(r)
+	expect.String(string(f), err).ToBe(t, "2024-01-01 no PRI")
+	f, _ = readFrame(r)
+	expect.String(string(f)).ToBe(t, "12345678901234567890")
+
+	_, err = readOctetCounted(bufio.NewReader(strings.NewReader("99999999 x")))
+	expect.Error(err).ToContain(t, "invalid frame length")
+	_, err = readOctetCounted(bufio.NewReader(strings.NewReader("65537 x")))
+	expect.Error(err).ToContain(t, "invalid frame length")
+}
+
+func TestWithIdleTimeout(t *testing.T) {
+	s := NewServer(WithIdleTimeout(20 * time.Millisecond))
+	defer s.Shutdown()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	expect.Error(err).ToBeNil(t)
+	s.ListenListener(l, AcceptEverything)
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+
+	// the server closes the idle connection
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = c.Read(make([]byte, 1))
+	expect.Bool(errors.Is(err, io.EOF)).ToBeTrue(t)
+}
+
+func TestListenUnix(t *testing.T) {
+	received := make(chan *Message, 1)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	path := filepath.Join(t.TempDir(), "log")
+	expect.Error(s.ListenUnix(path, AcceptEverything)).ToBeNil(t)
+
+	c, err := net.Dial("unix", path)
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+
+	_, err = io.WriteString(c, "<13>Oct 11 22:14:15 myhost myapp[123]: hello\x00")
+	expect.Error(err).ToBeNil(t)
+
+	m := <-received
+	expect.String(m.Application).ToBe(t, "myapp")
+	expect.String(m.Content).ToBe(t, ": hello")
+}
+
+func TestListenUnix_backpressure(t *testing.T) {
+	gate := make(chan struct{})
+	received := make(chan *Message, 100)
+	s := NewServer(WithQueueLength(1), WithOverflowPolicy(OverflowDropNewest))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			<-gate
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	path := filepath.Join(t.TempDir(), "log")
+	expect.Error(s.ListenUnix(path, AcceptEverything)).ToBeNil(t)
+
+	c, err := net.Dial("unix", path)
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+
+	// more batches than fit in the queue
+	for i := 0; i < 300; i++ {
+		_, err = io.WriteString(c, "<13>1 - host app - - - hello\n")
+		expect.Error(err).ToBeNil(t)
+	}
+
+	close(gate)
+	for i := 0; i < 300; i++ {
+		<-received
+	}
+	expect.Number(s.Dropped()).ToBe(t, 0)
+}
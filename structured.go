@@ -0,0 +1,172 @@
+package syslog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SDElement is an element of RFC 5424 structured data, such as
+// [exampleSDID@32473 iut="3" eventSource="Application"].
+type SDElement struct {
+	ID     string
+	Params []SDParam
+}
+
+// SDParam is a parameter of an [SDElement]. The value is unescaped.
+type SDParam struct {
+	Name, Value string
+}
+
+// Param gets the value of the first parameter with the given name.
+func (e SDElement) Param(name string) (string, bool) {
+	for _, p := range e.Params {
+		if p.Name == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// StructuredData parses the structured data of the message into its elements, in order,
+// removing the escapes from the parameter values. Messages without structured data have no
+// elements. If the data is malformed, the elements before the fault are returned with an
+// error. Messages that have not been parsed (see [WithLazyParsing]) are parsed first.
+func (m *Message) StructuredData() ([]SDElement, error) {
+	if err := m.Parse(); err != nil {
+		return nil, err
+	}
+	return parseStructuredData(m.Data)
+}
+
+// SetStructuredData replaces the structured data of the message with the given elements,
+// escaping the parameter values as RFC 5424 requires; with no elements, the data is "-".
+// An error is returned, leaving the message unchanged, if an SD-ID or parameter name is not
+// 1 to 32 printable ASCII characters other than '=', ']' and '"'.
+func (m *Message) SetStructuredData(elems []SDElement) error {
+	if err := m.Parse(); err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	for _, e := range elems {
+		if !validSDName(e.ID) {
+			return fmt.Errorf("%q: invalid SD-ID", e.ID)
+		}
+		for _, p := range e.Params {
+			if !validSDName(p.Name) {
+				return fmt.Errorf("%s: %q: invalid SD parameter name", e.ID, p.Name)
+			}
+		}
+		appendSDElement(&sb, e)
+	}
+
+	m.Data = sb.String()
+	if m.Data == "" {
+		m.Data = "-"
+	}
+	return nil
+}
+
+// addSDElement appends an element to the structured data, given its SD-ID and pairs of
+// parameter names and values, which are not validated.
+func (m *Message) addSDElement(id string, params ...string) {
+	e := SDElement{ID: id}
+	for i := 0; i+1 < len(params); i += 2 {
+		e.Params = append(e.Params, SDParam{params[i], params[i+1]})
+	}
+
+	var sb strings.Builder
+	if m.Data != "-" {
+		sb.WriteString(m.Data)
+	}
+	appendSDElement(&sb, e)
+	m.Data = sb.String()
+}
+
+func appendSDElement(sb *strings.Builder, e SDElement) {
+	sb.WriteString("[" + e.ID)
+	for _, p := range e.Params {
+		sb.WriteString(" " + p.Name + `="`)
+		sdEscaper.WriteString(sb, p.Value)
+		sb.WriteByte('"')
+	}
+	sb.WriteByte(']')
+}
+
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// validSDName checks an SD-NAME as defined by RFC 5424.
+func validSDName(s string) bool {
+	if len(s) == 0 || len(s) > 32 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+			return false
+		}
+	}
+	return true
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// parseStructuredData parses structured data such as [id k="v"][id2 k2="v2"], with the
+// escapes in the values removed. If the data is malformed, the elements before the fault
+// are returned with an error.
+func parseStructuredData(data string) ([]SDElement, error) {
+	if data == "" || data == "-" {
+		return nil, nil
+	}
+
+	var elems []SDElement
+	s := data
+	for len(s) > 0 {
+		if s[0] != '[' {
+			return elems, fmt.Errorf("%s: malformed structured data", cropString(data, 40))
+		}
+		end := strings.IndexAny(s, " ]")
+		if end < 0 {
+			return elems, fmt.Errorf("%s: unterminated structured data", cropString(data, 40))
+		}
+		e := SDElement{ID: s[1:end]}
+		s = s[end:]
+
+		for strings.HasPrefix(s, " ") {
+			eq := strings.Index(s, `="`)
+			if eq < 0 {
+				return elems, fmt.Errorf("%s: malformed SD parameter in %s", cropString(data, 40), e.ID)
+			}
+			value, n, ok := sdValue(s[eq+2:])
+			if !ok {
+				return elems, fmt.Errorf("%s: unterminated SD parameter in %s", cropString(data, 40), e.ID)
+			}
+			e.Params = append(e.Params, SDParam{Name: s[1:eq], Value: value})
+			s = s[eq+2+n:]
+		}
+
+		if !strings.HasPrefix(s, "]") {
+			return elems, fmt.Errorf("%s: unterminated structured data", cropString(data, 40))
+		}
+		elems = append(elems, e)
+		s = s[1:]
+	}
+	return elems, nil
+}
+
+// sdValue reads a parameter value up to the closing quote, returning the unescaped value
+// and the number of bytes consumed, including the quote.
+func sdValue(s string) (string, int, bool) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			return sb.String(), i + 1, true
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(`"\\]`, s[i+1]) >= 0:
+			i++
+			sb.WriteByte(s[i])
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
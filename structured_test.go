@@ -0,0 +1,59 @@
+package syslog
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestMessage_StructuredData(t *testing.T) {
+	m := &Message{Data: `[exampleSDID@32473 iut="3" eventSource="App\]lication"][meta x="a\"b\\c"][empty]`}
+	elems, err := m.StructuredData()
+	expect.Error(err).ToBeNil(t)
+	expect.Slice(elems).ToBe(t,
+		SDElement{"exampleSDID@32473", []SDParam{{"iut", "3"}, {"eventSource", "App]lication"}}},
+		SDElement{"meta", []SDParam{{"x", `a"b\c`}}},
+		SDElement{ID: "empty"},
+	)
+	v, found := elems[1].Param("x")
+	expect.String(v).ToBe(t, `a"b\c`)
+	expect.Bool(found).ToBeTrue(t)
+
+	m.Data = "-"
+	elems, err = m.StructuredData()
+	expect.Error(err).ToBeNil(t)
+	expect.Slice(elems).ToBeEmpty(t)
+
+	m.Data = `[ok a="1"][id k="unterminated]`
+	elems, err = m.StructuredData()
+	expect.Error(err).ToContain(t, "unterminated SD parameter in id")
+	expect.Slice(elems).ToHaveLength(t, 1)
+
+	m.Data = `[ok a="1"]junk`
+	_, err = m.StructuredData()
+	expect.Error(err).ToContain(t, "malformed structured data")
+}
+
+func TestMessage_SetStructuredData(t *testing.T) {
+	m := &Message{Data: `[old x="y"]`}
+	elems := []SDElement{
+		{"origin", []SDParam{{"ip", "10.0.0.1"}, {"software", `my "app" [v\1]`}}},
+		{ID: "meta"},
+	}
+	expect.Error(m.SetStructuredData(elems)).ToBeNil(t)
+	expect.String(m.Data).ToBe(t, `[origin ip="10.0.0.1" software="my \"app\" [v\\1\]"][meta]`)
+
+	got, err := m.StructuredData()
+	expect.Error(err).ToBeNil(t)
+	expect.Slice(got).ToBe(t, elems...)
+
+	expect.Error(m.SetStructuredData([]SDElement{{ID: "bad id"}})).ToContain(t, "invalid SD-ID")
+	expect.Error(m.SetStructuredData([]SDElement{{"id", []SDParam{{"a=b", ""}}}})).ToContain(t, "invalid SD parameter name")
+	expect.String(m.Data).ToBe(t, `[origin ip="10.0.0.1" software="my \"app\" [v\\1\]"][meta]`)
+
+	expect.Error(m.SetStructuredData(nil)).ToBeNil(t)
+	expect.String(m.Data).ToBe(t, "-")
+
+	m.addSDElement("id", "n", "v")
+	expect.String(m.Data).ToBe(t, `[id n="v"]`)
+}
@@ -0,0 +1,94 @@
+// Package syslogtest provides helpers for testing code built on the syslog package, such as
+// custom parsers, formatters and handlers: message comparison that ignores how and when a
+// message was received, round-trip checks and golden files.
+//
+// Golden files are rewritten, rather than compared, when the tests are run with -update:
+//
+//	go test ./... -update
+package syslogtest
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/syslog"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files instead of comparing them")
+
+// AssertEqual reports an error for each field that differs between the messages, ignoring
+// the receive metadata; see [syslog.DiffMessages].
+func AssertEqual(t testing.TB, want, got *syslog.Message) bool {
+	t.Helper()
+	diffs := syslog.DiffMessages(want, got)
+	for _, d := range diffs {
+		t.Error(d)
+	}
+	return len(diffs) == 0
+}
+
+// ParseFunc parses a message, e.g. [syslog.ParseMessage].
+type ParseFunc func([]byte) (*syslog.Message, error)
+
+// FormatFunc renders a message, e.g. as [syslog.Message.AppendFormat] does.
+type FormatFunc func(*syslog.Message) []byte
+
+// Format returns a [FormatFunc] that uses a format string such as [syslog.RFCFormat].
+// Note that the timestamps of formatted messages have whole seconds, so messages with
+// fractional timestamps do not survive a round trip.
+func Format(format string) FormatFunc {
+	return func(m *syslog.Message) []byte {
+		return m.AppendFormat(nil, format)
+	}
+}
+
+// RoundTrip parses pkt, renders the result and parses that again, failing the test if
+// either parse fails or the two messages differ. It returns the first message and the
+// rendering, e.g. for use with [Golden].
+func RoundTrip(t testing.TB, pkt []byte, parse ParseFunc, format FormatFunc) (*syslog.Message, []byte) {
+	t.Helper()
+	m1, err := parse(pkt)
+	if err != nil {
+		t.Fatalf("%q: %v", pkt, err)
+	}
+
+	rendered := format(m1)
+	m2, err := parse(rendered)
+	if err != nil {
+		t.Fatalf("%q: %v", rendered, err)
+	}
+
+	if diffs := syslog.DiffMessages(m1, m2); len(diffs) > 0 {
+		t.Errorf("%q was rendered as %q:\n%s", pkt, rendered, strings.Join(diffs, "\n"))
+	}
+	return m1, rendered
+}
+
+// Golden compares got with the contents of testdata/name, failing the test if they differ.
+// With -update, the file is written instead.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("%s differs:\nwant %q\n got %q", path, want, got)
+	}
+}
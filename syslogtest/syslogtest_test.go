@@ -0,0 +1,73 @@
+package syslogtest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+	"github.com/rickb777/syslog"
+	"github.com/rickb777/syslog/bench"
+)
+
+func TestRoundTrip(t *testing.T) {
+	parseJSON := func(bs []byte) (*syslog.Message, error) {
+		m, _, err := syslog.ParseJSON(bs)
+		return m, err
+	}
+	formatJSON := func(m *syslog.Message) []byte {
+		bs, _ := m.AppendJSON(nil, syslog.JSONv1)
+		return bs
+	}
+
+	var all []byte
+	for _, pkt := range bench.RFC5424 {
+		m, err := syslog.ParseMessage(pkt)
+		expect.Error(err).ToBeNil(t)
+		m.Time = time.Time{} // the receive time varies
+
+		_, rendered := RoundTrip(t, formatJSON(m), parseJSON, formatJSON)
+		all = append(append(all, rendered...), '\n')
+	}
+	Golden(t, "rfc5424.golden", all)
+}
+
+func TestRoundTrip_format(t *testing.T) {
+	pkt := []byte("<14>1 2024-03-01T12:00:00Z host app 1 ID [a b=\"c\"] content")
+	_, rendered := RoundTrip(t, pkt, syslog.ParseMessage, Format(syslog.RFCFormat))
+	expect.String(string(rendered)).ToBe(t, string(pkt))
+}
+
+func TestAssertEqual(t *testing.T) {
+	m, err := syslog.ParseMessage(bench.RFC5424[2])
+	expect.Error(err).ToBeNil(t)
+
+	other := m.Clone()
+	other.Time = time.Now()
+	other.Size = 1
+	expect.Bool(AssertEqual(t, m, other)).ToBeTrue(t)
+
+	other.Hostname = "elsewhere"
+	expect.Slice(syslog.DiffMessages(m, other)).ToBe(t, `Hostname: "mymachine.example.com" != "elsewhere"`)
+}
+
+func TestGolden(t *testing.T) {
+	if *update {
+		t.Skip("golden files are being updated")
+	}
+	rec := &recorder{TB: t}
+	Golden(rec, "rfc5424.golden", []byte("different"))
+	expect.Bool(bytes.Contains([]byte(rec.msg), []byte("differs"))).ToBeTrue(t)
+}
+
+// recorder captures a failure instead of failing the test.
+type recorder struct {
+	testing.TB
+	msg string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.msg = format
+}
@@ -0,0 +1,245 @@
+package syslog
+
+import (
+	"cmp"
+	"encoding/json"
+	"expvar"
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TalkerKey identifies one attribute of messages whose values are tracked by a
+// [TalkersHandler], e.g. the hostname.
+type TalkerKey struct {
+	Name  string                // e.g. "host"
+	Value func(*Message) string // gets the value from a message; blank values are ignored
+}
+
+// Talker is a value of a [TalkerKey] and the approximate number of messages that had it.
+type Talker struct {
+	Value    string `json:"value"`
+	Messages uint64 `json:"messages"`
+}
+
+// TalkerStats describes the values of one [TalkerKey] seen during the window of a
+// [TalkersHandler].
+type TalkerStats struct {
+	Key         string   `json:"key"`
+	Cardinality uint64   `json:"cardinality"` // the estimated number of distinct values
+	Top         []Talker `json:"top"`         // the busiest values, busiest first
+}
+
+// talkerSlots is the number of parts into which the window is divided; the window slides
+// forward one part at a time.
+const talkerSlots = 6
+
+// TalkersHandler is a [Handler] that reports which hosts, applications etc. send the most
+// messages, and estimates how many distinct values each has, over a sliding window. Runaway
+// cardinality is the main cause of exploding indexing costs downstream, so this helps to
+// find the culprits. Memory use is bounded, however many distinct values there are: the
+// busiest values are found with the space-saving algorithm and the cardinality is estimated
+// using HyperLogLog (to within about 2%), so both are approximate. The statistics can be
+// read using [TalkersHandler.Stats], published with [expvar] (see [TalkersHandler.Publish]),
+// or served as JSON because TalkersHandler is also an [http.Handler].
+// All messages are passed on to subsequent handlers. A TalkersHandler is safe for
+// concurrent use.
+type TalkersHandler struct {
+	keys  []TalkerKey
+	top   int
+	width time.Duration // of each slot
+	seed  maphash.Seed
+	clock func() time.Time
+
+	mu    sync.Mutex
+	slots [talkerSlots]talkerSlot
+}
+
+type talkerSlot struct {
+	epoch  int64 // the number of slot widths since 1970
+	counts []*spaceSaving
+	sketch []*hyperLogLog
+}
+
+// HostKey tracks the hostname of each message; see [NewTalkersHandler].
+var HostKey = TalkerKey{Name: "host", Value: func(m *Message) string { return m.Hostname }}
+
+// ApplicationKey tracks the application of each message; see [NewTalkersHandler].
+var ApplicationKey = TalkerKey{Name: "application", Value: func(m *Message) string { return m.Application }}
+
+// NewTalkersHandler creates a handler that reports the top busiest values of each key over
+// the most recent window. If no keys are given, [HostKey] and [ApplicationKey] are used.
+func NewTalkersHandler(window time.Duration, top int, keys ...TalkerKey) *TalkersHandler {
+	if len(keys) == 0 {
+		keys = []TalkerKey{HostKey, ApplicationKey}
+	}
+	return &TalkersHandler{
+		keys:  keys,
+		top:   max(top, 1),
+		width: max(window/talkerSlots, time.Millisecond),
+		seed:  maphash.MakeSeed(),
+		clock: time.Now,
+	}
+}
+
+// Close does nothing; it implements [io.Closer].
+func (h *TalkersHandler) Close() error {
+	return nil
+}
+
+func (h *TalkersHandler) Handle(m *Message) *Message {
+	if m == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	slot := h.slot(h.clock())
+	for i, k := range h.keys {
+		if v := k.Value(m); v != "" {
+			slot.counts[i].add(v)
+			slot.sketch[i].add(maphash.String(h.seed, v))
+		}
+	}
+	return m
+}
+
+// slot gets the current slot, clearing it if it belonged to an earlier part of the window.
+func (h *TalkersHandler) slot(t time.Time) *talkerSlot {
+	epoch := t.UnixNano() / int64(h.width)
+	slot := &h.slots[epoch%talkerSlots]
+	if slot.epoch != epoch || slot.counts == nil {
+		slot.epoch = epoch
+		slot.counts = make([]*spaceSaving, len(h.keys))
+		slot.sketch = make([]*hyperLogLog, len(h.keys))
+		for i := range h.keys {
+			slot.counts[i] = newSpaceSaving(4 * h.top)
+			slot.sketch[i] = new(hyperLogLog)
+		}
+	}
+	return slot
+}
+
+// Stats gets the statistics for each key over the current window.
+func (h *TalkersHandler) Stats() []TalkerStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.clock().UnixNano() / int64(h.width)
+	stats := make([]TalkerStats, len(h.keys))
+	for i, k := range h.keys {
+		counts := make(map[string]uint64)
+		var sketch hyperLogLog
+		for _, slot := range h.slots {
+			if slot.counts == nil || slot.epoch <= now-talkerSlots {
+				continue // expired
+			}
+			for v, n := range slot.counts[i].counts {
+				counts[v] += n
+			}
+			sketch.merge(slot.sketch[i])
+		}
+
+		top := make([]Talker, 0, len(counts))
+		for v, n := range counts {
+			top = append(top, Talker{Value: v, Messages: n})
+		}
+		slices.SortFunc(top, func(a, b Talker) int {
+			if c := cmp.Compare(b.Messages, a.Messages); c != 0 {
+				return c
+			}
+			return strings.Compare(a.Value, b.Value)
+		})
+
+		stats[i] = TalkerStats{
+			Key:         k.Name,
+			Cardinality: sketch.estimate(),
+			Top:         top[:min(len(top), h.top)],
+		}
+	}
+	return stats
+}
+
+// Publish makes the statistics available as an [expvar] variable with the given name,
+// which must be unique.
+func (h *TalkersHandler) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return h.Stats() }))
+}
+
+// ServeHTTP writes the statistics as JSON.
+func (h *TalkersHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	checkErr(json.NewEncoder(w).Encode(h.Stats()), "write", "talkers")
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// spaceSaving counts the most frequent values using a fixed number of counters. When a new
+// value arrives and all the counters are in use, the smallest counter is given to it; this
+// overestimates the counts of rare values, but the frequent ones are always kept.
+type spaceSaving struct {
+	capacity int
+	counts   map[string]uint64
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{capacity: capacity, counts: make(map[string]uint64, capacity)}
+}
+
+func (ss *spaceSaving) add(v string) {
+	if _, exists := ss.counts[v]; exists || len(ss.counts) < ss.capacity {
+		ss.counts[v]++
+		return
+	}
+
+	smallest, least := "", uint64(math.MaxUint64)
+	for k, n := range ss.counts {
+		if n < least {
+			smallest, least = k, n
+		}
+	}
+	delete(ss.counts, smallest)
+	ss.counts[v] = least + 1
+}
+
+// hllPrecision is the number of hash bits that select a register; the standard error of
+// the estimate is 1.04/sqrt(2^hllPrecision), i.e. 1.6%.
+const hllPrecision = 12
+
+// hyperLogLog estimates the number of distinct hashes it has seen.
+type hyperLogLog [1 << hllPrecision]uint8
+
+func (hll *hyperLogLog) add(hash uint64) {
+	i := hash >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1))) + 1
+	hll[i] = max(hll[i], rank)
+}
+
+func (hll *hyperLogLog) merge(other *hyperLogLog) {
+	for i, r := range other {
+		hll[i] = max(hll[i], r)
+	}
+}
+
+func (hll *hyperLogLog) estimate() uint64 {
+	const m = float64(len(hll))
+	sum, zeros := 0.0, 0
+	for _, r := range hll {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros)) // linear counting is better for small numbers
+	}
+	return uint64(math.Round(e))
+}
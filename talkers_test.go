@@ -0,0 +1,48 @@
+package syslog
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestTalkersHandler(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := NewTalkersHandler(time.Minute, 2)
+	h.clock = func() time.Time { return now }
+
+	for i := range 1000 {
+		m := &Message{Hostname: "noisy", Application: fmt.Sprintf("app%d", i)}
+		expect.Any(h.Handle(m)).ToBe(t, m)
+	}
+	h.Handle(&Message{Hostname: "quiet", Application: "app1"})
+	h.Handle(&Message{Hostname: "calm", Application: "app1"})
+	h.Handle(&Message{Hostname: "calm"})
+
+	stats := h.Stats()
+	expect.Number(len(stats)).ToBe(t, 2)
+	expect.String(stats[0].Key).ToBe(t, "host")
+	expect.Number(stats[0].Cardinality).ToBe(t, 3)
+	expect.Slice(stats[0].Top).ToBe(t, Talker{Value: "noisy", Messages: 1000}, Talker{Value: "calm", Messages: 2})
+
+	expect.String(stats[1].Key).ToBe(t, "application")
+	expect.Bool(stats[1].Cardinality > 920 && stats[1].Cardinality < 1080).Info(stats[1].Cardinality).ToBeTrue(t)
+	expect.Number(len(stats[1].Top)).ToBe(t, 2)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/talkers", nil))
+	expect.String(w.Body.String()).ToContain(t, `{"key":"host","cardinality":3,"top":[{"value":"noisy","messages":1000}`)
+
+	// the window slides past the old messages
+	now = now.Add(50 * time.Second)
+	h.Handle(&Message{Hostname: "late"})
+	expect.Slice(h.Stats()[0].Top).ToBe(t, Talker{Value: "noisy", Messages: 1000}, Talker{Value: "calm", Messages: 2})
+
+	now = now.Add(20 * time.Second)
+	stats = h.Stats()
+	expect.Number(stats[0].Cardinality).ToBe(t, 1)
+	expect.Slice(stats[0].Top).ToBe(t, Talker{Value: "late", Messages: 1})
+}
@@ -0,0 +1,207 @@
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// DefaultTemplateMaxOutput limits the rendering of a [Template] if no other limit is given.
+const DefaultTemplateMaxOutput = 64 * 1024
+
+// Template is a message layout written as a Go text template (see [text/template]), for
+// layouts that [Message.Format] cannot express. For example
+//
+//	{{.Timestamp}} {{.Hostname | default "unknown"}} {{.Application | upper}}: {{.Content}}
+//
+// Because layouts are often loaded from configuration files, which are only semi-trusted,
+// templates are sandboxed:
+//
+//   - the data are plain values, so no methods can be called: the fields are Priority,
+//     Facility, Severity, Version, Timestamp, Time, Hostname, Application, ProcID, MsgID,
+//     Data, Content, Source, Sequence, Size and Annotations (a map)
+//   - only the functions upper, lower, trim, replace, default, truncate and quote are added,
+//     and the built-in call and printf functions are not available; the other built-ins,
+//     such as print, println, len, index, slice and the comparisons, remain
+//   - range may only iterate over .Annotations, and templates cannot define or invoke
+//     other templates, so execution always terminates
+//   - the output of each execution is limited in size
+//
+// A Template is safe for concurrent use.
+type Template struct {
+	t         *template.Template
+	maxOutput int
+}
+
+var errNotAllowed = errors.New("not allowed in templates")
+
+var templateFuncs = template.FuncMap{
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"replace": replace,
+	"default": ifBlankReversed,
+	"truncate": func(n int, s string) string {
+		if n >= 0 && len(s) > n {
+			return s[:n]
+		}
+		return s
+	},
+	"quote": strconv.Quote,
+
+	// built-ins that are not allowed
+	"call":   func(...any) (string, error) { return "", errNotAllowed },
+	"printf": func(...any) (string, error) { return "", errNotAllowed },
+}
+
+// maxReplaceOutput limits the result of replace, which could otherwise build a string far
+// larger than the output limit before it is written.
+const maxReplaceOutput = 1024 * 1024
+
+// replace replaces every old in s with new. Unlike [strings.ReplaceAll], an empty old is an
+// error, because it would insert new between every character.
+func replace(old, new, s string) (string, error) {
+	if old == "" {
+		return "", errors.New("replace: old string is empty")
+	}
+	if n := strings.Count(s, old); len(s)+n*(len(new)-len(old)) > maxReplaceOutput {
+		return "", fmt.Errorf("replace: result would be longer than %d bytes", maxReplaceOutput)
+	}
+	return strings.ReplaceAll(s, old, new), nil
+}
+
+// rangeableFields lists the only fields that templates may range over.
+var rangeableFields = map[string]bool{"Annotations": true}
+
+// ifBlankReversed is ifBlank with the arguments in pipeline order.
+func ifBlankReversed(d, s string) string {
+	return ifBlank(s, d)
+}
+
+// CompileTemplate parses a template layout. The rendering of each message is limited to
+// maxOutput bytes; if maxOutput is zero or less, [DefaultTemplateMaxOutput] applies. An
+// error is returned if the layout is invalid or uses features that are not allowed.
+func CompileTemplate(text string, maxOutput int) (*Template, error) {
+	t, err := template.New("layout").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(t.Templates()) > 1 {
+		return nil, fmt.Errorf("template definitions are %w", errNotAllowed)
+	}
+	if err = checkTemplateNode(t.Tree.Root); err != nil {
+		return nil, err
+	}
+
+	if maxOutput <= 0 {
+		maxOutput = DefaultTemplateMaxOutput
+	}
+	return &Template{t: t, maxOutput: maxOutput}, nil
+}
+
+// checkTemplateNode rejects the parts of a template that could run unboundedly.
+func checkTemplateNode(n parse.Node) error {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			if err := checkTemplateNode(c); err != nil {
+				return err
+			}
+		}
+
+	case *parse.TemplateNode:
+		return fmt.Errorf("%s: template invocations are %w", n, errNotAllowed)
+
+	case *parse.RangeNode:
+		if !isRangeable(n.Pipe) {
+			return fmt.Errorf("%s: range over anything except %s is %w", n.Pipe, ".Annotations", errNotAllowed)
+		}
+		return errors.Join(checkTemplateNode(n.List), checkTemplateNode(n.ElseList))
+
+	case *parse.IfNode:
+		return errors.Join(checkTemplateNode(n.List), checkTemplateNode(n.ElseList))
+
+	case *parse.WithNode:
+		return errors.Join(checkTemplateNode(n.List), checkTemplateNode(n.ElseList))
+	}
+	return nil
+}
+
+// isRangeable is true for a range pipeline that is simply one of the rangeable fields.
+func isRangeable(p *parse.PipeNode) bool {
+	if len(p.Cmds) != 1 || len(p.Cmds[0].Args) != 1 {
+		return false
+	}
+	f, ok := p.Cmds[0].Args[0].(*parse.FieldNode)
+	return ok && len(f.Ident) == 1 && rangeableFields[f.Ident[0]]
+}
+
+// Format renders a message using the template.
+func (t *Template) Format(m *Message) (string, error) {
+	bs, err := t.AppendFormat(nil, m)
+	return string(bs), err
+}
+
+// AppendFormat is like [Template.Format] but appends the rendering to a byte slice,
+// returning the extended slice.
+func (t *Template) AppendFormat(bs []byte, m *Message) ([]byte, error) {
+	w := &limitedBuffer{bs: bs, limit: len(bs) + t.maxOutput, max: t.maxOutput}
+	err := t.t.Execute(w, templateData(m))
+	return w.bs, err
+}
+
+// String returns the source text of the template.
+func (t *Template) String() string {
+	return t.t.Tree.Root.String()
+}
+
+func templateData(m *Message) map[string]any {
+	source := ""
+	if m.Source != nil {
+		source = m.Source.String()
+	}
+
+	return map[string]any{
+		"Priority":    m.Priority(),
+		"Facility":    m.Facility.String(),
+		"Severity":    m.Severity.String(),
+		"Version":     m.Version,
+		"Timestamp":   m.ts().Format(time.RFC3339Nano),
+		"Time":        m.Time.Format(time.RFC3339Nano),
+		"Hostname":    m.Hostname,
+		"Application": m.Application,
+		"ProcID":      m.ProcID,
+		"MsgID":       m.MsgID,
+		"Data":        m.Data,
+		"Content":     m.Content,
+		"Source":      source,
+		"Sequence":    m.Sequence,
+		"Size":        m.Size,
+		"Annotations": m.Annotations,
+	}
+}
+
+// limitedBuffer appends to a byte slice up to a limit, after which writes fail.
+type limitedBuffer struct {
+	bs    []byte
+	limit int // length of bs at which to stop
+	max   int // the number of bytes that may be written
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if len(b.bs)+len(p) > b.limit {
+		n := b.limit - len(b.bs)
+		b.bs = append(b.bs, p[:n]...)
+		return n, fmt.Errorf("template output exceeds %d bytes", b.max)
+	}
+	b.bs = append(b.bs, p...)
+	return len(p), nil
+}
@@ -0,0 +1,64 @@
+package syslog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestCompileTemplate(t *testing.T) {
+	m := &Message{
+		Facility:    Daemon,
+		Severity:    Err,
+		Timestamp:   time.Date(2023, 10, 26, 15, 30, 0, 0, time.UTC),
+		Application: "myapp",
+		Content:     "disk full",
+		Annotations: map[string]string{"b": "2", "a": "1"},
+	}
+
+	tpl, err := CompileTemplate(`{{.Timestamp}} {{.Hostname | default "unknown"}} {{.Application | upper}}[{{.Severity}}]: {{.Content | quote}}`+
+		`{{range $k, $v := .Annotations}} {{$k}}={{$v}}{{end}}`, 0)
+	expect.Error(err).ToBeNil(t)
+	s, err := tpl.Format(m)
+	expect.String(s, err).ToBe(t, `2023-10-26T15:30:00Z unknown MYAPP[err]: "disk full" a=1 b=2`)
+
+	tpl, err = CompileTemplate(`{{.Nosuch}}`, 0)
+	expect.Error(err).ToBeNil(t)
+	_, err = tpl.Format(m)
+	expect.Error(err).ToContain(t, "Nosuch")
+}
+
+func TestCompileTemplate_sandbox(t *testing.T) {
+	for _, text := range []string{
+		`{{range 1000000000}}{{end}}`,
+		`{{range .Content}}{{end}}`,
+		`{{define "a"}}{{template "a"}}{{end}}{{template "a"}}`,
+		`{{if .Content}}{{range $i := 10}}{{end}}{{end}}`,
+	} {
+		_, err := CompileTemplate(text, 0)
+		expect.Error(err).Info(text).ToContain(t, "not allowed")
+	}
+
+	tpl, err := CompileTemplate(`{{printf "%999999999d" 1}}`, 0)
+	expect.Error(err).ToBeNil(t)
+	_, err = tpl.Format(&Message{})
+	expect.Error(err).ToContain(t, "not allowed")
+
+	tpl, err = CompileTemplate(`{{.Content}}{{.Content}}`, 10)
+	expect.Error(err).ToBeNil(t)
+	s, err := tpl.Format(&Message{Content: "1234567"})
+	expect.String(s).ToBe(t, "1234567123")
+	expect.Error(err).ToContain(t, "exceeds 10 bytes")
+
+	for _, text := range []string{
+		`{{replace "" "x" .Content}}`,
+		`{{replace "1" "1111111111111111111111111111111111111111" .Content | replace "1" "1111111111111111111111111111111111111111"}}`,
+	} {
+		tpl, err = CompileTemplate(text, 10)
+		expect.Error(err).ToBeNil(t)
+		_, err = tpl.Format(&Message{Content: strings.Repeat("1", 1000)})
+		expect.Error(err).Info(text).ToContain(t, "replace: ")
+	}
+}
@@ -0,0 +1,215 @@
+package syslog
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// PeerIdentity describes the verified certificate presented by a TLS client, so that
+// handlers can trust the attribution of the messages it sent; see [MutualTLSConfig].
+type PeerIdentity struct {
+	CommonName  string   // the subject common name
+	DNSNames    []string // the DNS subject alternative names
+	URIs        []string // the URI subject alternative names
+	SPIFFEID    string   // the SPIFFE ID, if any; see [SPIFFEServerConfig]
+	Fingerprint string   // the hex-encoded SHA-256 hash of the certificate
+}
+
+// ListenTLS starts a goroutine that receives syslog messages over TLS on a specified
+// host:port address, as defined in RFC 5425 (the IANA-assigned port is 6514). Messages
+// use octet-counting framing, as required by RFC 5425; non-transparent framing is also
+// tolerated. The cfg must contain at least one certificate, or GetCertificate; use
+// [KeyPair] to allow the certificate to be reloaded without restarting the listener.
+// If cfg verifies client certificates (see [MutualTLSConfig]), the identity of each client
+// is attached to its messages as [Message.TLSPeer].
+// Only the messages matching accept are processed.
+func (s *Server) ListenTLS(addr string, cfg *tls.Config, accept Filter) error {
+	if cfg == nil || (len(cfg.Certificates) == 0 && cfg.GetCertificate == nil && cfg.GetConfigForClient == nil) {
+		return errors.New("ListenTLS requires a TLS configuration with a certificate")
+	}
+
+	l, err := tls.Listen("tcp", addr, cfg)
+	if err != nil {
+		return err
+	}
+
+	s.ListenListener(l, accept)
+	return nil
+}
+
+// MutualTLSConfig creates a server configuration that requires every client to present a
+// certificate signed by one of clientCAs. It can be used with [Server.ListenTLS],
+// [Server.ListenRELP] and [Server.ListenReplica].
+func MutualTLSConfig(cert tls.Certificate, clientCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// KeyPair is a certificate and private key loaded from PEM files that can be reloaded
+// whilst listeners are running, so that short-lived certificates can be renewed without
+// restarting the server. Use [KeyPair.Config], or [KeyPair.GetCertificate] in your own
+// configuration; new connections use the latest certificate and existing connections are
+// not interrupted. The same applies to forwarders that present a client certificate, such
+// as [ReplicationHandler] (see [KeyPair.ClientConfig]). The files are reloaded by
+// [KeyPair.Reload] or [KeyPair.SigHup], or automatically when they change if
+// [KeyPair.Watch] is used. A KeyPair is safe for concurrent use.
+type KeyPair struct {
+	certFile, keyFile string
+	cert              atomic.Pointer[tls.Certificate]
+	stop              chan struct{}
+}
+
+// LoadKeyPair loads a certificate and its private key from a pair of PEM files.
+func LoadKeyPair(certFile, keyFile string) (*KeyPair, error) {
+	kp := &KeyPair{certFile: certFile, keyFile: keyFile}
+	if err := kp.Reload(); err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+// Reload reads the files again. If they are not valid, the previous certificate is kept
+// and the error is returned.
+func (kp *KeyPair) Reload() error {
+	cert, err := tls.LoadX509KeyPair(kp.certFile, kp.keyFile)
+	if err != nil {
+		return err
+	}
+	kp.cert.Store(&cert)
+	return nil
+}
+
+// SigHup reloads the files, logging any error. This is typically used when the
+// certificate has been renewed.
+func (kp *KeyPair) SigHup() {
+	checkErr(kp.Reload(), "reload", kp.certFile)
+}
+
+// GetCertificate gets the current certificate; it can be used as [tls.Config.GetCertificate].
+func (kp *KeyPair) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return kp.cert.Load(), nil
+}
+
+// GetClientCertificate gets the current certificate; it can be used as
+// [tls.Config.GetClientCertificate].
+func (kp *KeyPair) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return kp.cert.Load(), nil
+}
+
+// Config creates a server configuration that always uses the current certificate.
+func (kp *KeyPair) Config() *tls.Config {
+	return &tls.Config{
+		GetCertificate: kp.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+}
+
+// ClientConfig creates a client configuration for forwarders that always presents the
+// current certificate. Servers are verified using rootCAs, or the system roots if it is nil.
+func (kp *KeyPair) ClientConfig(rootCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		GetClientCertificate: kp.GetClientCertificate,
+		RootCAs:              rootCAs,
+		MinVersion:           tls.VersionTLS12,
+	}
+}
+
+// Watch starts a goroutine that checks the files at regular intervals and reloads them
+// when they change, e.g. after renewal by an ACME client. Errors are logged and the previous
+// certificate is kept. Watch should be called at most once; the goroutine runs until
+// [KeyPair.Stop] is called.
+func (kp *KeyPair) Watch(interval time.Duration) {
+	kp.stop = make(chan struct{})
+	go kp.watch(interval, kp.stop)
+}
+
+// Stop stops watching the files.
+func (kp *KeyPair) Stop() {
+	if kp.stop != nil {
+		close(kp.stop)
+	}
+}
+
+func (kp *KeyPair) watch(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := kp.modified()
+	for {
+		select {
+		case <-ticker.C:
+			// if the files are replaced one at a time, reloading may fail until both are done
+			if m := kp.modified(); m != last {
+				last = m
+				kp.SigHup()
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// modified summarises the modification times and sizes of the files.
+func (kp *KeyPair) modified() [2]fileVersion {
+	return [2]fileVersion{versionOf(kp.certFile), versionOf(kp.keyFile)}
+}
+
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func versionOf(file string) fileVersion {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return fileVersion{}
+	}
+	return fileVersion{modTime: fi.ModTime(), size: fi.Size()}
+}
+
+// tlsHandshakeTimeout limits how long a client may take to complete the TLS handshake.
+const tlsHandshakeTimeout = 10 * time.Second
+
+// tlsPeer gets the identity of the client on a TLS connection from its verified
+// certificate, or returns nil if there is none.
+func tlsPeer(c net.Conn) *PeerIdentity {
+	tc, ok := c.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	tc.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	err := tc.Handshake()
+	tc.SetDeadline(time.Time{})
+	if err != nil {
+		return nil
+	}
+
+	chains := tc.ConnectionState().VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil
+	}
+
+	leaf := chains[0][0]
+	sum := sha256.Sum256(leaf.Raw)
+	peer := &PeerIdentity{
+		CommonName:  leaf.Subject.CommonName,
+		DNSNames:    leaf.DNSNames,
+		SPIFFEID:    spiffeID(leaf),
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+	for _, u := range leaf.URIs {
+		peer.URIs = append(peer.URIs, u.String())
+	}
+	return peer
+}
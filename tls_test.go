@@ -0,0 +1,235 @@
+package syslog
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestListenTLS(t *testing.T) {
+	cert, pool := testCertificate(t, "localhost")
+
+	received := make(chan *Message, 1)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	expect.Error(s.ListenTLS("127.0.0.1:0", nil, AcceptEverything)).ToContain(t, "requires a TLS configuration")
+
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	expect.Error(s.ListenTLS("127.0.0.1:0", cfg, AcceptEverything)).ToBeNil(t)
+
+	c, err := tls.Dial("tcp", s.listeners[0].Addr().String(), &tls.Config{RootCAs: pool, ServerName: "localhost"})
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+
+	msg := "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - hello"
+	_, err = fmt.Fprintf(c, "%d %s", len(msg), msg)
+	expect.Error(err).ToBeNil(t)
+
+	m := <-received
+	expect.String(m.Hostname).ToBe(t, "mymachine.example.com")
+	expect.String(m.Content).ToBe(t, "hello")
+}
+
+func TestListenTLS_mutual(t *testing.T) {
+	cert, pool := testCertificate(t, "localhost")
+
+	received := make(chan *Message, 1)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+
+	trusted := func(m *Message) bool { return m.TLSPeer != nil && m.TLSPeer.CommonName == "localhost" }
+	expect.Error(s.ListenTLS("127.0.0.1:0", MutualTLSConfig(cert, pool), trusted)).ToBeNil(t)
+
+	c, err := tls.Dial("tcp", s.listeners[0].Addr().String(), &tls.Config{
+		RootCAs:      pool,
+		ServerName:   "localhost",
+		Certificates: []tls.Certificate{cert},
+	})
+	expect.Error(err).ToBeNil(t)
+	defer c.Close()
+
+	msg := "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - hello"
+	_, err = fmt.Fprintf(c, "%d %s", len(msg), msg)
+	expect.Error(err).ToBeNil(t)
+
+	m := <-received
+	expect.String(m.Content).ToBe(t, "hello")
+	expect.Slice(m.TLSPeer.DNSNames).ToBe(t, "localhost")
+	expect.Number(len(m.TLSPeer.Fingerprint)).ToBe(t, 64)
+}
+
+func TestKeyPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	cert1, pool := testCertificate(t, "localhost")
+	writeKeyPair(t, cert1, certFile, keyFile)
+	kp, err := LoadKeyPair(certFile, keyFile)
+	expect.Error(err).ToBeNil(t)
+
+	s := NewServer()
+	defer s.Shutdown()
+	expect.Error(s.ListenTLS("127.0.0.1:0", kp.Config(), AcceptEverything)).ToBeNil(t)
+	addr := s.listeners[0].Addr().String()
+
+	c1, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool, ServerName: "localhost"})
+	expect.Error(err).ToBeNil(t)
+	defer c1.Close()
+
+	cert2, pool2 := testCertificate(t, "localhost")
+	writeKeyPair(t, cert2, certFile, keyFile)
+	kp.SigHup()
+
+	c2, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool2, ServerName: "localhost"})
+	expect.Error(err).ToBeNil(t)
+	defer c2.Close()
+	expect.Bool(c2.ConnectionState().PeerCertificates[0].Equal(cert2.Leaf)).ToBeTrue(t)
+
+	// the existing connection is not interrupted
+	_, err = fmt.Fprintf(c1, "5 hello")
+	expect.Error(err).ToBeNil(t)
+
+	// an invalid file does not replace the certificate
+	expect.Error(os.WriteFile(keyFile, []byte("junk"), 0600)).ToBeNil(t)
+	expect.Bool(kp.Reload() != nil).ToBeTrue(t)
+	current, _ := kp.GetCertificate(nil)
+	expect.Bool(current.Leaf.Equal(cert2.Leaf)).ToBeTrue(t)
+}
+
+func TestKeyPair_Watch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	serverCert, pool := testCertificate(t, "localhost")
+	cert1, _ := testCertificate(t, "client")
+	cert2, _ := testCertificate(t, "client")
+	pool.AddCert(cert1.Leaf)
+	pool.AddCert(cert2.Leaf)
+
+	writeKeyPair(t, cert1, certFile, keyFile)
+	kp, err := LoadKeyPair(certFile, keyFile)
+	expect.Error(err).ToBeNil(t)
+	kp.Watch(5 * time.Millisecond)
+	defer kp.Stop()
+
+	received := make(chan *Message, 1)
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			received <- m
+		}
+		return m
+	}))
+	defer s.Shutdown()
+	expect.Error(s.ListenReplica("127.0.0.1:0", MutualTLSConfig(serverCert, pool), AcceptEverything)).ToBeNil(t)
+
+	time.Sleep(20 * time.Millisecond)
+	writeKeyPair(t, cert2, certFile, keyFile)
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if c, _ := kp.GetCertificate(nil); c.Leaf.Equal(cert2.Leaf) {
+			break
+		}
+	}
+
+	h := NewReplicationHandler(s.listeners[0].Addr().String(), kp.ClientConfig(pool))
+	h.SetTimeout(time.Second)
+	defer h.Close()
+	h.Handle(&Message{Facility: User, Severity: Info, Version: 1, Hostname: "myhost", Content: "hello"})
+
+	sum := sha256.Sum256(cert2.Leaf.Raw)
+	expect.String((<-received).TLSPeer.Fingerprint).ToBe(t, hex.EncodeToString(sum[:]))
+}
+
+func parseURIs(t *testing.T, uris []string) []*url.URL {
+	t.Helper()
+	var parsed []*url.URL
+	for _, u := range uris {
+		p, err := url.Parse(u)
+		expect.Error(err).ToBeNil(t)
+		parsed = append(parsed, p)
+	}
+	return parsed
+}
+
+// writeKeyPair writes a certificate and its key to PEM files.
+func writeKeyPair(t *testing.T, cert tls.Certificate, certFile, keyFile string) {
+	t.Helper()
+	certPEM, keyPEM := encodeKeyPair(t, cert)
+	expect.Error(os.WriteFile(certFile, certPEM, 0600)).ToBeNil(t)
+	expect.Error(os.WriteFile(keyFile, keyPEM, 0600)).ToBeNil(t)
+}
+
+// encodeKeyPair encodes a certificate and its key as PEM.
+func encodeKeyPair(t *testing.T, cert tls.Certificate) (certPEM, keyPEM []byte) {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	expect.Error(err).ToBeNil(t)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+// testCertificate creates a self-signed certificate for the specified host.
+func testCertificate(t *testing.T, host string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	return testCertificateWithURIs(t, host)
+}
+
+// testCertificateWithURIs creates a self-signed certificate for the specified host with
+// URI subject alternative names, such as SPIFFE IDs.
+func testCertificateWithURIs(t *testing.T, host string, uris ...string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	expect.Error(err).ToBeNil(t)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		URIs:                  parseURIs(t, uris),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	expect.Error(err).ToBeNil(t)
+
+	leaf, err := x509.ParseCertificate(der)
+	expect.Error(err).ToBeNil(t)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
@@ -0,0 +1,79 @@
+package syslog
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Annotation keys for the trace context of a message; see [TraceHandler]. The values are
+// lower-case hex, as in the TraceId, SpanId and Flags fields of OpenTelemetry log records.
+const (
+	TraceIDAnnotation    = "trace-id"
+	SpanIDAnnotation     = "span-id"
+	TraceFlagsAnnotation = "trace-flags"
+)
+
+var (
+	// a W3C traceparent header: version-traceid-parentid-flags
+	traceparentRE = regexp.MustCompile(`\b([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})\b`)
+
+	// key=value or key:value pairs, as written by many logging libraries and in SD parameters
+	traceFieldRE = regexp.MustCompile(`(?i)\b(trace[_.-]?id|trace|span[_.-]?id|span)["']?\s*[=:]\s*["']?([0-9a-f]{16,32})\b`)
+)
+
+// TraceHandler is a [Handler] that finds the trace context embedded in the structured data
+// or content of each message and records it as annotations ([TraceIDAnnotation],
+// [SpanIDAnnotation] and [TraceFlagsAnnotation]), so that application logs received over
+// syslog can be correlated with distributed traces. A W3C traceparent value is preferred;
+// otherwise fields such as trace_id=..., traceId: "..." or span.id=... are recognised.
+// Only the first [DefaultRegexpMaxInput] bytes of the content are examined.
+type TraceHandler struct{}
+
+// Close does nothing; it implements [io.Closer].
+func (h TraceHandler) Close() error {
+	return nil
+}
+
+func (h TraceHandler) Handle(m *Message) *Message {
+	if m == nil {
+		return nil
+	}
+
+	content := m.Content[:min(len(m.Content), DefaultRegexpMaxInput)]
+	traceID, spanID, flags := extractTrace(m.Data + " " + content)
+	if traceID != "" {
+		m.Annotate(TraceIDAnnotation, traceID)
+	}
+	if spanID != "" {
+		m.Annotate(SpanIDAnnotation, spanID)
+	}
+	if flags != "" {
+		m.Annotate(TraceFlagsAnnotation, flags)
+	}
+	return m
+}
+
+// extractTrace finds the trace context in s. The IDs are blank if not found; all-zero IDs
+// are invalid.
+func extractTrace(s string) (traceID, spanID, flags string) {
+	for _, sm := range traceparentRE.FindAllStringSubmatch(s, -1) {
+		if sm[1] != "ff" && !allZero(sm[2]) && !allZero(sm[3]) {
+			return sm[2], sm[3], sm[4]
+		}
+	}
+
+	for _, kv := range traceFieldRE.FindAllStringSubmatch(s, -1) {
+		key, v := strings.ToLower(kv[1]), strings.ToLower(kv[2])
+		switch {
+		case traceID == "" && strings.HasPrefix(key, "trace") && len(v) == 32 && !allZero(v):
+			traceID = v
+		case spanID == "" && strings.HasPrefix(key, "span") && len(v) == 16 && !allZero(v):
+			spanID = v
+		}
+	}
+	return traceID, spanID, ""
+}
+
+func allZero(hex string) bool {
+	return strings.Trim(hex, "0") == ""
+}
@@ -0,0 +1,28 @@
+package syslog
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestTraceHandler(t *testing.T) {
+	cases := []struct {
+		data, content, traceID, spanID, flags string
+	}{
+		{"-", "GET /x traceparent=00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01 200",
+			"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "01"},
+		{`[otel@32473 trace_id="4BF92F3577B34DA6A3CE929D0E0E4736" span_id="00f067aa0ba902b7"]`, "done",
+			"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", ""},
+		{"", `{"msg":"done","traceId":"4bf92f3577b34da6a3ce929d0e0e4736"}`,
+			"4bf92f3577b34da6a3ce929d0e0e4736", "", ""},
+		{"", "trace.id=00000000000000000000000000000000 span=00f067aa0ba902b7", "", "00f067aa0ba902b7", ""},
+		{"", "order 991823 accepted", "", "", ""},
+	}
+	for _, c := range cases {
+		m := TraceHandler{}.Handle(&Message{Data: c.data, Content: c.content})
+		expect.String(m.Annotations[TraceIDAnnotation]).Info(c.content).ToBe(t, c.traceID)
+		expect.String(m.Annotations[SpanIDAnnotation]).Info(c.content).ToBe(t, c.spanID)
+		expect.String(m.Annotations[TraceFlagsAnnotation]).Info(c.content).ToBe(t, c.flags)
+	}
+}
@@ -0,0 +1,88 @@
+package syslog
+
+import (
+	"net"
+	"os"
+)
+
+// socketPermissions are applied to the socket files of Unix listeners; see
+// [WithSocketPermissions].
+type socketPermissions struct {
+	mode     os.FileMode
+	uid, gid int
+}
+
+// listenUnixgram opens a Unix datagram socket, replacing a stale socket file if necessary.
+func (s *Server) listenUnixgram(path string) (net.PacketConn, error) {
+	a, err := net.ResolveUnixAddr("unixgram", path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.removeStaleSocket("unixgram", path)
+	c, err := net.ListenUnixgram("unixgram", a)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.setSocketPermissions(path); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// listenUnix opens a Unix stream socket, replacing a stale socket file if necessary.
+func (s *Server) listenUnix(path string) (net.Listener, error) {
+	s.removeStaleSocket("unix", path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.setSocketPermissions(path); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// removeStaleSocket removes a socket file left behind by a process that no longer exists,
+// e.g. after a crash, which would otherwise prevent the socket from being bound. A socket
+// that is still in use, or a file that is not a socket, is left alone, in which case binding
+// fails as usual.
+func (s *Server) removeStaleSocket(network, path string) {
+	if path == "" || path[0] == '@' {
+		return // abstract sockets have no file
+	}
+
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+
+	c, err := net.Dial(network, path)
+	if err == nil {
+		c.Close()
+		return
+	}
+
+	if connRefused(err) && !checkErr(os.Remove(path), "remove stale socket", path) {
+		s.logger.Printf("Removed stale socket %s\n", path)
+	}
+}
+
+// setSocketPermissions sets the mode and owner of a socket file, if required.
+func (s *Server) setSocketPermissions(path string) error {
+	p := s.socketPermissions
+	if p == nil || path == "" || path[0] == '@' {
+		return nil
+	}
+
+	if p.uid >= 0 || p.gid >= 0 {
+		if err := os.Chown(path, p.uid, p.gid); err != nil {
+			return err
+		}
+	}
+	return os.Chmod(path, p.mode)
+}
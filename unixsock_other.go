@@ -0,0 +1,7 @@
+//go:build !unix
+
+package syslog
+
+func connRefused(error) bool {
+	return false
+}
@@ -0,0 +1,47 @@
+//go:build unix
+
+package syslog
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestWithSocketPermissions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log")
+
+	// a socket file left behind by a crashed process
+	stale, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	expect.Error(err).ToBeNil(t)
+	stale.Close()
+
+	s := NewServer(WithSocketPermissions(0666, -1, os.Getgid()))
+	defer s.Shutdown()
+	expect.Error(s.Listen(path)).ToBeNil(t)
+
+	fi, err := os.Stat(path)
+	expect.Error(err).ToBeNil(t)
+	expect.Number(fi.Mode().Perm()).ToBe(t, 0666)
+
+	// a socket that is in use is not removed
+	expect.Error(s.Listen(path)).ToContain(t, "address already in use")
+	c, err := net.Dial("unixgram", path)
+	expect.Error(err).ToBeNil(t)
+	c.Close()
+
+	// nor is a file that is not a socket
+	other := filepath.Join(dir, "other")
+	expect.Error(os.WriteFile(other, nil, 0600)).ToBeNil(t)
+	expect.Error(s.ListenUnix(other, AcceptEverything)).ToContain(t, "address already in use")
+
+	stream := filepath.Join(dir, "stream")
+	expect.Error(s.ListenUnix(stream, AcceptEverything)).ToBeNil(t)
+	fi, err = os.Stat(stream)
+	expect.Error(err).ToBeNil(t)
+	expect.Number(fi.Mode().Perm()).ToBe(t, 0666)
+}
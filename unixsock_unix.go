@@ -0,0 +1,13 @@
+//go:build unix
+
+package syslog
+
+import (
+	"errors"
+	"syscall"
+)
+
+// connRefused is true when nothing is listening on a socket file.
+func connRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
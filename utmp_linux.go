@@ -0,0 +1,38 @@
+package syslog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+)
+
+const (
+	utmpRecordSize  = 384
+	utmpUserProcess = 7
+	utmpLineOffset  = 8
+	utmpLineSize    = 32
+)
+
+// loggedInTerminals reads the utmp file to find the terminal lines (relative to /dev) of
+// all logged-in users.
+func loggedInTerminals(utmp string) ([]string, error) {
+	bs, err := os.ReadFile(utmp)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for ; len(bs) >= utmpRecordSize; bs = bs[utmpRecordSize:] {
+		if binary.NativeEndian.Uint16(bs) != utmpUserProcess {
+			continue
+		}
+		line := bs[utmpLineOffset : utmpLineOffset+utmpLineSize]
+		if nul := bytes.IndexByte(line, 0); nul >= 0 {
+			line = line[:nul]
+		}
+		if len(line) > 0 {
+			lines = append(lines, string(line))
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package syslog
+
+import "errors"
+
+func loggedInTerminals(string) ([]string, error) {
+	return nil, errors.New("utmp is not supported on this platform")
+}
@@ -0,0 +1,119 @@
+package syslog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WallHandler is a [Handler] that writes messages to the terminals of all logged-in users,
+// like the 'wall' command. This replicates the classic "*.emerg *" rule of syslog.conf.
+// By default, only [Emerg] messages are broadcast; see [WallHandler.SetFilter].
+// All messages are passed on to subsequent handlers.
+//
+// Control characters from the message, such as escape sequences, are written as visible
+// escapes (e.g. \x1b) so that senders cannot control the users' terminals.
+//
+// Logged-in users are found from the utmp file, which is only supported on Linux.
+// It is safe for concurrent use.
+type WallHandler struct {
+	acceptFunc Filter
+	format     string
+	utmp       string
+	dev        string
+	mu         sync.Mutex // serialises broadcasts, so that they are not interleaved on terminals
+}
+
+// NewWallHandler creates a handler that broadcasts messages in the specified format,
+// e.g. [RFCFormat].
+func NewWallHandler(format string) *WallHandler {
+	return &WallHandler{
+		acceptFunc: Severities{Emerg}.Filter(),
+		format:     format,
+		utmp:       "/var/run/utmp",
+		dev:        "/dev",
+	}
+}
+
+// SetFilter changes the function used to decide which messages are broadcast.
+func (h *WallHandler) SetFilter(acceptFunc Filter) {
+	h.acceptFunc = acceptFunc
+}
+
+// Close does nothing because terminals are only open whilst a message is being written.
+// It implements [io.Closer].
+func (h *WallHandler) Close() error {
+	return nil
+}
+
+func (h *WallHandler) Handle(m *Message) *Message {
+	if m != nil && h.acceptFunc(m) {
+		h.broadcast(m)
+	}
+	return m
+}
+
+func (h *WallHandler) broadcast(m *Message) {
+	terminals, err := loggedInTerminals(h.utmp)
+	if checkErr(err, "utmp", h.utmp) {
+		return
+	}
+
+	text := fmt.Sprintf("\r\n\aBroadcast message from syslog@%s (%s):\r\n\r\n%s\r\n",
+		appendPrintable(nil, ifBlank(m.Hostname, "localhost")), m.Time.Format(time.ANSIC),
+		strings.ReplaceAll(string(appendPrintable(nil, m.Format(h.format))), "\n", "\r\n"))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, line := range terminals {
+		h.writeTerminal(line, text)
+	}
+}
+
+func (h *WallHandler) writeTerminal(line, text string) {
+	if strings.Contains(line, "..") {
+		return // refuse to write outside the device directory
+	}
+
+	// non-blocking so that a stuck terminal, e.g. one stopped by XOFF, cannot stall the pipeline
+	tty := filepath.Join(h.dev, line)
+	f, err := os.OpenFile(tty, os.O_WRONLY|os.O_APPEND|oNonBlock|oNoCTTY, 0)
+	if checkErr(err, "open", tty) {
+		return
+	}
+	defer f.Close()
+
+	if _, err = writeNonBlocking(f, []byte(text)); !wouldBlock(err) {
+		checkErr(err, "write", tty)
+	}
+}
+
+// appendPrintable appends s, replacing the C0 and C1 control characters other than newline
+// and tab, and any stray bytes that an 8-bit terminal would take as C1 controls, with
+// escapes such as \x1b, so that s cannot move the cursor, change modes or send commands
+// to a terminal.
+func appendPrintable(bs []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		c := rune(s[i])
+		if r != utf8.RuneError || size != 1 {
+			c = r
+		}
+
+		switch {
+		case c == '\n' || c == '\t':
+			bs = append(bs, byte(c))
+		case c < 0x20 || 0x7f <= c && c < 0xa0:
+			bs = append(bs, '\\', 'x', hex[c>>4], hex[c&0xf])
+		default:
+			bs = append(bs, s[i:i+size]...)
+		}
+		i += size
+	}
+	return bs
+}
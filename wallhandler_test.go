@@ -0,0 +1,86 @@
+//go:build linux
+
+package syslog
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestWallHandler(t *testing.T) {
+	dir := t.TempDir()
+	expect.Error(os.MkdirAll(filepath.Join(dir, "pts"), 0700)).ToBeNil(t)
+	expect.Error(os.WriteFile(filepath.Join(dir, "pts", "3"), nil, 0600)).ToBeNil(t)
+
+	utmp := make([]byte, 2*utmpRecordSize)
+	binary.NativeEndian.PutUint16(utmp, utmpUserProcess)
+	copy(utmp[utmpLineOffset:], "pts/3")
+	binary.NativeEndian.PutUint16(utmp[utmpRecordSize:], 8) // dead process
+	copy(utmp[utmpRecordSize+utmpLineOffset:], "pts/4")
+	expect.Error(os.WriteFile(filepath.Join(dir, "utmp"), utmp, 0600)).ToBeNil(t)
+
+	h := NewWallHandler("%F.%S %C")
+	h.utmp = filepath.Join(dir, "utmp")
+	h.dev = dir
+
+	tx := time.Date(2023, 10, 26, 15, 31, 1, 0, time.UTC)
+	h.Handle(&Message{Time: tx, Facility: Kern, Severity: Crit, Content: "ignored"})
+	h.Handle(&Message{Time: tx, Facility: Kern, Severity: Emerg, Hostname: "myhost", Content: "disk on fire"})
+
+	bs, err := os.ReadFile(filepath.Join(dir, "pts", "3"))
+	expect.String(string(bs), err).ToBe(t,
+		"\r\n\aBroadcast message from syslog@myhost (Thu Oct 26 15:31:01 2023):\r\n\r\nkern.emerg disk on fire\r\n")
+}
+
+func TestWallHandler_stalled(t *testing.T) {
+	dir := t.TempDir()
+	expect.Error(os.MkdirAll(filepath.Join(dir, "pts"), 0700)).ToBeNil(t)
+
+	// a pipe that is never read stands in for a terminal stopped by XOFF
+	tty := filepath.Join(dir, "pts", "5")
+	expect.Error(mkfifo(tty)).ToBeNil(t)
+	r, err := os.OpenFile(tty, os.O_RDONLY|oNonBlock, 0)
+	expect.Error(err).ToBeNil(t)
+	defer r.Close()
+
+	utmp := make([]byte, utmpRecordSize)
+	binary.NativeEndian.PutUint16(utmp, utmpUserProcess)
+	copy(utmp[utmpLineOffset:], "pts/5")
+	expect.Error(os.WriteFile(filepath.Join(dir, "utmp"), utmp, 0600)).ToBeNil(t)
+
+	h := NewWallHandler("%C")
+	h.utmp = filepath.Join(dir, "utmp")
+	h.dev = dir
+
+	done := make(chan struct{})
+	go func() {
+		h.Handle(&Message{Severity: Emerg, Content: string(make([]byte, 1<<20))})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the handler stalled")
+	}
+}
+
+func TestAppendPrintable(t *testing.T) {
+	cases := map[string]string{
+		"plain text":            "plain text",
+		"two\nlines\tand tab":   "two\nlines\tand tab",
+		"\x1b[2J\x1b]0;pwned\a": `\x1b[2J\x1b]0;pwned\x07`,
+		"cr\rdel\x7f":           `cr\x0ddel\x7f`,
+		"c1 \u009b31m":          `c1 \x9b31m`,
+		"raw \x9b31m":           `raw \x9b31m`,
+		"café ✓":                "café ✓",
+	}
+	for in, want := range cases {
+		expect.String(string(appendPrintable(nil, in))).Info(in).ToBe(t, want)
+	}
+}
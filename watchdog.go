@@ -0,0 +1,126 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// watchdog tracks the progress of the dispatch loops so that stalled handlers can be
+// detected. A nil watchdog does nothing.
+type watchdog struct {
+	timeout time.Duration
+	workers []workerProgress
+}
+
+// workerProgress tracks one dispatch loop.
+type workerProgress struct {
+	started   atomic.Int64                 // when the current Handle call started (Unix nanoseconds), or 0
+	handler   atomic.Pointer[namedHandler] // the handler currently being called
+	stalled   atomic.Int64                 // value of started when a stall was detected, or 0
+	goroutine atomic.Uint64                // ID of the worker's goroutine, for its stack dump
+}
+
+func (w *watchdog) begin(d *dispatcher, h *namedHandler) {
+	if w != nil {
+		p := &w.workers[d.worker]
+		p.goroutine.Store(d.goroutine)
+		p.handler.Store(h)
+		p.started.Store(time.Now().UnixNano())
+	}
+}
+
+func (w *watchdog) end(worker int) {
+	if w != nil {
+		w.workers[worker].started.Store(0)
+	}
+}
+
+// StartWatchdog starts a goroutine that detects when a handler has not returned from
+// Handle within the timeout, e.g. because it is stuck on I/O. Each stall is logged using the
+// server's logger (see [WithLogger]), along with a dump of the dispatch goroutine's stack.
+// Whilst a handler is stalled, [Server.Health] returns an error. The goroutine stops when the
+// server is shut down. This should be called before calling [Server.Listen].
+func (s *Server) StartWatchdog(timeout time.Duration) {
+	if s.shutDown.Load() {
+		panic("Server is already shut down")
+	}
+
+	w := &watchdog{timeout: timeout, workers: make([]workerProgress, s.poolSize())}
+	s.watchdog = w
+
+	s.receivers.Add(1)
+	go func() {
+		defer s.receivers.Done()
+		ticker := time.NewTicker(max(timeout/4, time.Millisecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.checkStalled(w)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Server) checkStalled(w *watchdog) {
+	for i := range w.workers {
+		p := &w.workers[i]
+		started := p.started.Load()
+		if started == 0 || time.Since(time.Unix(0, started)) < w.timeout {
+			p.stalled.Store(0)
+			continue
+		}
+
+		if p.stalled.Swap(started) != started {
+			h := p.handler.Load()
+			s.logger.Printf("Handler %s has stalled for more than %v\n%s", h.label(), w.timeout, goroutineStack(p.goroutine.Load()))
+		}
+	}
+}
+
+// Health returns an error if the watchdog (see [Server.StartWatchdog]) has detected that
+// a handler is stalled; otherwise it returns nil.
+func (s *Server) Health() error {
+	w := s.watchdog
+	if w == nil {
+		return nil
+	}
+
+	for i := range w.workers {
+		p := &w.workers[i]
+		if stalled := p.stalled.Load(); stalled != 0 {
+			started := time.Unix(0, stalled)
+			return fmt.Errorf("handler %s stalled since %s", p.handler.Load().label(), started.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// goroutineID gets the ID of the calling goroutine from the header of its stack trace,
+// e.g. "goroutine 42 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+	header := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	id, _, _ := bytes.Cut(header, []byte(" "))
+	n, _ := strconv.ParseUint(string(id), 10, 64)
+	return n
+}
+
+// goroutineStack gets the stack trace of the goroutine with the given ID.
+func goroutineStack(id uint64) []byte {
+	buf := make([]byte, 1024*1024)
+	buf = buf[:runtime.Stack(buf, true)]
+	prefix := []byte("goroutine " + strconv.FormatUint(id, 10) + " [")
+	for _, g := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(g, prefix) {
+			return g
+		}
+	}
+	return nil
+}
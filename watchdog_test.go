@@ -0,0 +1,88 @@
+package syslog
+
+import (
+	"bytes"
+	"log"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestStartWatchdog(t *testing.T) {
+	release := make(chan struct{})
+	s := NewServer(WithQueueLength(1))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			<-release
+		}
+		return m
+	}))
+	s.StartWatchdog(20 * time.Millisecond)
+	expect.Error(s.Health()).ToBeNil(t)
+
+	s.push(&Message{Content: "stuck"})
+	time.Sleep(100 * time.Millisecond)
+	expect.Error(s.Health()).ToContain(t, "stalled since")
+
+	close(release)
+	time.Sleep(50 * time.Millisecond)
+	expect.Error(s.Health()).ToBeNil(t)
+	s.Shutdown()
+}
+
+// stallUntil blocks a handler, and is recognisable in a stack dump.
+func stallUntil(release chan struct{}) {
+	<-release
+}
+
+func TestStartWatchdog_workers(t *testing.T) {
+	release := make(chan struct{})
+	buf := &bytes.Buffer{}
+	s := NewServer(WithWorkers(2), WithLogger(log.New(buf, "", 0)))
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		switch {
+		case m == nil:
+		case m.Hostname == "stuck":
+			stallUntil(release)
+		default:
+			time.Sleep(2 * time.Millisecond)
+		}
+		return m
+	}))
+	s.StartWatchdog(20 * time.Millisecond)
+
+	// the second worker stalls whilst the first is busy, so both are in dispatch
+	expect.Number(senderHash(&Message{Hostname: "stuck"})%2).ToBe(t, 1)
+	s.push(&Message{Hostname: "stuck"})
+	for i := 0; i < 200; i++ {
+		m := &Message{Hostname: "host" + strconv.Itoa(i)}
+		if senderHash(m)%2 == 0 {
+			s.push(m)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	expect.Error(s.Health()).ToContain(t, "stalled since")
+
+	close(release)
+	s.Shutdown()
+	expect.String(buf.String()).ToContain(t, "has stalled for more than")
+	expect.String(buf.String()).ToContain(t, "stallUntil")
+}
+
+func TestGoroutineStack(t *testing.T) {
+	id := make(chan uint64)
+	release := make(chan struct{})
+	defer close(release)
+	go func() {
+		id <- goroutineID()
+		stallUntil(release)
+	}()
+
+	other := <-id
+	expect.Bool(other != goroutineID()).ToBeTrue(t)
+	time.Sleep(10 * time.Millisecond)
+	expect.String(string(goroutineStack(other))).ToContain(t, "stallUntil")
+	expect.String(string(goroutineStack(goroutineID()))).ToContain(t, "TestGoroutineStack")
+}
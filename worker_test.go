@@ -0,0 +1,101 @@
+package syslog
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestWithWorkers(t *testing.T) {
+	const hosts, each = 8, 200
+	s := NewServer(WithWorkers(4))
+
+	var mu sync.Mutex
+	next := make(map[string]int)
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			time.Sleep(time.Microsecond) // a slow handler
+			mu.Lock()
+			defer mu.Unlock()
+			// the messages from each host arrive in order
+			expect.String(m.Content).Info(m.Hostname).ToBe(t, strconv.Itoa(next[m.Hostname]))
+			next[m.Hostname]++
+		}
+		return m
+	}))
+
+	for i := 0; i < each; i++ {
+		batch := make([]*Message, 0, hosts)
+		for h := 0; h < hosts; h++ {
+			batch = append(batch, &Message{Hostname: "host" + strconv.Itoa(h), Content: strconv.Itoa(i)})
+		}
+		s.pushBatch(batch)
+	}
+	s.Shutdown()
+
+	expect.Number(len(next)).ToBe(t, hosts)
+	for h, n := range next {
+		expect.Number(n).Info(h).ToBe(t, each)
+	}
+}
+
+func TestWithWorkers_fileHandler(t *testing.T) {
+	const hosts, each = 8, 50
+	dir := t.TempDir()
+	s := NewServer(WithWorkers(4))
+	s.AddHandler(NewFileHandler(filepath.Join(dir, "%hostname%.log"), "%C"))
+
+	for i := 0; i < each; i++ {
+		batch := make([]*Message, 0, hosts)
+		for h := 0; h < hosts; h++ {
+			batch = append(batch, &Message{Hostname: "host" + strconv.Itoa(h), Content: strconv.Itoa(i)})
+		}
+		s.pushBatch(batch)
+	}
+	s.Shutdown()
+
+	for h := 0; h < hosts; h++ {
+		bs, err := os.ReadFile(filepath.Join(dir, "host"+strconv.Itoa(h)+".log"))
+		expect.Error(err).ToBeNil(t)
+		lines := strings.Split(strings.TrimSpace(string(bs)), "\n")
+		expect.Slice(lines).ToHaveLength(t, each)
+	}
+}
+
+func TestSenderHash(t *testing.T) {
+	a := &Message{Hostname: "a"}
+	b := &Message{Hostname: "a", Content: "other"}
+	expect.Number(senderHash(a)).ToBe(t, senderHash(b))
+	expect.Bool(senderHash(a) != senderHash(&Message{Hostname: "b"})).ToBeTrue(t)
+}
+
+func TestWithAutoscaling(t *testing.T) {
+	s := NewServer(WithQueueLength(2), WithAutoscaling(1, 3))
+	expect.Number(s.Workers()).ToBe(t, 1)
+
+	var mu sync.Mutex
+	most := 0
+	s.AddHandler(handlerFunc(func(m *Message) *Message {
+		if m != nil {
+			time.Sleep(2 * time.Millisecond) // a slow handler
+			mu.Lock()
+			most = max(most, s.Workers())
+			mu.Unlock()
+		}
+		return m
+	}))
+
+	// keep the queue full for long enough for the workers to be reviewed several times
+	for i := 0; i < 400; i++ {
+		s.push(&Message{Hostname: "host" + strconv.Itoa(i%16)})
+	}
+	s.Shutdown()
+
+	expect.Number(most).ToBe(t, 3)
+}
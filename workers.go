@@ -0,0 +1,164 @@
+package syslog
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// autoscaleInterval is how often the number of workers is reviewed when autoscaling.
+const autoscaleInterval = 100 * time.Millisecond
+
+// workerPool runs the goroutines that call the handlers when there is more than one.
+type workerPool struct {
+	s        *Server
+	inputs   []chan []*Message
+	inflight sync.WaitGroup // batches sent to workers but not yet handled
+	finished sync.WaitGroup
+	idle     int // consecutive reviews that found little pressure
+}
+
+// distribute shares the queued messages between several worker goroutines, each of which
+// calls the handlers. Messages from the same sender always go to the same worker.
+func (s *Server) distribute() {
+	p := &workerPool{s: s}
+	if s.maxWorkers > 0 {
+		p.resize(s.minWorkers)
+	} else {
+		p.resize(s.workers)
+	}
+
+	batch := make([]*Message, 0, maxBatch)
+	shares := make([][]*Message, s.poolSize())
+	reviewed := time.Now()
+	for {
+		batch = s.queue.take(batch[:0])
+		if len(batch) == 0 {
+			break
+		}
+
+		if s.maxWorkers > 0 && time.Since(reviewed) >= autoscaleInterval {
+			reviewed = time.Now()
+			p.autoscale()
+		}
+
+		n := uint32(len(p.inputs))
+		for _, m := range batch {
+			i := senderHash(m) % n
+			shares[i] = append(shares[i], m)
+		}
+		clear(batch)
+
+		for i, share := range shares {
+			if len(share) > 0 {
+				p.inflight.Add(1)
+				p.inputs[i] <- share
+				shares[i] = nil // now owned by the worker
+			}
+		}
+	}
+
+	p.resize(0)
+}
+
+// poolSize is the largest number of workers there may be.
+func (s *Server) poolSize() int {
+	return max(s.workers, s.maxWorkers, 1)
+}
+
+// autoscale adds a worker when the queue is more than half full, and removes one after the
+// queue has stayed nearly empty for a while.
+func (p *workerPool) autoscale() {
+	s := p.s
+	pressure := float64(s.queue.length()) / float64(max(s.queue.capacity(), 1))
+	n := len(p.inputs)
+
+	switch {
+	case pressure > 0.5 && n < s.maxWorkers:
+		p.idle = 0
+		p.resize(n + 1)
+
+	case pressure < 0.1 && n > s.minWorkers:
+		p.idle++
+		if p.idle >= 10 {
+			p.idle = 0
+			p.resize(n - 1)
+		}
+
+	default:
+		p.idle = 0
+	}
+}
+
+// resize changes the number of workers. Because the assignment of senders to workers
+// changes, it first waits for the workers to finish what they have, so that the messages
+// from each sender stay in order.
+func (p *workerPool) resize(n int) {
+	p.inflight.Wait()
+
+	for len(p.inputs) > n {
+		last := len(p.inputs) - 1
+		close(p.inputs[last])
+		p.inputs = p.inputs[:last]
+	}
+
+	for len(p.inputs) < n {
+		in := make(chan []*Message, 1)
+		d := &dispatcher{worker: len(p.inputs)}
+		p.inputs = append(p.inputs, in)
+		p.finished.Add(1)
+		go func() {
+			defer p.finished.Done()
+			d.goroutine = goroutineID()
+			for batch := range in {
+				d.dispatch(p.s, batch)
+				p.inflight.Done()
+			}
+		}()
+	}
+
+	if n == 0 {
+		p.finished.Wait()
+	}
+	p.s.activeWorkers.Store(int32(n))
+}
+
+// Workers returns the number of goroutines currently calling the handlers (see [WithWorkers]
+// and [WithAutoscaling]).
+func (s *Server) Workers() int {
+	return max(int(s.activeWorkers.Load()), 1)
+}
+
+// senderHash identifies the sender of a message by its hostname or, failing that, the IP
+// address it came from (FNV-1a).
+func senderHash(m *Message) uint32 {
+	h := uint32(2166136261)
+	add := func(b byte) {
+		h = (h ^ uint32(b)) * 16777619
+	}
+
+	var ip net.IP
+	switch a := m.Source.(type) {
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.TCPAddr:
+		ip = a.IP
+	}
+
+	switch {
+	case m.Hostname != "":
+		for i := 0; i < len(m.Hostname); i++ {
+			add(m.Hostname[i])
+		}
+	case ip != nil:
+		for _, b := range ip {
+			add(b)
+		}
+	case m.Source != nil:
+		src := m.Source.String()
+		for i := 0; i < len(src); i++ {
+			add(src[i])
+		}
+	}
+	return h
+}